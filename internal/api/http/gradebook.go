@@ -0,0 +1,215 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"learn-go/internal/domain"
+	"learn-go/internal/service"
+	"learn-go/pkg/response"
+	"learn-go/pkg/spreadsheet"
+)
+
+func (h *Handler) GetGradebook(c *gin.Context) {
+	accountID := getAccountID(c)
+	if accountID == "" {
+		response.Error(c, http.StatusUnauthorized, "missing account context", nil)
+		return
+	}
+
+	classID := strings.TrimSpace(c.Param("classID"))
+	if classID == "" {
+		response.Error(c, http.StatusBadRequest, "missing class id", nil)
+		return
+	}
+	courseID := strings.TrimSpace(c.Query("course_id"))
+
+	format := strings.ToLower(strings.TrimSpace(c.DefaultQuery("format", "json")))
+	if format != "json" && format != "csv" && format != "xlsx" {
+		response.Error(c, http.StatusBadRequest, "format must be json, csv or xlsx", nil)
+		return
+	}
+
+	book, err := h.gradebook.GetGradebook(c.Request.Context(), accountID, getRole(c), classID, courseID)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrGradebookForbidden):
+			response.Error(c, http.StatusForbidden, "not allowed to view gradebook", nil)
+		default:
+			response.Error(c, http.StatusInternalServerError, "unable to build gradebook", err.Error())
+		}
+		return
+	}
+
+	filename := "gradebook-" + classID
+	switch format {
+	case "csv":
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.csv"`, filename))
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Status(http.StatusOK)
+		_ = spreadsheet.WriteCSV(c.Writer, service.GradebookTable(book))
+		return
+	case "xlsx":
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.xlsx"`, filename))
+		c.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		c.Status(http.StatusOK)
+		_ = spreadsheet.WriteXLSX(c.Writer, "Gradebook", service.GradebookTable(book))
+		return
+	}
+
+	response.Success(c, http.StatusOK, gin.H{"gradebook": gradebookPayload(book)})
+}
+
+func (h *Handler) ListGradeCategories(c *gin.Context) {
+	accountID := getAccountID(c)
+	if accountID == "" {
+		response.Error(c, http.StatusUnauthorized, "missing account context", nil)
+		return
+	}
+
+	classID := strings.TrimSpace(c.Param("classID"))
+	if classID == "" {
+		response.Error(c, http.StatusBadRequest, "missing class id", nil)
+		return
+	}
+
+	categories, err := h.gradebook.ListCategories(c.Request.Context(), accountID, getRole(c), classID, strings.TrimSpace(c.Query("course_id")))
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrGradebookForbidden):
+			response.Error(c, http.StatusForbidden, "not allowed to view gradebook", nil)
+		default:
+			response.Error(c, http.StatusInternalServerError, "unable to list grade categories", err.Error())
+		}
+		return
+	}
+
+	response.Success(c, http.StatusOK, gin.H{"categories": gradeCategoriesPayload(categories)})
+}
+
+type replaceGradeCategoriesRequest struct {
+	CourseID   string                 `json:"course_id"`
+	Categories []gradeCategoryRequest `json:"categories" validate:"required,min=1,dive"`
+}
+
+type gradeCategoryRequest struct {
+	Name           string  `json:"name" validate:"required"`
+	AssignmentType string  `json:"assignment_type" validate:"required,oneof=homework exam"`
+	Weight         float64 `json:"weight" validate:"gt=0"`
+	DropLowest     int     `json:"drop_lowest" validate:"gte=0"`
+}
+
+func (h *Handler) ReplaceGradeCategories(c *gin.Context) {
+	accountID := getAccountID(c)
+	if accountID == "" {
+		response.Error(c, http.StatusUnauthorized, "missing account context", nil)
+		return
+	}
+
+	classID := strings.TrimSpace(c.Param("classID"))
+	if classID == "" {
+		response.Error(c, http.StatusBadRequest, "missing class id", nil)
+		return
+	}
+
+	var req replaceGradeCategoriesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "invalid request body", err.Error())
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		response.Error(c, http.StatusBadRequest, "validation error", err.Error())
+		return
+	}
+
+	inputs := make([]service.GradeCategoryInput, 0, len(req.Categories))
+	for _, category := range req.Categories {
+		inputs = append(inputs, service.GradeCategoryInput{
+			Name:           category.Name,
+			AssignmentType: service.ToAssignmentType(category.AssignmentType),
+			Weight:         category.Weight,
+			DropLowest:     category.DropLowest,
+		})
+	}
+
+	categories, err := h.gradebook.ReplaceCategories(c.Request.Context(), accountID, getRole(c), classID, strings.TrimSpace(req.CourseID), inputs)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrGradebookForbidden):
+			response.Error(c, http.StatusForbidden, "not allowed to configure gradebook", nil)
+		case errors.Is(err, service.ErrInvalidGradeCategory):
+			response.Error(c, http.StatusBadRequest, "invalid grade category", err.Error())
+		default:
+			response.Error(c, http.StatusInternalServerError, "unable to save grade categories", err.Error())
+		}
+		return
+	}
+
+	response.Success(c, http.StatusOK, gin.H{"categories": gradeCategoriesPayload(categories)})
+}
+
+func gradebookPayload(book *service.Gradebook) gin.H {
+	assignments := make([]gin.H, 0, len(book.Assignments))
+	for _, assignment := range book.Assignments {
+		assignments = append(assignments, gin.H{
+			"id":        assignment.ID,
+			"course_id": assignment.CourseID,
+			"type":      assignment.Type,
+			"title":     assignment.Title,
+			"due_at":    assignment.DueAt,
+			"max_score": assignment.MaxScore,
+		})
+	}
+
+	rows := make([]gin.H, 0, len(book.Rows))
+	for _, row := range book.Rows {
+		cells := make([]gin.H, 0, len(row.Cells))
+		for _, cell := range row.Cells {
+			cells = append(cells, gin.H{
+				"assignment_id": cell.AssignmentID,
+				"status":        cell.Status,
+				"score":         cell.Score,
+				"percent":       cell.Percent,
+				"missing":       cell.Missing,
+				"late":          cell.Late,
+				"dropped":       cell.Dropped,
+			})
+		}
+		rows = append(rows, gin.H{
+			"student_id":        row.Student.ID,
+			"account_id":        row.Student.AccountID,
+			"number":            row.Student.Number,
+			"name":              row.Name,
+			"cells":             cells,
+			"category_averages": row.CategoryAverages,
+			"term_average":      row.TermAverage,
+			"missing_count":     row.MissingCount,
+		})
+	}
+
+	return gin.H{
+		"class_id":    book.ClassID,
+		"course_id":   book.CourseID,
+		"assignments": assignments,
+		"categories":  gradeCategoriesPayload(book.Categories),
+		"rows":        rows,
+	}
+}
+
+func gradeCategoriesPayload(categories []domain.GradeCategory) []gin.H {
+	payload := make([]gin.H, 0, len(categories))
+	for _, category := range categories {
+		payload = append(payload, gin.H{
+			"id":              category.ID,
+			"name":            category.Name,
+			"assignment_type": category.AssignmentType,
+			"weight":          category.Weight,
+			"drop_lowest":     category.DropLowest,
+		})
+	}
+	return payload
+}
@@ -41,12 +41,20 @@ type Handler struct {
 	notes         *service.NoteService
 	noteComments  *service.NoteCommentService
 	conversations *service.ConversationService
+	classChats    *service.ClassChatService
+	gradebook     *service.GradebookService
+	similarity    *service.SimilarityService
+	presence      *service.PresenceService
+	policies      *service.MessagingPolicyService
+	moderation    *service.ModerationService
+	notifications *service.NotificationService
 	wsHub         *ws.Hub
+	typing        *typingLimiter
 	validate      *validator.Validate
 }
 
 // NewHandler constructs a Handler instance.
-func NewHandler(auth *service.AuthService, admin *service.AdminService, assignments *service.AssignmentService, conversations *service.ConversationService, notes *service.NoteService, noteComments *service.NoteCommentService, wsHub *ws.Hub) *Handler {
+func NewHandler(auth *service.AuthService, admin *service.AdminService, assignments *service.AssignmentService, conversations *service.ConversationService, classChats *service.ClassChatService, notes *service.NoteService, noteComments *service.NoteCommentService, gradebook *service.GradebookService, similarity *service.SimilarityService, presence *service.PresenceService, policies *service.MessagingPolicyService, moderation *service.ModerationService, notifications *service.NotificationService, wsHub *ws.Hub) *Handler {
 	return &Handler{
 		auth:          auth,
 		admin:         admin,
@@ -54,35 +62,89 @@ func NewHandler(auth *service.AuthService, admin *service.AdminService, assignme
 		notes:         notes,
 		noteComments:  noteComments,
 		conversations: conversations,
+		classChats:    classChats,
+		gradebook:     gradebook,
+		similarity:    similarity,
+		presence:      presence,
+		policies:      policies,
+		moderation:    moderation,
+		notifications: notifications,
 		wsHub:         wsHub,
+		typing:        newTypingLimiter(),
 		validate:      validator.New(),
 	}
 }
 
-// RegisterRoutes attaches HTTP endpoints to router.
-func (h *Handler) RegisterRoutes(r *gin.Engine, adminGuard gin.HandlerFunc, teacherGuard gin.HandlerFunc, studentGuard gin.HandlerFunc) {
+// RegisterRoutes attaches HTTP endpoints to router. chatGuard admits every
+// role that may chat, parents included.
+func (h *Handler) RegisterRoutes(r *gin.Engine, adminGuard gin.HandlerFunc, teacherGuard gin.HandlerFunc, studentGuard gin.HandlerFunc, chatGuard gin.HandlerFunc) {
 	api := r.Group("/api/v1")
 	{
 		api.POST("/auth/login", h.Login)
+		api.GET("/ws", chatGuard, h.AccountStream)
+		api.GET("/presence", chatGuard, h.GetPresence)
 
 		admin := api.Group("/admin", adminGuard)
 		admin.POST("/teachers", h.CreateTeacher)
 		admin.POST("/students", h.CreateStudent)
+		admin.POST("/parents", h.CreateParent)
+		admin.PUT("/parents/:id/students", h.BindParentStudents)
+		admin.GET("/messaging-policy", h.GetMessagingPolicy)
+		admin.PUT("/messaging-policy", h.UpdateMessagingPolicy)
+		admin.GET("/moderation/rules", h.ListModerationRules)
+		admin.POST("/moderation/rules", h.CreateModerationRule)
+		admin.PUT("/moderation/rules/:id", h.UpdateModerationRule)
+		admin.DELETE("/moderation/rules/:id", h.DeleteModerationRule)
+		admin.GET("/moderation/cases", h.ListModerationCases)
+		admin.GET("/moderation/cases/:id", h.GetModerationCase)
+		admin.POST("/moderation/cases/:id/resolve", h.ResolveModerationCase)
+		admin.POST("/moderation/cases/:id/remove", h.RemoveModeratedContent)
 		admin.POST("/departments", h.CreateDepartment)
 		admin.POST("/classes", h.CreateClass)
 		admin.GET("/departments", h.ListDepartments)
+		admin.GET("/ws/stats", h.WebsocketStats)
 		admin.GET("/departments/:id/classes", h.ListClasses)
+		admin.PATCH("/students/:id/class", h.TransferStudent)
+		admin.PUT("/students/:id/teachers", h.BindStudentTeachers)
+		admin.PUT("/classes/:id/homeroom", h.SetClassHomeroom)
+		admin.POST("/classes/:id/chats", h.EnableClassChat)
+		admin.POST("/classes/:id/chats/sync", h.SyncClassChats)
 
 		assignments := api.Group("/assignments", teacherGuard)
 		assignments.POST("", h.CreateAssignment)
+		assignments.GET("created", h.ListCreatedAssignments)
+		assignments.PATCH(":id", h.UpdateAssignment)
+		assignments.DELETE(":id", h.DeleteAssignment)
+		assignments.POST(":id/publish", h.PublishAssignment)
+		assignments.POST(":id/close", h.CloseAssignment)
+		assignments.POST(":id/archive", h.ArchiveAssignment)
+		assignments.POST(":id/duplicate", h.DuplicateAssignment)
+		assignments.GET(":id/revisions", h.ListAssignmentRevisions)
 		assignments.GET(":id/submissions", h.ListAssignmentSubmissions)
+		assignments.GET(":id/stats", h.GetAssignmentStats)
+		assignments.GET(":id/similarity", h.GetSimilarityReport)
+		assignments.POST(":id/similarity/rescan", h.RescanSimilarity)
+		assignments.GET(":id/similarity/:matchID", h.GetSimilarityMatch)
 		assignments.GET(":id/submissions/:submissionID", h.GetAssignmentSubmission)
 		assignments.PATCH(":id/submissions/:submissionID/grade", h.GradeSubmission)
+		assignments.GET(":id/submissions/:submissionID/history", h.ListGradeHistory)
+		assignments.GET(":id/regrades", h.ListAssignmentRegrades)
+		assignments.POST(":id/regrades/:requestID/resolve", h.ResolveRegrade)
 
 		submissions := api.Group("/assignments", studentGuard)
+		submissions.GET("mine", h.ListMyAssignments)
 		submissions.POST(":id/submissions", h.SubmitAssignment)
 		submissions.GET(":id", h.GetAssignment)
 		submissions.GET(":id/submissions/me", h.GetMySubmission)
+		submissions.POST(":id/attachments", h.CreateAttachmentUpload)
+		submissions.GET(":id/attachments/:attachmentID", h.GetAttachmentDownload)
+		submissions.POST(":id/submissions/me/regrades", h.RequestRegrade)
+		submissions.GET(":id/submissions/me/regrades", h.ListMyRegrades)
+
+		gradebook := api.Group("/gradebook", teacherGuard)
+		gradebook.GET("/classes/:classID", h.GetGradebook)
+		gradebook.GET("/classes/:classID/categories", h.ListGradeCategories)
+		gradebook.PUT("/classes/:classID/categories", h.ReplaceGradeCategories)
 
 		notes := api.Group("/notes", studentGuard)
 		notes.POST("", h.CreateNote)
@@ -91,16 +153,46 @@ func (h *Handler) RegisterRoutes(r *gin.Engine, adminGuard gin.HandlerFunc, teac
 		notes.PATCH(":id", h.UpdateNote)
 		notes.DELETE(":id", h.DeleteNote)
 		notes.POST(":id/restore", h.RestoreNote)
+		notes.POST(":id/report", h.ReportNote)
 		notes.POST(":id/comments", h.CreateNoteComment)
 		notes.GET(":id/comments", h.ListNoteComments)
+		notes.POST(":id/comments/:commentID/report", h.ReportNoteComment)
 
-		conversations := api.Group("/conversations", studentGuard)
+		conversations := api.Group("/conversations", chatGuard)
 		conversations.POST("", h.CreateConversation)
 		conversations.GET("", h.ListConversations)
+		conversations.GET("mentions", h.ListMentions)
+		conversations.GET("search", h.SearchMessages)
+		conversations.GET(":id", h.GetConversation)
+		conversations.PATCH(":id", h.RenameConversation)
+		conversations.PATCH(":id/announcement", h.SetConversationAnnouncement)
+		conversations.PATCH(":id/settings", h.UpdateConversationSettings)
+		conversations.POST(":id/members", h.AddConversationMembers)
+		conversations.PATCH(":id/members/:accountID", h.UpdateConversationMember)
+		conversations.DELETE(":id/members/:accountID", h.RemoveConversationMember)
+		conversations.POST(":id/leave", h.LeaveConversation)
 		conversations.GET(":id/messages", h.ListMessages)
 		conversations.POST(":id/messages", h.SendMessage)
+		conversations.PATCH(":id/messages/:messageID", h.EditMessage)
+		conversations.DELETE(":id/messages/:messageID", h.DeleteMessage)
+		conversations.POST(":id/messages/:messageID/recall", h.RecallMessage)
+		conversations.GET(":id/messages/:messageID/edits", h.ListMessageEdits)
+		conversations.GET(":id/messages/:messageID/readers", h.ListMessageReaders)
+		conversations.POST(":id/messages/:messageID/reactions", h.ToggleReaction)
+		conversations.POST(":id/messages/:messageID/report", h.ReportMessage)
+		conversations.POST(":id/media", h.CreateMediaUpload)
+		conversations.GET(":id/media/:mediaID", h.GetMediaDownload)
 		conversations.POST(":id/read", h.MarkConversationRead)
 		conversations.GET(":id/stream", h.ConversationStream)
+
+		notifications := api.Group("/notifications", chatGuard)
+		notifications.GET("", h.ListNotifications)
+		notifications.GET("unread-count", h.GetUnreadNotificationCount)
+		notifications.POST("read-all", h.MarkAllNotificationsRead)
+		notifications.GET("preferences", h.GetNotificationPreferences)
+		notifications.PUT("preferences", h.UpdateNotificationPreferences)
+		notifications.POST(":id/read", h.MarkNotificationRead)
+		notifications.POST(":id/unread", h.MarkNotificationUnread)
 	}
 }
 
@@ -332,6 +424,138 @@ func (h *Handler) ListClasses(c *gin.Context) {
 	response.Success(c, http.StatusOK, gin.H{"classes": items})
 }
 
+type transferStudentRequest struct {
+	ClassID string `json:"class_id" validate:"required"`
+}
+
+func (h *Handler) TransferStudent(c *gin.Context) {
+	var req transferStudentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "invalid request body", err.Error())
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		response.Error(c, http.StatusBadRequest, "validation error", err.Error())
+		return
+	}
+
+	student, err := h.admin.TransferStudent(c.Request.Context(), c.Param("id"), req.ClassID)
+	if err != nil {
+		respondClassError(c, err, "unable to transfer student")
+		return
+	}
+
+	response.Success(c, http.StatusOK, gin.H{"student_id": student.ID, "class_id": student.ClassID})
+}
+
+type bindStudentTeachersRequest struct {
+	TeacherIDs []string `json:"teacher_ids" validate:"required,min=1,dive,required"`
+}
+
+func (h *Handler) BindStudentTeachers(c *gin.Context) {
+	var req bindStudentTeachersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "invalid request body", err.Error())
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		response.Error(c, http.StatusBadRequest, "validation error", err.Error())
+		return
+	}
+
+	if err := h.admin.BindStudentTeachers(c.Request.Context(), c.Param("id"), req.TeacherIDs); err != nil {
+		respondClassError(c, err, "unable to bind teachers")
+		return
+	}
+
+	response.Success(c, http.StatusOK, gin.H{"student_id": c.Param("id"), "teacher_ids": req.TeacherIDs})
+}
+
+type setClassHomeroomRequest struct {
+	TeacherID string `json:"teacher_id"`
+}
+
+func (h *Handler) SetClassHomeroom(c *gin.Context) {
+	var req setClassHomeroomRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "invalid request body", err.Error())
+		return
+	}
+
+	if err := h.admin.SetClassHomeroom(c.Request.Context(), c.Param("id"), strings.TrimSpace(req.TeacherID)); err != nil {
+		respondClassError(c, err, "unable to set homeroom teacher")
+		return
+	}
+
+	response.Success(c, http.StatusOK, gin.H{"class_id": c.Param("id"), "homeroom_id": req.TeacherID})
+}
+
+type enableClassChatRequest struct {
+	CourseID string `json:"course_id"`
+}
+
+// EnableClassChat creates the class chat, or the course chat of the class
+// when course_id is given.
+func (h *Handler) EnableClassChat(c *gin.Context) {
+	var req enableClassChatRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "invalid request body", err.Error())
+		return
+	}
+
+	classID := c.Param("id")
+	if req.CourseID == "" {
+		if _, err := h.classChats.SyncClass(c.Request.Context(), classID); err != nil {
+			respondClassError(c, err, "unable to create class chat")
+			return
+		}
+		response.Success(c, http.StatusOK, gin.H{"class_id": classID})
+		return
+	}
+
+	summary, err := h.classChats.EnableCourseChat(c.Request.Context(), classID, req.CourseID)
+	if err != nil {
+		respondClassError(c, err, "unable to create course chat")
+		return
+	}
+	response.Success(c, http.StatusOK, gin.H{"conversation": conversationPayload(*summary)})
+}
+
+func (h *Handler) SyncClassChats(c *gin.Context) {
+	changes, err := h.classChats.SyncClass(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		respondClassError(c, err, "unable to sync class chats")
+		return
+	}
+
+	items := make([]gin.H, 0, len(changes))
+	for _, change := range changes {
+		items = append(items, gin.H{
+			"conversation_id": change.Summary.Conversation.ID,
+			"member_count":    len(change.Summary.Members),
+			"removed":         change.Removed,
+		})
+	}
+	response.Success(c, http.StatusOK, gin.H{"changed": items})
+}
+
+func respondClassError(c *gin.Context, err error, failure string) {
+	switch {
+	case errors.Is(err, service.ErrClassNotFound):
+		response.Error(c, http.StatusNotFound, "class not found", nil)
+	case errors.Is(err, service.ErrCourseNotFound):
+		response.Error(c, http.StatusNotFound, "course not found", nil)
+	case errors.Is(err, service.ErrStudentNotFound):
+		response.Error(c, http.StatusNotFound, "student not found", nil)
+	case errors.Is(err, service.ErrTeacherNotFound):
+		response.Error(c, http.StatusNotFound, "teacher not found", nil)
+	case errors.Is(err, service.ErrConversationMemberLimit):
+		response.Error(c, http.StatusConflict, "conversation member limit reached", err.Error())
+	default:
+		response.Error(c, http.StatusBadRequest, failure, err.Error())
+	}
+}
+
 type createAssignmentRequest struct {
 	CourseID      string                          `json:"course_id" validate:"required"`
 	TeacherID     string                          `json:"teacher_id" validate:"required"`
@@ -339,11 +563,16 @@ type createAssignmentRequest struct {
 	Type          string                          `json:"type" validate:"required,oneof=homework exam"`
 	Title         string                          `json:"title" validate:"required"`
 	Description   string                          `json:"description"`
+	Status        string                          `json:"status" validate:"omitempty,oneof=draft scheduled published"`
+	PublishAt     *service.TimeISO8601            `json:"publish_at"`
 	StartAt       *service.TimeISO8601            `json:"start_at"`
 	DueAt         *service.TimeISO8601            `json:"due_at"`
 	MaxScore      float64                         `json:"max_score" validate:"gte=0"`
 	AllowResubmit bool                            `json:"allow_resubmit"`
 	Questions     []createAssignmentQuestionInput `json:"questions" validate:"required,min=1,dive"`
+
+	MaxAttachmentBytes     int64    `json:"max_attachment_bytes" validate:"gte=0"`
+	AllowedAttachmentTypes []string `json:"allowed_attachment_types"`
 }
 
 type createAssignmentQuestionInput struct {
@@ -388,128 +617,154 @@ func (h *Handler) CreateAssignment(c *gin.Context) {
 		Type:          service.ToAssignmentType(req.Type),
 		Title:         req.Title,
 		Description:   req.Description,
+		Status:        domain.AssignmentStatus(req.Status),
+		PublishAt:     convertToTime(req.PublishAt),
 		StartAt:       startAt,
 		DueAt:         dueAt,
 		MaxScore:      req.MaxScore,
 		AllowResubmit: req.AllowResubmit,
 		Questions:     questions,
+
+		MaxAttachmentBytes:     req.MaxAttachmentBytes,
+		AllowedAttachmentTypes: req.AllowedAttachmentTypes,
 	})
 	if err != nil {
-		response.Error(c, http.StatusBadRequest, "unable to create assignment", err.Error())
+		switch {
+		case errors.Is(err, service.ErrQuestionScoreMismatch):
+			response.Error(c, http.StatusUnprocessableEntity, "question scores do not sum to max score", err.Error())
+		case errors.Is(err, service.ErrInvalidAssignmentStatus):
+			response.Error(c, http.StatusUnprocessableEntity, "invalid assignment status", err.Error())
+		default:
+			response.Error(c, http.StatusBadRequest, "unable to create assignment", err.Error())
+		}
 		return
 	}
 
-	response.Success(c, http.StatusCreated, gin.H{"assignment_id": assignment.ID})
+	response.Success(c, http.StatusCreated, gin.H{"assignment_id": assignment.ID, "status": assignment.Status})
 }
 
-func (h *Handler) GetAssignment(c *gin.Context) {
-	assignmentID := strings.TrimSpace(c.Param("id"))
-	if assignmentID == "" {
-		response.Error(c, http.StatusBadRequest, "missing assignment id", nil)
+func (h *Handler) ListMyAssignments(c *gin.Context) {
+	accountID := getAccountID(c)
+	if accountID == "" {
+		response.Error(c, http.StatusUnauthorized, "missing account context", nil)
 		return
 	}
 
-	assignment, questions, err := h.assignments.GetAssignment(c.Request.Context(), assignmentID)
+	filter := service.StudentAssignmentFilter{
+		CourseID: strings.TrimSpace(c.Query("course_id")),
+		Type:     domain.AssignmentType(strings.ToLower(strings.TrimSpace(c.Query("type")))),
+		Status:   service.StudentAssignmentStatus(strings.ToLower(strings.TrimSpace(c.Query("status")))),
+		DueDesc:  strings.EqualFold(c.Query("sort"), "due_desc"),
+	}
+
+	inbox, err := h.assignments.ListStudentAssignments(c.Request.Context(), accountID, filter)
 	if err != nil {
 		switch {
-		case errors.Is(err, service.ErrAssignmentNotFound):
-			response.Error(c, http.StatusNotFound, "assignment not found", nil)
+		case errors.Is(err, service.ErrStudentProfileNotFound):
+			response.Error(c, http.StatusNotFound, "student profile not found", nil)
 		default:
-			response.Error(c, http.StatusInternalServerError, "unable to load assignment", err.Error())
+			response.Error(c, http.StatusInternalServerError, "unable to list assignments", err.Error())
 		}
 		return
 	}
 
-	response.Success(c, http.StatusOK, gin.H{"assignment": assignmentPayload(*assignment, questions)})
+	items := make([]gin.H, 0, len(inbox.Items))
+	for _, item := range inbox.Items {
+		payload := assignmentSummaryPayload(item.Assignment)
+		payload["my_status"] = item.Status
+		payload["score"] = item.Score
+		payload["submitted_at"] = item.SubmittedAt
+		payload["overdue"] = item.Overdue
+		items = append(items, payload)
+	}
+
+	counts := gin.H{}
+	for _, status := range []service.StudentAssignmentStatus{
+		service.StudentAssignmentNotStarted,
+		service.StudentAssignmentDraft,
+		service.StudentAssignmentSubmitted,
+		service.StudentAssignmentLate,
+		service.StudentAssignmentGraded,
+	} {
+		counts[string(status)] = inbox.Counts[status]
+	}
+
+	response.Success(c, http.StatusOK, gin.H{
+		"assignments": items,
+		"counts":      counts,
+		"pending":     inbox.Pending,
+	})
 }
 
-func (h *Handler) ListAssignmentSubmissions(c *gin.Context) {
-	assignmentID := strings.TrimSpace(c.Param("id"))
-	if assignmentID == "" {
-		response.Error(c, http.StatusBadRequest, "missing assignment id", nil)
+func (h *Handler) ListCreatedAssignments(c *gin.Context) {
+	accountID := getAccountID(c)
+	if accountID == "" {
+		response.Error(c, http.StatusUnauthorized, "missing account context", nil)
 		return
 	}
 
-	details, err := h.assignments.ListAssignmentSubmissions(c.Request.Context(), assignmentID)
+	items, err := h.assignments.ListTeacherAssignments(c.Request.Context(), accountID, service.TeacherAssignmentFilter{
+		ClassID:  strings.TrimSpace(c.Query("class_id")),
+		CourseID: strings.TrimSpace(c.Query("course_id")),
+		Type:     domain.AssignmentType(strings.ToLower(strings.TrimSpace(c.Query("type")))),
+		Status:   domain.AssignmentStatus(strings.ToLower(strings.TrimSpace(c.Query("status")))),
+	})
 	if err != nil {
-		response.Error(c, http.StatusInternalServerError, "unable to list submissions", err.Error())
+		response.Error(c, http.StatusInternalServerError, "unable to list assignments", err.Error())
 		return
 	}
 
-	payload := make([]gin.H, 0, len(details))
-	for _, detail := range details {
-		payload = append(payload, submissionDetailPayload(detail))
+	payload := make([]gin.H, 0, len(items))
+	var ungradedTotal int64
+	for _, item := range items {
+		entry := assignmentSummaryPayload(item.Assignment)
+		entry["submitted"] = item.Submitted
+		entry["graded"] = item.Graded
+		entry["ungraded"] = item.Ungraded
+		payload = append(payload, entry)
+		ungradedTotal += item.Ungraded
 	}
 
-	response.Success(c, http.StatusOK, gin.H{"submissions": payload})
-}
-
-type submitAssignmentRequest struct {
-	StudentID string                   `json:"student_id" validate:"required"`
-	Status    string                   `json:"status" validate:"required"`
-	Score     *float64                 `json:"score"`
-	Feedback  string                   `json:"feedback"`
-	Answers   []submitAssignmentAnswer `json:"answers" validate:"required,min=1,dive"`
-}
-
-type submitAssignmentAnswer struct {
-	QuestionID string   `json:"question_id" validate:"required"`
-	Answer     string   `json:"answer" validate:"required"`
-	Score      *float64 `json:"score"`
-}
-
-type submissionCommentRequest struct {
-	Content string `json:"content"`
-}
-
-type gradeSubmissionRequest struct {
-	Score      *float64                  `json:"score"`
-	Feedback   string                    `json:"feedback"`
-	ItemScores map[string]*float64       `json:"item_scores"`
-	Comment    *submissionCommentRequest `json:"comment"`
-}
-
-type createNoteRequest struct {
-	Title      string `json:"title" validate:"required"`
-	Content    string `json:"content" validate:"required"`
-	Visibility string `json:"visibility" validate:"required,oneof=private class school"`
-	Status     string `json:"status" validate:"required,oneof=draft published"`
-}
-
-type updateNoteRequest struct {
-	Title      *string `json:"title"`
-	Content    *string `json:"content"`
-	Visibility *string `json:"visibility"`
-	Status     *string `json:"status"`
+	response.Success(c, http.StatusOK, gin.H{"assignments": payload, "ungraded_total": ungradedTotal})
 }
 
-type createNoteCommentRequest struct {
-	Content string `json:"content" validate:"required"`
-}
+type updateAssignmentRequest struct {
+	Title         *string                         `json:"title"`
+	Description   *string                         `json:"description"`
+	StartAt       *service.TimeISO8601            `json:"start_at"`
+	DueAt         *service.TimeISO8601            `json:"due_at"`
+	MaxScore      *float64                        `json:"max_score" validate:"omitempty,gte=0"`
+	AllowResubmit *bool                           `json:"allow_resubmit"`
+	Questions     []createAssignmentQuestionInput `json:"questions" validate:"omitempty,min=1,dive"`
+	Summary       string                          `json:"summary"`
 
-type createConversationRequest struct {
-	ParticipantIDs []string `json:"participant_ids" validate:"required,min=1,dive,required"`
+	MaxAttachmentBytes     *int64   `json:"max_attachment_bytes" validate:"omitempty,gte=0"`
+	AllowedAttachmentTypes []string `json:"allowed_attachment_types"`
 }
 
-type sendMessageRequest struct {
-	Kind     string `json:"kind" validate:"required,oneof=text image video audio file"`
-	Text     string `json:"text"`
-	MediaURI string `json:"media_uri"`
-	Metadata string `json:"metadata"`
+type publishAssignmentRequest struct {
+	PublishAt *service.TimeISO8601 `json:"publish_at"`
 }
 
-type markConversationReadRequest struct {
-	MessageID string `json:"message_id" validate:"required"`
+type duplicateAssignmentRequest struct {
+	ClassID  string `json:"class_id" validate:"required"`
+	CourseID string `json:"course_id"`
 }
 
-func (h *Handler) SubmitAssignment(c *gin.Context) {
-	assignmentID := c.Param("id")
+func (h *Handler) UpdateAssignment(c *gin.Context) {
+	assignmentID := strings.TrimSpace(c.Param("id"))
 	if assignmentID == "" {
 		response.Error(c, http.StatusBadRequest, "missing assignment id", nil)
 		return
 	}
 
-	var req submitAssignmentRequest
+	accountID := getAccountID(c)
+	if accountID == "" {
+		response.Error(c, http.StatusUnauthorized, "missing account context", nil)
+		return
+	}
+
+	var req updateAssignmentRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		response.Error(c, http.StatusBadRequest, "invalid request body", err.Error())
 		return
@@ -519,133 +774,630 @@ func (h *Handler) SubmitAssignment(c *gin.Context) {
 		return
 	}
 
-	answers := make([]service.AnswerInput, 0, len(req.Answers))
-	for _, ans := range req.Answers {
-		answers = append(answers, service.AnswerInput{
-			QuestionID: ans.QuestionID,
-			Answer:     ans.Answer,
-			Score:      ans.Score,
-		})
+	var questions []service.QuestionInput
+	if req.Questions != nil {
+		questions = make([]service.QuestionInput, 0, len(req.Questions))
+		for _, q := range req.Questions {
+			questions = append(questions, service.QuestionInput{
+				Type:       service.ToQuestionType(q.Type),
+				Prompt:     q.Prompt,
+				Options:    q.Options,
+				Answer:     q.Answer,
+				Score:      q.Score,
+				OrderIndex: q.OrderIndex,
+			})
+		}
 	}
 
-	err := h.assignments.Submit(c.Request.Context(), service.SubmitAssignmentInput{
-		AssignmentID: assignmentID,
-		StudentID:    req.StudentID,
-		Answers:      answers,
-		Score:        req.Score,
-		Feedback:     req.Feedback,
-		Status:       req.Status,
+	assignment, assignmentQuestions, err := h.assignments.UpdateAssignment(c.Request.Context(), accountID, getRole(c), service.UpdateAssignmentInput{
+		AssignmentID:  assignmentID,
+		Title:         req.Title,
+		Description:   req.Description,
+		StartAt:       convertToTime(req.StartAt),
+		DueAt:         convertToTime(req.DueAt),
+		MaxScore:      req.MaxScore,
+		AllowResubmit: req.AllowResubmit,
+		Questions:     questions,
+		Summary:       req.Summary,
+
+		MaxAttachmentBytes:     req.MaxAttachmentBytes,
+		AllowedAttachmentTypes: req.AllowedAttachmentTypes,
 	})
 	if err != nil {
-		response.Error(c, http.StatusBadRequest, "unable to submit assignment", err.Error())
+		respondAssignmentLifecycleError(c, err, "unable to update assignment")
 		return
 	}
 
-	response.Success(c, http.StatusCreated, gin.H{"submission": "ok"})
+	response.Success(c, http.StatusOK, gin.H{"assignment": assignmentPayload(*assignment, assignmentQuestions)})
 }
 
-func (h *Handler) GetMySubmission(c *gin.Context) {
+func (h *Handler) PublishAssignment(c *gin.Context) {
 	assignmentID := strings.TrimSpace(c.Param("id"))
 	if assignmentID == "" {
 		response.Error(c, http.StatusBadRequest, "missing assignment id", nil)
 		return
 	}
 
-	studentID := getAccountID(c)
-	if studentID == "" {
+	accountID := getAccountID(c)
+	if accountID == "" {
 		response.Error(c, http.StatusUnauthorized, "missing account context", nil)
 		return
 	}
 
-	detail, comments, err := h.assignments.GetSubmissionForStudent(c.Request.Context(), assignmentID, studentID)
-	if err != nil {
-		switch {
-		case errors.Is(err, service.ErrAssignmentNotFound):
-			response.Error(c, http.StatusNotFound, "assignment not found", nil)
-		case errors.Is(err, service.ErrSubmissionNotFound):
-			response.Error(c, http.StatusNotFound, "submission not found", nil)
-		default:
-			response.Error(c, http.StatusInternalServerError, "unable to load submission", err.Error())
+	var req publishAssignmentRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			response.Error(c, http.StatusBadRequest, "invalid request body", err.Error())
+			return
 		}
-		return
 	}
 
-	payload := gin.H{
-		"submission": submissionDetailPayload(*detail),
-		"comments":   submissionCommentsPayload(comments),
+	assignment, err := h.assignments.PublishAssignment(c.Request.Context(), accountID, getRole(c), assignmentID, convertToTime(req.PublishAt))
+	if err != nil {
+		respondAssignmentLifecycleError(c, err, "unable to publish assignment")
+		return
 	}
-	response.Success(c, http.StatusOK, payload)
+
+	response.Success(c, http.StatusOK, gin.H{"assignment": assignmentPayload(*assignment, nil)})
 }
 
-func (h *Handler) GetAssignmentSubmission(c *gin.Context) {
+func (h *Handler) CloseAssignment(c *gin.Context) {
+	h.transitionAssignment(c, h.assignments.CloseAssignment, "unable to close assignment")
+}
+
+func (h *Handler) ArchiveAssignment(c *gin.Context) {
+	h.transitionAssignment(c, h.assignments.ArchiveAssignment, "unable to archive assignment")
+}
+
+func (h *Handler) transitionAssignment(c *gin.Context, transition func(context.Context, string, domain.Role, string) (*domain.Assignment, error), failure string) {
 	assignmentID := strings.TrimSpace(c.Param("id"))
-	submissionID := strings.TrimSpace(c.Param("submissionID"))
-	if assignmentID == "" || submissionID == "" {
-		response.Error(c, http.StatusBadRequest, "missing assignment or submission id", nil)
+	if assignmentID == "" {
+		response.Error(c, http.StatusBadRequest, "missing assignment id", nil)
 		return
 	}
 
-	teacherID := getAccountID(c)
-	if teacherID == "" {
+	accountID := getAccountID(c)
+	if accountID == "" {
 		response.Error(c, http.StatusUnauthorized, "missing account context", nil)
 		return
 	}
 
-	detail, comments, err := h.assignments.GetSubmissionForTeacher(c.Request.Context(), teacherID, assignmentID, submissionID)
+	assignment, err := transition(c.Request.Context(), accountID, getRole(c), assignmentID)
 	if err != nil {
-		switch {
-		case errors.Is(err, service.ErrAssignmentNotFound):
-			response.Error(c, http.StatusNotFound, "assignment not found", nil)
-		case errors.Is(err, service.ErrSubmissionNotFound):
-			response.Error(c, http.StatusNotFound, "submission not found", nil)
-		case errors.Is(err, service.ErrSubmissionForbidden):
-			response.Error(c, http.StatusForbidden, "submission forbidden", nil)
-		default:
-			response.Error(c, http.StatusInternalServerError, "unable to load submission", err.Error())
-		}
+		respondAssignmentLifecycleError(c, err, failure)
 		return
 	}
 
-	payload := gin.H{
-		"submission": submissionDetailPayload(*detail),
-		"comments":   submissionCommentsPayload(comments),
-	}
-	response.Success(c, http.StatusOK, payload)
+	response.Success(c, http.StatusOK, gin.H{"assignment": assignmentPayload(*assignment, nil)})
 }
 
-func (h *Handler) GradeSubmission(c *gin.Context) {
+func (h *Handler) DuplicateAssignment(c *gin.Context) {
 	assignmentID := strings.TrimSpace(c.Param("id"))
-	submissionID := strings.TrimSpace(c.Param("submissionID"))
-	if assignmentID == "" || submissionID == "" {
-		response.Error(c, http.StatusBadRequest, "missing assignment or submission id", nil)
+	if assignmentID == "" {
+		response.Error(c, http.StatusBadRequest, "missing assignment id", nil)
 		return
 	}
 
-	var req gradeSubmissionRequest
+	accountID := getAccountID(c)
+	if accountID == "" {
+		response.Error(c, http.StatusUnauthorized, "missing account context", nil)
+		return
+	}
+
+	var req duplicateAssignmentRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		response.Error(c, http.StatusBadRequest, "invalid request body", err.Error())
 		return
 	}
-	if req.Comment != nil && strings.TrimSpace(req.Comment.Content) == "" {
-		response.Error(c, http.StatusBadRequest, "comment content cannot be empty", nil)
+	if err := h.validate.Struct(req); err != nil {
+		response.Error(c, http.StatusBadRequest, "validation error", err.Error())
 		return
 	}
 
-	teacherID := getAccountID(c)
-	if teacherID == "" {
+	assignment, err := h.assignments.DuplicateAssignment(c.Request.Context(), accountID, getRole(c), service.DuplicateAssignmentInput{
+		AssignmentID: assignmentID,
+		ClassID:      req.ClassID,
+		CourseID:     req.CourseID,
+	})
+	if err != nil {
+		respondAssignmentLifecycleError(c, err, "unable to duplicate assignment")
+		return
+	}
+
+	response.Success(c, http.StatusCreated, gin.H{"assignment_id": assignment.ID, "status": assignment.Status})
+}
+
+func (h *Handler) DeleteAssignment(c *gin.Context) {
+	assignmentID := strings.TrimSpace(c.Param("id"))
+	if assignmentID == "" {
+		response.Error(c, http.StatusBadRequest, "missing assignment id", nil)
+		return
+	}
+
+	accountID := getAccountID(c)
+	if accountID == "" {
+		response.Error(c, http.StatusUnauthorized, "missing account context", nil)
+		return
+	}
+
+	if err := h.assignments.DeleteAssignment(c.Request.Context(), accountID, getRole(c), assignmentID); err != nil {
+		respondAssignmentLifecycleError(c, err, "unable to delete assignment")
+		return
+	}
+
+	response.Success(c, http.StatusOK, gin.H{"deleted": true})
+}
+
+func (h *Handler) ListAssignmentRevisions(c *gin.Context) {
+	assignmentID := strings.TrimSpace(c.Param("id"))
+	if assignmentID == "" {
+		response.Error(c, http.StatusBadRequest, "missing assignment id", nil)
+		return
+	}
+
+	accountID := getAccountID(c)
+	if accountID == "" {
+		response.Error(c, http.StatusUnauthorized, "missing account context", nil)
+		return
+	}
+
+	revisions, err := h.assignments.ListAssignmentRevisions(c.Request.Context(), accountID, getRole(c), assignmentID)
+	if err != nil {
+		respondAssignmentLifecycleError(c, err, "unable to list revisions")
+		return
+	}
+
+	payload := make([]gin.H, 0, len(revisions))
+	for _, revision := range revisions {
+		payload = append(payload, gin.H{
+			"id":         revision.ID,
+			"version":    revision.Version,
+			"editor_id":  revision.EditorID,
+			"summary":    revision.Summary,
+			"snapshot":   json.RawMessage(revision.Snapshot),
+			"created_at": revision.CreatedAt,
+		})
+	}
+
+	response.Success(c, http.StatusOK, gin.H{"revisions": payload})
+}
+
+func respondAssignmentLifecycleError(c *gin.Context, err error, failure string) {
+	switch {
+	case errors.Is(err, service.ErrAssignmentNotFound):
+		response.Error(c, http.StatusNotFound, "assignment not found", nil)
+	case errors.Is(err, service.ErrAssignmentForbidden):
+		response.Error(c, http.StatusForbidden, "not allowed to manage this assignment", nil)
+	case errors.Is(err, service.ErrClassNotFound):
+		response.Error(c, http.StatusNotFound, "class not found", nil)
+	case errors.Is(err, service.ErrAssignmentLocked):
+		response.Error(c, http.StatusConflict, "assignment cannot be changed", err.Error())
+	case errors.Is(err, service.ErrAssignmentHasSubmissions):
+		response.Error(c, http.StatusConflict, "assignment has submissions; archive it instead", nil)
+	case errors.Is(err, service.ErrInvalidAssignmentStatus):
+		response.Error(c, http.StatusConflict, "invalid status transition", err.Error())
+	case errors.Is(err, service.ErrQuestionScoreMismatch):
+		response.Error(c, http.StatusUnprocessableEntity, "question scores do not sum to max score", err.Error())
+	default:
+		response.Error(c, http.StatusBadRequest, failure, err.Error())
+	}
+}
+
+func (h *Handler) GetAssignment(c *gin.Context) {
+	assignmentID := strings.TrimSpace(c.Param("id"))
+	if assignmentID == "" {
+		response.Error(c, http.StatusBadRequest, "missing assignment id", nil)
+		return
+	}
+
+	assignment, questions, err := h.assignments.GetAssignmentForViewer(c.Request.Context(), getRole(c), assignmentID)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrAssignmentNotFound):
+			response.Error(c, http.StatusNotFound, "assignment not found", nil)
+		default:
+			response.Error(c, http.StatusInternalServerError, "unable to load assignment", err.Error())
+		}
+		return
+	}
+
+	response.Success(c, http.StatusOK, gin.H{"assignment": assignmentPayload(*assignment, questions)})
+}
+
+func (h *Handler) ListAssignmentSubmissions(c *gin.Context) {
+	assignmentID := strings.TrimSpace(c.Param("id"))
+	if assignmentID == "" {
+		response.Error(c, http.StatusBadRequest, "missing assignment id", nil)
+		return
+	}
+
+	details, err := h.assignments.ListAssignmentSubmissions(c.Request.Context(), assignmentID)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "unable to list submissions", err.Error())
+		return
+	}
+
+	payload := make([]gin.H, 0, len(details))
+	for _, detail := range details {
+		payload = append(payload, submissionDetailPayload(detail))
+	}
+
+	response.Success(c, http.StatusOK, gin.H{"submissions": payload})
+}
+
+func (h *Handler) GetAssignmentStats(c *gin.Context) {
+	assignmentID := strings.TrimSpace(c.Param("id"))
+	if assignmentID == "" {
+		response.Error(c, http.StatusBadRequest, "missing assignment id", nil)
+		return
+	}
+
+	accountID := getAccountID(c)
+	if accountID == "" {
+		response.Error(c, http.StatusUnauthorized, "missing account context", nil)
+		return
+	}
+
+	stats, err := h.assignments.GetAssignmentStats(c.Request.Context(), accountID, getRole(c), assignmentID)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrAssignmentNotFound):
+			response.Error(c, http.StatusNotFound, "assignment not found", nil)
+		case errors.Is(err, service.ErrSubmissionForbidden):
+			response.Error(c, http.StatusForbidden, "not allowed to view statistics", nil)
+		default:
+			response.Error(c, http.StatusInternalServerError, "unable to compute statistics", err.Error())
+		}
+		return
+	}
+
+	response.Success(c, http.StatusOK, gin.H{"stats": assignmentStatsPayload(*stats)})
+}
+
+type submitAssignmentRequest struct {
+	StudentID string                   `json:"student_id" validate:"required"`
+	Status    string                   `json:"status" validate:"required"`
+	Score     *float64                 `json:"score"`
+	Feedback  string                   `json:"feedback"`
+	Answers   []submitAssignmentAnswer `json:"answers" validate:"required,min=1,dive"`
+}
+
+type submitAssignmentAnswer struct {
+	QuestionID    string   `json:"question_id" validate:"required"`
+	Answer        string   `json:"answer"`
+	Score         *float64 `json:"score"`
+	AttachmentIDs []string `json:"attachment_ids"`
+}
+
+type submissionCommentRequest struct {
+	Content       string   `json:"content"`
+	AttachmentIDs []string `json:"attachment_ids"`
+}
+
+type createAttachmentRequest struct {
+	FileName    string `json:"file_name" validate:"required"`
+	ContentType string `json:"content_type" validate:"required"`
+	Size        int64  `json:"size" validate:"required,gt=0"`
+}
+
+type gradeSubmissionRequest struct {
+	Score         *float64                  `json:"score"`
+	OverrideTotal bool                      `json:"override_total"`
+	Feedback      string                    `json:"feedback"`
+	ItemScores    map[string]*float64       `json:"item_scores"`
+	Comment       *submissionCommentRequest `json:"comment"`
+	Reason        string                    `json:"reason"`
+}
+
+type createNoteRequest struct {
+	Title      string `json:"title" validate:"required"`
+	Content    string `json:"content" validate:"required"`
+	Visibility string `json:"visibility" validate:"required,oneof=private class school"`
+	Status     string `json:"status" validate:"required,oneof=draft published"`
+}
+
+type updateNoteRequest struct {
+	Title      *string `json:"title"`
+	Content    *string `json:"content"`
+	Visibility *string `json:"visibility"`
+	Status     *string `json:"status"`
+}
+
+type createNoteCommentRequest struct {
+	Content string `json:"content" validate:"required"`
+}
+
+type createConversationRequest struct {
+	Type           string   `json:"type" validate:"omitempty,oneof=direct group"`
+	Name           string   `json:"name"`
+	ParticipantIDs []string `json:"participant_ids" validate:"required,min=1,dive,required"`
+}
+
+type renameConversationRequest struct {
+	Name string `json:"name" validate:"required"`
+}
+
+type setConversationAnnouncementRequest struct {
+	Enabled *bool `json:"enabled" validate:"required"`
+}
+
+type addConversationMembersRequest struct {
+	AccountIDs []string `json:"account_ids" validate:"required,min=1,dive,required"`
+}
+
+type updateConversationMemberRequest struct {
+	Role string `json:"role" validate:"required,oneof=owner admin member"`
+}
+
+type updateConversationSettingsRequest struct {
+	Pinned      *bool   `json:"pinned"`
+	Archived    *bool   `json:"archived"`
+	Muted       *bool   `json:"muted"`
+	MuteMinutes int     `json:"mute_minutes" validate:"min=0"`
+	Title       *string `json:"title"`
+}
+
+type sendMessageRequest struct {
+	Kind       string   `json:"kind" validate:"required,oneof=text image video audio file"`
+	Text       string   `json:"text"`
+	MediaID    string   `json:"media_id"`
+	Metadata   string   `json:"metadata"`
+	ReplyToID  string   `json:"reply_to_id"`
+	MentionIDs []string `json:"mention_ids"`
+	// ClientMsgID is a client-generated idempotency key, e.g. a UUID.
+	ClientMsgID string `json:"client_msg_id" validate:"max=64"`
+}
+
+type createMediaUploadRequest struct {
+	Kind        string `json:"kind" validate:"required,oneof=image video audio file"`
+	FileName    string `json:"file_name"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size" validate:"required,gt=0"`
+}
+
+type markConversationReadRequest struct {
+	MessageID string `json:"message_id" validate:"required"`
+}
+
+func (h *Handler) SubmitAssignment(c *gin.Context) {
+	assignmentID := c.Param("id")
+	if assignmentID == "" {
+		response.Error(c, http.StatusBadRequest, "missing assignment id", nil)
+		return
+	}
+
+	var req submitAssignmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "invalid request body", err.Error())
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		response.Error(c, http.StatusBadRequest, "validation error", err.Error())
+		return
+	}
+
+	answers := make([]service.AnswerInput, 0, len(req.Answers))
+	for _, ans := range req.Answers {
+		answers = append(answers, service.AnswerInput{
+			QuestionID:    ans.QuestionID,
+			Answer:        ans.Answer,
+			Score:         ans.Score,
+			AttachmentIDs: ans.AttachmentIDs,
+		})
+	}
+
+	err := h.assignments.Submit(c.Request.Context(), service.SubmitAssignmentInput{
+		AssignmentID: assignmentID,
+		StudentID:    req.StudentID,
+		Answers:      answers,
+		Score:        req.Score,
+		Feedback:     req.Feedback,
+		Status:       req.Status,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrAssignmentNotFound):
+			response.Error(c, http.StatusNotFound, "assignment not found", nil)
+		case errors.Is(err, service.ErrAssignmentNotOpen):
+			response.Error(c, http.StatusConflict, "assignment is not accepting submissions", nil)
+		case errors.Is(err, service.ErrInvalidAttachment):
+			response.Error(c, http.StatusUnprocessableEntity, "invalid attachment", err.Error())
+		default:
+			response.Error(c, http.StatusBadRequest, "unable to submit assignment", err.Error())
+		}
+		return
+	}
+
+	response.Success(c, http.StatusCreated, gin.H{"submission": "ok"})
+}
+
+func (h *Handler) GetMySubmission(c *gin.Context) {
+	assignmentID := strings.TrimSpace(c.Param("id"))
+	if assignmentID == "" {
+		response.Error(c, http.StatusBadRequest, "missing assignment id", nil)
+		return
+	}
+
+	studentID := getAccountID(c)
+	if studentID == "" {
+		response.Error(c, http.StatusUnauthorized, "missing account context", nil)
+		return
+	}
+
+	detail, comments, err := h.assignments.GetSubmissionForStudent(c.Request.Context(), assignmentID, studentID)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrAssignmentNotFound):
+			response.Error(c, http.StatusNotFound, "assignment not found", nil)
+		case errors.Is(err, service.ErrSubmissionNotFound):
+			response.Error(c, http.StatusNotFound, "submission not found", nil)
+		default:
+			response.Error(c, http.StatusInternalServerError, "unable to load submission", err.Error())
+		}
+		return
+	}
+
+	payload := gin.H{
+		"submission": submissionDetailPayload(*detail),
+		"comments":   submissionCommentsPayload(comments, detail.Attachments),
+	}
+	response.Success(c, http.StatusOK, payload)
+}
+
+func (h *Handler) CreateAttachmentUpload(c *gin.Context) {
+	assignmentID := strings.TrimSpace(c.Param("id"))
+	if assignmentID == "" {
+		response.Error(c, http.StatusBadRequest, "missing assignment id", nil)
+		return
+	}
+
+	accountID := getAccountID(c)
+	if accountID == "" {
+		response.Error(c, http.StatusUnauthorized, "missing account context", nil)
+		return
+	}
+
+	var req createAttachmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "invalid request body", err.Error())
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		response.Error(c, http.StatusBadRequest, "validation error", err.Error())
+		return
+	}
+
+	upload, err := h.assignments.CreateAttachmentUpload(c.Request.Context(), accountID, getRole(c), service.AttachmentUploadInput{
+		AssignmentID: assignmentID,
+		FileName:     req.FileName,
+		ContentType:  req.ContentType,
+		Size:         req.Size,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrAssignmentNotFound):
+			response.Error(c, http.StatusNotFound, "assignment not found", nil)
+		case errors.Is(err, service.ErrSubmissionForbidden):
+			response.Error(c, http.StatusForbidden, "not allowed to attach files", nil)
+		case errors.Is(err, service.ErrAssignmentNotOpen):
+			response.Error(c, http.StatusConflict, "assignment is not accepting submissions", nil)
+		case errors.Is(err, service.ErrAttachmentTooLarge):
+			response.Error(c, http.StatusRequestEntityTooLarge, "attachment too large", err.Error())
+		case errors.Is(err, service.ErrAttachmentTypeNotAllowed):
+			response.Error(c, http.StatusUnsupportedMediaType, "attachment type not allowed", err.Error())
+		case errors.Is(err, service.ErrInvalidAttachment):
+			response.Error(c, http.StatusUnprocessableEntity, "invalid attachment", err.Error())
+		default:
+			response.Error(c, http.StatusInternalServerError, "unable to create upload", err.Error())
+		}
+		return
+	}
+
+	response.Success(c, http.StatusCreated, gin.H{
+		"attachment":  attachmentPayload(upload.Attachment),
+		"credentials": upload.Credentials,
+	})
+}
+
+func (h *Handler) GetAttachmentDownload(c *gin.Context) {
+	assignmentID := strings.TrimSpace(c.Param("id"))
+	attachmentID := strings.TrimSpace(c.Param("attachmentID"))
+	if assignmentID == "" || attachmentID == "" {
+		response.Error(c, http.StatusBadRequest, "missing assignment or attachment id", nil)
+		return
+	}
+
+	accountID := getAccountID(c)
+	if accountID == "" {
+		response.Error(c, http.StatusUnauthorized, "missing account context", nil)
+		return
+	}
+
+	url, err := h.assignments.AttachmentDownloadURL(c.Request.Context(), accountID, getRole(c), assignmentID, attachmentID)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrAttachmentNotFound), errors.Is(err, service.ErrAssignmentNotFound):
+			response.Error(c, http.StatusNotFound, "attachment not found", nil)
+		default:
+			response.Error(c, http.StatusInternalServerError, "unable to create download url", err.Error())
+		}
+		return
+	}
+
+	response.Success(c, http.StatusOK, gin.H{"url": url})
+}
+
+func (h *Handler) GetAssignmentSubmission(c *gin.Context) {
+	assignmentID := strings.TrimSpace(c.Param("id"))
+	submissionID := strings.TrimSpace(c.Param("submissionID"))
+	if assignmentID == "" || submissionID == "" {
+		response.Error(c, http.StatusBadRequest, "missing assignment or submission id", nil)
+		return
+	}
+
+	teacherID := getAccountID(c)
+	if teacherID == "" {
+		response.Error(c, http.StatusUnauthorized, "missing account context", nil)
+		return
+	}
+
+	detail, comments, err := h.assignments.GetSubmissionForTeacher(c.Request.Context(), teacherID, assignmentID, submissionID)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrAssignmentNotFound):
+			response.Error(c, http.StatusNotFound, "assignment not found", nil)
+		case errors.Is(err, service.ErrSubmissionNotFound):
+			response.Error(c, http.StatusNotFound, "submission not found", nil)
+		case errors.Is(err, service.ErrSubmissionForbidden):
+			response.Error(c, http.StatusForbidden, "submission forbidden", nil)
+		default:
+			response.Error(c, http.StatusInternalServerError, "unable to load submission", err.Error())
+		}
+		return
+	}
+
+	payload := gin.H{
+		"submission": submissionDetailPayload(*detail),
+		"comments":   submissionCommentsPayload(comments, detail.Attachments),
+	}
+	response.Success(c, http.StatusOK, payload)
+}
+
+func (h *Handler) GradeSubmission(c *gin.Context) {
+	assignmentID := strings.TrimSpace(c.Param("id"))
+	submissionID := strings.TrimSpace(c.Param("submissionID"))
+	if assignmentID == "" || submissionID == "" {
+		response.Error(c, http.StatusBadRequest, "missing assignment or submission id", nil)
+		return
+	}
+
+	var req gradeSubmissionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "invalid request body", err.Error())
+		return
+	}
+	if req.Comment != nil && strings.TrimSpace(req.Comment.Content) == "" && len(req.Comment.AttachmentIDs) == 0 {
+		response.Error(c, http.StatusBadRequest, "comment content cannot be empty", nil)
+		return
+	}
+
+	teacherID := getAccountID(c)
+	if teacherID == "" {
 		response.Error(c, http.StatusUnauthorized, "missing account context", nil)
 		return
 	}
 
 	input := service.GradeSubmissionInput{
-		AssignmentID: assignmentID,
-		SubmissionID: submissionID,
-		Score:        req.Score,
-		Feedback:     req.Feedback,
-		ItemScores:   req.ItemScores,
+		AssignmentID:  assignmentID,
+		SubmissionID:  submissionID,
+		Score:         req.Score,
+		OverrideTotal: req.OverrideTotal,
+		Feedback:      req.Feedback,
+		ItemScores:    req.ItemScores,
+		Reason:        req.Reason,
 	}
 	if req.Comment != nil {
-		input.Comment = &service.SubmissionCommentInput{Content: req.Comment.Content}
+		input.Comment = &service.SubmissionCommentInput{Content: req.Comment.Content, AttachmentIDs: req.Comment.AttachmentIDs}
 	}
 
 	detail, comments, err := h.assignments.GradeSubmission(c.Request.Context(), teacherID, input)
@@ -653,21 +1405,231 @@ func (h *Handler) GradeSubmission(c *gin.Context) {
 		switch {
 		case errors.Is(err, service.ErrAssignmentNotFound):
 			response.Error(c, http.StatusNotFound, "assignment not found", nil)
-		case errors.Is(err, service.ErrSubmissionNotFound):
-			response.Error(c, http.StatusNotFound, "submission not found", nil)
+		case errors.Is(err, service.ErrSubmissionNotFound):
+			response.Error(c, http.StatusNotFound, "submission not found", nil)
+		case errors.Is(err, service.ErrSubmissionForbidden):
+			response.Error(c, http.StatusForbidden, "submission forbidden", nil)
+		case errors.Is(err, service.ErrInvalidItemScore):
+			response.Error(c, http.StatusUnprocessableEntity, "item score out of range", err.Error())
+		case errors.Is(err, service.ErrInvalidTotalScore):
+			response.Error(c, http.StatusUnprocessableEntity, "total score out of range", err.Error())
+		case errors.Is(err, service.ErrTotalScoreMismatch):
+			response.Error(c, http.StatusUnprocessableEntity, "total score does not match item scores", err.Error())
+		case errors.Is(err, service.ErrInvalidAttachment):
+			response.Error(c, http.StatusUnprocessableEntity, "invalid attachment", err.Error())
+		default:
+			response.Error(c, http.StatusInternalServerError, "unable to grade submission", err.Error())
+		}
+		return
+	}
+
+	payload := gin.H{
+		"submission": submissionDetailPayload(*detail),
+		"comments":   submissionCommentsPayload(comments, detail.Attachments),
+	}
+	response.Success(c, http.StatusOK, payload)
+}
+
+func (h *Handler) ListGradeHistory(c *gin.Context) {
+	assignmentID := strings.TrimSpace(c.Param("id"))
+	submissionID := strings.TrimSpace(c.Param("submissionID"))
+	if assignmentID == "" || submissionID == "" {
+		response.Error(c, http.StatusBadRequest, "missing assignment or submission id", nil)
+		return
+	}
+
+	accountID := getAccountID(c)
+	if accountID == "" {
+		response.Error(c, http.StatusUnauthorized, "missing account context", nil)
+		return
+	}
+
+	changes, err := h.assignments.ListGradeHistory(c.Request.Context(), accountID, getRole(c), assignmentID, submissionID)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrAssignmentNotFound):
+			response.Error(c, http.StatusNotFound, "assignment not found", nil)
+		case errors.Is(err, service.ErrSubmissionNotFound):
+			response.Error(c, http.StatusNotFound, "submission not found", nil)
+		case errors.Is(err, service.ErrSubmissionForbidden):
+			response.Error(c, http.StatusForbidden, "submission forbidden", nil)
+		default:
+			response.Error(c, http.StatusInternalServerError, "unable to load grade history", err.Error())
+		}
+		return
+	}
+
+	payload := make([]gin.H, 0, len(changes))
+	for _, change := range changes {
+		payload = append(payload, gradeChangePayload(change))
+	}
+
+	response.Success(c, http.StatusOK, gin.H{"history": payload})
+}
+
+type requestRegradeRequest struct {
+	ItemID string `json:"item_id" validate:"required"`
+	Reason string `json:"reason" validate:"required"`
+}
+
+func (h *Handler) RequestRegrade(c *gin.Context) {
+	assignmentID := strings.TrimSpace(c.Param("id"))
+	if assignmentID == "" {
+		response.Error(c, http.StatusBadRequest, "missing assignment id", nil)
+		return
+	}
+
+	studentID := getAccountID(c)
+	if studentID == "" {
+		response.Error(c, http.StatusUnauthorized, "missing account context", nil)
+		return
+	}
+
+	var req requestRegradeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "invalid request body", err.Error())
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		response.Error(c, http.StatusBadRequest, "validation error", err.Error())
+		return
+	}
+
+	request, err := h.assignments.RequestRegrade(c.Request.Context(), studentID, service.RegradeRequestInput{
+		AssignmentID: assignmentID,
+		ItemID:       req.ItemID,
+		Reason:       req.Reason,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrSubmissionNotFound):
+			response.Error(c, http.StatusNotFound, "submission not found", nil)
+		case errors.Is(err, service.ErrRegradePending):
+			response.Error(c, http.StatusConflict, "regrade request already pending", nil)
+		case errors.Is(err, service.ErrRegradeNotAllowed):
+			response.Error(c, http.StatusUnprocessableEntity, "regrade not allowed", err.Error())
+		default:
+			response.Error(c, http.StatusBadRequest, "unable to request regrade", err.Error())
+		}
+		return
+	}
+
+	response.Success(c, http.StatusCreated, gin.H{"regrade": regradePayload(*request)})
+}
+
+func (h *Handler) ListMyRegrades(c *gin.Context) {
+	assignmentID := strings.TrimSpace(c.Param("id"))
+	if assignmentID == "" {
+		response.Error(c, http.StatusBadRequest, "missing assignment id", nil)
+		return
+	}
+
+	studentID := getAccountID(c)
+	if studentID == "" {
+		response.Error(c, http.StatusUnauthorized, "missing account context", nil)
+		return
+	}
+
+	requests, err := h.assignments.ListMyRegradeRequests(c.Request.Context(), studentID, assignmentID)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrSubmissionNotFound):
+			response.Error(c, http.StatusNotFound, "submission not found", nil)
+		default:
+			response.Error(c, http.StatusInternalServerError, "unable to list regrade requests", err.Error())
+		}
+		return
+	}
+
+	response.Success(c, http.StatusOK, gin.H{"regrades": regradesPayload(requests)})
+}
+
+func (h *Handler) ListAssignmentRegrades(c *gin.Context) {
+	assignmentID := strings.TrimSpace(c.Param("id"))
+	if assignmentID == "" {
+		response.Error(c, http.StatusBadRequest, "missing assignment id", nil)
+		return
+	}
+
+	accountID := getAccountID(c)
+	if accountID == "" {
+		response.Error(c, http.StatusUnauthorized, "missing account context", nil)
+		return
+	}
+
+	status := domain.RegradeStatus(strings.ToLower(strings.TrimSpace(c.Query("status"))))
+	requests, err := h.assignments.ListAssignmentRegradeRequests(c.Request.Context(), accountID, getRole(c), assignmentID, status)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrAssignmentNotFound):
+			response.Error(c, http.StatusNotFound, "assignment not found", nil)
+		case errors.Is(err, service.ErrSubmissionForbidden):
+			response.Error(c, http.StatusForbidden, "submission forbidden", nil)
+		default:
+			response.Error(c, http.StatusInternalServerError, "unable to list regrade requests", err.Error())
+		}
+		return
+	}
+
+	response.Success(c, http.StatusOK, gin.H{"regrades": regradesPayload(requests)})
+}
+
+type resolveRegradeRequest struct {
+	Accept *bool    `json:"accept" validate:"required"`
+	Score  *float64 `json:"score"`
+	Note   string   `json:"note"`
+}
+
+func (h *Handler) ResolveRegrade(c *gin.Context) {
+	assignmentID := strings.TrimSpace(c.Param("id"))
+	requestID := strings.TrimSpace(c.Param("requestID"))
+	if assignmentID == "" || requestID == "" {
+		response.Error(c, http.StatusBadRequest, "missing assignment or request id", nil)
+		return
+	}
+
+	teacherID := getAccountID(c)
+	if teacherID == "" {
+		response.Error(c, http.StatusUnauthorized, "missing account context", nil)
+		return
+	}
+
+	var req resolveRegradeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "invalid request body", err.Error())
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		response.Error(c, http.StatusBadRequest, "validation error", err.Error())
+		return
+	}
+
+	request, err := h.assignments.ResolveRegrade(c.Request.Context(), teacherID, service.ResolveRegradeInput{
+		AssignmentID: assignmentID,
+		RequestID:    requestID,
+		Accept:       *req.Accept,
+		Score:        req.Score,
+		Note:         req.Note,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrAssignmentNotFound):
+			response.Error(c, http.StatusNotFound, "assignment not found", nil)
+		case errors.Is(err, service.ErrRegradeNotFound):
+			response.Error(c, http.StatusNotFound, "regrade request not found", nil)
 		case errors.Is(err, service.ErrSubmissionForbidden):
 			response.Error(c, http.StatusForbidden, "submission forbidden", nil)
+		case errors.Is(err, service.ErrRegradeResolved):
+			response.Error(c, http.StatusConflict, "regrade request already resolved", nil)
+		case errors.Is(err, service.ErrInvalidItemScore), errors.Is(err, service.ErrInvalidTotalScore):
+			response.Error(c, http.StatusUnprocessableEntity, "score out of range", err.Error())
 		default:
-			response.Error(c, http.StatusInternalServerError, "unable to grade submission", err.Error())
+			response.Error(c, http.StatusInternalServerError, "unable to resolve regrade request", err.Error())
 		}
 		return
 	}
 
-	payload := gin.H{
-		"submission": submissionDetailPayload(*detail),
-		"comments":   submissionCommentsPayload(comments),
-	}
-	response.Success(c, http.StatusOK, payload)
+	response.Success(c, http.StatusOK, gin.H{"regrade": regradePayload(*request)})
 }
 
 func (h *Handler) CreateNote(c *gin.Context) {
@@ -694,6 +1656,10 @@ func (h *Handler) CreateNote(c *gin.Context) {
 		Status:     req.Status,
 	})
 	if err != nil {
+		if errors.Is(err, service.ErrContentBlocked) {
+			respondContentBlocked(c)
+			return
+		}
 		response.Error(c, http.StatusBadRequest, "unable to create note", err.Error())
 		return
 	}
@@ -746,6 +1712,8 @@ func (h *Handler) UpdateNote(c *gin.Context) {
 			response.Error(c, http.StatusNotFound, "note not found", nil)
 		case errors.Is(err, service.ErrNoteForbidden):
 			response.Error(c, http.StatusForbidden, "not allowed to access note", nil)
+		case errors.Is(err, service.ErrContentBlocked):
+			respondContentBlocked(c)
 		default:
 			response.Error(c, http.StatusBadRequest, "unable to update note", err.Error())
 		}
@@ -860,90 +1828,426 @@ func (h *Handler) RestoreNote(c *gin.Context) {
 		return
 	}
 
-	response.Success(c, http.StatusOK, gin.H{"restored": true})
+	response.Success(c, http.StatusOK, gin.H{"restored": true})
+}
+
+func (h *Handler) CreateNoteComment(c *gin.Context) {
+	accountID := getAccountID(c)
+	if accountID == "" {
+		response.Error(c, http.StatusUnauthorized, "missing account context", nil)
+		return
+	}
+
+	noteID := c.Param("id")
+	if noteID == "" {
+		response.Error(c, http.StatusBadRequest, "missing note id", nil)
+		return
+	}
+
+	var req createNoteCommentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "invalid request body", err.Error())
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		response.Error(c, http.StatusBadRequest, "validation error", err.Error())
+		return
+	}
+
+	comment, err := h.noteComments.AddComment(c.Request.Context(), accountID, noteID, req.Content)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrNoteNotFound):
+			response.Error(c, http.StatusNotFound, "note not found", nil)
+		case errors.Is(err, service.ErrNoteCommentNotAllowed):
+			response.Error(c, http.StatusForbidden, "not allowed to comment", nil)
+		case errors.Is(err, service.ErrContentBlocked):
+			respondContentBlocked(c)
+		default:
+			response.Error(c, http.StatusBadRequest, "unable to create comment", err.Error())
+		}
+		return
+	}
+
+	response.Success(c, http.StatusCreated, gin.H{"comment": noteCommentPayload(*comment)})
+}
+
+func (h *Handler) ListNoteComments(c *gin.Context) {
+	accountID := getAccountID(c)
+	if accountID == "" {
+		response.Error(c, http.StatusUnauthorized, "missing account context", nil)
+		return
+	}
+
+	noteID := c.Param("id")
+	if noteID == "" {
+		response.Error(c, http.StatusBadRequest, "missing note id", nil)
+		return
+	}
+
+	comments, err := h.noteComments.ListComments(c.Request.Context(), accountID, noteID)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrNoteNotFound):
+			response.Error(c, http.StatusNotFound, "note not found", nil)
+		case errors.Is(err, service.ErrNoteCommentNotAllowed):
+			response.Error(c, http.StatusForbidden, "not allowed to view comments", nil)
+		default:
+			response.Error(c, http.StatusBadRequest, "unable to list comments", err.Error())
+		}
+		return
+	}
+
+	payload := make([]gin.H, 0, len(comments))
+	for _, comment := range comments {
+		payload = append(payload, noteCommentPayload(comment))
+	}
+
+	response.Success(c, http.StatusOK, gin.H{"comments": payload})
+}
+
+func (h *Handler) CreateConversation(c *gin.Context) {
+	accountID := getAccountID(c)
+	if accountID == "" {
+		response.Error(c, http.StatusUnauthorized, "missing account context", nil)
+		return
+	}
+
+	var req createConversationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "invalid request body", err.Error())
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		response.Error(c, http.StatusBadRequest, "validation error", err.Error())
+		return
+	}
+
+	if req.Type == domain.ConversationGroup || (req.Type == "" && len(req.ParticipantIDs) > 1) {
+		change, err := h.conversations.CreateGroupConversation(c.Request.Context(), accountID, req.Name, req.ParticipantIDs)
+		if err != nil {
+			respondConversationError(c, err, "unable to create conversation")
+			return
+		}
+		h.broadcastGroupChange(change)
+		response.Success(c, http.StatusCreated, gin.H{"conversation": conversationPayload(*change.Summary)})
+		return
+	}
+
+	if len(req.ParticipantIDs) != 1 {
+		response.Error(c, http.StatusBadRequest, "direct conversation requires exactly one participant", nil)
+		return
+	}
+
+	summary, err := h.conversations.CreateDirectConversation(c.Request.Context(), accountID, req.ParticipantIDs[0])
+	if err != nil {
+		respondConversationError(c, err, "unable to create conversation")
+		return
+	}
+
+	response.Success(c, http.StatusCreated, gin.H{"conversation": conversationPayload(*summary)})
+
+	// The chat may already exist; clients treat conversation.created as an upsert.
+	payload := sharedConversationPayload(*summary)
+	for _, member := range summary.Members {
+		h.wsHub.Join(summary.Conversation.ID, member.AccountID)
+		h.wsHub.Notify(member.AccountID, summary.Conversation.ID, "conversation.created", payload)
+	}
+}
+
+func (h *Handler) RenameConversation(c *gin.Context) {
+	accountID := getAccountID(c)
+	if accountID == "" {
+		response.Error(c, http.StatusUnauthorized, "missing account context", nil)
+		return
+	}
+
+	var req renameConversationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "invalid request body", err.Error())
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		response.Error(c, http.StatusBadRequest, "validation error", err.Error())
+		return
+	}
+
+	change, err := h.conversations.RenameGroup(c.Request.Context(), accountID, c.Param("id"), req.Name)
+	if err != nil {
+		respondConversationError(c, err, "unable to rename conversation")
+		return
+	}
+	h.broadcastGroupChange(change)
+	response.Success(c, http.StatusOK, gin.H{"conversation": conversationPayload(*change.Summary)})
+}
+
+func (h *Handler) SetConversationAnnouncement(c *gin.Context) {
+	accountID := getAccountID(c)
+	if accountID == "" {
+		response.Error(c, http.StatusUnauthorized, "missing account context", nil)
+		return
+	}
+
+	var req setConversationAnnouncementRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "invalid request body", err.Error())
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		response.Error(c, http.StatusBadRequest, "validation error", err.Error())
+		return
+	}
+
+	change, err := h.conversations.SetAnnouncementMode(c.Request.Context(), accountID, c.Param("id"), *req.Enabled)
+	if err != nil {
+		respondConversationError(c, err, "unable to update announcement mode")
+		return
+	}
+	h.broadcastGroupChange(change)
+	response.Success(c, http.StatusOK, gin.H{"conversation": conversationPayload(*change.Summary)})
+}
+
+func (h *Handler) AddConversationMembers(c *gin.Context) {
+	accountID := getAccountID(c)
+	if accountID == "" {
+		response.Error(c, http.StatusUnauthorized, "missing account context", nil)
+		return
+	}
+
+	var req addConversationMembersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "invalid request body", err.Error())
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		response.Error(c, http.StatusBadRequest, "validation error", err.Error())
+		return
+	}
+
+	change, err := h.conversations.AddGroupMembers(c.Request.Context(), accountID, c.Param("id"), req.AccountIDs)
+	if err != nil {
+		respondConversationError(c, err, "unable to add members")
+		return
+	}
+	h.broadcastGroupChange(change)
+	response.Success(c, http.StatusOK, gin.H{"conversation": conversationPayload(*change.Summary)})
+}
+
+func (h *Handler) RemoveConversationMember(c *gin.Context) {
+	accountID := getAccountID(c)
+	if accountID == "" {
+		response.Error(c, http.StatusUnauthorized, "missing account context", nil)
+		return
+	}
+
+	change, err := h.conversations.RemoveGroupMember(c.Request.Context(), accountID, c.Param("id"), c.Param("accountID"))
+	if err != nil {
+		respondConversationError(c, err, "unable to remove member")
+		return
+	}
+	h.broadcastGroupChange(change)
+	response.Success(c, http.StatusOK, gin.H{"conversation": conversationPayload(*change.Summary)})
+}
+
+func (h *Handler) UpdateConversationMember(c *gin.Context) {
+	accountID := getAccountID(c)
+	if accountID == "" {
+		response.Error(c, http.StatusUnauthorized, "missing account context", nil)
+		return
+	}
+
+	var req updateConversationMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "invalid request body", err.Error())
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		response.Error(c, http.StatusBadRequest, "validation error", err.Error())
+		return
+	}
+
+	change, err := h.conversations.SetGroupMemberRole(c.Request.Context(), accountID, c.Param("id"), c.Param("accountID"), domain.GroupRole(req.Role))
+	if err != nil {
+		respondConversationError(c, err, "unable to update member")
+		return
+	}
+	h.broadcastGroupChange(change)
+	response.Success(c, http.StatusOK, gin.H{"conversation": conversationPayload(*change.Summary)})
+}
+
+func (h *Handler) LeaveConversation(c *gin.Context) {
+	accountID := getAccountID(c)
+	if accountID == "" {
+		response.Error(c, http.StatusUnauthorized, "missing account context", nil)
+		return
+	}
+
+	conversationID := c.Param("id")
+	change, err := h.conversations.LeaveGroup(c.Request.Context(), accountID, conversationID)
+	if err != nil {
+		respondConversationError(c, err, "unable to leave conversation")
+		return
+	}
+	h.broadcastGroupChange(change)
+	response.Success(c, http.StatusOK, gin.H{"conversation_id": conversationID, "left": true})
+}
+
+// broadcastGroupChange pushes the system message and the new member list, then
+// disconnects sockets of accounts that are no longer members.
+// NotifyGroupChange pushes a membership change made outside a request, such
+// as a class chat sync, to connected clients.
+func (h *Handler) NotifyGroupChange(change *service.GroupChange) {
+	h.broadcastGroupChange(change)
+}
+
+func (h *Handler) broadcastGroupChange(change *service.GroupChange) {
+	conversationID := change.Summary.Conversation.ID
+	// Unread counts and settings are per account; recipients fetch their own.
+	payload := sharedConversationPayload(*change.Summary)
+	for _, accountID := range change.Added {
+		h.wsHub.Join(conversationID, accountID)
+		h.wsHub.Notify(accountID, conversationID, "conversation.created", payload)
+	}
+	if change.Message != nil {
+		h.wsHub.BroadcastSeq(conversationID, change.Message.Seq, "message.created", messagePayload(*change.Message))
+	}
+	h.wsHub.Broadcast(conversationID, "conversation.updated", payload)
+	for _, accountID := range change.Removed {
+		h.wsHub.RemoveAccount(conversationID, accountID)
+		h.wsHub.Notify(accountID, conversationID, "conversation.removed", gin.H{"conversation_id": conversationID})
+	}
+}
+
+func respondConversationError(c *gin.Context, err error, failure string) {
+	var violation *service.PolicyViolation
+	switch {
+	case errors.As(err, &violation):
+		respondPolicyViolation(c, violation)
+	case errors.Is(err, service.ErrConversationInvalid):
+		response.Error(c, http.StatusBadRequest, "invalid conversation", err.Error())
+	case errors.Is(err, service.ErrConversationMemberLimit):
+		response.Error(c, http.StatusConflict, "conversation member limit reached", err.Error())
+	case errors.Is(err, service.ErrConversationForbidden):
+		response.Error(c, http.StatusForbidden, "not allowed to manage conversation", nil)
+	case errors.Is(err, service.ErrConversationMuted):
+		response.Error(c, http.StatusForbidden, "conversation is announcement only", nil)
+	case errors.Is(err, service.ErrConversationNotFound):
+		response.Error(c, http.StatusNotFound, "conversation or member not found", nil)
+	default:
+		response.Error(c, http.StatusBadRequest, failure, err.Error())
+	}
+}
+
+func (h *Handler) ListConversations(c *gin.Context) {
+	accountID := getAccountID(c)
+	if accountID == "" {
+		response.Error(c, http.StatusUnauthorized, "missing account context", nil)
+		return
+	}
+
+	input := service.ListConversationsInput{Cursor: c.Query("cursor"), Archived: c.Query("archived") == "true"}
+	if raw := c.Query("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 {
+			response.Error(c, http.StatusBadRequest, "invalid limit", raw)
+			return
+		}
+		input.Limit = parsed
+	}
+
+	page, err := h.conversations.ListConversations(c.Request.Context(), accountID, input)
+	if err != nil {
+		if errors.Is(err, service.ErrConversationInvalid) {
+			response.Error(c, http.StatusBadRequest, "invalid conversation list request", err.Error())
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, "unable to list conversations", err.Error())
+		return
+	}
+
+	payload := make([]gin.H, 0, len(page.Conversations))
+	for _, conv := range page.Conversations {
+		payload = append(payload, conversationPayload(conv))
+	}
+
+	response.Success(c, http.StatusOK, gin.H{"conversations": payload, "next_cursor": page.NextCursor})
 }
 
-func (h *Handler) CreateNoteComment(c *gin.Context) {
+// GetConversation returns a conversation with its full member list, which
+// the conversation list leaves out.
+func (h *Handler) GetConversation(c *gin.Context) {
 	accountID := getAccountID(c)
 	if accountID == "" {
 		response.Error(c, http.StatusUnauthorized, "missing account context", nil)
 		return
 	}
 
-	noteID := c.Param("id")
-	if noteID == "" {
-		response.Error(c, http.StatusBadRequest, "missing note id", nil)
-		return
-	}
-
-	var req createNoteCommentRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		response.Error(c, http.StatusBadRequest, "invalid request body", err.Error())
-		return
-	}
-	if err := h.validate.Struct(req); err != nil {
-		response.Error(c, http.StatusBadRequest, "validation error", err.Error())
-		return
-	}
-
-	comment, err := h.noteComments.AddComment(c.Request.Context(), accountID, noteID, req.Content)
+	summary, err := h.conversations.GetConversationSummary(c.Request.Context(), accountID, c.Param("id"))
 	if err != nil {
 		switch {
-		case errors.Is(err, service.ErrNoteNotFound):
-			response.Error(c, http.StatusNotFound, "note not found", nil)
-		case errors.Is(err, service.ErrNoteCommentNotAllowed):
-			response.Error(c, http.StatusForbidden, "not allowed to comment", nil)
+		case errors.Is(err, service.ErrConversationForbidden):
+			response.Error(c, http.StatusForbidden, "not a member of this conversation", nil)
+		case errors.Is(err, service.ErrConversationNotFound):
+			response.Error(c, http.StatusNotFound, "conversation not found", nil)
 		default:
-			response.Error(c, http.StatusBadRequest, "unable to create comment", err.Error())
+			response.Error(c, http.StatusInternalServerError, "unable to load conversation", err.Error())
 		}
 		return
 	}
 
-	response.Success(c, http.StatusCreated, gin.H{"comment": noteCommentPayload(*comment)})
+	response.Success(c, http.StatusOK, gin.H{"conversation": conversationPayload(*summary)})
 }
 
-func (h *Handler) ListNoteComments(c *gin.Context) {
+// UpdateConversationSettings pins, mutes, archives or retitles a
+// conversation for the caller. The caller's other connections are told.
+func (h *Handler) UpdateConversationSettings(c *gin.Context) {
 	accountID := getAccountID(c)
 	if accountID == "" {
 		response.Error(c, http.StatusUnauthorized, "missing account context", nil)
 		return
 	}
 
-	noteID := c.Param("id")
-	if noteID == "" {
-		response.Error(c, http.StatusBadRequest, "missing note id", nil)
+	var req updateConversationSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "invalid request body", err.Error())
 		return
 	}
-
-	comments, err := h.noteComments.ListComments(c.Request.Context(), accountID, noteID)
-	if err != nil {
-		switch {
-		case errors.Is(err, service.ErrNoteNotFound):
-			response.Error(c, http.StatusNotFound, "note not found", nil)
-		case errors.Is(err, service.ErrNoteCommentNotAllowed):
-			response.Error(c, http.StatusForbidden, "not allowed to view comments", nil)
-		default:
-			response.Error(c, http.StatusBadRequest, "unable to list comments", err.Error())
-		}
+	if err := h.validate.Struct(req); err != nil {
+		response.Error(c, http.StatusBadRequest, "validation error", err.Error())
 		return
 	}
 
-	payload := make([]gin.H, 0, len(comments))
-	for _, comment := range comments {
-		payload = append(payload, noteCommentPayload(comment))
+	conversationID := c.Param("id")
+	summary, err := h.conversations.UpdateConversationSettings(c.Request.Context(), accountID, conversationID, service.ConversationSettingsInput{
+		Pinned:   req.Pinned,
+		Archived: req.Archived,
+		Muted:    req.Muted,
+		MuteFor:  time.Duration(req.MuteMinutes) * time.Minute,
+		Title:    req.Title,
+	})
+	if err != nil {
+		respondConversationError(c, err, "unable to update conversation settings")
+		return
 	}
 
-	response.Success(c, http.StatusOK, gin.H{"comments": payload})
+	response.Success(c, http.StatusOK, gin.H{"conversation": conversationPayload(*summary)})
+	h.wsHub.Notify(accountID, conversationID, "conversation.settings", conversationSettingsPayload(*summary.Member))
 }
 
-func (h *Handler) CreateConversation(c *gin.Context) {
+func (h *Handler) SendMessage(c *gin.Context) {
 	accountID := getAccountID(c)
 	if accountID == "" {
 		response.Error(c, http.StatusUnauthorized, "missing account context", nil)
 		return
 	}
 
-	var req createConversationRequest
+	conversationID := c.Param("id")
+	if conversationID == "" {
+		response.Error(c, http.StatusBadRequest, "missing conversation id", nil)
+		return
+	}
+
+	var req sendMessageRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		response.Error(c, http.StatusBadRequest, "invalid request body", err.Error())
 		return
@@ -953,49 +2257,130 @@ func (h *Handler) CreateConversation(c *gin.Context) {
 		return
 	}
 
-	if len(req.ParticipantIDs) != 1 {
-		response.Error(c, http.StatusBadRequest, "only one participant supported for direct conversation", nil)
+	if req.Kind == "text" {
+		if strings.TrimSpace(req.Text) == "" {
+			response.Error(c, http.StatusBadRequest, "text message requires non-empty text", nil)
+			return
+		}
+	} else if req.MediaID == "" {
+		response.Error(c, http.StatusBadRequest, "media message requires media_id", nil)
 		return
 	}
 
-	summary, err := h.conversations.CreateDirectConversation(c.Request.Context(), accountID, req.ParticipantIDs[0])
+	msg, created, err := h.conversations.SendMessage(c.Request.Context(), accountID, service.SendMessageInput{
+		ConversationID: conversationID,
+		Kind:           req.Kind,
+		Text:           req.Text,
+		MediaID:        req.MediaID,
+		Metadata:       req.Metadata,
+		ReplyToID:      req.ReplyToID,
+		MentionIDs:     req.MentionIDs,
+		ClientMsgID:    req.ClientMsgID,
+	})
 	if err != nil {
+		var violation *service.PolicyViolation
 		switch {
-		case errors.Is(err, service.ErrConversationInvalid):
-			response.Error(c, http.StatusBadRequest, "invalid conversation", err.Error())
+		case errors.As(err, &violation):
+			respondPolicyViolation(c, violation)
 		case errors.Is(err, service.ErrConversationForbidden):
-			response.Error(c, http.StatusForbidden, "not allowed to create conversation", nil)
+			response.Error(c, http.StatusForbidden, "not allowed to send message", nil)
+		case errors.Is(err, service.ErrConversationInvalid):
+			response.Error(c, http.StatusBadRequest, "invalid message", err.Error())
+		case errors.Is(err, service.ErrContentBlocked):
+			respondContentBlocked(c)
+		case errors.Is(err, service.ErrConversationMuted):
+			response.Error(c, http.StatusForbidden, "conversation is announcement only", nil)
+		case errors.Is(err, service.ErrConversationNotFound):
+			response.Error(c, http.StatusNotFound, "conversation not found", nil)
+		case errors.Is(err, service.ErrMediaTooLarge):
+			response.Error(c, http.StatusRequestEntityTooLarge, "media too large", err.Error())
+		case errors.Is(err, service.ErrMediaTypeMismatch):
+			response.Error(c, http.StatusUnsupportedMediaType, "media type mismatch", err.Error())
+		case errors.Is(err, service.ErrInvalidMedia):
+			response.Error(c, http.StatusUnprocessableEntity, "invalid media", err.Error())
 		default:
-			response.Error(c, http.StatusBadRequest, "unable to create conversation", err.Error())
+			response.Error(c, http.StatusBadRequest, "unable to send message", err.Error())
 		}
 		return
 	}
 
-	response.Success(c, http.StatusCreated, gin.H{"conversation": conversationPayload(*summary)})
+	payload := h.describeMessage(c.Request.Context(), *msg)
+	if !created {
+		// A retry of a send that already succeeded; it was broadcast then.
+		response.Success(c, http.StatusOK, gin.H{"message": payload, "duplicate": true})
+		return
+	}
+	response.Success(c, http.StatusCreated, gin.H{"message": payload})
+
+	h.broadcastMessageCreated(c.Request.Context(), payload)
 }
 
-func (h *Handler) ListConversations(c *gin.Context) {
-	accountID := getAccountID(c)
-	if accountID == "" {
-		response.Error(c, http.StatusUnauthorized, "missing account context", nil)
-		return
+// describeMessage renders a message with its reply preview, reactions and
+// mentions, falling back to the bare message if those cannot be loaded.
+func (h *Handler) describeMessage(ctx context.Context, msg domain.Message) gin.H {
+	details, err := h.conversations.DescribeMessages(ctx, []domain.Message{msg})
+	if err != nil || len(details) == 0 {
+		return messagePayload(msg)
+	}
+	return messageDetailPayload(details[0])
+}
+
+// broadcastMessageCreated publishes a new message, alerts mentioned accounts
+// and refreshes the unread counts of the other members.
+func (h *Handler) broadcastMessageCreated(ctx context.Context, payload gin.H) {
+	conversationID, _ := payload["conversation_id"].(string)
+	senderID, _ := payload["sender_id"].(string)
+	seq, _ := payload["seq"].(int64)
+	h.wsHub.BroadcastSeq(conversationID, seq, "message.created", payload)
+	mentions, _ := payload["mentions"].([]string)
+	for _, accountID := range mentions {
+		h.wsHub.SendToAccount(conversationID, accountID, "mention.created", gin.H{
+			"conversation_id": conversationID,
+			"message_id":      payload["id"],
+			"sender_id":       senderID,
+		})
 	}
 
-	convs, err := h.conversations.ListConversations(c.Request.Context(), accountID)
+	memberIDs, err := h.conversations.MemberIDs(ctx, conversationID)
 	if err != nil {
-		response.Error(c, http.StatusInternalServerError, "unable to list conversations", err.Error())
 		return
 	}
+	recipients := make([]string, 0, len(memberIDs))
+	for _, id := range memberIDs {
+		if id != senderID {
+			recipients = append(recipients, id)
+		}
+	}
+	h.pushUnreadCounts(conversationID, recipients...)
+}
 
-	payload := make([]gin.H, 0, len(convs))
-	for _, conv := range convs {
-		payload = append(payload, conversationPayload(conv))
+// pushUnreadCounts has the account clients of the given accounts, on any
+// instance, sent fresh unread counts for the conversation.
+func (h *Handler) pushUnreadCounts(conversationID string, accountIDs ...string) {
+	h.wsHub.Refresh(conversationID, accountIDs...)
+}
+
+// UnreadEvent builds the conversation.unread event for an account; the hub
+// calls it on the instance holding the account's connection.
+func (h *Handler) UnreadEvent(ctx context.Context, conversationID, accountID string) (string, interface{}, bool) {
+	unread, mentions, err := h.conversations.UnreadCounts(ctx, accountID, conversationID)
+	if err != nil {
+		return "", nil, false
 	}
+	return "conversation.unread", gin.H{
+		"conversation_id": conversationID,
+		"unread_count":    unread,
+		"mention_count":   mentions,
+	}, true
+}
 
-	response.Success(c, http.StatusOK, gin.H{"conversations": payload})
+// NotifyMessageUpdated pushes a changed message, such as one whose media has
+// finished processing, to clients watching its conversation.
+func (h *Handler) NotifyMessageUpdated(msg domain.Message) {
+	h.wsHub.Broadcast(msg.ConversationID, "message.updated", messagePayload(msg))
 }
 
-func (h *Handler) SendMessage(c *gin.Context) {
+func (h *Handler) CreateMediaUpload(c *gin.Context) {
 	accountID := getAccountID(c)
 	if accountID == "" {
 		response.Error(c, http.StatusUnauthorized, "missing account context", nil)
@@ -1008,7 +2393,7 @@ func (h *Handler) SendMessage(c *gin.Context) {
 		return
 	}
 
-	var req sendMessageRequest
+	var req createMediaUploadRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		response.Error(c, http.StatusBadRequest, "invalid request body", err.Error())
 		return
@@ -1018,39 +2403,67 @@ func (h *Handler) SendMessage(c *gin.Context) {
 		return
 	}
 
-	if req.Kind == "text" {
-		if strings.TrimSpace(req.Text) == "" {
-			response.Error(c, http.StatusBadRequest, "text message requires non-empty text", nil)
-			return
+	upload, err := h.conversations.CreateMediaUpload(c.Request.Context(), accountID, service.MediaUploadInput{
+		ConversationID: conversationID,
+		Kind:           req.Kind,
+		FileName:       req.FileName,
+		ContentType:    req.ContentType,
+		Size:           req.Size,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrConversationForbidden):
+			response.Error(c, http.StatusForbidden, "not allowed to upload media", nil)
+		case errors.Is(err, service.ErrMediaTooLarge):
+			response.Error(c, http.StatusRequestEntityTooLarge, "media too large", err.Error())
+		case errors.Is(err, service.ErrMediaTypeMismatch):
+			response.Error(c, http.StatusUnsupportedMediaType, "media type mismatch", err.Error())
+		case errors.Is(err, service.ErrInvalidMedia):
+			response.Error(c, http.StatusUnprocessableEntity, "invalid media", err.Error())
+		default:
+			response.Error(c, http.StatusInternalServerError, "unable to create upload", err.Error())
 		}
-	} else if req.MediaURI == "" {
-		response.Error(c, http.StatusBadRequest, "media message requires media_uri", nil)
 		return
 	}
 
-	msg, err := h.conversations.SendMessage(c.Request.Context(), accountID, service.SendMessageInput{
-		ConversationID: conversationID,
-		Kind:           req.Kind,
-		Text:           req.Text,
-		MediaURI:       req.MediaURI,
-		Metadata:       req.Metadata,
+	response.Success(c, http.StatusCreated, gin.H{
+		"media":       chatMediaPayload(upload.Media),
+		"credentials": upload.Credentials,
 	})
+}
+
+func (h *Handler) GetMediaDownload(c *gin.Context) {
+	accountID := getAccountID(c)
+	if accountID == "" {
+		response.Error(c, http.StatusUnauthorized, "missing account context", nil)
+		return
+	}
+
+	conversationID := c.Param("id")
+	mediaID := c.Param("mediaID")
+	if conversationID == "" || mediaID == "" {
+		response.Error(c, http.StatusBadRequest, "missing conversation or media id", nil)
+		return
+	}
+
+	media, links, err := h.conversations.MediaDownload(c.Request.Context(), accountID, conversationID, mediaID)
 	if err != nil {
 		switch {
 		case errors.Is(err, service.ErrConversationForbidden):
-			response.Error(c, http.StatusForbidden, "not allowed to send message", nil)
-		case errors.Is(err, service.ErrConversationNotFound):
-			response.Error(c, http.StatusNotFound, "conversation not found", nil)
+			response.Error(c, http.StatusForbidden, "not allowed to view media", nil)
+		case errors.Is(err, service.ErrMediaNotFound):
+			response.Error(c, http.StatusNotFound, "media not found", nil)
 		default:
-			response.Error(c, http.StatusBadRequest, "unable to send message", err.Error())
+			response.Error(c, http.StatusInternalServerError, "unable to create download url", err.Error())
 		}
 		return
 	}
 
-	payload := messagePayload(*msg)
-	response.Success(c, http.StatusCreated, gin.H{"message": payload})
-
-	h.wsHub.Broadcast(conversationID, "message.created", payload)
+	response.Success(c, http.StatusOK, gin.H{
+		"media":         chatMediaPayload(*media),
+		"url":           links.URL,
+		"thumbnail_url": links.ThumbnailURL,
+	})
 }
 
 func (h *Handler) ListMessages(c *gin.Context) {
@@ -1075,6 +2488,15 @@ func (h *Handler) ListMessages(c *gin.Context) {
 		}
 		limit = parsed
 	}
+	if raw := c.Query("after_seq"); raw != "" {
+		afterSeq, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || afterSeq < 0 {
+			response.Error(c, http.StatusBadRequest, "invalid after_seq", raw)
+			return
+		}
+		h.listMessagesAfter(c, accountID, conversationID, afterSeq, limit)
+		return
+	}
 	beforeID := c.Query("before_id")
 
 	messages, err := h.conversations.ListMessages(c.Request.Context(), accountID, conversationID, limit, beforeID)
@@ -1090,14 +2512,44 @@ func (h *Handler) ListMessages(c *gin.Context) {
 		return
 	}
 
-	payload := make([]gin.H, 0, len(messages))
-	for _, msg := range messages {
-		payload = append(payload, messagePayload(msg))
+	details, err := h.conversations.DescribeMessages(c.Request.Context(), messages)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "unable to load message details", err.Error())
+		return
+	}
+
+	payload := make([]gin.H, 0, len(details))
+	for _, detail := range details {
+		payload = append(payload, messageDetailPayload(detail))
 	}
 
 	response.Success(c, http.StatusOK, gin.H{"messages": payload})
 }
 
+// listMessagesAfter answers a resume request with the messages after
+// afterSeq in ascending order.
+func (h *Handler) listMessagesAfter(c *gin.Context, accountID, conversationID string, afterSeq int64, limit int) {
+	gap, err := h.conversations.MessagesAfter(c.Request.Context(), accountID, conversationID, afterSeq, limit)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrConversationForbidden):
+			response.Error(c, http.StatusForbidden, "not allowed to view messages", nil)
+		case errors.Is(err, service.ErrConversationNotFound):
+			response.Error(c, http.StatusNotFound, "conversation not found", nil)
+		default:
+			response.Error(c, http.StatusInternalServerError, "unable to list messages", err.Error())
+		}
+		return
+	}
+
+	payload, err := h.gapPayload(c.Request.Context(), gap)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "unable to load message details", err.Error())
+		return
+	}
+	response.Success(c, http.StatusOK, payload)
+}
+
 func (h *Handler) MarkConversationRead(c *gin.Context) {
 	accountID := getAccountID(c)
 	if accountID == "" {
@@ -1121,7 +2573,8 @@ func (h *Handler) MarkConversationRead(c *gin.Context) {
 		return
 	}
 
-	if err := h.conversations.MarkRead(c.Request.Context(), accountID, conversationID, req.MessageID); err != nil {
+	state, err := h.conversations.MarkRead(c.Request.Context(), accountID, conversationID, req.MessageID)
+	if err != nil {
 		switch {
 		case errors.Is(err, service.ErrConversationForbidden):
 			response.Error(c, http.StatusForbidden, "not allowed to mark read", nil)
@@ -1133,12 +2586,27 @@ func (h *Handler) MarkConversationRead(c *gin.Context) {
 		return
 	}
 
-	response.Success(c, http.StatusOK, gin.H{"read": true})
+	response.Success(c, http.StatusOK, gin.H{"read": true, "last_read_seq": state.LastReadSeq})
 
-	h.wsHub.Broadcast(conversationID, "conversation.read", gin.H{
-		"message_id": req.MessageID,
-		"reader_id":  accountID,
-	})
+	h.broadcastRead(req.MessageID, *state)
+}
+
+// broadcastRead tells the other member of a direct chat how far the reader
+// has read. In groups only the reader's own connections are told, sparing
+// large groups an event per read.
+func (h *Handler) broadcastRead(messageID string, state service.ReadState) {
+	payload := gin.H{
+		"message_id":    messageID,
+		"reader_id":     state.AccountID,
+		"last_read_seq": state.LastReadSeq,
+		"read_at":       state.LastReadAt,
+	}
+	if state.ConversationType == domain.ConversationDirect {
+		h.wsHub.Broadcast(state.ConversationID, "conversation.read", payload)
+	} else {
+		h.wsHub.SendToAccount(state.ConversationID, state.AccountID, "conversation.read", payload)
+	}
+	h.pushUnreadCounts(state.ConversationID, state.AccountID)
 }
 
 func (h *Handler) ConversationStream(c *gin.Context) {
@@ -1180,15 +2648,36 @@ func (h *Handler) ConversationStream(c *gin.Context) {
 		return
 	}
 
+	details, err := h.conversations.DescribeMessages(c.Request.Context(), messages)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "unable to load message details", err.Error())
+		return
+	}
+
+	// Messages sent while the snapshot was loading follow it as a resume
+	// gap. A reconnecting client may pass the last seq it saw instead.
+	lastSeq := summary.Conversation.LastSeq
+	for _, msg := range messages {
+		lastSeq = max(lastSeq, msg.Seq)
+	}
+	if raw := c.Query("last_seq"); raw != "" {
+		parsed, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || parsed < 0 {
+			response.Error(c, http.StatusBadRequest, "invalid last_seq", raw)
+			return
+		}
+		lastSeq = parsed
+	}
+
 	conn, err := wsUpgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
 		response.Error(c, http.StatusInternalServerError, "websocket upgrade failed", err.Error())
 		return
 	}
 
-	history := make([]gin.H, 0, len(messages))
-	for _, msg := range messages {
-		history = append(history, messagePayload(msg))
+	history := make([]gin.H, 0, len(details))
+	for _, detail := range details {
+		history = append(history, messageDetailPayload(detail))
 	}
 
 	snapshot := gin.H{
@@ -1211,6 +2700,9 @@ func (h *Handler) ConversationStream(c *gin.Context) {
 	client := ws.NewClient(h.wsHub, conn, accountID, conversationID, func(ctx context.Context, wsClient *ws.Client, payload []byte) {
 		h.handleConversationSocketMessage(ctx, wsClient, accountID, conversationID, payload)
 	})
+	h.wsHub.Hold(client, conversationID)
+	client.Start()
+	h.resumeConversation(c.Request.Context(), client, conversationID, lastSeq)
 	client.Run()
 }
 
@@ -1227,11 +2719,23 @@ func (h *Handler) handleConversationSocketMessage(ctx context.Context, client *w
 		return
 	}
 
-	switch envelope.Type {
+	if envelope.Type == "presence.set" {
+		h.handlePresenceEvent(client, envelope.Data)
+		return
+	}
+	h.dispatchConversationEvent(ctx, client, accountID, conversationID, envelope.Type, envelope.Data)
+}
+
+// dispatchConversationEvent handles a client frame addressed to one
+// conversation, from either a conversation stream or an account stream.
+func (h *Handler) dispatchConversationEvent(ctx context.Context, client *ws.Client, accountID, conversationID, event string, data json.RawMessage) {
+	switch event {
 	case "conversation.read":
-		h.handleConversationReadEvent(ctx, client, accountID, conversationID, envelope.Data)
+		h.handleConversationReadEvent(ctx, client, accountID, conversationID, data)
 	case "message.create":
-		h.handleConversationMessageCreateEvent(ctx, client, accountID, conversationID, envelope.Data)
+		h.handleConversationMessageCreateEvent(ctx, client, accountID, conversationID, data)
+	case "typing.start", "typing.stop":
+		h.handleTypingEvent(ctx, client, accountID, conversationID, event)
 	default:
 		_ = client.SendJSON(gin.H{"type": "error", "data": gin.H{"message": "unsupported event"}})
 	}
@@ -1250,7 +2754,8 @@ func (h *Handler) handleConversationReadEvent(ctx context.Context, client *ws.Cl
 		return
 	}
 
-	if err := h.conversations.MarkRead(ctx, accountID, conversationID, payload.MessageID); err != nil {
+	state, err := h.conversations.MarkRead(ctx, accountID, conversationID, payload.MessageID)
+	if err != nil {
 		switch {
 		case errors.Is(err, service.ErrConversationForbidden):
 			_ = client.SendJSON(gin.H{"type": "error", "data": gin.H{"message": "not allowed to mark read"}})
@@ -1264,10 +2769,7 @@ func (h *Handler) handleConversationReadEvent(ctx context.Context, client *ws.Cl
 
 	_ = client.SendJSON(gin.H{"type": "conversation.read.ack", "data": gin.H{"message_id": payload.MessageID}})
 
-	h.wsHub.Broadcast(conversationID, "conversation.read", gin.H{
-		"message_id": payload.MessageID,
-		"reader_id":  accountID,
-	})
+	h.broadcastRead(payload.MessageID, *state)
 }
 
 func (h *Handler) handleConversationMessageCreateEvent(ctx context.Context, client *ws.Client, accountID, conversationID string, raw json.RawMessage) {
@@ -1276,45 +2778,63 @@ func (h *Handler) handleConversationMessageCreateEvent(ctx context.Context, clie
 		_ = client.SendJSON(gin.H{"type": "error", "data": gin.H{"message": "invalid message.create data"}})
 		return
 	}
+	// Errors echo client_msg_id so the client knows which send failed.
+	sendError := func(message string) {
+		_ = client.SendJSON(gin.H{"type": "error", "data": gin.H{"message": message, "client_msg_id": payload.ClientMsgID}})
+	}
 
 	if err := h.validate.Struct(payload); err != nil {
-		_ = client.SendJSON(gin.H{"type": "error", "data": gin.H{"message": "validation failed"}})
+		sendError("validation failed")
 		return
 	}
 
 	if payload.Kind == "text" {
 		if strings.TrimSpace(payload.Text) == "" {
-			_ = client.SendJSON(gin.H{"type": "error", "data": gin.H{"message": "text message requires content"}})
+			sendError("text message requires content")
 			return
 		}
-	} else if payload.MediaURI == "" {
-		_ = client.SendJSON(gin.H{"type": "error", "data": gin.H{"message": "media message requires media_uri"}})
+	} else if payload.MediaID == "" {
+		sendError("media message requires media_id")
 		return
 	}
 
-	msg, err := h.conversations.SendMessage(ctx, accountID, service.SendMessageInput{
+	msg, created, err := h.conversations.SendMessage(ctx, accountID, service.SendMessageInput{
 		ConversationID: conversationID,
 		Kind:           payload.Kind,
 		Text:           payload.Text,
-		MediaURI:       payload.MediaURI,
+		MediaID:        payload.MediaID,
 		Metadata:       payload.Metadata,
+		ReplyToID:      payload.ReplyToID,
+		MentionIDs:     payload.MentionIDs,
+		ClientMsgID:    payload.ClientMsgID,
 	})
 	if err != nil {
+		var violation *service.PolicyViolation
 		switch {
+		case errors.As(err, &violation):
+			_ = client.SendJSON(gin.H{"type": "error", "data": gin.H{"message": violation.Reason, "rule": violation.Rule, "client_msg_id": payload.ClientMsgID}})
 		case errors.Is(err, service.ErrConversationForbidden):
-			_ = client.SendJSON(gin.H{"type": "error", "data": gin.H{"message": "not allowed to send message"}})
+			sendError("not allowed to send message")
+		case errors.Is(err, service.ErrConversationMuted):
+			sendError("conversation is announcement only")
+		case errors.Is(err, service.ErrContentBlocked):
+			sendError("content blocked by moderation")
 		case errors.Is(err, service.ErrConversationNotFound):
-			_ = client.SendJSON(gin.H{"type": "error", "data": gin.H{"message": "conversation not found"}})
+			sendError("conversation not found")
+		case errors.Is(err, service.ErrConversationInvalid), errors.Is(err, service.ErrMediaTooLarge), errors.Is(err, service.ErrMediaTypeMismatch), errors.Is(err, service.ErrInvalidMedia):
+			sendError(err.Error())
 		default:
-			_ = client.SendJSON(gin.H{"type": "error", "data": gin.H{"message": "unable to send message"}})
+			sendError("unable to send message")
 		}
 		return
 	}
 
-	msgPayload := messagePayload(*msg)
+	msgPayload := h.describeMessage(ctx, *msg)
 	_ = client.SendJSON(gin.H{"type": "message.create.ack", "data": msgPayload})
 
-	h.wsHub.Broadcast(conversationID, "message.created", msgPayload)
+	if created {
+		h.broadcastMessageCreated(ctx, msgPayload)
+	}
 }
 
 func assignmentPayload(assignment domain.Assignment, questions []domain.AssignmentQuestion) gin.H {
@@ -1331,6 +2851,10 @@ func assignmentPayload(assignment domain.Assignment, questions []domain.Assignme
 		"type":           assignment.Type,
 		"title":          assignment.Title,
 		"description":    assignment.Description,
+		"status":         assignment.Status,
+		"publish_at":     assignment.PublishAt,
+		"published_at":   assignment.PublishedAt,
+		"version":        assignment.Version,
 		"start_at":       assignment.StartAt,
 		"due_at":         assignment.DueAt,
 		"max_score":      assignment.MaxScore,
@@ -1341,6 +2865,22 @@ func assignmentPayload(assignment domain.Assignment, questions []domain.Assignme
 	}
 }
 
+func assignmentSummaryPayload(assignment domain.Assignment) gin.H {
+	return gin.H{
+		"id":         assignment.ID,
+		"course_id":  assignment.CourseID,
+		"teacher_id": assignment.TeacherID,
+		"class_id":   assignment.ClassID,
+		"type":       assignment.Type,
+		"title":      assignment.Title,
+		"status":     assignment.Status,
+		"publish_at": assignment.PublishAt,
+		"start_at":   assignment.StartAt,
+		"due_at":     assignment.DueAt,
+		"max_score":  assignment.MaxScore,
+	}
+}
+
 func assignmentQuestionPayload(q domain.AssignmentQuestion) gin.H {
 	return gin.H{
 		"id":            q.ID,
@@ -1354,15 +2894,63 @@ func assignmentQuestionPayload(q domain.AssignmentQuestion) gin.H {
 	}
 }
 
+func assignmentStatsPayload(stats service.AssignmentStats) gin.H {
+	histogram := make([]gin.H, 0, len(stats.Histogram))
+	for _, bucket := range stats.Histogram {
+		histogram = append(histogram, gin.H{
+			"lower": bucket.Lower,
+			"upper": bucket.Upper,
+			"count": bucket.Count,
+		})
+	}
+
+	questions := make([]gin.H, 0, len(stats.Questions))
+	for _, q := range stats.Questions {
+		questions = append(questions, gin.H{
+			"question_id":    q.Question.ID,
+			"type":           q.Question.Type,
+			"order_index":    q.Question.OrderIndex,
+			"max_score":      q.Question.Score,
+			"graded":         q.Graded,
+			"avg_score":      q.AvgScore,
+			"difficulty":     q.Difficulty,
+			"discrimination": q.Discrimination,
+			"option_counts":  q.OptionCounts,
+		})
+	}
+
+	return gin.H{
+		"assignment_id":   stats.AssignmentID,
+		"class_size":      stats.ClassSize,
+		"submitted":       stats.Submitted,
+		"graded":          stats.Graded,
+		"submission_rate": stats.SubmissionRate,
+		"mean":            stats.Mean,
+		"median":          stats.Median,
+		"stddev":          stats.StdDev,
+		"min":             stats.Min,
+		"max":             stats.Max,
+		"histogram":       histogram,
+		"questions":       questions,
+		"computed_at":     stats.ComputedAt,
+	}
+}
+
 func submissionDetailPayload(detail service.SubmissionDetail) gin.H {
+	attachments := attachmentsByTarget(detail.Attachments)
 	items := make([]gin.H, 0, len(detail.Items))
 	for _, item := range detail.Items {
+		itemAttachments := attachments[item.ID]
+		if itemAttachments == nil {
+			itemAttachments = []gin.H{}
+		}
 		items = append(items, gin.H{
 			"id":            item.ID,
 			"submission_id": item.SubmissionID,
 			"question_id":   item.QuestionID,
 			"answer":        item.Answer,
 			"score":         item.Score,
+			"attachments":   itemAttachments,
 		})
 	}
 
@@ -1380,7 +2968,10 @@ func submissionDetailPayload(detail service.SubmissionDetail) gin.H {
 	}
 }
 
-func submissionCommentPayload(comment domain.SubmissionComment) gin.H {
+func submissionCommentPayload(comment domain.SubmissionComment, attachments []gin.H) gin.H {
+	if attachments == nil {
+		attachments = []gin.H{}
+	}
 	return gin.H{
 		"id":             comment.ID,
 		"submission_id":  comment.SubmissionID,
@@ -1388,14 +2979,75 @@ func submissionCommentPayload(comment domain.SubmissionComment) gin.H {
 		"author_role":    string(comment.AuthorRole),
 		"content":        comment.Content,
 		"attachment_uri": comment.AttachmentURI,
+		"attachments":    attachments,
 		"created_at":     comment.CreatedAt,
 	}
 }
 
-func submissionCommentsPayload(comments []domain.SubmissionComment) []gin.H {
+func submissionCommentsPayload(comments []domain.SubmissionComment, attachments []domain.Attachment) []gin.H {
+	byTarget := attachmentsByTarget(attachments)
 	payload := make([]gin.H, 0, len(comments))
 	for _, comment := range comments {
-		payload = append(payload, submissionCommentPayload(comment))
+		payload = append(payload, submissionCommentPayload(comment, byTarget[comment.ID]))
+	}
+	return payload
+}
+
+func attachmentPayload(attachment domain.Attachment) gin.H {
+	return gin.H{
+		"id":           attachment.ID,
+		"file_name":    attachment.FileName,
+		"content_type": attachment.ContentType,
+		"size":         attachment.Size,
+		"object_key":   attachment.ObjectKey,
+		"status":       attachment.Status,
+		"created_at":   attachment.CreatedAt,
+	}
+}
+
+func attachmentsByTarget(attachments []domain.Attachment) map[string][]gin.H {
+	grouped := make(map[string][]gin.H)
+	for _, attachment := range attachments {
+		grouped[attachment.TargetID] = append(grouped[attachment.TargetID], attachmentPayload(attachment))
+	}
+	return grouped
+}
+
+func gradeChangePayload(change domain.GradeChange) gin.H {
+	return gin.H{
+		"id":            change.ID,
+		"submission_id": change.SubmissionID,
+		"item_id":       change.ItemID,
+		"old_score":     change.OldScore,
+		"new_score":     change.NewScore,
+		"grader_id":     change.GraderID,
+		"reason":        change.Reason,
+		"created_at":    change.CreatedAt,
+	}
+}
+
+func regradePayload(request domain.RegradeRequest) gin.H {
+	return gin.H{
+		"id":              request.ID,
+		"assignment_id":   request.AssignmentID,
+		"submission_id":   request.SubmissionID,
+		"item_id":         request.ItemID,
+		"student_id":      request.StudentID,
+		"reason":          request.Reason,
+		"status":          request.Status,
+		"comment_id":      request.CommentID,
+		"resolver_id":     request.ResolverID,
+		"resolution_note": request.ResolutionNote,
+		"resolved_at":     request.ResolvedAt,
+		"created_at":      request.CreatedAt,
+		"updated_at":      request.UpdatedAt,
+	}
+}
+
+func regradesPayload(requests []domain.RegradeRequest) []gin.H {
+	payload := make([]gin.H, 0, len(requests))
+	for _, request := range requests {
+		payload = append(payload, regradePayload(request))
 	}
 	return payload
 }
@@ -1408,6 +3060,8 @@ func conversationPayload(summary service.ConversationSummary) gin.H {
 			"conversation_id": member.ConversationID,
 			"account_id":      member.AccountID,
 			"role":            string(member.Role),
+			"group_role":      string(member.GroupRole),
+			"last_read_seq":   member.LastReadSeq,
 			"created_at":      member.CreatedAt,
 		})
 	}
@@ -1417,19 +3071,61 @@ func conversationPayload(summary service.ConversationSummary) gin.H {
 		last = messagePayload(*summary.LastMessage)
 	}
 
+	payload := gin.H{
+		"id":                summary.Conversation.ID,
+		"type":              summary.Conversation.Type,
+		"name":              summary.Conversation.Name,
+		"school_id":         summary.Conversation.SchoolID,
+		"class_id":          summary.Conversation.ClassID,
+		"course_id":         summary.Conversation.CourseID,
+		"announcement_only": summary.Conversation.AnnouncementOnly,
+		"created_at":        summary.Conversation.CreatedAt,
+		"updated_at":        summary.Conversation.UpdatedAt,
+		"members":           members,
+		"member_count":      summary.MemberCount,
+		"last_message":      last,
+		"unread_count":      summary.UnreadCount,
+		"mention_count":     summary.MentionCount,
+	}
+	if summary.Member != nil {
+		payload["settings"] = conversationSettingsPayload(*summary.Member)
+	}
+	return payload
+}
+
+// sharedConversationPayload leaves out what differs between members, for
+// events sent to all of them.
+func sharedConversationPayload(summary service.ConversationSummary) gin.H {
+	payload := conversationPayload(summary)
+	delete(payload, "unread_count")
+	delete(payload, "mention_count")
+	delete(payload, "settings")
+	return payload
+}
+
+// conversationSettingsPayload describes a member's own settings. A mute
+// until unmuted has no muted_until.
+func conversationSettingsPayload(member domain.ConversationMember) gin.H {
+	muted := member.MutedUntil != nil && member.MutedUntil.After(time.Now())
+	var mutedUntil *time.Time
+	if muted && !member.MutedUntil.Equal(domain.MutedForever) {
+		mutedUntil = member.MutedUntil
+	}
 	return gin.H{
-		"id":           summary.Conversation.ID,
-		"type":         summary.Conversation.Type,
-		"school_id":    summary.Conversation.SchoolID,
-		"created_at":   summary.Conversation.CreatedAt,
-		"updated_at":   summary.Conversation.UpdatedAt,
-		"members":      members,
-		"last_message": last,
-		"unread_count": summary.UnreadCount,
+		"pinned":      member.PinnedAt != nil,
+		"pinned_at":   member.PinnedAt,
+		"muted":       muted,
+		"muted_until": mutedUntil,
+		"archived":    member.ArchivedAt != nil,
+		"title":       member.Title,
 	}
 }
 
 func messagePayload(msg domain.Message) gin.H {
+	clientMsgID := ""
+	if msg.ClientMsgID != nil {
+		clientMsgID = *msg.ClientMsgID
+	}
 	return gin.H{
 		"id":              msg.ID,
 		"conversation_id": msg.ConversationID,
@@ -1438,8 +3134,32 @@ func messagePayload(msg domain.Message) gin.H {
 		"kind":            msg.Kind,
 		"text":            msg.Text,
 		"media_uri":       msg.MediaURI,
+		"media_id":        msg.MediaID,
 		"metadata":        msg.Metadata,
+		"reply_to_id":     msg.ReplyToID,
+		"seq":             msg.Seq,
+		"client_msg_id":   clientMsgID,
 		"created_at":      msg.CreatedAt,
+		"edited_at":       msg.EditedAt,
+		"recalled_at":     msg.RecalledAt,
+		"removed_at":      msg.RemovedAt,
+	}
+}
+
+func chatMediaPayload(media domain.ChatMedia) gin.H {
+	return gin.H{
+		"id":              media.ID,
+		"conversation_id": media.ConversationID,
+		"message_id":      media.MessageID,
+		"kind":            media.Kind,
+		"file_name":       media.FileName,
+		"content_type":    media.ContentType,
+		"size":            media.Size,
+		"status":          media.Status,
+		"width":           media.Width,
+		"height":          media.Height,
+		"duration_ms":     media.DurationMS,
+		"created_at":      media.CreatedAt,
 	}
 }
 
@@ -1450,6 +3170,7 @@ func noteCommentPayload(comment domain.NoteComment) gin.H {
 		"author_id":   comment.AuthorID,
 		"author_role": string(comment.AuthorRole),
 		"content":     comment.Content,
+		"removed_at":  comment.RemovedAt,
 		"created_at":  comment.CreatedAt,
 	}
 }
@@ -1470,6 +3191,7 @@ func notePayload(note domain.Note) gin.H {
 		"visibility": note.Visibility,
 		"status":     note.Status,
 		"deleted_at": deletedAt,
+		"removed_at": note.RemovedAt,
 		"created_at": note.CreatedAt,
 		"updated_at": note.UpdatedAt,
 	}
@@ -1479,6 +3201,10 @@ func getAccountID(c *gin.Context) string {
 	return c.GetString(middleware.ContextAccountID)
 }
 
+func getRole(c *gin.Context) domain.Role {
+	return domain.Role(c.GetString(middleware.ContextRole))
+}
+
 func convertToTime(t *service.TimeISO8601) *time.Time {
 	if t == nil {
 		return nil
@@ -0,0 +1,370 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"learn-go/internal/domain"
+	"learn-go/internal/service"
+	"learn-go/pkg/response"
+)
+
+type editMessageRequest struct {
+	Text     string `json:"text" validate:"required"`
+	Metadata string `json:"metadata"`
+}
+
+func (h *Handler) EditMessage(c *gin.Context) {
+	accountID := getAccountID(c)
+	if accountID == "" {
+		response.Error(c, http.StatusUnauthorized, "missing account context", nil)
+		return
+	}
+
+	var req editMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "invalid request body", err.Error())
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		response.Error(c, http.StatusBadRequest, "validation error", err.Error())
+		return
+	}
+
+	msg, err := h.conversations.EditMessage(c.Request.Context(), accountID, service.EditMessageInput{
+		ConversationID: c.Param("id"),
+		MessageID:      c.Param("messageID"),
+		Text:           req.Text,
+		Metadata:       req.Metadata,
+	})
+	if err != nil {
+		respondMessageError(c, err, "unable to edit message")
+		return
+	}
+
+	payload := h.describeMessage(c.Request.Context(), *msg)
+	h.wsHub.Broadcast(msg.ConversationID, "message.edited", payload)
+	response.Success(c, http.StatusOK, gin.H{"message": payload})
+}
+
+func (h *Handler) ListMessageEdits(c *gin.Context) {
+	accountID := getAccountID(c)
+	if accountID == "" {
+		response.Error(c, http.StatusUnauthorized, "missing account context", nil)
+		return
+	}
+
+	edits, err := h.conversations.ListMessageEdits(c.Request.Context(), accountID, c.Param("id"), c.Param("messageID"))
+	if err != nil {
+		respondMessageError(c, err, "unable to list message edits")
+		return
+	}
+
+	items := make([]gin.H, 0, len(edits))
+	for _, edit := range edits {
+		items = append(items, messageEditPayload(edit))
+	}
+	response.Success(c, http.StatusOK, gin.H{"edits": items})
+}
+
+// ListMessageReaders reports how many members have read a message and, in
+// direct chats, who.
+func (h *Handler) ListMessageReaders(c *gin.Context) {
+	accountID := getAccountID(c)
+	if accountID == "" {
+		response.Error(c, http.StatusUnauthorized, "missing account context", nil)
+		return
+	}
+
+	readers, err := h.conversations.ListMessageReaders(c.Request.Context(), accountID, c.Param("id"), c.Param("messageID"))
+	if err != nil {
+		respondMessageError(c, err, "unable to list message readers")
+		return
+	}
+
+	items := make([]gin.H, 0, len(readers.Readers))
+	for _, member := range readers.Readers {
+		items = append(items, gin.H{
+			"account_id":    member.AccountID,
+			"last_read_seq": member.LastReadSeq,
+			"read_at":       member.LastReadAt,
+		})
+	}
+	response.Success(c, http.StatusOK, gin.H{
+		"message_id":   readers.Message.ID,
+		"read_count":   readers.ReadCount,
+		"unread_count": readers.UnreadCount,
+		"readers":      items,
+	})
+}
+
+func (h *Handler) RecallMessage(c *gin.Context) {
+	accountID := getAccountID(c)
+	if accountID == "" {
+		response.Error(c, http.StatusUnauthorized, "missing account context", nil)
+		return
+	}
+
+	msg, err := h.conversations.RecallMessage(c.Request.Context(), accountID, c.Param("id"), c.Param("messageID"))
+	if err != nil {
+		respondMessageError(c, err, "unable to recall message")
+		return
+	}
+
+	payload := messagePayload(*msg)
+	h.wsHub.Broadcast(msg.ConversationID, "message.recalled", payload)
+	response.Success(c, http.StatusOK, gin.H{"message": payload})
+}
+
+// DeleteMessage hides a message for the caller only; other members are unaffected.
+func (h *Handler) DeleteMessage(c *gin.Context) {
+	accountID := getAccountID(c)
+	if accountID == "" {
+		response.Error(c, http.StatusUnauthorized, "missing account context", nil)
+		return
+	}
+
+	conversationID := c.Param("id")
+	messageID := c.Param("messageID")
+	if err := h.conversations.DeleteMessageForMe(c.Request.Context(), accountID, conversationID, messageID); err != nil {
+		respondMessageError(c, err, "unable to delete message")
+		return
+	}
+
+	// Only the caller's other connections need to drop the message.
+	h.wsHub.SendToAccount(conversationID, accountID, "message.deleted", gin.H{
+		"conversation_id": conversationID,
+		"message_id":      messageID,
+	})
+	h.pushUnreadCounts(conversationID, accountID)
+	response.Success(c, http.StatusOK, gin.H{"deleted": true})
+}
+
+type toggleReactionRequest struct {
+	Emoji string `json:"emoji" validate:"required"`
+}
+
+func (h *Handler) ToggleReaction(c *gin.Context) {
+	accountID := getAccountID(c)
+	if accountID == "" {
+		response.Error(c, http.StatusUnauthorized, "missing account context", nil)
+		return
+	}
+
+	var req toggleReactionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "invalid request body", err.Error())
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		response.Error(c, http.StatusBadRequest, "validation error", err.Error())
+		return
+	}
+
+	conversationID := c.Param("id")
+	change, err := h.conversations.ToggleReaction(c.Request.Context(), accountID, conversationID, c.Param("messageID"), req.Emoji)
+	if err != nil {
+		respondMessageError(c, err, "unable to react to message")
+		return
+	}
+
+	payload := gin.H{
+		"conversation_id": conversationID,
+		"message_id":      change.MessageID,
+		"reactions":       reactionsPayload(change.Reactions),
+	}
+	h.wsHub.Broadcast(conversationID, "message.reactions", payload)
+	payload["added"] = change.Added
+	response.Success(c, http.StatusOK, payload)
+}
+
+func (h *Handler) ListMentions(c *gin.Context) {
+	accountID := getAccountID(c)
+	if accountID == "" {
+		response.Error(c, http.StatusUnauthorized, "missing account context", nil)
+		return
+	}
+
+	limit := 50
+	if raw := c.Query("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 {
+			response.Error(c, http.StatusBadRequest, "invalid limit", raw)
+			return
+		}
+		limit = parsed
+	}
+	unreadOnly := c.Query("unread") == "true"
+
+	mentions, err := h.conversations.ListMentions(c.Request.Context(), accountID, unreadOnly, limit)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "unable to list mentions", err.Error())
+		return
+	}
+
+	items := make([]gin.H, 0, len(mentions))
+	for _, mention := range mentions {
+		items = append(items, gin.H{
+			"id":              mention.ID,
+			"conversation_id": mention.ConversationID,
+			"message_id":      mention.MessageID,
+			"read_at":         mention.ReadAt,
+			"created_at":      mention.CreatedAt,
+		})
+	}
+	response.Success(c, http.StatusOK, gin.H{"mentions": items})
+}
+
+func (h *Handler) SearchMessages(c *gin.Context) {
+	accountID := getAccountID(c)
+	if accountID == "" {
+		response.Error(c, http.StatusUnauthorized, "missing account context", nil)
+		return
+	}
+
+	input := service.SearchMessagesInput{
+		Query:          c.Query("q"),
+		ConversationID: c.Query("conversation_id"),
+		SenderID:       c.Query("sender_id"),
+		Kind:           c.Query("kind"),
+		Page:           1,
+		Size:           20,
+	}
+	var err error
+	if input.Page, err = positiveQuery(c, "page", input.Page); err != nil {
+		response.Error(c, http.StatusBadRequest, "invalid page", c.Query("page"))
+		return
+	}
+	if input.Size, err = positiveQuery(c, "size", input.Size); err != nil {
+		response.Error(c, http.StatusBadRequest, "invalid size", c.Query("size"))
+		return
+	}
+	if input.Size > 100 {
+		input.Size = 100
+	}
+	if input.From, err = timeQuery(c, "from"); err != nil {
+		response.Error(c, http.StatusBadRequest, "invalid from, expected RFC3339", c.Query("from"))
+		return
+	}
+	if input.To, err = timeQuery(c, "to"); err != nil {
+		response.Error(c, http.StatusBadRequest, "invalid to, expected RFC3339", c.Query("to"))
+		return
+	}
+
+	hits, total, err := h.conversations.SearchMessages(c.Request.Context(), accountID, input)
+	if err != nil {
+		if errors.Is(err, service.ErrConversationInvalid) {
+			response.Error(c, http.StatusBadRequest, "invalid search", err.Error())
+			return
+		}
+		respondMessageError(c, err, "unable to search messages")
+		return
+	}
+
+	items := make([]gin.H, 0, len(hits))
+	for _, hit := range hits {
+		payload := messageDetailPayload(hit.MessageDetail)
+		payload["snippet"] = hit.Snippet
+		payload["highlights"] = hit.Highlights
+		items = append(items, payload)
+	}
+	response.Success(c, http.StatusOK, gin.H{
+		"messages": items,
+		"total":    total,
+		"page":     input.Page,
+		"size":     input.Size,
+	})
+}
+
+// positiveQuery reads an optional positive integer query parameter.
+func positiveQuery(c *gin.Context, name string, fallback int) (int, error) {
+	raw := c.Query(name)
+	if raw == "" {
+		return fallback, nil
+	}
+	parsed, err := strconv.Atoi(raw)
+	if err != nil || parsed <= 0 {
+		return 0, errors.New("must be a positive integer")
+	}
+	return parsed, nil
+}
+
+// timeQuery reads an optional RFC3339 timestamp query parameter.
+func timeQuery(c *gin.Context, name string) (*time.Time, error) {
+	raw := c.Query(name)
+	if raw == "" {
+		return nil, nil
+	}
+	parsed, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return nil, err
+	}
+	return &parsed, nil
+}
+
+func respondMessageError(c *gin.Context, err error, failure string) {
+	switch {
+	case errors.Is(err, service.ErrConversationForbidden):
+		response.Error(c, http.StatusForbidden, "not a member of conversation", nil)
+	case errors.Is(err, service.ErrMessageNotFound):
+		response.Error(c, http.StatusNotFound, "message not found", nil)
+	case errors.Is(err, service.ErrMessageNotEditable):
+		response.Error(c, http.StatusForbidden, "message cannot be modified", nil)
+	case errors.Is(err, service.ErrRecallWindowExpired):
+		response.Error(c, http.StatusConflict, "recall window expired", nil)
+	case errors.Is(err, service.ErrConversationInvalid):
+		response.Error(c, http.StatusBadRequest, "invalid message", err.Error())
+	case errors.Is(err, service.ErrContentBlocked):
+		respondContentBlocked(c)
+	default:
+		response.Error(c, http.StatusBadRequest, failure, err.Error())
+	}
+}
+
+func messageEditPayload(edit domain.MessageEdit) gin.H {
+	return gin.H{
+		"id":         edit.ID,
+		"message_id": edit.MessageID,
+		"editor_id":  edit.EditorID,
+		"text":       edit.Text,
+		"metadata":   edit.Metadata,
+		"created_at": edit.CreatedAt,
+	}
+}
+
+func messageDetailPayload(detail service.MessageDetail) gin.H {
+	payload := messagePayload(detail.Message)
+	if detail.ReplyTo != nil {
+		payload["reply_to"] = gin.H{
+			"id":        detail.ReplyTo.ID,
+			"sender_id": detail.ReplyTo.SenderID,
+			"kind":      detail.ReplyTo.Kind,
+			"text":      detail.ReplyTo.Text,
+			"recalled":  detail.ReplyTo.Recalled,
+			"removed":   detail.ReplyTo.Removed,
+		}
+	}
+	payload["reactions"] = reactionsPayload(detail.Reactions)
+	mentions := detail.Mentions
+	if mentions == nil {
+		mentions = []string{}
+	}
+	payload["mentions"] = mentions
+	return payload
+}
+
+func reactionsPayload(reactions []service.ReactionSummary) []gin.H {
+	items := make([]gin.H, 0, len(reactions))
+	for _, reaction := range reactions {
+		items = append(items, gin.H{
+			"emoji":       reaction.Emoji,
+			"count":       reaction.Count,
+			"account_ids": reaction.AccountIDs,
+		})
+	}
+	return items
+}
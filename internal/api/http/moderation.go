@@ -0,0 +1,378 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"learn-go/internal/domain"
+	"learn-go/internal/repository"
+	"learn-go/internal/service"
+	"learn-go/pkg/response"
+)
+
+type createModerationRuleRequest struct {
+	SchoolID string `json:"school_id" validate:"required"`
+	Pattern  string `json:"pattern" validate:"required"`
+	IsRegex  bool   `json:"is_regex"`
+	Action   string `json:"action" validate:"required,oneof=block mask flag"`
+}
+
+type updateModerationRuleRequest struct {
+	Pattern string `json:"pattern" validate:"required"`
+	IsRegex bool   `json:"is_regex"`
+	Action  string `json:"action" validate:"required,oneof=block mask flag"`
+}
+
+type reportContentRequest struct {
+	Reason string `json:"reason"`
+}
+
+type resolveModerationCaseRequest struct {
+	Resolution string `json:"resolution"`
+}
+
+type removeModeratedContentRequest struct {
+	Reason string `json:"reason"`
+}
+
+// ListModerationRules returns the blocklist of the school in school_id.
+func (h *Handler) ListModerationRules(c *gin.Context) {
+	schoolID := strings.TrimSpace(c.Query("school_id"))
+	if schoolID == "" {
+		response.Error(c, http.StatusBadRequest, "school_id is required", nil)
+		return
+	}
+
+	rules, err := h.moderation.ListRules(c.Request.Context(), schoolID)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "unable to list moderation rules", err.Error())
+		return
+	}
+
+	items := make([]gin.H, 0, len(rules))
+	for _, rule := range rules {
+		items = append(items, moderationRulePayload(rule))
+	}
+	response.Success(c, http.StatusOK, gin.H{"rules": items})
+}
+
+// CreateModerationRule adds an entry to a school's blocklist.
+func (h *Handler) CreateModerationRule(c *gin.Context) {
+	var req createModerationRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "invalid request body", err.Error())
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		response.Error(c, http.StatusBadRequest, "validation error", err.Error())
+		return
+	}
+
+	rule, err := h.moderation.CreateRule(c.Request.Context(), req.SchoolID, getAccountID(c), service.ModerationRuleInput{
+		Pattern: req.Pattern,
+		IsRegex: req.IsRegex,
+		Action:  domain.ModerationAction(req.Action),
+	})
+	if err != nil {
+		respondModerationError(c, err, "unable to create moderation rule")
+		return
+	}
+
+	response.Success(c, http.StatusCreated, gin.H{"rule": moderationRulePayload(*rule)})
+}
+
+// UpdateModerationRule replaces the pattern and action of a rule.
+func (h *Handler) UpdateModerationRule(c *gin.Context) {
+	var req updateModerationRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "invalid request body", err.Error())
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		response.Error(c, http.StatusBadRequest, "validation error", err.Error())
+		return
+	}
+
+	rule, err := h.moderation.UpdateRule(c.Request.Context(), c.Param("id"), service.ModerationRuleInput{
+		Pattern: req.Pattern,
+		IsRegex: req.IsRegex,
+		Action:  domain.ModerationAction(req.Action),
+	})
+	if err != nil {
+		respondModerationError(c, err, "unable to update moderation rule")
+		return
+	}
+
+	response.Success(c, http.StatusOK, gin.H{"rule": moderationRulePayload(*rule)})
+}
+
+// DeleteModerationRule removes a rule from its school's blocklist.
+func (h *Handler) DeleteModerationRule(c *gin.Context) {
+	if err := h.moderation.DeleteRule(c.Request.Context(), c.Param("id")); err != nil {
+		respondModerationError(c, err, "unable to delete moderation rule")
+		return
+	}
+
+	response.Success(c, http.StatusOK, gin.H{"deleted": true})
+}
+
+// ListModerationCases returns a page of the review queue, oldest first.
+func (h *Handler) ListModerationCases(c *gin.Context) {
+	filter := repository.ModerationCaseFilter{
+		SchoolID:   strings.TrimSpace(c.Query("school_id")),
+		Status:     domain.ModerationCaseStatus(c.DefaultQuery("status", string(domain.ModerationCaseOpen))),
+		TargetType: domain.ModerationTarget(c.Query("target_type")),
+		Page:       1,
+		Size:       20,
+	}
+	if filter.SchoolID == "" {
+		response.Error(c, http.StatusBadRequest, "school_id is required", nil)
+		return
+	}
+	var err error
+	if filter.Page, err = positiveQuery(c, "page", filter.Page); err != nil {
+		response.Error(c, http.StatusBadRequest, "invalid page", c.Query("page"))
+		return
+	}
+	if filter.Size, err = positiveQuery(c, "size", filter.Size); err != nil {
+		response.Error(c, http.StatusBadRequest, "invalid size", c.Query("size"))
+		return
+	}
+	if filter.Size > 100 {
+		filter.Size = 100
+	}
+
+	cases, total, err := h.moderation.ListCases(c.Request.Context(), filter)
+	if err != nil {
+		respondModerationError(c, err, "unable to list moderation cases")
+		return
+	}
+
+	items := make([]gin.H, 0, len(cases))
+	for _, item := range cases {
+		items = append(items, moderationCasePayload(item))
+	}
+	response.Success(c, http.StatusOK, gin.H{
+		"cases": items,
+		"total": total,
+		"page":  filter.Page,
+		"size":  filter.Size,
+	})
+}
+
+// GetModerationCase returns a case with its content and, once removed, the
+// original content.
+func (h *Handler) GetModerationCase(c *gin.Context) {
+	detail, err := h.moderation.GetCase(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		respondModerationError(c, err, "unable to load moderation case")
+		return
+	}
+
+	response.Success(c, http.StatusOK, moderationCaseDetailPayload(*detail))
+}
+
+// ResolveModerationCase closes a case and keeps the content.
+func (h *Handler) ResolveModerationCase(c *gin.Context) {
+	var req resolveModerationCaseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "invalid request body", err.Error())
+		return
+	}
+
+	detail, err := h.moderation.ResolveCase(c.Request.Context(), getAccountID(c), c.Param("id"), req.Resolution)
+	if err != nil {
+		respondModerationError(c, err, "unable to resolve moderation case")
+		return
+	}
+
+	response.Success(c, http.StatusOK, moderationCaseDetailPayload(*detail))
+}
+
+// RemoveModeratedContent replaces the content of a case with a tombstone.
+// Members of a conversation whose message was removed are told.
+func (h *Handler) RemoveModeratedContent(c *gin.Context) {
+	var req removeModeratedContentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "invalid request body", err.Error())
+		return
+	}
+
+	detail, err := h.moderation.RemoveContent(c.Request.Context(), getAccountID(c), c.Param("id"), req.Reason)
+	if err != nil {
+		respondModerationError(c, err, "unable to remove content")
+		return
+	}
+
+	response.Success(c, http.StatusOK, moderationCaseDetailPayload(*detail))
+	if detail.Message != nil {
+		h.wsHub.Broadcast(detail.Message.ConversationID, "message.removed", messagePayload(*detail.Message))
+	}
+}
+
+// ReportMessage lets a member report a message of the conversation.
+func (h *Handler) ReportMessage(c *gin.Context) {
+	accountID := getAccountID(c)
+	if accountID == "" {
+		response.Error(c, http.StatusUnauthorized, "missing account context", nil)
+		return
+	}
+
+	var req reportContentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "invalid request body", err.Error())
+		return
+	}
+
+	report, err := h.moderation.ReportMessage(c.Request.Context(), accountID, c.Param("id"), c.Param("messageID"), req.Reason)
+	if err != nil {
+		respondModerationError(c, err, "unable to report message")
+		return
+	}
+
+	response.Success(c, http.StatusCreated, gin.H{"report": reportPayload(*report)})
+}
+
+// ReportNote lets an account report a note it can see.
+func (h *Handler) ReportNote(c *gin.Context) {
+	accountID := getAccountID(c)
+	if accountID == "" {
+		response.Error(c, http.StatusUnauthorized, "missing account context", nil)
+		return
+	}
+
+	var req reportContentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "invalid request body", err.Error())
+		return
+	}
+
+	report, err := h.moderation.ReportNote(c.Request.Context(), accountID, c.Param("id"), req.Reason)
+	if err != nil {
+		respondModerationError(c, err, "unable to report note")
+		return
+	}
+
+	response.Success(c, http.StatusCreated, gin.H{"report": reportPayload(*report)})
+}
+
+// ReportNoteComment lets an account report a comment on a note it can see.
+func (h *Handler) ReportNoteComment(c *gin.Context) {
+	accountID := getAccountID(c)
+	if accountID == "" {
+		response.Error(c, http.StatusUnauthorized, "missing account context", nil)
+		return
+	}
+
+	var req reportContentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "invalid request body", err.Error())
+		return
+	}
+
+	report, err := h.moderation.ReportNoteComment(c.Request.Context(), accountID, c.Param("id"), c.Param("commentID"), req.Reason)
+	if err != nil {
+		respondModerationError(c, err, "unable to report comment")
+		return
+	}
+
+	response.Success(c, http.StatusCreated, gin.H{"report": reportPayload(*report)})
+}
+
+func respondModerationError(c *gin.Context, err error, failure string) {
+	switch {
+	case errors.Is(err, service.ErrModerationInvalid):
+		response.Error(c, http.StatusBadRequest, "invalid moderation request", err.Error())
+	case errors.Is(err, service.ErrModerationRuleNotFound):
+		response.Error(c, http.StatusNotFound, "moderation rule not found", nil)
+	case errors.Is(err, service.ErrModerationCaseNotFound):
+		response.Error(c, http.StatusNotFound, "moderation case not found", nil)
+	case errors.Is(err, service.ErrReportTargetNotFound):
+		response.Error(c, http.StatusNotFound, "reported content not found", nil)
+	case errors.Is(err, service.ErrModerationCaseClosed):
+		response.Error(c, http.StatusConflict, "moderation case already closed", nil)
+	default:
+		response.Error(c, http.StatusInternalServerError, failure, err.Error())
+	}
+}
+
+// respondContentBlocked refuses content that matched a block rule without
+// telling which one.
+func respondContentBlocked(c *gin.Context) {
+	response.Error(c, http.StatusUnprocessableEntity, "content blocked by moderation", nil)
+}
+
+func moderationRulePayload(rule domain.ModerationRule) gin.H {
+	return gin.H{
+		"id":         rule.ID,
+		"school_id":  rule.SchoolID,
+		"pattern":    rule.Pattern,
+		"is_regex":   rule.IsRegex,
+		"action":     rule.Action,
+		"created_by": rule.CreatedBy,
+		"created_at": rule.CreatedAt,
+		"updated_at": rule.UpdatedAt,
+	}
+}
+
+// reportPayload is what a reporter sees of its report.
+func reportPayload(report domain.ModerationCase) gin.H {
+	return gin.H{
+		"id":          report.ID,
+		"target_type": report.TargetType,
+		"target_id":   report.TargetID,
+		"reason":      report.Reason,
+		"status":      report.Status,
+		"created_at":  report.CreatedAt,
+	}
+}
+
+func moderationCasePayload(item domain.ModerationCase) gin.H {
+	return gin.H{
+		"id":          item.ID,
+		"school_id":   item.SchoolID,
+		"target_type": item.TargetType,
+		"target_id":   item.TargetID,
+		"parent_id":   item.ParentID,
+		"author_id":   item.AuthorID,
+		"rule_id":     item.RuleID,
+		"reporter_id": item.ReporterID,
+		"reason":      item.Reason,
+		"status":      item.Status,
+		"resolved_by": item.ResolvedBy,
+		"resolution":  item.Resolution,
+		"resolved_at": item.ResolvedAt,
+		"created_at":  item.CreatedAt,
+	}
+}
+
+func moderationCaseDetailPayload(detail service.ModerationCaseDetail) gin.H {
+	payload := gin.H{"case": moderationCasePayload(detail.Case)}
+	switch {
+	case detail.Message != nil:
+		payload["message"] = messagePayload(*detail.Message)
+	case detail.Note != nil:
+		payload["note"] = notePayload(*detail.Note)
+	case detail.Comment != nil:
+		payload["comment"] = noteCommentPayload(*detail.Comment)
+	}
+	if detail.Removal != nil {
+		payload["removal"] = gin.H{
+			"id":         detail.Removal.ID,
+			"case_id":    detail.Removal.CaseID,
+			"author_id":  detail.Removal.AuthorID,
+			"title":      detail.Removal.Title,
+			"content":    detail.Removal.Content,
+			"media_uri":  detail.Removal.MediaURI,
+			"media_id":   detail.Removal.MediaID,
+			"metadata":   detail.Removal.Metadata,
+			"removed_by": detail.Removal.RemovedBy,
+			"reason":     detail.Removal.Reason,
+			"created_at": detail.Removal.CreatedAt,
+		}
+	}
+	return payload
+}
@@ -0,0 +1,248 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"learn-go/internal/domain"
+	"learn-go/internal/service"
+	"learn-go/pkg/response"
+)
+
+type markAllNotificationsReadRequest struct {
+	Category string `json:"category"`
+}
+
+type updateNotificationPreferencesRequest struct {
+	// Preferences maps a category to whether it is enabled.
+	Preferences map[string]bool `json:"preferences" validate:"required,min=1"`
+}
+
+// ListNotifications returns a page of the caller's inbox, newest first, with
+// the unread counts.
+func (h *Handler) ListNotifications(c *gin.Context) {
+	accountID := getAccountID(c)
+	if accountID == "" {
+		response.Error(c, http.StatusUnauthorized, "missing account context", nil)
+		return
+	}
+
+	input := service.ListNotificationsInput{
+		Category:   domain.NotificationCategory(c.Query("category")),
+		UnreadOnly: c.Query("unread") == "true",
+		Page:       1,
+		Size:       20,
+	}
+	var err error
+	if input.Page, err = positiveQuery(c, "page", input.Page); err != nil {
+		response.Error(c, http.StatusBadRequest, "invalid page", c.Query("page"))
+		return
+	}
+	if input.Size, err = positiveQuery(c, "size", input.Size); err != nil {
+		response.Error(c, http.StatusBadRequest, "invalid size", c.Query("size"))
+		return
+	}
+	if input.Size > 100 {
+		input.Size = 100
+	}
+
+	page, err := h.notifications.ListNotifications(c.Request.Context(), accountID, input)
+	if err != nil {
+		respondNotificationError(c, err, "unable to list notifications")
+		return
+	}
+
+	items := make([]gin.H, 0, len(page.Notifications))
+	for _, n := range page.Notifications {
+		items = append(items, notificationPayload(n))
+	}
+	response.Success(c, http.StatusOK, gin.H{
+		"notifications": items,
+		"total":         page.Total,
+		"page":          input.Page,
+		"size":          input.Size,
+		"unread":        unreadNotificationsPayload(page.Unread),
+	})
+}
+
+// GetUnreadNotificationCount returns the caller's unread counts, in all and
+// per category.
+func (h *Handler) GetUnreadNotificationCount(c *gin.Context) {
+	accountID := getAccountID(c)
+	if accountID == "" {
+		response.Error(c, http.StatusUnauthorized, "missing account context", nil)
+		return
+	}
+
+	unread, err := h.notifications.UnreadCount(c.Request.Context(), accountID)
+	if err != nil {
+		respondNotificationError(c, err, "unable to count notifications")
+		return
+	}
+	response.Success(c, http.StatusOK, gin.H{"unread": unreadNotificationsPayload(*unread)})
+}
+
+// MarkNotificationRead marks one of the caller's notifications read.
+func (h *Handler) MarkNotificationRead(c *gin.Context) {
+	h.setNotificationRead(c, true)
+}
+
+// MarkNotificationUnread marks one of the caller's notifications unread again.
+func (h *Handler) MarkNotificationUnread(c *gin.Context) {
+	h.setNotificationRead(c, false)
+}
+
+func (h *Handler) setNotificationRead(c *gin.Context, read bool) {
+	accountID := getAccountID(c)
+	if accountID == "" {
+		response.Error(c, http.StatusUnauthorized, "missing account context", nil)
+		return
+	}
+
+	ids := []string{c.Param("id")}
+	var err error
+	if read {
+		_, err = h.notifications.MarkRead(c.Request.Context(), accountID, ids)
+	} else {
+		_, err = h.notifications.MarkUnread(c.Request.Context(), accountID, ids)
+	}
+	if err != nil {
+		respondNotificationError(c, err, "unable to update notification")
+		return
+	}
+
+	h.respondNotificationsRead(c, accountID, gin.H{"ids": ids, "read": read})
+}
+
+// MarkAllNotificationsRead marks every unread notification of the caller
+// read, only those of the category when one is given.
+func (h *Handler) MarkAllNotificationsRead(c *gin.Context) {
+	accountID := getAccountID(c)
+	if accountID == "" {
+		response.Error(c, http.StatusUnauthorized, "missing account context", nil)
+		return
+	}
+
+	var req markAllNotificationsReadRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			response.Error(c, http.StatusBadRequest, "invalid request body", err.Error())
+			return
+		}
+	}
+
+	category := domain.NotificationCategory(req.Category)
+	if _, err := h.notifications.MarkAllRead(c.Request.Context(), accountID, category); err != nil {
+		respondNotificationError(c, err, "unable to update notifications")
+		return
+	}
+
+	h.respondNotificationsRead(c, accountID, gin.H{"all": true, "category": category, "read": true})
+}
+
+// respondNotificationsRead answers a read state change with the new unread
+// counts and tells the caller's other connections about it.
+func (h *Handler) respondNotificationsRead(c *gin.Context, accountID string, change gin.H) {
+	unread, err := h.notifications.UnreadCount(c.Request.Context(), accountID)
+	if err != nil {
+		respondNotificationError(c, err, "unable to count notifications")
+		return
+	}
+	change["unread"] = unreadNotificationsPayload(*unread)
+	h.wsHub.Notify(accountID, "", "notification.read", change)
+	response.Success(c, http.StatusOK, change)
+}
+
+// GetNotificationPreferences returns the caller's setting for every category.
+func (h *Handler) GetNotificationPreferences(c *gin.Context) {
+	accountID := getAccountID(c)
+	if accountID == "" {
+		response.Error(c, http.StatusUnauthorized, "missing account context", nil)
+		return
+	}
+
+	prefs, err := h.notifications.Preferences(c.Request.Context(), accountID)
+	if err != nil {
+		respondNotificationError(c, err, "unable to load notification preferences")
+		return
+	}
+	response.Success(c, http.StatusOK, gin.H{"preferences": notificationPreferencesPayload(prefs)})
+}
+
+// UpdateNotificationPreferences turns categories on or off for the caller.
+func (h *Handler) UpdateNotificationPreferences(c *gin.Context) {
+	accountID := getAccountID(c)
+	if accountID == "" {
+		response.Error(c, http.StatusUnauthorized, "missing account context", nil)
+		return
+	}
+
+	var req updateNotificationPreferencesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "invalid request body", err.Error())
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		response.Error(c, http.StatusBadRequest, "validation error", err.Error())
+		return
+	}
+
+	enabled := make(map[domain.NotificationCategory]bool, len(req.Preferences))
+	for category, value := range req.Preferences {
+		enabled[domain.NotificationCategory(category)] = value
+	}
+	prefs, err := h.notifications.UpdatePreferences(c.Request.Context(), accountID, enabled)
+	if err != nil {
+		respondNotificationError(c, err, "unable to update notification preferences")
+		return
+	}
+	response.Success(c, http.StatusOK, gin.H{"preferences": notificationPreferencesPayload(prefs)})
+}
+
+// DeliverNotifications pushes newly stored notifications to the recipients
+// that are connected; the others find them in their inbox.
+func (h *Handler) DeliverNotifications(notifications []domain.Notification) {
+	for _, n := range notifications {
+		h.wsHub.Notify(n.AccountID, "", "notification.created", notificationPayload(n))
+	}
+}
+
+func respondNotificationError(c *gin.Context, err error, failure string) {
+	switch {
+	case errors.Is(err, service.ErrNotificationInvalid):
+		response.Error(c, http.StatusBadRequest, "invalid notification request", err.Error())
+	default:
+		response.Error(c, http.StatusInternalServerError, failure, err.Error())
+	}
+}
+
+func notificationPayload(n domain.Notification) gin.H {
+	return gin.H{
+		"id":           n.ID,
+		"category":     n.Category,
+		"title":        n.Title,
+		"body":         n.Body,
+		"subject_type": n.SubjectType,
+		"subject_id":   n.SubjectID,
+		"actor_id":     n.ActorID,
+		"count":        n.Count,
+		"read":         n.ReadAt != nil,
+		"read_at":      n.ReadAt,
+		"created_at":   n.CreatedAt,
+	}
+}
+
+func unreadNotificationsPayload(unread service.UnreadNotifications) gin.H {
+	return gin.H{"total": unread.Total, "by_category": unread.ByCategory}
+}
+
+// notificationPreferencesPayload maps each category to whether it is enabled.
+func notificationPreferencesPayload(prefs []domain.NotificationPreference) gin.H {
+	payload := make(gin.H, len(prefs))
+	for _, pref := range prefs {
+		payload[string(pref.Category)] = pref.Enabled
+	}
+	return payload
+}
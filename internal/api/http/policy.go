@@ -0,0 +1,150 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"learn-go/internal/domain"
+	"learn-go/internal/service"
+	"learn-go/pkg/response"
+)
+
+type createParentRequest struct {
+	SchoolID   string   `json:"school_id" validate:"required"`
+	Phone      string   `json:"phone" validate:"required"`
+	Name       string   `json:"name" validate:"required"`
+	StudentIDs []string `json:"student_ids" validate:"required,min=1,dive,required"`
+	DefaultPwd string   `json:"default_password" validate:"required"`
+}
+
+type bindParentStudentsRequest struct {
+	StudentIDs []string `json:"student_ids" validate:"required,min=1,dive,required"`
+}
+
+type updateMessagingPolicyRequest struct {
+	SchoolID         string `json:"school_id" validate:"required"`
+	RestrictStudents bool   `json:"restrict_students"`
+	RestrictParents  bool   `json:"restrict_parents"`
+	QuietHoursStart  string `json:"quiet_hours_start"`
+	QuietHoursEnd    string `json:"quiet_hours_end"`
+	TimeZone         string `json:"time_zone"`
+}
+
+// CreateParent creates a parent account bound to its children.
+func (h *Handler) CreateParent(c *gin.Context) {
+	var req createParentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "invalid request body", err.Error())
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		response.Error(c, http.StatusBadRequest, "validation error", err.Error())
+		return
+	}
+
+	parent, err := h.admin.CreateParent(c.Request.Context(), service.CreateParentInput{
+		SchoolID:   req.SchoolID,
+		Phone:      req.Phone,
+		Name:       req.Name,
+		DefaultPwd: req.DefaultPwd,
+		StudentIDs: req.StudentIDs,
+	})
+	if err != nil {
+		respondClassError(c, err, "unable to create parent")
+		return
+	}
+
+	response.Success(c, http.StatusCreated, gin.H{"parent_id": parent.ID, "student_ids": req.StudentIDs})
+}
+
+// BindParentStudents replaces the children of a parent.
+func (h *Handler) BindParentStudents(c *gin.Context) {
+	var req bindParentStudentsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "invalid request body", err.Error())
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		response.Error(c, http.StatusBadRequest, "validation error", err.Error())
+		return
+	}
+
+	if err := h.admin.BindParentStudents(c.Request.Context(), c.Param("id"), req.StudentIDs); err != nil {
+		if errors.Is(err, service.ErrParentNotFound) {
+			response.Error(c, http.StatusNotFound, "parent not found", nil)
+			return
+		}
+		respondClassError(c, err, "unable to bind students")
+		return
+	}
+
+	response.Success(c, http.StatusOK, gin.H{"parent_id": c.Param("id"), "student_ids": req.StudentIDs})
+}
+
+// GetMessagingPolicy returns the messaging policy of the school in school_id.
+func (h *Handler) GetMessagingPolicy(c *gin.Context) {
+	schoolID := strings.TrimSpace(c.Query("school_id"))
+	if schoolID == "" {
+		response.Error(c, http.StatusBadRequest, "school_id is required", nil)
+		return
+	}
+
+	policy, err := h.policies.Get(c.Request.Context(), schoolID)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "unable to load messaging policy", err.Error())
+		return
+	}
+
+	response.Success(c, http.StatusOK, gin.H{"policy": messagingPolicyPayload(*policy)})
+}
+
+// UpdateMessagingPolicy replaces a school's messaging policy.
+func (h *Handler) UpdateMessagingPolicy(c *gin.Context) {
+	var req updateMessagingPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Error(c, http.StatusBadRequest, "invalid request body", err.Error())
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
+		response.Error(c, http.StatusBadRequest, "validation error", err.Error())
+		return
+	}
+
+	policy, err := h.policies.Update(c.Request.Context(), req.SchoolID, service.MessagingPolicyInput{
+		RestrictStudents: req.RestrictStudents,
+		RestrictParents:  req.RestrictParents,
+		QuietHoursStart:  strings.TrimSpace(req.QuietHoursStart),
+		QuietHoursEnd:    strings.TrimSpace(req.QuietHoursEnd),
+		TimeZone:         strings.TrimSpace(req.TimeZone),
+	})
+	if err != nil {
+		if errors.Is(err, service.ErrMessagingPolicyInvalid) {
+			response.Error(c, http.StatusBadRequest, "invalid messaging policy", err.Error())
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, "unable to update messaging policy", err.Error())
+		return
+	}
+
+	response.Success(c, http.StatusOK, gin.H{"policy": messagingPolicyPayload(*policy)})
+}
+
+// respondPolicyViolation tells the caller which messaging rule refused it.
+func respondPolicyViolation(c *gin.Context, violation *service.PolicyViolation) {
+	response.Error(c, http.StatusForbidden, "messaging policy violation", gin.H{"rule": violation.Rule, "reason": violation.Reason})
+}
+
+func messagingPolicyPayload(policy domain.MessagingPolicy) gin.H {
+	return gin.H{
+		"school_id":         policy.SchoolID,
+		"restrict_students": policy.RestrictStudents,
+		"restrict_parents":  policy.RestrictParents,
+		"quiet_hours_start": policy.QuietHoursStart,
+		"quiet_hours_end":   policy.QuietHoursEnd,
+		"time_zone":         policy.TimeZone,
+		"updated_at":        policy.UpdatedAt,
+	}
+}
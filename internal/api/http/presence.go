@@ -0,0 +1,235 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"learn-go/internal/api/ws"
+	"learn-go/internal/service"
+	"learn-go/pkg/response"
+)
+
+const (
+	// typingInterval is the least time between two typing.start events of an
+	// account in a conversation; more frequent ones are dropped.
+	typingInterval = 3 * time.Second
+	// typingTTL tells clients how long a typing.start holds without renewal.
+	typingTTL = 6 * time.Second
+	// presenceHookTimeout bounds storing one presence change.
+	presenceHookTimeout = 5 * time.Second
+)
+
+// typingLimiter remembers when each account last announced typing in a
+// conversation. Typing state is never persisted.
+type typingLimiter struct {
+	mu      sync.Mutex
+	entries map[string]*typingEntry
+}
+
+// typingEntry is a typing.start. Client frames are handled concurrently, so
+// a typing.stop may arrive while its start is still being checked; it is
+// then sent right after the start.
+type typingEntry struct {
+	at      time.Time
+	sent    bool
+	stopped bool
+}
+
+func newTypingLimiter() *typingLimiter {
+	return &typingLimiter{entries: make(map[string]*typingEntry)}
+}
+
+// start reports whether a typing.start may be sent now.
+func (l *typingLimiter) start(key string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if entry, ok := l.entries[key]; ok && now.Sub(entry.at) < typingInterval {
+		return false
+	}
+	if len(l.entries) > 4096 {
+		for k, entry := range l.entries {
+			if now.Sub(entry.at) > typingTTL {
+				delete(l.entries, k)
+			}
+		}
+	}
+	l.entries[key] = &typingEntry{at: now}
+	return true
+}
+
+// started records that the typing.start went out, reporting whether a
+// typing.stop came in meanwhile and should follow.
+func (l *typingLimiter) started(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry, ok := l.entries[key]
+	if !ok {
+		return false
+	}
+	if entry.stopped {
+		delete(l.entries, key)
+		return true
+	}
+	entry.sent = true
+	return false
+}
+
+// cancel forgets a typing.start that was rejected.
+func (l *typingLimiter) cancel(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.entries, key)
+}
+
+// stop reports whether a typing.stop should be sent now for a typing.start
+// that went out and has not expired yet.
+func (l *typingLimiter) stop(key string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry, ok := l.entries[key]
+	if !ok {
+		return false
+	}
+	if !entry.sent {
+		entry.stopped = true
+		return false
+	}
+	delete(l.entries, key)
+	return now.Sub(entry.at) <= typingTTL
+}
+
+// GetPresence returns the presence of the accounts in account_ids, a comma
+// separated list of the caller's contacts.
+func (h *Handler) GetPresence(c *gin.Context) {
+	accountID := getAccountID(c)
+	if accountID == "" {
+		response.Error(c, http.StatusUnauthorized, "missing account context", nil)
+		return
+	}
+
+	var ids []string
+	for _, id := range strings.Split(c.Query("account_ids"), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+
+	presence, err := h.presence.Query(c.Request.Context(), accountID, ids)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrPresenceForbidden):
+			response.Error(c, http.StatusForbidden, "not allowed to view presence", nil)
+		case errors.Is(err, service.ErrConversationInvalid):
+			response.Error(c, http.StatusBadRequest, "invalid presence query", err.Error())
+		default:
+			response.Error(c, http.StatusInternalServerError, "unable to load presence", err.Error())
+		}
+		return
+	}
+
+	items := make([]gin.H, 0, len(presence))
+	for _, p := range presence {
+		items = append(items, presencePayload(p))
+	}
+	response.Success(c, http.StatusOK, gin.H{"presence": items})
+}
+
+// PresenceChanged stores the connections this instance holds for an account
+// and tells its contacts when its status changed. The hub calls it.
+func (h *Handler) PresenceChanged(accountID string, connections, active int) {
+	ctx, cancel := context.WithTimeout(context.Background(), presenceHookTimeout)
+	defer cancel()
+
+	presence, changed, err := h.presence.Update(ctx, h.wsHub.InstanceID(), accountID, connections, active)
+	if err != nil || !changed {
+		return
+	}
+	h.BroadcastPresence(ctx, *presence)
+}
+
+// BroadcastPresence sends presence.updated to the account's contacts and to
+// its own account streams.
+func (h *Handler) BroadcastPresence(ctx context.Context, presence service.Presence) {
+	audience, err := h.presence.Audience(ctx, presence.AccountID)
+	if err != nil {
+		return
+	}
+	h.wsHub.NotifyAccounts(audience, "presence.updated", presencePayload(presence))
+}
+
+// handlePresenceEvent lets a client report its user away, e.g. while its
+// window is hidden, or back online.
+func (h *Handler) handlePresenceEvent(client *ws.Client, raw json.RawMessage) {
+	var payload struct {
+		Status string `json:"status"`
+	}
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		_ = client.SendJSON(gin.H{"type": "error", "data": gin.H{"message": "invalid presence.set data"}})
+		return
+	}
+	switch service.PresenceStatus(payload.Status) {
+	case service.PresenceOnline:
+		h.wsHub.SetAway(client, false)
+	case service.PresenceAway:
+		h.wsHub.SetAway(client, true)
+	default:
+		_ = client.SendJSON(gin.H{"type": "error", "data": gin.H{"message": "status must be online or away"}})
+	}
+}
+
+// handleTypingEvent relays typing.start and typing.stop to the conversation.
+// Starts are rate limited per account and conversation; a stop is relayed
+// only after a start.
+func (h *Handler) handleTypingEvent(ctx context.Context, client *ws.Client, accountID, conversationID, event string) {
+	key := accountID + "/" + conversationID
+	now := time.Now()
+	if event == "typing.stop" {
+		if h.typing.stop(key, now) {
+			h.broadcastTypingStop(accountID, conversationID)
+		}
+		return
+	}
+
+	if !h.typing.start(key, now) {
+		return
+	}
+	ok, err := h.conversations.IsMember(ctx, accountID, conversationID)
+	if err != nil || !ok {
+		h.typing.cancel(key)
+		_ = client.SendJSON(gin.H{"type": "error", "data": gin.H{"message": "not allowed to type in conversation"}})
+		return
+	}
+	h.wsHub.Broadcast(conversationID, event, gin.H{
+		"conversation_id": conversationID,
+		"account_id":      accountID,
+		"expires_in":      int(typingTTL / time.Second),
+	})
+	if h.typing.started(key) {
+		h.broadcastTypingStop(accountID, conversationID)
+	}
+}
+
+func (h *Handler) broadcastTypingStop(accountID, conversationID string) {
+	h.wsHub.Broadcast(conversationID, "typing.stop", gin.H{
+		"conversation_id": conversationID,
+		"account_id":      accountID,
+	})
+}
+
+func presencePayload(p service.Presence) gin.H {
+	return gin.H{
+		"account_id":   p.AccountID,
+		"status":       p.Status,
+		"last_seen_at": p.LastSeenAt,
+	}
+}
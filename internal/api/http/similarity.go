@@ -0,0 +1,136 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"learn-go/internal/domain"
+	"learn-go/internal/service"
+	"learn-go/pkg/response"
+	"learn-go/pkg/similarity"
+)
+
+func (h *Handler) GetSimilarityReport(c *gin.Context) {
+	accountID := getAccountID(c)
+	if accountID == "" {
+		response.Error(c, http.StatusUnauthorized, "missing account context", nil)
+		return
+	}
+
+	assignmentID := strings.TrimSpace(c.Param("id"))
+	if assignmentID == "" {
+		response.Error(c, http.StatusBadRequest, "missing assignment id", nil)
+		return
+	}
+
+	var minScore float64
+	if raw := strings.TrimSpace(c.Query("min_score")); raw != "" {
+		parsed, err := strconv.ParseFloat(raw, 64)
+		if err != nil || parsed < 0 || parsed > 1 {
+			response.Error(c, http.StatusBadRequest, "min_score must be between 0 and 1", nil)
+			return
+		}
+		minScore = parsed
+	}
+
+	report, err := h.similarity.GetSimilarityReport(c.Request.Context(), accountID, getRole(c), assignmentID, minScore)
+	if err != nil {
+		respondAssignmentLifecycleError(c, err, "unable to load similarity report")
+		return
+	}
+
+	matches := make([]gin.H, 0, len(report.Matches))
+	for _, match := range report.Matches {
+		matches = append(matches, similarityMatchPayload(match))
+	}
+	response.Success(c, http.StatusOK, gin.H{
+		"assignment_id": report.AssignmentID,
+		"threshold":     report.Threshold,
+		"pending":       report.Pending,
+		"matches":       matches,
+	})
+}
+
+func (h *Handler) GetSimilarityMatch(c *gin.Context) {
+	accountID := getAccountID(c)
+	if accountID == "" {
+		response.Error(c, http.StatusUnauthorized, "missing account context", nil)
+		return
+	}
+
+	assignmentID := strings.TrimSpace(c.Param("id"))
+	matchID := strings.TrimSpace(c.Param("matchID"))
+	if assignmentID == "" || matchID == "" {
+		response.Error(c, http.StatusBadRequest, "missing assignment or match id", nil)
+		return
+	}
+
+	detail, err := h.similarity.GetSimilarityMatch(c.Request.Context(), accountID, getRole(c), assignmentID, matchID)
+	if err != nil {
+		if errors.Is(err, service.ErrSimilarityMatchNotFound) {
+			response.Error(c, http.StatusNotFound, "similarity match not found", nil)
+			return
+		}
+		respondAssignmentLifecycleError(c, err, "unable to load similarity match")
+		return
+	}
+
+	payload := similarityMatchPayload(detail.Match)
+	if detail.Question != nil {
+		payload["question"] = assignmentQuestionPayload(*detail.Question)
+	}
+	payload["answer_a"] = similarityAnswerPayload(detail.AnswerA, detail.SpansA)
+	payload["answer_b"] = similarityAnswerPayload(detail.AnswerB, detail.SpansB)
+	response.Success(c, http.StatusOK, gin.H{"match": payload})
+}
+
+func (h *Handler) RescanSimilarity(c *gin.Context) {
+	accountID := getAccountID(c)
+	if accountID == "" {
+		response.Error(c, http.StatusUnauthorized, "missing account context", nil)
+		return
+	}
+
+	assignmentID := strings.TrimSpace(c.Param("id"))
+	if assignmentID == "" {
+		response.Error(c, http.StatusBadRequest, "missing assignment id", nil)
+		return
+	}
+
+	pending, err := h.similarity.RescanAssignment(c.Request.Context(), accountID, getRole(c), assignmentID)
+	if err != nil {
+		respondAssignmentLifecycleError(c, err, "unable to rescan similarity")
+		return
+	}
+
+	response.Success(c, http.StatusAccepted, gin.H{"assignment_id": assignmentID, "pending": pending})
+}
+
+func similarityMatchPayload(match domain.SimilarityMatch) gin.H {
+	return gin.H{
+		"id":              match.ID,
+		"question_id":     match.QuestionID,
+		"score":           match.Score,
+		"submission_a_id": match.SubmissionAID,
+		"submission_b_id": match.SubmissionBID,
+		"student_a_id":    match.StudentAID,
+		"student_b_id":    match.StudentBID,
+		"created_at":      match.CreatedAt,
+	}
+}
+
+func similarityAnswerPayload(answer string, spans []similarity.Span) gin.H {
+	highlights := make([]gin.H, 0, len(spans))
+	for _, span := range spans {
+		highlights = append(highlights, gin.H{
+			"start": span.Start,
+			"end":   span.End,
+			"text":  similarity.Excerpt(answer, span),
+		})
+	}
+	return gin.H{"text": answer, "highlights": highlights}
+}
@@ -0,0 +1,241 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"learn-go/internal/api/ws"
+	"learn-go/internal/service"
+	"learn-go/pkg/response"
+)
+
+// WebsocketStats reports the connection and flow control counters of this
+// instance's websocket hub.
+func (h *Handler) WebsocketStats(c *gin.Context) {
+	response.Success(c, http.StatusOK, gin.H{"instance_id": h.wsHub.InstanceID(), "stats": h.wsHub.Stats()})
+}
+
+// AccountStream upgrades to a websocket carrying every conversation of the
+// account. The client starts subscribed to all of them and receives a
+// session.snapshot with the first page of the conversation list and the
+// unread notification counts.
+func (h *Handler) AccountStream(c *gin.Context) {
+	accountID := getAccountID(c)
+	if accountID == "" {
+		response.Error(c, http.StatusUnauthorized, "missing account context", nil)
+		return
+	}
+
+	ids, err := h.conversations.ConversationIDs(c.Request.Context(), accountID)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "unable to load conversations", err.Error())
+		return
+	}
+	page, err := h.conversations.ListConversations(c.Request.Context(), accountID, service.ListConversationsInput{})
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "unable to load conversations", err.Error())
+		return
+	}
+	unread, err := h.notifications.UnreadCount(c.Request.Context(), accountID)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "unable to count notifications", err.Error())
+		return
+	}
+
+	conn, err := wsUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "websocket upgrade failed", err.Error())
+		return
+	}
+
+	items := make([]gin.H, 0, len(page.Conversations))
+	for _, summary := range page.Conversations {
+		items = append(items, conversationPayload(summary))
+	}
+
+	_ = conn.SetWriteDeadline(time.Now().Add(conversationStreamWriteTimeout))
+	if err := conn.WriteJSON(gin.H{
+		"type": "session.snapshot",
+		"data": gin.H{"conversations": items, "next_cursor": page.NextCursor, "notifications": unreadNotificationsPayload(*unread)},
+	}); err != nil {
+		_ = conn.Close()
+		return
+	}
+	_ = conn.SetWriteDeadline(time.Time{})
+
+	client := ws.NewAccountClient(h.wsHub, conn, accountID, ids, h.handleAccountSocketMessage)
+	client.Run()
+}
+
+func (h *Handler) handleAccountSocketMessage(ctx context.Context, client *ws.Client, payload []byte) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var envelope struct {
+		Type string          `json:"type"`
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(payload, &envelope); err != nil {
+		_ = client.SendJSON(gin.H{"type": "error", "data": gin.H{"message": "invalid payload"}})
+		return
+	}
+
+	switch envelope.Type {
+	case "subscribe", "unsubscribe":
+		h.handleSubscriptionEvent(ctx, client, envelope.Type, envelope.Data)
+	case "resume":
+		h.handleResumeEvent(ctx, client, envelope.Data)
+	case "presence.set":
+		h.handlePresenceEvent(client, envelope.Data)
+	default:
+		var target struct {
+			ConversationID string `json:"conversation_id"`
+		}
+		if err := json.Unmarshal(envelope.Data, &target); err != nil || target.ConversationID == "" {
+			_ = client.SendJSON(gin.H{"type": "error", "data": gin.H{"message": "conversation_id required"}})
+			return
+		}
+		h.dispatchConversationEvent(ctx, client, client.AccountID(), target.ConversationID, envelope.Type, envelope.Data)
+	}
+}
+
+// handleSubscriptionEvent changes which conversations an account stream
+// receives events for. Subscribing with no ids subscribes to all of the
+// account's conversations; ids the account is not a member of are rejected.
+func (h *Handler) handleSubscriptionEvent(ctx context.Context, client *ws.Client, event string, raw json.RawMessage) {
+	var payload struct {
+		ConversationIDs []string `json:"conversation_ids"`
+	}
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &payload); err != nil {
+			_ = client.SendJSON(gin.H{"type": "error", "data": gin.H{"message": "invalid " + event + " data"}})
+			return
+		}
+	}
+
+	if payload.ConversationIDs == nil {
+		payload.ConversationIDs = []string{}
+	}
+	if event == "unsubscribe" {
+		h.wsHub.Unsubscribe(client, payload.ConversationIDs...)
+		_ = client.SendJSON(gin.H{"type": "unsubscribed", "data": gin.H{"conversation_ids": payload.ConversationIDs}})
+		return
+	}
+
+	memberOf, err := h.conversations.ConversationIDs(ctx, client.AccountID())
+	if err != nil {
+		_ = client.SendJSON(gin.H{"type": "error", "data": gin.H{"message": "unable to load conversations"}})
+		return
+	}
+	accepted := memberOf
+	rejected := make([]string, 0)
+	if len(payload.ConversationIDs) > 0 {
+		isMember := make(map[string]bool, len(memberOf))
+		for _, id := range memberOf {
+			isMember[id] = true
+		}
+		accepted = make([]string, 0, len(payload.ConversationIDs))
+		for _, id := range payload.ConversationIDs {
+			if isMember[id] {
+				accepted = append(accepted, id)
+			} else {
+				rejected = append(rejected, id)
+			}
+		}
+	}
+	h.wsHub.Subscribe(client, accepted...)
+	_ = client.SendJSON(gin.H{"type": "subscribed", "data": gin.H{
+		"conversation_ids": accepted,
+		"rejected":         rejected,
+	}})
+}
+
+// handleResumeEvent catches an account stream up after a reconnect. For each
+// conversation the client sends the last seq it saw; it is subscribed and
+// receives a conversation.resume with the missed messages before any live
+// event of that conversation.
+func (h *Handler) handleResumeEvent(ctx context.Context, client *ws.Client, raw json.RawMessage) {
+	var payload struct {
+		Conversations []struct {
+			ConversationID string `json:"conversation_id"`
+			LastSeq        int64  `json:"last_seq"`
+		} `json:"conversations"`
+	}
+	if err := json.Unmarshal(raw, &payload); err != nil || len(payload.Conversations) == 0 {
+		_ = client.SendJSON(gin.H{"type": "error", "data": gin.H{"message": "invalid resume data"}})
+		return
+	}
+
+	memberOf, err := h.conversations.ConversationIDs(ctx, client.AccountID())
+	if err != nil {
+		_ = client.SendJSON(gin.H{"type": "error", "data": gin.H{"message": "unable to load conversations"}})
+		return
+	}
+	isMember := make(map[string]bool, len(memberOf))
+	for _, id := range memberOf {
+		isMember[id] = true
+	}
+
+	for _, item := range payload.Conversations {
+		if !isMember[item.ConversationID] {
+			_ = client.SendJSON(gin.H{"type": "error", "data": gin.H{
+				"message":         "not allowed to resume conversation",
+				"conversation_id": item.ConversationID,
+			}})
+			continue
+		}
+		h.wsHub.Hold(client, item.ConversationID)
+		h.wsHub.Subscribe(client, item.ConversationID)
+		h.resumeConversation(ctx, client, item.ConversationID, item.LastSeq)
+	}
+}
+
+// resumeConversation sends the messages after lastSeq as a
+// conversation.resume frame and releases the live events held for the
+// client meanwhile, dropping those the gap already contains. The caller
+// holds the conversation for the client beforehand.
+func (h *Handler) resumeConversation(ctx context.Context, client *ws.Client, conversationID string, lastSeq int64) {
+	gap, err := h.conversations.MessagesAfter(ctx, client.AccountID(), conversationID, lastSeq, 0)
+	if err != nil {
+		h.wsHub.Release(client, conversationID, nil, 0)
+		_ = client.SendJSON(gin.H{"type": "error", "data": gin.H{
+			"message":         "unable to resume conversation",
+			"conversation_id": conversationID,
+		}})
+		return
+	}
+
+	payload, err := h.gapPayload(ctx, gap)
+	var frame []byte
+	if err == nil {
+		frame, err = json.Marshal(ws.Event{Type: "conversation.resume", ConversationID: conversationID, Data: payload})
+	}
+	if err != nil {
+		h.wsHub.Release(client, conversationID, nil, 0)
+		return
+	}
+	h.wsHub.Release(client, conversationID, [][]byte{frame}, gap.LastSeq)
+}
+
+// gapPayload renders the messages a client missed. When complete is false
+// the client requests the rest with after_seq set to last_seq.
+func (h *Handler) gapPayload(ctx context.Context, gap *service.MessageGap) (gin.H, error) {
+	details, err := h.conversations.DescribeMessages(ctx, gap.Messages)
+	if err != nil {
+		return nil, err
+	}
+	messages := make([]gin.H, 0, len(details))
+	for _, detail := range details {
+		messages = append(messages, messageDetailPayload(detail))
+	}
+	return gin.H{
+		"conversation_id": gap.ConversationID,
+		"messages":        messages,
+		"last_seq":        gap.LastSeq,
+		"complete":        gap.Complete,
+	}, nil
+}
@@ -0,0 +1,59 @@
+package ws
+
+import (
+	"context"
+	"sync"
+)
+
+// Backplane carries hub deliveries between server instances so a client
+// receives events no matter which replica produced them.
+type Backplane interface {
+	// Publish sends an encoded delivery to every subscribed hub, including
+	// ones in this process.
+	Publish(ctx context.Context, data []byte) error
+	// Subscribe registers a receiver for deliveries from any instance.
+	Subscribe(receive func(data []byte))
+	// Close stops receiving deliveries.
+	Close() error
+}
+
+// MemoryBackplane connects the hubs of a single process. It suits a single
+// replica deployment and tests running several hubs side by side.
+type MemoryBackplane struct {
+	mu        sync.RWMutex
+	receivers []func([]byte)
+}
+
+// NewMemoryBackplane constructs an in-process backplane.
+func NewMemoryBackplane() *MemoryBackplane {
+	return &MemoryBackplane{}
+}
+
+// Publish hands the delivery to every receiver synchronously.
+func (b *MemoryBackplane) Publish(_ context.Context, data []byte) error {
+	b.mu.RLock()
+	receivers := b.receivers
+	b.mu.RUnlock()
+
+	for _, receive := range receivers {
+		receive(data)
+	}
+	return nil
+}
+
+// Subscribe registers a receiver.
+func (b *MemoryBackplane) Subscribe(receive func(data []byte)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.receivers = append(b.receivers, receive)
+}
+
+// Close drops all receivers.
+func (b *MemoryBackplane) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.receivers = nil
+	return nil
+}
+
+var _ Backplane = (*MemoryBackplane)(nil)
@@ -0,0 +1,216 @@
+package ws
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"learn-go/pkg/logger"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// notifyChunkSize keeps each NOTIFY payload, base64 encoded with its
+	// header, below PostgreSQL's 8000 byte limit.
+	notifyChunkSize = 5000
+	// chunkTTL drops deliveries whose remaining chunks never arrived.
+	chunkTTL = time.Minute
+	// listenRetryDelay is the pause before reconnecting a lost listener.
+	listenRetryDelay = 2 * time.Second
+)
+
+var channelPattern = regexp.MustCompile(`^[a-z_][a-z0-9_]{0,62}$`)
+
+// PostgresBackplane fans deliveries out through PostgreSQL LISTEN/NOTIFY.
+// Deliveries larger than a NOTIFY payload are split into chunks sent in one
+// transaction, which PostgreSQL delivers together and in order. Delivery is
+// best effort: notifications sent while a listener reconnects are lost.
+type PostgresBackplane struct {
+	db      *gorm.DB
+	dsn     string
+	channel string
+	log     *logger.Logger
+
+	mu        sync.Mutex
+	receivers []func([]byte)
+	cancel    context.CancelFunc
+	done      chan struct{}
+}
+
+// notifyChunk is one NOTIFY payload of a delivery.
+type notifyChunk struct {
+	ID    string `json:"id"`
+	Part  int    `json:"part"`
+	Parts int    `json:"parts"`
+	Data  string `json:"data"`
+}
+
+// NewPostgresBackplane constructs a backplane publishing through db and
+// listening on its own connection opened from dsn.
+func NewPostgresBackplane(db *gorm.DB, dsn, channel string, log *logger.Logger) (*PostgresBackplane, error) {
+	if !channelPattern.MatchString(channel) {
+		return nil, fmt.Errorf("invalid backplane channel %q", channel)
+	}
+	return &PostgresBackplane{db: db, dsn: dsn, channel: channel, log: log}, nil
+}
+
+// Publish sends the delivery with pg_notify.
+func (b *PostgresBackplane) Publish(ctx context.Context, data []byte) error {
+	id := uuid.NewString()
+	parts := (len(data) + notifyChunkSize - 1) / notifyChunkSize
+	payloads := make([]string, 0, parts)
+	for part := 0; part < parts; part++ {
+		end := min((part+1)*notifyChunkSize, len(data))
+		payload, err := json.Marshal(notifyChunk{
+			ID:    id,
+			Part:  part,
+			Parts: parts,
+			Data:  base64.StdEncoding.EncodeToString(data[part*notifyChunkSize : end]),
+		})
+		if err != nil {
+			return err
+		}
+		payloads = append(payloads, string(payload))
+	}
+
+	err := b.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, payload := range payloads {
+			if err := tx.Exec("SELECT pg_notify(?, ?)", b.channel, payload).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		b.log.Printf("ws backplane: publish: %v", err)
+	}
+	return err
+}
+
+// Subscribe registers a receiver and starts listening on first use.
+func (b *PostgresBackplane) Subscribe(receive func(data []byte)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.receivers = append(b.receivers, receive)
+	if b.cancel == nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		b.cancel = cancel
+		b.done = make(chan struct{})
+		go b.listen(ctx)
+	}
+}
+
+// Close stops the listener.
+func (b *PostgresBackplane) Close() error {
+	b.mu.Lock()
+	cancel, done := b.cancel, b.done
+	b.cancel = nil
+	b.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+	return nil
+}
+
+// listen keeps a LISTEN connection open, reconnecting after failures.
+func (b *PostgresBackplane) listen(ctx context.Context) {
+	defer close(b.done)
+	for {
+		err := b.listenOnce(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		b.log.Printf("ws backplane: listen: %v; reconnecting", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(listenRetryDelay):
+		}
+	}
+}
+
+func (b *PostgresBackplane) listenOnce(ctx context.Context) error {
+	conn, err := pgx.Connect(ctx, b.dsn)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{b.channel}.Sanitize()); err != nil {
+		return err
+	}
+
+	pending := make(map[string][]string)
+	started := make(map[string]time.Time)
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		var chunk notifyChunk
+		if err := json.Unmarshal([]byte(notification.Payload), &chunk); err != nil || chunk.Parts <= 0 || chunk.Part >= chunk.Parts {
+			continue
+		}
+
+		parts := pending[chunk.ID]
+		if parts == nil {
+			parts = make([]string, chunk.Parts)
+			pending[chunk.ID] = parts
+			started[chunk.ID] = time.Now()
+		}
+		if len(parts) != chunk.Parts {
+			continue
+		}
+		parts[chunk.Part] = chunk.Data
+		if data, complete := assembleChunks(parts); complete {
+			delete(pending, chunk.ID)
+			delete(started, chunk.ID)
+			b.deliver(data)
+		}
+		for id, at := range started {
+			if time.Since(at) > chunkTTL {
+				delete(pending, id)
+				delete(started, id)
+			}
+		}
+	}
+}
+
+func (b *PostgresBackplane) deliver(data []byte) {
+	b.mu.Lock()
+	receivers := b.receivers
+	b.mu.Unlock()
+
+	for _, receive := range receivers {
+		receive(data)
+	}
+}
+
+// assembleChunks joins decoded chunks once all of them have arrived.
+func assembleChunks(parts []string) ([]byte, bool) {
+	var data []byte
+	for _, part := range parts {
+		if part == "" {
+			return nil, false
+		}
+		decoded, err := base64.StdEncoding.DecodeString(part)
+		if err != nil {
+			return nil, false
+		}
+		data = append(data, decoded...)
+	}
+	return data, true
+}
+
+var _ Backplane = (*PostgresBackplane)(nil)
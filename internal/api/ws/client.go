@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"sort"
 	"sync"
 	"time"
 
@@ -14,35 +15,111 @@ const (
 	writeWait  = 10 * time.Second
 	pongWait   = 60 * time.Second
 	pingPeriod = pongWait * 9 / 10
+	// rejectNoticeInterval spaces the errors sent to a client whose frames
+	// are being rejected.
+	rejectNoticeInterval = time.Second
 )
 
+// errSendBufferFull is returned by SendJSON for a client that is not
+// keeping up.
+var errSendBufferFull = errors.New("client send buffer full")
+
 // Client represents a websocket connection.
 type Client struct {
-	hub            *Hub
-	conn           *websocket.Conn
-	send           chan []byte
-	accountID      string
+	hub       *Hub
+	conn      *websocket.Conn
+	send      chan []byte
+	accountID string
+	// conversationID is set for a conversation stream and empty for an
+	// account client multiplexing several conversations.
 	conversationID string
-	closeOnce      sync.Once
-	onMessage      func(context.Context, *Client, []byte)
+	// subscriptions, held and away are guarded by the hub's lock.
+	subscriptions map[string]struct{}
+	held          map[string][]heldFrame
+	away          bool
+
+	// mu guards closed and the slow consumer state: while lagging, frames
+	// are dropped and the conversations they belonged to remembered for
+	// the resync.required sent once the queue drains.
+	mu            sync.Mutex
+	closed        bool
+	lagging       bool
+	dropped       int
+	resync        map[string]struct{}
+	resyncSession bool
+
+	inbound    chan []byte
+	limiter    *tokenBucket
+	rejectedAt time.Time
+
+	startOnce sync.Once
+	closeOnce sync.Once
+	onMessage func(context.Context, *Client, []byte)
 }
 
-// NewClient constructs a websocket client for the hub.
+// heldFrame is a live frame buffered while the client resumes.
+type heldFrame struct {
+	seq  int64
+	data []byte
+}
+
+// NewClient constructs a websocket client streaming a single conversation.
 func NewClient(hub *Hub, conn *websocket.Conn, accountID, conversationID string, onMessage func(context.Context, *Client, []byte)) *Client {
+	client := newClient(hub, conn, accountID, onMessage)
+	client.conversationID = conversationID
+	client.subscriptions[conversationID] = struct{}{}
+	return client
+}
+
+// NewAccountClient constructs a websocket client for all of an account's
+// conversations, initially subscribed to conversationIDs.
+func NewAccountClient(hub *Hub, conn *websocket.Conn, accountID string, conversationIDs []string, onMessage func(context.Context, *Client, []byte)) *Client {
+	client := newClient(hub, conn, accountID, onMessage)
+	for _, id := range conversationIDs {
+		client.subscriptions[id] = struct{}{}
+	}
+	return client
+}
+
+func newClient(hub *Hub, conn *websocket.Conn, accountID string, onMessage func(context.Context, *Client, []byte)) *Client {
 	return &Client{
-		hub:            hub,
-		conn:           conn,
-		send:           make(chan []byte, 16),
-		accountID:      accountID,
-		conversationID: conversationID,
-		onMessage:      onMessage,
+		hub:           hub,
+		conn:          conn,
+		send:          make(chan []byte, hub.cfg.SendBuffer),
+		accountID:     accountID,
+		subscriptions: make(map[string]struct{}),
+		resync:        make(map[string]struct{}),
+		inbound:       make(chan []byte, hub.cfg.InboundQueue),
+		limiter:       newTokenBucket(hub.cfg.InboundRate, hub.cfg.InboundBurst),
+		onMessage:     onMessage,
 	}
 }
 
-// Run starts read and write loops for the client.
+// AccountID returns the authenticated account of the connection.
+func (c *Client) AccountID() string {
+	return c.accountID
+}
+
+func (c *Client) multiplexed() bool {
+	return c.conversationID == ""
+}
+
+// Start registers the client and starts its write loop. Run calls it, so
+// it is only needed to deliver frames before reading begins.
+func (c *Client) Start() {
+	c.startOnce.Do(func() {
+		c.hub.Register(c)
+		go c.writeLoop()
+	})
+}
+
+// Run starts the client's loops and handles its frames on a fixed number of
+// workers until the connection closes.
 func (c *Client) Run() {
-	c.hub.Register(c)
-	go c.writeLoop()
+	c.Start()
+	for i := 0; i < c.hub.cfg.Workers; i++ {
+		go c.work()
+	}
 	c.readLoop()
 }
 
@@ -50,15 +127,19 @@ func (c *Client) Run() {
 func (c *Client) Close() {
 	c.closeOnce.Do(func() {
 		c.hub.Unregister(c)
+		c.mu.Lock()
+		c.closed = true
 		close(c.send)
+		c.mu.Unlock()
 		_ = c.conn.Close()
 	})
 }
 
 func (c *Client) readLoop() {
 	defer c.Close()
+	defer close(c.inbound)
 
-	c.conn.SetReadLimit(512)
+	c.conn.SetReadLimit(c.hub.cfg.MaxFrameSize)
 	_ = c.conn.SetReadDeadline(time.Now().Add(pongWait))
 	c.conn.SetPongHandler(func(string) error {
 		return c.conn.SetReadDeadline(time.Now().Add(pongWait))
@@ -69,15 +150,37 @@ func (c *Client) readLoop() {
 		if err != nil {
 			break
 		}
-		if messageType != websocket.TextMessage {
+		if messageType != websocket.TextMessage || c.onMessage == nil {
 			continue
 		}
-		if c.onMessage != nil {
-			go c.onMessage(context.Background(), c, payload)
+		if !c.limiter.allow(time.Now()) {
+			c.reject("rate limited")
+			continue
+		}
+		select {
+		case c.inbound <- payload:
+		default:
+			c.reject("too many pending frames")
 		}
 	}
 }
 
+// reject drops an inbound frame, telling the client at most once per
+// rejectNoticeInterval.
+func (c *Client) reject(reason string) {
+	c.hub.metrics.inboundRejected.Add(1)
+	if now := time.Now(); now.Sub(c.rejectedAt) >= rejectNoticeInterval {
+		c.rejectedAt = now
+		_ = c.SendJSON(map[string]interface{}{"type": "error", "data": map[string]string{"message": reason}})
+	}
+}
+
+func (c *Client) work() {
+	for payload := range c.inbound {
+		c.onMessage(context.Background(), c, payload)
+	}
+}
+
 func (c *Client) writeLoop() {
 	ticker := time.NewTicker(pingPeriod)
 	defer func() {
@@ -93,21 +196,110 @@ func (c *Client) writeLoop() {
 				_ = c.conn.WriteMessage(websocket.CloseMessage, []byte{})
 				return
 			}
-			if err := c.conn.SetWriteDeadline(time.Now().Add(writeWait)); err != nil {
-				return
-			}
-			if err := c.conn.WriteMessage(websocket.TextMessage, message); err != nil {
+			if err := c.write(websocket.TextMessage, message); err != nil {
 				return
 			}
+			c.hub.metrics.framesSent.Add(1)
 		case <-ticker.C:
-			if err := c.conn.SetWriteDeadline(time.Now().Add(writeWait)); err != nil {
+			if err := c.write(websocket.PingMessage, nil); err != nil {
 				return
 			}
-			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+		}
+
+		if frame := c.takeResync(); frame != nil {
+			if err := c.write(websocket.TextMessage, frame); err != nil {
 				return
 			}
+			c.hub.metrics.resyncs.Add(1)
+		}
+	}
+}
+
+func (c *Client) write(messageType int, data []byte) error {
+	if err := c.conn.SetWriteDeadline(time.Now().Add(writeWait)); err != nil {
+		return err
+	}
+	return c.conn.WriteMessage(messageType, data)
+}
+
+// takeResync ends a lag once the send queue has drained, returning the
+// resync.required frame listing what the client missed. Frames queued after
+// it follow it on the wire.
+func (c *Client) takeResync() []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.dropped == 0 || len(c.send) > 0 {
+		return nil
+	}
+	conversationIDs := make([]string, 0, len(c.resync))
+	for id := range c.resync {
+		conversationIDs = append(conversationIDs, id)
+	}
+	sort.Strings(conversationIDs)
+	frame, err := json.Marshal(Event{Type: "resync.required", Data: map[string]interface{}{
+		"conversation_ids": conversationIDs,
+		"session":          c.resyncSession,
+		"dropped":          c.dropped,
+	}})
+	if err != nil {
+		return nil
+	}
+	c.lagging = false
+	c.dropped = 0
+	c.resync = make(map[string]struct{})
+	c.resyncSession = false
+	return frame
+}
+
+// deliver queues a conversation frame, or buffers it while the conversation
+// is held for a resume. It runs under the hub's lock.
+func (c *Client) deliver(conversationID string, seq int64, data []byte) {
+	held, ok := c.held[conversationID]
+	if !ok {
+		c.enqueue(conversationID, data)
+		return
+	}
+	if len(held) >= maxHeldFrames {
+		c.mu.Lock()
+		c.dropLocked(conversationID)
+		c.mu.Unlock()
+		return
+	}
+	c.held[conversationID] = append(held, heldFrame{seq: seq, data: data})
+}
+
+// enqueue queues an encoded frame of the conversation, or of the session
+// when conversationID is empty. A client whose queue is full starts lagging
+// and drops frames until the queue drains.
+func (c *Client) enqueue(conversationID string, data []byte) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return false
+	}
+	if !c.lagging {
+		select {
+		case c.send <- data:
+			return true
+		default:
+			c.lagging = true
+			c.hub.metrics.slowConsumers.Add(1)
 		}
 	}
+	c.dropLocked(conversationID)
+	return false
+}
+
+func (c *Client) dropLocked(conversationID string) {
+	c.dropped++
+	if conversationID == "" {
+		c.resyncSession = true
+	} else {
+		c.resync[conversationID] = struct{}{}
+	}
+	c.hub.metrics.framesDropped.Add(1)
 }
 
 // SendJSON enqueues a JSON message to the client.
@@ -116,11 +308,8 @@ func (c *Client) SendJSON(v interface{}) error {
 	if err != nil {
 		return err
 	}
-	select {
-	case c.send <- data:
-		return nil
-	default:
-		go c.Close()
-		return errors.New("client send buffer full")
+	if !c.enqueue("", data) {
+		return errSendBufferFull
 	}
+	return nil
 }
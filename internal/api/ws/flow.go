@@ -0,0 +1,109 @@
+package ws
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Config tunes how clients buffer outgoing frames and how fast they may
+// send. Zero fields take the DefaultConfig value.
+type Config struct {
+	// SendBuffer is how many outgoing frames may queue for a client. A
+	// client whose queue is full misses frames until it catches up and is
+	// then sent resync.required.
+	SendBuffer int
+	// MaxFrameSize bounds incoming frames in bytes.
+	MaxFrameSize int64
+	// InboundRate and InboundBurst limit the frames a client may send per
+	// second and in a burst; frames beyond them are rejected.
+	InboundRate  float64
+	InboundBurst int
+	// Workers is how many of a client's frames are handled concurrently, and
+	// InboundQueue how many more may wait before frames are rejected.
+	Workers      int
+	InboundQueue int
+}
+
+// DefaultConfig returns the limits used for unset Config fields.
+func DefaultConfig() Config {
+	return Config{
+		SendBuffer:   256,
+		MaxFrameSize: 16 << 10,
+		InboundRate:  10,
+		InboundBurst: 20,
+		Workers:      2,
+		InboundQueue: 16,
+	}
+}
+
+func (c Config) withDefaults() Config {
+	d := DefaultConfig()
+	if c.SendBuffer <= 0 {
+		c.SendBuffer = d.SendBuffer
+	}
+	if c.MaxFrameSize <= 0 {
+		c.MaxFrameSize = d.MaxFrameSize
+	}
+	if c.InboundRate <= 0 {
+		c.InboundRate = d.InboundRate
+	}
+	if c.InboundBurst <= 0 {
+		c.InboundBurst = d.InboundBurst
+	}
+	if c.Workers <= 0 {
+		c.Workers = d.Workers
+	}
+	if c.InboundQueue <= 0 {
+		c.InboundQueue = d.InboundQueue
+	}
+	return c
+}
+
+// Stats are counters of a hub since it started, except Clients, which is
+// the number of connected clients.
+type Stats struct {
+	Clients int `json:"clients"`
+	// FramesSent counts frames written to clients.
+	FramesSent uint64 `json:"frames_sent"`
+	// FramesDropped counts frames a slow client missed.
+	FramesDropped uint64 `json:"frames_dropped"`
+	// SlowConsumers counts the times a client's send queue filled up.
+	SlowConsumers uint64 `json:"slow_consumers"`
+	// Resyncs counts resync.required frames sent.
+	Resyncs uint64 `json:"resyncs"`
+	// InboundRejected counts client frames refused by the rate limiter or a
+	// full queue.
+	InboundRejected uint64 `json:"inbound_rejected"`
+}
+
+type metrics struct {
+	framesSent      atomic.Uint64
+	framesDropped   atomic.Uint64
+	slowConsumers   atomic.Uint64
+	resyncs         atomic.Uint64
+	inboundRejected atomic.Uint64
+}
+
+// tokenBucket limits the rate of a client's inbound frames. Only the
+// client's read loop uses it.
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// allow takes a token if one is available.
+func (b *tokenBucket) allow(now time.Time) bool {
+	b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
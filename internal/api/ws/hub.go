@@ -1,67 +1,490 @@
 package ws
 
 import (
+	"context"
 	"encoding/json"
+	"slices"
 	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// publishTimeout bounds how long a delivery may wait on the backplane.
+const publishTimeout = 5 * time.Second
+
+// maxHeldFrames bounds the live frames buffered for a resuming client.
+const maxHeldFrames = 256
+
+// Event is the envelope of every frame sent to clients. ConversationID is
+// set for conversation events so multiplexed clients can route them; Seq is
+// set for events carrying a new message.
+type Event struct {
+	Type           string      `json:"type"`
+	ConversationID string      `json:"conversation_id,omitempty"`
+	Seq            int64       `json:"seq,omitempty"`
+	Data           interface{} `json:"data"`
+}
+
+// Refresher builds an account-specific event, such as fresh unread counts,
+// on the instance holding the account's connections. ok is false when there
+// is nothing to send.
+type Refresher func(ctx context.Context, conversationID, accountID string) (event string, payload interface{}, ok bool)
+
+// PresenceHook receives the number of connections, and of those not
+// reporting away, this instance holds for an account after they changed.
+// Calls are made one at a time and always carry the latest counts.
+type PresenceHook func(accountID string, connections, active int)
+
+// Delivery operations exchanged over the backplane.
+const (
+	opBroadcast = "broadcast"
+	opAccount   = "account"
+	opNotify    = "notify"
+	opJoin      = "join"
+	opRemove    = "remove"
+	opRefresh   = "refresh"
 )
 
-// Hub manages websocket clients per conversation.
+// delivery is a hub operation replayed on every instance.
+type delivery struct {
+	Origin         string          `json:"origin"`
+	Op             string          `json:"op"`
+	ConversationID string          `json:"conversation_id,omitempty"`
+	AccountIDs     []string        `json:"account_ids,omitempty"`
+	Seq            int64           `json:"seq,omitempty"`
+	Frame          json.RawMessage `json:"frame,omitempty"`
+}
+
+// Hub manages websocket clients and their conversation subscriptions. A
+// conversation stream client is subscribed to its one conversation; an
+// account client may subscribe to any of its account's conversations and
+// also receives account-wide notifications. Deliveries are applied locally
+// and published on the backplane for the hubs of other instances.
 type Hub struct {
-	mu      sync.RWMutex
-	clients map[string]map[*Client]struct{}
+	mu            sync.RWMutex
+	conversations map[string]map[*Client]struct{}
+	accounts      map[string]map[*Client]struct{}
+	// connections indexes every client, of either kind, by account.
+	connections map[string]map[*Client]struct{}
+
+	instanceID string
+	backplane  Backplane
+	refresher  Refresher
+	cfg        Config
+	metrics    metrics
+
+	presenceMu      sync.Mutex
+	presence        PresenceHook
+	presencePending map[string]struct{}
+	presenceWake    chan struct{}
 }
 
-// NewHub constructs a Hub instance.
-func NewHub() *Hub {
-	return &Hub{clients: make(map[string]map[*Client]struct{})}
+// NewHub constructs a Hub instance connected to the backplane.
+func NewHub(backplane Backplane, cfg Config) *Hub {
+	h := &Hub{
+		conversations: make(map[string]map[*Client]struct{}),
+		accounts:      make(map[string]map[*Client]struct{}),
+		connections:   make(map[string]map[*Client]struct{}),
+		instanceID:    uuid.NewString(),
+		backplane:     backplane,
+		cfg:           cfg.withDefaults(),
+	}
+	backplane.Subscribe(h.receive)
+	return h
 }
 
-// Register adds a client to the hub.
+// OnRefresh registers the builder of events sent by Refresh.
+func (h *Hub) OnRefresh(fn Refresher) {
+	h.refresher = fn
+}
+
+// InstanceID identifies this hub among the instances sharing the backplane.
+func (h *Hub) InstanceID() string {
+	return h.instanceID
+}
+
+// Stats reports the hub's connection and flow control counters.
+func (h *Hub) Stats() Stats {
+	h.mu.RLock()
+	clients := 0
+	for _, set := range h.connections {
+		clients += len(set)
+	}
+	h.mu.RUnlock()
+
+	return Stats{
+		Clients:         clients,
+		FramesSent:      h.metrics.framesSent.Load(),
+		FramesDropped:   h.metrics.framesDropped.Load(),
+		SlowConsumers:   h.metrics.slowConsumers.Load(),
+		Resyncs:         h.metrics.resyncs.Load(),
+		InboundRejected: h.metrics.inboundRejected.Load(),
+	}
+}
+
+// OnPresence registers the hook told about connection changes and starts
+// the goroutine calling it.
+func (h *Hub) OnPresence(fn PresenceHook) {
+	h.presenceMu.Lock()
+	defer h.presenceMu.Unlock()
+
+	if h.presence == nil {
+		h.presencePending = make(map[string]struct{})
+		h.presenceWake = make(chan struct{}, 1)
+		go h.reportPresence()
+	}
+	h.presence = fn
+}
+
+// Register adds a client to the hub with its initial subscriptions.
 func (h *Hub) Register(client *Client) {
 	h.mu.Lock()
-	defer h.mu.Unlock()
-
-	convClients := h.clients[client.conversationID]
-	if convClients == nil {
-		convClients = make(map[*Client]struct{})
-		h.clients[client.conversationID] = convClients
+	for conversationID := range client.subscriptions {
+		addClient(h.conversations, conversationID, client)
 	}
-	convClients[client] = struct{}{}
+	if client.multiplexed() {
+		addClient(h.accounts, client.accountID, client)
+	}
+	addClient(h.connections, client.accountID, client)
+	h.mu.Unlock()
+
+	h.presenceChanged(client.accountID)
 }
 
 // Unregister removes a client from the hub.
 func (h *Hub) Unregister(client *Client) {
+	h.mu.Lock()
+	for conversationID := range client.subscriptions {
+		removeClient(h.conversations, conversationID, client)
+	}
+	client.subscriptions = nil
+	if client.multiplexed() {
+		removeClient(h.accounts, client.accountID, client)
+	}
+	removeClient(h.connections, client.accountID, client)
+	h.mu.Unlock()
+
+	h.presenceChanged(client.accountID)
+}
+
+// SetAway marks whether the client's user is away, e.g. its window is
+// hidden. An account whose connections are all away is reported away.
+func (h *Hub) SetAway(client *Client, away bool) {
+	h.mu.Lock()
+	changed := client.away != away
+	client.away = away
+	h.mu.Unlock()
+
+	if changed {
+		h.presenceChanged(client.accountID)
+	}
+}
+
+// Subscribe adds conversations to an account client. Callers check that the
+// account is a member.
+func (h *Hub) Subscribe(client *Client, conversationIDs ...string) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
-	if convClients, ok := h.clients[client.conversationID]; ok {
-		delete(convClients, client)
-		if len(convClients) == 0 {
-			delete(h.clients, client.conversationID)
-		}
+	if client.subscriptions == nil || !client.multiplexed() {
+		return
+	}
+	for _, conversationID := range conversationIDs {
+		client.subscriptions[conversationID] = struct{}{}
+		addClient(h.conversations, conversationID, client)
 	}
 }
 
+// Unsubscribe stops delivering the conversations' events to an account client.
+func (h *Hub) Unsubscribe(client *Client, conversationIDs ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !client.multiplexed() {
+		return
+	}
+	for _, conversationID := range conversationIDs {
+		delete(client.subscriptions, conversationID)
+		removeClient(h.conversations, conversationID, client)
+	}
+}
+
+// Join subscribes every account client of the account to a conversation it
+// has just become a member of.
+func (h *Hub) Join(conversationID, accountID string) {
+	h.publish(delivery{Op: opJoin, ConversationID: conversationID, AccountIDs: []string{accountID}})
+}
+
 // Broadcast sends an event to all clients watching the conversation.
 func (h *Hub) Broadcast(conversationID, event string, payload interface{}) {
-	message := map[string]interface{}{
-		"type": event,
-		"data": payload,
+	h.publishEvent(delivery{Op: opBroadcast, ConversationID: conversationID}, event, payload)
+}
+
+// BroadcastSeq sends the event of a new message, tagged with its sequence
+// number so resuming clients do not receive it twice.
+func (h *Hub) BroadcastSeq(conversationID string, seq int64, event string, payload interface{}) {
+	h.publishEvent(delivery{Op: opBroadcast, ConversationID: conversationID, Seq: seq}, event, payload)
+}
+
+// Hold buffers the live events of the conversations for the client until
+// Release, so a gap can be sent ahead of them. It may be called before the
+// client starts.
+func (h *Hub) Hold(client *Client, conversationIDs ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if client.held == nil {
+		client.held = make(map[string][]heldFrame)
+	}
+	for _, conversationID := range conversationIDs {
+		if _, ok := client.held[conversationID]; !ok {
+			client.held[conversationID] = []heldFrame{}
+		}
+	}
+}
+
+// Release sends the gap frames, then the events held since Hold except new
+// messages numbered upToSeq or lower, which the gap already covered, and
+// resumes live delivery.
+func (h *Hub) Release(client *Client, conversationID string, gap [][]byte, upToSeq int64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	held, ok := client.held[conversationID]
+	delete(client.held, conversationID)
+	for _, frame := range gap {
+		client.enqueue(conversationID, frame)
+	}
+	if !ok {
+		return
 	}
+	for _, frame := range held {
+		if frame.seq == 0 || frame.seq > upToSeq {
+			client.enqueue(conversationID, frame.data)
+		}
+	}
+}
+
+// SendToAccount sends an event only to the account's connections on the
+// conversation, e.g. to sync a change that is private to that account.
+func (h *Hub) SendToAccount(conversationID, accountID, event string, payload interface{}) {
+	h.publishEvent(delivery{Op: opAccount, ConversationID: conversationID, AccountIDs: []string{accountID}}, event, payload)
+}
 
-	data, err := json.Marshal(message)
+// Notify sends an account-wide event, such as a new conversation, to every
+// account client of the account regardless of its subscriptions.
+func (h *Hub) Notify(accountID, conversationID, event string, payload interface{}) {
+	h.publishEvent(delivery{Op: opNotify, ConversationID: conversationID, AccountIDs: []string{accountID}}, event, payload)
+}
+
+// NotifyAccounts sends an account-wide event to every account client of the
+// accounts, e.g. a presence change to an account's contacts.
+func (h *Hub) NotifyAccounts(accountIDs []string, event string, payload interface{}) {
+	if len(accountIDs) == 0 {
+		return
+	}
+	h.publishEvent(delivery{Op: opNotify, AccountIDs: accountIDs}, event, payload)
+}
+
+// Refresh asks every instance to build a fresh event with the Refresher for
+// those of the accounts it holds account clients for.
+func (h *Hub) Refresh(conversationID string, accountIDs ...string) {
+	if len(accountIDs) == 0 {
+		return
+	}
+	h.publish(delivery{Op: opRefresh, ConversationID: conversationID, AccountIDs: accountIDs})
+}
+
+// RemoveAccount detaches the account from the conversation, e.g. after it
+// was removed from a group: its conversation streams are closed and its
+// account clients stop receiving the conversation's events.
+func (h *Hub) RemoveAccount(conversationID, accountID string) {
+	h.publish(delivery{Op: opRemove, ConversationID: conversationID, AccountIDs: []string{accountID}})
+}
+
+func (h *Hub) publishEvent(d delivery, event string, payload interface{}) {
+	frame, err := json.Marshal(Event{Type: event, ConversationID: d.ConversationID, Seq: d.Seq, Data: payload})
 	if err != nil {
 		return
 	}
+	d.Frame = frame
+	h.publish(d)
+}
 
-	h.mu.RLock()
-	clients := h.clients[conversationID]
-	for client := range clients {
-		select {
-		case client.send <- data:
-		default:
-			go client.Close()
+// publish applies the delivery to local clients, then hands it to the
+// backplane for other instances. Backplanes log their own failures; local
+// clients are served either way.
+func (h *Hub) publish(d delivery) {
+	h.apply(d)
+
+	d.Origin = h.instanceID
+	data, err := json.Marshal(d)
+	if err != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+	defer cancel()
+	_ = h.backplane.Publish(ctx, data)
+}
+
+// receive applies deliveries published by other instances.
+func (h *Hub) receive(data []byte) {
+	var d delivery
+	if err := json.Unmarshal(data, &d); err != nil || d.Origin == h.instanceID {
+		return
+	}
+	h.apply(d)
+}
+
+func (h *Hub) apply(d delivery) {
+	switch d.Op {
+	case opBroadcast:
+		h.mu.Lock()
+		for client := range h.conversations[d.ConversationID] {
+			client.deliver(d.ConversationID, d.Seq, d.Frame)
+		}
+		h.mu.Unlock()
+	case opAccount:
+		h.mu.Lock()
+		for client := range h.conversations[d.ConversationID] {
+			if slices.Contains(d.AccountIDs, client.accountID) {
+				client.deliver(d.ConversationID, d.Seq, d.Frame)
+			}
+		}
+		h.mu.Unlock()
+	case opNotify:
+		h.mu.RLock()
+		for _, accountID := range d.AccountIDs {
+			for client := range h.accounts[accountID] {
+				client.enqueue(d.ConversationID, d.Frame)
+			}
+		}
+		h.mu.RUnlock()
+	case opJoin:
+		h.join(d.ConversationID, d.AccountIDs)
+	case opRemove:
+		h.remove(d.ConversationID, d.AccountIDs)
+	case opRefresh:
+		// Building the events may query the database; don't hold up the
+		// publisher or the backplane listener.
+		go h.refresh(d.ConversationID, d.AccountIDs)
+	}
+}
+
+func (h *Hub) join(conversationID string, accountIDs []string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, accountID := range accountIDs {
+		for client := range h.accounts[accountID] {
+			if client.subscriptions == nil {
+				continue
+			}
+			client.subscriptions[conversationID] = struct{}{}
+			addClient(h.conversations, conversationID, client)
+		}
+	}
+}
+
+func (h *Hub) remove(conversationID string, accountIDs []string) {
+	h.mu.Lock()
+	var targets []*Client
+	for client := range h.conversations[conversationID] {
+		if !slices.Contains(accountIDs, client.accountID) {
+			continue
+		}
+		if client.multiplexed() {
+			delete(client.subscriptions, conversationID)
+			removeClient(h.conversations, conversationID, client)
+			continue
+		}
+		targets = append(targets, client)
+	}
+	h.mu.Unlock()
+
+	for _, client := range targets {
+		client.Close()
+	}
+}
+
+func (h *Hub) refresh(conversationID string, accountIDs []string) {
+	if h.refresher == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+	defer cancel()
+
+	for _, accountID := range accountIDs {
+		h.mu.RLock()
+		online := len(h.accounts[accountID]) > 0
+		h.mu.RUnlock()
+		if !online {
+			continue
+		}
+		event, payload, ok := h.refresher(ctx, conversationID, accountID)
+		if !ok {
+			continue
+		}
+		frame, err := json.Marshal(Event{Type: event, ConversationID: conversationID, Data: payload})
+		if err != nil {
+			continue
+		}
+		h.apply(delivery{Op: opNotify, AccountIDs: []string{accountID}, Frame: frame})
+	}
+}
+
+// presenceChanged queues the account for the presence hook. Accounts that
+// change again before the hook runs are reported once.
+func (h *Hub) presenceChanged(accountID string) {
+	h.presenceMu.Lock()
+	defer h.presenceMu.Unlock()
+
+	if h.presence == nil {
+		return
+	}
+	h.presencePending[accountID] = struct{}{}
+	select {
+	case h.presenceWake <- struct{}{}:
+	default:
+	}
+}
+
+func (h *Hub) reportPresence() {
+	for range h.presenceWake {
+		h.presenceMu.Lock()
+		pending, hook := h.presencePending, h.presence
+		h.presencePending = make(map[string]struct{})
+		h.presenceMu.Unlock()
+
+		for accountID := range pending {
+			h.mu.RLock()
+			connections, active := len(h.connections[accountID]), 0
+			for client := range h.connections[accountID] {
+				if !client.away {
+					active++
+				}
+			}
+			h.mu.RUnlock()
+			hook(accountID, connections, active)
+		}
+	}
+}
+
+func addClient(index map[string]map[*Client]struct{}, key string, client *Client) {
+	clients := index[key]
+	if clients == nil {
+		clients = make(map[*Client]struct{})
+		index[key] = clients
+	}
+	clients[client] = struct{}{}
+}
+
+func removeClient(index map[string]map[*Client]struct{}, key string, client *Client) {
+	if clients, ok := index[key]; ok {
+		delete(clients, client)
+		if len(clients) == 0 {
+			delete(index, key)
 		}
 	}
-	h.mu.RUnlock()
 }
@@ -1,8 +1,14 @@
 package app
 
 import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
 	"fmt"
+	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
@@ -14,6 +20,7 @@ import (
 	"learn-go/internal/service"
 	"learn-go/pkg/logger"
 	"learn-go/pkg/middleware"
+	"learn-go/pkg/oss"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
@@ -23,10 +30,16 @@ import (
 
 // Application wires up services and transports.
 type Application struct {
-	cfg    config.AppConfig
-	engine *gin.Engine
-	db     *gorm.DB
-	log    *logger.Logger
+	cfg           config.AppConfig
+	engine        *gin.Engine
+	db            *gorm.DB
+	log           *logger.Logger
+	assignments   *service.AssignmentService
+	similarity    *service.SimilarityService
+	conversations *service.ConversationService
+	presence      *service.PresenceService
+	wsHub         *ws.Hub
+	handler       *apihandlers.Handler
 }
 
 // New creates the application, preparing dependencies.
@@ -62,69 +75,328 @@ func New() (*Application, error) {
 	departmentRepo := gormrepo.NewDepartmentStore(db)
 	classRepo := gormrepo.NewClassStore(db)
 	teacherStudentRepo := gormrepo.NewTeacherStudentStore(db)
+	parentStudentRepo := gormrepo.NewParentStudentStore(db)
+	messagingPolicyRepo := gormrepo.NewMessagingPolicyStore(db)
+	courseRepo := gormrepo.NewCourseStore(db)
 	assignmentRepo := gormrepo.NewAssignmentStore(db)
 	submissionRepo := gormrepo.NewSubmissionStore(db)
 	submissionCommentRepo := gormrepo.NewSubmissionCommentStore(db)
+	gradeChangeRepo := gormrepo.NewGradeChangeStore(db)
+	regradeRepo := gormrepo.NewRegradeRequestStore(db)
+	gradeCategoryRepo := gormrepo.NewGradeCategoryStore(db)
+	assignmentStatsRepo := gormrepo.NewAssignmentStatsStore(db)
+	attachmentRepo := gormrepo.NewAttachmentStore(db)
 	noteRepo := gormrepo.NewNoteStore(db)
 	noteCommentRepo := gormrepo.NewNoteCommentStore(db)
 	conversationRepo := gormrepo.NewConversationStore(db)
 	messageRepo := gormrepo.NewMessageStore(db)
-	receiptRepo := gormrepo.NewMessageReceiptStore(db)
+	reactionRepo := gormrepo.NewMessageReactionStore(db)
+	mentionRepo := gormrepo.NewMessageMentionStore(db)
+	messageSearchRepo := gormrepo.NewMessageSearchStore(db)
+	chatMediaRepo := gormrepo.NewChatMediaStore(db)
+	similarityRepo := gormrepo.NewSimilarityStore(db)
+	presenceRepo := gormrepo.NewPresenceStore(db)
+	moderationRuleRepo := gormrepo.NewModerationRuleStore(db)
+	moderationCaseRepo := gormrepo.NewModerationCaseStore(db)
+	notificationRepo := gormrepo.NewNotificationStore(db)
+
+	storage, localStorage, err := newStorage(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("init object storage: %w", err)
+	}
 
 	authService := service.NewAuthService(accountRepo, cfg)
-	adminService := service.NewAdminService(accountRepo, teacherRepo, studentRepo, departmentRepo, classRepo, teacherStudentRepo)
-	assignmentService := service.NewAssignmentService(assignmentRepo, submissionRepo, submissionCommentRepo)
-	conversationService := service.NewConversationService(conversationRepo, messageRepo, receiptRepo, accountRepo)
-	noteService := service.NewNoteService(noteRepo, accountRepo)
-	noteCommentService := service.NewNoteCommentService(noteRepo, noteCommentRepo, accountRepo)
-	wsHub := ws.NewHub()
+	messagingPolicyService := service.NewMessagingPolicyService(messagingPolicyRepo, studentRepo, teacherRepo, teacherStudentRepo, parentStudentRepo)
+	moderationService := service.NewModerationService(moderationRuleRepo, moderationCaseRepo, accountRepo, conversationRepo, messageRepo, noteRepo, noteCommentRepo)
+	notificationService := service.NewNotificationService(notificationRepo, log)
+	conversationService := service.NewConversationService(conversationRepo, messageRepo, reactionRepo, mentionRepo, messageSearchRepo, accountRepo, chatMediaRepo, storage, time.Duration(cfg.MessageRecallWindow)*time.Second, messagingPolicyService, moderationService, notificationService)
+	classChatService := service.NewClassChatService(conversationService, classRepo, studentRepo, teacherRepo, teacherStudentRepo, courseRepo)
+	adminService := service.NewAdminService(accountRepo, teacherRepo, studentRepo, departmentRepo, classRepo, teacherStudentRepo, parentStudentRepo, classChatService)
+	assignmentService := service.NewAssignmentService(assignmentRepo, submissionRepo, submissionCommentRepo, gradeChangeRepo, regradeRepo, assignmentStatsRepo, studentRepo, classRepo, teacherRepo, teacherStudentRepo, courseRepo, attachmentRepo, storage, notificationService)
+	noteService := service.NewNoteService(noteRepo, accountRepo, moderationService)
+	noteCommentService := service.NewNoteCommentService(noteRepo, noteCommentRepo, accountRepo, moderationService, notificationService)
+	gradebookService := service.NewGradebookService(assignmentRepo, submissionRepo, studentRepo, accountRepo, gradeCategoryRepo)
+	similarityService := service.NewSimilarityService(assignmentRepo, submissionRepo, similarityRepo)
+	presenceService := service.NewPresenceService(presenceRepo, conversationRepo)
+	backplane, err := newBackplane(cfg, db, log)
+	if err != nil {
+		return nil, fmt.Errorf("init websocket backplane: %w", err)
+	}
+	wsHub := ws.NewHub(backplane, ws.Config{
+		SendBuffer:   int(cfg.WSSendBuffer),
+		MaxFrameSize: cfg.WSMaxFrameBytes,
+		InboundRate:  float64(cfg.WSInboundRate),
+		InboundBurst: int(cfg.WSInboundBurst),
+		Workers:      int(cfg.WSWorkers),
+		InboundQueue: int(cfg.WSInboundQueue),
+	})
 
 	engine := gin.New()
 	engine.Use(gin.Recovery())
 	engine.Use(gin.Logger())
 
-	handler := apihandlers.NewHandler(authService, adminService, assignmentService, conversationService, noteService, noteCommentService, wsHub)
+	handler := apihandlers.NewHandler(authService, adminService, assignmentService, conversationService, classChatService, noteService, noteCommentService, gradebookService, similarityService, presenceService, messagingPolicyService, moderationService, notificationService, wsHub)
+	classChatService.OnChange(handler.NotifyGroupChange)
+	notificationService.OnCreate(handler.DeliverNotifications)
+	wsHub.OnRefresh(handler.UnreadEvent)
+	wsHub.OnPresence(handler.PresenceChanged)
 
 	adminGuard := middleware.JWTAuth(middleware.AuthConfig{Secret: cfg.JWTSecret, AllowedRoles: []string{string(domain.RoleAdmin)}})
 	teacherGuard := middleware.JWTAuth(middleware.AuthConfig{Secret: cfg.JWTSecret, AllowedRoles: []string{string(domain.RoleTeacher), string(domain.RoleAdmin)}})
 	studentGuard := middleware.JWTAuth(middleware.AuthConfig{Secret: cfg.JWTSecret, AllowedRoles: []string{string(domain.RoleStudent), string(domain.RoleTeacher), string(domain.RoleAdmin)}})
+	chatGuard := middleware.JWTAuth(middleware.AuthConfig{Secret: cfg.JWTSecret, AllowedRoles: []string{string(domain.RoleStudent), string(domain.RoleTeacher), string(domain.RoleAdmin), string(domain.RoleParent)}})
 
-	handler.RegisterRoutes(engine, adminGuard, teacherGuard, studentGuard)
+	handler.RegisterRoutes(engine, adminGuard, teacherGuard, studentGuard, chatGuard)
+	if localStorage != nil {
+		// Signed URLs carry their own authorization, so no JWT guard here.
+		engine.Any(localFilesPrefix+"/*key", gin.WrapH(http.StripPrefix(localFilesPrefix, localStorage)))
+	}
 
-	return &Application{cfg: cfg, engine: engine, db: db, log: log}, nil
+	return &Application{
+		cfg:           cfg,
+		engine:        engine,
+		db:            db,
+		log:           log,
+		assignments:   assignmentService,
+		similarity:    similarityService,
+		conversations: conversationService,
+		presence:      presenceService,
+		wsHub:         wsHub,
+		handler:       handler,
+	}, nil
 }
 
 // Run starts the HTTP server.
 func (a *Application) Run() error {
+	go a.runScheduler(context.Background())
+	go a.runMediaWorker(context.Background())
+	go a.runPresenceHeartbeat(context.Background())
+
 	address := fmt.Sprintf(":%s", a.cfg.HTTPPort)
 	a.log.Printf("starting http server on %s", address)
 	return a.engine.Run(address)
 }
 
+// runScheduler periodically publishes assignments whose scheduled time has
+// come and runs the similarity analysis over newly changed submissions.
+func (a *Application) runScheduler(ctx context.Context) {
+	interval := time.Duration(a.cfg.SchedulerInterval) * time.Second
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if n, err := a.assignments.PublishScheduled(ctx, time.Now()); err != nil {
+			a.log.Printf("scheduler: publish scheduled assignments: %v", err)
+		} else if n > 0 {
+			a.log.Printf("scheduler: published %d scheduled assignments", n)
+		}
+		if n, err := a.similarity.AnalyzePending(ctx, similarityBatchSize); err != nil {
+			a.log.Printf("scheduler: similarity analysis: %v", err)
+		} else if n > 0 {
+			a.log.Printf("scheduler: analyzed %d submissions for similarity", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// similarityBatchSize caps how many submissions one scheduler tick analyzes.
+const similarityBatchSize = 100
+
+// mediaSweepInterval is how often the media worker looks for sent media that
+// missed the in-memory queue, e.g. after a restart.
+const mediaSweepInterval = time.Minute
+
+// runMediaWorker extracts thumbnails and durations for sent chat media off the
+// request path, then pushes the updated message to connected clients.
+func (a *Application) runMediaWorker(ctx context.Context) {
+	ticker := time.NewTicker(mediaSweepInterval)
+	defer ticker.Stop()
+
+	process := func(id string) {
+		msg, err := a.conversations.ProcessMedia(ctx, id)
+		if err != nil {
+			a.log.Printf("media worker: process %s: %v", id, err)
+			return
+		}
+		if msg != nil {
+			a.handler.NotifyMessageUpdated(*msg)
+		}
+	}
+	sweep := func() {
+		ids, err := a.conversations.PendingMedia(ctx, 50)
+		if err != nil {
+			a.log.Printf("media worker: list pending media: %v", err)
+			return
+		}
+		for _, id := range ids {
+			process(id)
+		}
+	}
+
+	sweep()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case id := <-a.conversations.MediaJobs():
+			process(id)
+		case <-ticker.C:
+			sweep()
+		}
+	}
+}
+
+// runPresenceHeartbeat keeps this instance's presence sessions alive and
+// reports accounts whose connections were on an instance that went away.
+func (a *Application) runPresenceHeartbeat(ctx context.Context) {
+	ticker := time.NewTicker(service.PresenceHeartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		offline, err := a.presence.Heartbeat(ctx, a.wsHub.InstanceID())
+		if err != nil {
+			a.log.Printf("presence: heartbeat: %v", err)
+			continue
+		}
+		for _, p := range offline {
+			a.handler.BroadcastPresence(ctx, p)
+		}
+	}
+}
+
 func migrate(db *gorm.DB) error {
-	return db.AutoMigrate(
+	if err := db.AutoMigrate(
 		&domain.School{},
 		&domain.Account{},
 		&domain.Teacher{},
 		&domain.Student{},
 		&domain.TeacherStudentLink{},
+		&domain.ParentStudentLink{},
+		&domain.MessagingPolicy{},
 		&domain.Department{},
 		&domain.Class{},
 		&domain.Course{},
 		&domain.CourseSlot{},
 		&domain.CourseSession{},
 		&domain.Assignment{},
+		&domain.AssignmentRevision{},
+		&domain.GradeCategory{},
 		&domain.AssignmentQuestion{},
 		&domain.AssignmentSubmission{},
 		&domain.SubmissionItem{},
 		&domain.SubmissionComment{},
+		&domain.Attachment{},
+		&domain.AnswerFingerprint{},
+		&domain.SimilarityMatch{},
+		&domain.SimilarityCheck{},
+		&domain.GradeChange{},
+		&domain.RegradeRequest{},
 		&domain.Conversation{},
 		&domain.ConversationMember{},
 		&domain.Message{},
-		&domain.MessageReceipt{},
+		&domain.MessageEdit{},
+		&domain.MessageReaction{},
+		&domain.MessageMention{},
+		&domain.MessageDeletion{},
+		&domain.ChatMedia{},
+		&domain.PresenceSession{},
+		&domain.AccountLastSeen{},
 		&domain.Note{},
 		&domain.NoteComment{},
-	)
+		&domain.ModerationRule{},
+		&domain.ModerationCase{},
+		&domain.ModerationRemoval{},
+		&domain.Notification{},
+		&domain.NotificationPreference{},
+	); err != nil {
+		return err
+	}
+	if err := gormrepo.BackfillMessageSeq(db); err != nil {
+		return err
+	}
+	if err := gormrepo.MigrateMessageReceipts(db); err != nil {
+		return err
+	}
+	if err := gormrepo.PrepareNotificationGroups(db); err != nil {
+		return err
+	}
+	if err := gormrepo.PrepareRegradeRequests(db); err != nil {
+		return err
+	}
+	if err := gormrepo.PrepareManagedChats(db); err != nil {
+		return err
+	}
+	return gormrepo.PrepareMessageSearch(db)
+}
+
+// newBackplane selects how websocket events reach other replicas: in-process
+// only, or PostgreSQL LISTEN/NOTIFY when several replicas share the database.
+func newBackplane(cfg config.AppConfig, db *gorm.DB, log *logger.Logger) (ws.Backplane, error) {
+	switch cfg.WSBackplane {
+	case "", "memory":
+		return ws.NewMemoryBackplane(), nil
+	case "postgres":
+		if db.Dialector.Name() != "postgres" {
+			return nil, errors.New("websocket backplane postgres requires DATABASE_DRIVER=postgres")
+		}
+		return ws.NewPostgresBackplane(db, cfg.DatabaseDSN, cfg.WSBackplaneChannel, log)
+	default:
+		return nil, fmt.Errorf("unsupported websocket backplane: %s", cfg.WSBackplane)
+	}
+}
+
+// localFilesPrefix is where the local storage backend serves signed URLs.
+const localFilesPrefix = "/files"
+
+// newStorage selects the object storage backend: S3-compatible when
+// OSS_ENDPOINT is set, otherwise the local filesystem served by this app.
+func newStorage(cfg config.AppConfig) (oss.Client, *oss.LocalStorage, error) {
+	if cfg.OssEndpoint != "" {
+		storage, err := oss.NewS3Storage(oss.S3Config{
+			Endpoint:  cfg.OssEndpoint,
+			Region:    cfg.OssRegion,
+			Bucket:    cfg.OssBucket,
+			AccessKey: cfg.OssAccessKey,
+			SecretKey: cfg.OssSecretKey,
+			PathStyle: cfg.OssPathStyle,
+		})
+		return storage, nil, err
+	}
+
+	publicURL := cfg.OssPublicURL
+	if publicURL == "" {
+		publicURL = fmt.Sprintf("http://localhost:%s", cfg.HTTPPort)
+	}
+	local, err := oss.NewLocalStorage(cfg.OssLocalRoot, strings.TrimRight(publicURL, "/")+localFilesPrefix, localSigningSecret(cfg))
+	if err != nil {
+		return nil, nil, err
+	}
+	return local, local, nil
+}
+
+// localSigningSecret returns the key for local storage URLs. Without a
+// dedicated secret it derives one from the JWT secret, so a leaked URL
+// signature never doubles as a token signing key.
+func localSigningSecret(cfg config.AppConfig) []byte {
+	if cfg.OssSigningSecret != "" {
+		return []byte(cfg.OssSigningSecret)
+	}
+	mac := hmac.New(sha256.New, []byte(cfg.JWTSecret))
+	mac.Write([]byte("oss-local"))
+	return mac.Sum(nil)
 }
 
 func resolveDialector(cfg config.AppConfig) (gorm.Dialector, error) {
@@ -5,6 +5,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strconv"
 	"sync"
 
 	"github.com/joho/godotenv"
@@ -25,6 +26,34 @@ type AppConfig struct {
 	OssAccessKey    string
 	OssSecretKey    string
 	OssBucket       string
+	OssRegion       string
+	// OssPathStyle addresses buckets as endpoint/bucket (MinIO) instead of bucket.endpoint.
+	OssPathStyle bool
+	// OssLocalRoot is where objects are stored when OssEndpoint is empty.
+	OssLocalRoot string
+	// OssPublicURL is the externally reachable base URL of this server, used in local signed URLs.
+	OssPublicURL string
+	// OssSigningSecret signs local storage URLs; empty derives a key from JWTSecret.
+	OssSigningSecret string
+	// SchedulerInterval is how often background jobs such as scheduled publishing run, in seconds.
+	SchedulerInterval int64
+	// MessageRecallWindow is how long after sending a chat message may be recalled, in seconds.
+	MessageRecallWindow int64
+	// WSBackplane selects how websocket events reach other replicas: "memory" or "postgres".
+	WSBackplane string
+	// WSBackplaneChannel is the LISTEN/NOTIFY channel of the postgres backplane.
+	WSBackplaneChannel string
+	// WSSendBuffer is how many outgoing frames may queue per websocket client.
+	WSSendBuffer int64
+	// WSMaxFrameBytes bounds frames received from websocket clients.
+	WSMaxFrameBytes int64
+	// WSInboundRate and WSInboundBurst limit the frames a websocket client may send per second and in a burst.
+	WSInboundRate  int64
+	WSInboundBurst int64
+	// WSWorkers is how many frames of one websocket client are handled concurrently.
+	WSWorkers int64
+	// WSInboundQueue is how many frames of one websocket client may wait for a worker.
+	WSInboundQueue int64
 }
 
 var (
@@ -38,19 +67,34 @@ func Load() AppConfig {
 		loadDotEnv()
 
 		cfg = AppConfig{
-			AppName:         getEnv("APP_NAME", "LearnGo"),
-			Environment:     getEnv("APP_ENV", "local"),
-			HTTPPort:        getEnv("HTTP_PORT", "8080"),
-			DatabaseDriver:  getEnv("DATABASE_DRIVER", "sqlite"),
-			DatabaseDSN:     getEnv("DATABASE_DSN", "file:learn-go.db?cache=shared&_foreign_keys=on"),
-			JWTSecret:       mustEnv("JWT_SECRET"),
-			RefreshSecret:   mustEnv("REFRESH_SECRET"),
-			TokenTTL:        getEnvAsInt64("TOKEN_TTL", 3600),
-			RefreshTokenTTL: getEnvAsInt64("REFRESH_TOKEN_TTL", 2592000),
-			OssEndpoint:     getEnv("OSS_ENDPOINT", ""),
-			OssAccessKey:    getEnv("OSS_ACCESS_KEY", ""),
-			OssSecretKey:    getEnv("OSS_SECRET_KEY", ""),
-			OssBucket:       getEnv("OSS_BUCKET", ""),
+			AppName:             getEnv("APP_NAME", "LearnGo"),
+			Environment:         getEnv("APP_ENV", "local"),
+			HTTPPort:            getEnv("HTTP_PORT", "8080"),
+			DatabaseDriver:      getEnv("DATABASE_DRIVER", "sqlite"),
+			DatabaseDSN:         getEnv("DATABASE_DSN", "file:learn-go.db?cache=shared&_foreign_keys=on"),
+			JWTSecret:           mustEnv("JWT_SECRET"),
+			RefreshSecret:       mustEnv("REFRESH_SECRET"),
+			TokenTTL:            getEnvAsInt64("TOKEN_TTL", 3600),
+			RefreshTokenTTL:     getEnvAsInt64("REFRESH_TOKEN_TTL", 2592000),
+			OssEndpoint:         getEnv("OSS_ENDPOINT", ""),
+			OssAccessKey:        getEnv("OSS_ACCESS_KEY", ""),
+			OssSecretKey:        getEnv("OSS_SECRET_KEY", ""),
+			OssBucket:           getEnv("OSS_BUCKET", ""),
+			OssRegion:           getEnv("OSS_REGION", "us-east-1"),
+			OssPathStyle:        getEnvAsBool("OSS_PATH_STYLE", true),
+			OssLocalRoot:        getEnv("OSS_LOCAL_ROOT", "data/objects"),
+			OssPublicURL:        getEnv("OSS_PUBLIC_URL", ""),
+			OssSigningSecret:    getEnv("OSS_LOCAL_SIGNING_SECRET", ""),
+			SchedulerInterval:   getEnvAsInt64("SCHEDULER_INTERVAL", 30),
+			MessageRecallWindow: getEnvAsInt64("MESSAGE_RECALL_WINDOW", 120),
+			WSBackplane:         getEnv("WS_BACKPLANE", "memory"),
+			WSBackplaneChannel:  getEnv("WS_BACKPLANE_CHANNEL", "learn_go_ws"),
+			WSSendBuffer:        getEnvAsInt64("WS_SEND_BUFFER", 256),
+			WSMaxFrameBytes:     getEnvAsInt64("WS_MAX_FRAME_BYTES", 16384),
+			WSInboundRate:       getEnvAsInt64("WS_INBOUND_RATE", 10),
+			WSInboundBurst:      getEnvAsInt64("WS_INBOUND_BURST", 20),
+			WSWorkers:           getEnvAsInt64("WS_WORKERS", 2),
+			WSInboundQueue:      getEnvAsInt64("WS_INBOUND_QUEUE", 16),
 		}
 	})
 
@@ -92,6 +136,17 @@ func getEnvAsInt64(key string, fallback int64) int64 {
 	return fallback
 }
 
+func getEnvAsBool(key string, fallback bool) bool {
+	if value, ok := os.LookupEnv(key); ok {
+		parsed, err := strconv.ParseBool(value)
+		if err == nil {
+			return parsed
+		}
+		log.Printf("config: unable to parse %s=%s as bool: %v", key, value, err)
+	}
+	return fallback
+}
+
 func mustEnv(key string) string {
 	value := os.Getenv(key)
 	if value == "" {
@@ -13,6 +13,7 @@ const (
 	RoleAdmin   Role = "admin"
 	RoleTeacher Role = "teacher"
 	RoleStudent Role = "student"
+	RoleParent  Role = "parent"
 )
 
 // Account represents login credentials tied to a user type.
@@ -89,6 +90,34 @@ type TeacherStudentLink struct {
 	CreatedAt time.Time
 }
 
+// ParentStudentLink binds a parent account to a child's student profile.
+type ParentStudentLink struct {
+	ID        string `gorm:"primaryKey;size:36"`
+	ParentID  string `gorm:"size:36;index"` // parent account
+	StudentID string `gorm:"size:36;index"`
+	CreatedAt time.Time
+}
+
+// MessagingPolicy holds a school's rules on who may message whom. Teachers
+// and admins may message anyone in the school, and anyone may message an
+// admin.
+type MessagingPolicy struct {
+	SchoolID string `gorm:"primaryKey;size:36"`
+	// RestrictStudents limits students to their bound teachers, their
+	// classmates and their parents.
+	RestrictStudents bool
+	// RestrictParents limits parents to their children and the teachers
+	// bound to them.
+	RestrictParents bool
+	// QuietHoursStart and QuietHoursEnd ("15:04" in TimeZone) bound a daily
+	// window in which students may not message teachers. The window may
+	// span midnight; equal values disable it.
+	QuietHoursStart string `gorm:"size:5"`
+	QuietHoursEnd   string `gorm:"size:5"`
+	TimeZone        string `gorm:"size:64"` // IANA name, empty for the server's zone
+	UpdatedAt       time.Time
+}
+
 // CourseSlot defines a lesson time window.
 type CourseSlot struct {
 	ID        string `gorm:"primaryKey;size:36"`
@@ -132,21 +161,64 @@ const (
 	AssignmentExam     AssignmentType = "exam"
 )
 
+// AssignmentStatus enumerates assignment lifecycle states.
+type AssignmentStatus string
+
+const (
+	AssignmentDraft     AssignmentStatus = "draft"
+	AssignmentScheduled AssignmentStatus = "scheduled"
+	AssignmentPublished AssignmentStatus = "published"
+	AssignmentClosed    AssignmentStatus = "closed"
+	AssignmentArchived  AssignmentStatus = "archived"
+)
+
 // Assignment represents homework or exam.
 type Assignment struct {
-	ID            string         `gorm:"primaryKey;size:36"`
-	CourseID      string         `gorm:"size:36;index"`
-	TeacherID     string         `gorm:"size:36;index"`
-	ClassID       string         `gorm:"size:36;index"`
-	Type          AssignmentType `gorm:"size:16;index"`
-	Title         string         `gorm:"size:256"`
-	Description   string         `gorm:"size:1024"`
+	ID            string           `gorm:"primaryKey;size:36"`
+	CourseID      string           `gorm:"size:36;index"`
+	TeacherID     string           `gorm:"size:36;index"`
+	ClassID       string           `gorm:"size:36;index"`
+	Type          AssignmentType   `gorm:"size:16;index"`
+	Title         string           `gorm:"size:256"`
+	Description   string           `gorm:"size:1024"`
+	Status        AssignmentStatus `gorm:"size:16;index;default:published"`
+	PublishAt     *time.Time       `gorm:"index"` // scheduled publication time
+	PublishedAt   *time.Time
+	Version       int `gorm:"default:1"`
 	StartAt       *time.Time
 	DueAt         *time.Time
 	MaxScore      float64
 	AllowResubmit bool
-	CreatedAt     time.Time
-	UpdatedAt     time.Time
+	// MaxAttachmentBytes limits each uploaded file; zero uses the server default.
+	MaxAttachmentBytes int64
+	// AllowedAttachmentTypes is a comma-separated list of MIME types (image/* style wildcards allowed); empty uses the server default.
+	AllowedAttachmentTypes string `gorm:"size:512"`
+	CreatedAt              time.Time
+	UpdatedAt              time.Time
+}
+
+// AssignmentRevision records an edit made after an assignment was published.
+type AssignmentRevision struct {
+	ID           string `gorm:"primaryKey;size:36"`
+	AssignmentID string `gorm:"size:36;index"`
+	Version      int    // version the edit produced
+	EditorID     string `gorm:"size:36"`
+	Summary      string `gorm:"size:512"`
+	Snapshot     string `gorm:"type:text"` // JSON of the assignment and questions before the edit
+	CreatedAt    time.Time
+}
+
+// GradeCategory weights a group of assignments in a class/course gradebook.
+type GradeCategory struct {
+	ID             string         `gorm:"primaryKey;size:36"`
+	ClassID        string         `gorm:"size:36;index"`
+	CourseID       string         `gorm:"size:36;index"`
+	Name           string         `gorm:"size:64"`
+	AssignmentType AssignmentType `gorm:"size:16"`
+	Weight         float64
+	DropLowest     int
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
 }
 
 // QuestionType enumerates supported question formats.
@@ -204,46 +276,289 @@ type SubmissionComment struct {
 	CreatedAt     time.Time
 }
 
+// AttachmentStatus enumerates upload states of an attachment.
+type AttachmentStatus string
+
+const (
+	AttachmentPending  AttachmentStatus = "pending"
+	AttachmentAttached AttachmentStatus = "attached"
+)
+
+// AttachmentTarget names the kind of record an attachment belongs to.
+type AttachmentTarget string
+
+const (
+	AttachmentTargetSubmissionItem    AttachmentTarget = "submission_item"
+	AttachmentTargetSubmissionComment AttachmentTarget = "submission_comment"
+)
+
+// Attachment is an uploaded file linked to a submission item or comment.
+type Attachment struct {
+	ID           string           `gorm:"primaryKey;size:36"`
+	OwnerID      string           `gorm:"size:36;index"`
+	AssignmentID string           `gorm:"size:36;index"`
+	TargetType   AttachmentTarget `gorm:"size:32"`
+	TargetID     string           `gorm:"size:36;index"` // empty until bound
+	ObjectKey    string           `gorm:"size:512"`
+	FileName     string           `gorm:"size:256"`
+	ContentType  string           `gorm:"size:128"`
+	Size         int64
+	Status       AttachmentStatus `gorm:"size:16;index"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// AnswerFingerprint is the MinHash signature of one essay or fill answer,
+// keyed by submission and question so it survives resubmission.
+type AnswerFingerprint struct {
+	ID           string `gorm:"primaryKey;size:36"`
+	AssignmentID string `gorm:"size:36;index"`
+	QuestionID   string `gorm:"size:36;index"`
+	SubmissionID string `gorm:"size:36;index"`
+	StudentID    string `gorm:"size:36"`
+	Signature    string `gorm:"type:text"` // base64 MinHash
+	ShingleCount int
+	CreatedAt    time.Time
+}
+
+// SimilarityMatch records a pair of answers to the same question that share
+// enough text to look copied. SubmissionAID sorts before SubmissionBID.
+type SimilarityMatch struct {
+	ID            string  `gorm:"primaryKey;size:36"`
+	AssignmentID  string  `gorm:"size:36;index"`
+	QuestionID    string  `gorm:"size:36;index"`
+	SubmissionAID string  `gorm:"column:submission_a_id;size:36;index"`
+	SubmissionBID string  `gorm:"column:submission_b_id;size:36;index"`
+	StudentAID    string  `gorm:"column:student_a_id;size:36"`
+	StudentBID    string  `gorm:"column:student_b_id;size:36"`
+	Score         float64 `gorm:"index"`     // Jaccard similarity of shingle sets
+	SpansA        string  `gorm:"type:text"` // JSON [{start,end}] rune offsets
+	SpansB        string  `gorm:"type:text"`
+	CreatedAt     time.Time
+}
+
+// SimilarityCheck marks the submission version last fingerprinted, so the
+// analysis only revisits submissions that changed since.
+type SimilarityCheck struct {
+	SubmissionID        string `gorm:"primaryKey;size:36"`
+	AssignmentID        string `gorm:"size:36;index"`
+	SubmissionUpdatedAt time.Time
+	CheckedAt           time.Time
+}
+
+// GradeChange is an append-only audit record of a single score change.
+type GradeChange struct {
+	ID           string `gorm:"primaryKey;size:36"`
+	SubmissionID string `gorm:"size:36;index"`
+	ItemID       string `gorm:"size:36;index"` // empty when the submission total changed
+	OldScore     *float64
+	NewScore     *float64
+	GraderID     string `gorm:"size:36;index"`
+	Reason       string `gorm:"size:512"`
+	CreatedAt    time.Time
+}
+
+// RegradeStatus enumerates regrade request states.
+type RegradeStatus string
+
+const (
+	RegradePending  RegradeStatus = "pending"
+	RegradeAccepted RegradeStatus = "accepted"
+	RegradeRejected RegradeStatus = "rejected"
+)
+
+// RegradeRequest is a student's request to re-check a graded item.
+type RegradeRequest struct {
+	ID             string        `gorm:"primaryKey;size:36"`
+	AssignmentID   string        `gorm:"size:36;index"`
+	SubmissionID   string        `gorm:"size:36;index"`
+	ItemID         string        `gorm:"size:36;index"`
+	StudentID      string        `gorm:"size:36;index"`
+	Reason         string        `gorm:"type:text"`
+	Status         RegradeStatus `gorm:"size:16;index"`
+	CommentID      string        `gorm:"size:36"` // discussion thread starter in SubmissionComment
+	ResolverID     *string       `gorm:"size:36"`
+	ResolutionNote string        `gorm:"type:text"`
+	ResolvedAt     *time.Time
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
 // Conversation represents chat channel.
 type Conversation struct {
-	ID        string `gorm:"primaryKey;size:36"`
-	Type      string `gorm:"size:16"` // direct or group
-	SchoolID  string `gorm:"size:36;index"`
+	ID       string `gorm:"primaryKey;size:36"`
+	Type     string `gorm:"size:16"`  // direct, group, class or course
+	Name     string `gorm:"size:128"` // group, class and course chats
+	SchoolID string `gorm:"size:36;index"`
+	ClassID  string `gorm:"size:36;index"` // class and course chats
+	CourseID string `gorm:"size:36;index"` // course chats
+	// AnnouncementOnly lets only owners and admins post.
+	AnnouncementOnly bool
+	// LastSeq is the sequence number of the newest message.
+	LastSeq   int64
 	CreatedAt time.Time
 	UpdatedAt time.Time
 }
 
+// Conversation types. Class and course chats are managed: their membership
+// follows the class roster and cannot be edited by hand.
+const (
+	ConversationDirect = "direct"
+	ConversationGroup  = "group"
+	ConversationClass  = "class"
+	ConversationCourse = "course"
+)
+
+// GroupRole is a member's standing within a group conversation.
+type GroupRole string
+
+const (
+	GroupOwner  GroupRole = "owner"
+	GroupAdmin  GroupRole = "admin"
+	GroupMember GroupRole = "member"
+)
+
 // ConversationMember ties users to conversations.
 type ConversationMember struct {
-	ID             string `gorm:"primaryKey;size:36"`
-	ConversationID string `gorm:"size:36;index"`
-	AccountID      string `gorm:"size:36;index"`
-	Role           Role   `gorm:"size:16"`
-	CreatedAt      time.Time
+	ID             string    `gorm:"primaryKey;size:36"`
+	ConversationID string    `gorm:"size:36;index"`
+	AccountID      string    `gorm:"size:36;index"`
+	Role           Role      `gorm:"size:16"` // the account's role, e.g. teacher or student
+	GroupRole      GroupRole `gorm:"size:16;default:member"`
+	// LastReadSeq is the sequence number of the last message the member
+	// has read; later messages from others count as unread.
+	LastReadSeq int64
+	LastReadAt  *time.Time
+	// PinnedAt, MutedUntil, ArchivedAt and Title are the member's own
+	// settings; Title replaces the conversation name for this member only.
+	PinnedAt   *time.Time
+	MutedUntil *time.Time
+	ArchivedAt *time.Time
+	Title      string `gorm:"size:64"`
+	CreatedAt  time.Time
 }
 
+// MutedForever is the MutedUntil of a conversation muted until unmuted.
+var MutedForever = time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+
 // Message holds chat content.
 type Message struct {
 	ID             string `gorm:"primaryKey;size:36"`
-	ConversationID string `gorm:"size:36;index"`
-	SenderID       string `gorm:"size:36;index"`
+	ConversationID string `gorm:"size:36;index;index:idx_message_conversation_seq,priority:1"`
+	SenderID       string `gorm:"size:36;index;uniqueIndex:idx_message_client_msg,priority:1"`
 	SenderRole     Role   `gorm:"size:16"`
-	Kind           string `gorm:"size:16"` // text,image,video,audio
+	Kind           string `gorm:"size:16"` // text,image,video,audio,file,system
 	Text           string `gorm:"type:text"`
 	MediaURI       string `gorm:"size:256"`
+	MediaID        string `gorm:"size:36;index"` // set for media uploaded through the server
 	Metadata       string `gorm:"type:text"`
-	CreatedAt      time.Time
+	ReplyToID      string `gorm:"size:36;index"` // the message this one replies to
+	// Seq numbers the messages of a conversation from 1 in commit order.
+	Seq int64 `gorm:"index:idx_message_conversation_seq,priority:2"`
+	// ClientMsgID is the sender's idempotency key; a retried send returns the
+	// message already stored under it. Nil for messages sent without one.
+	ClientMsgID *string `gorm:"size:64;uniqueIndex:idx_message_client_msg,priority:2"`
+	CreatedAt   time.Time
+	// EditedAt is set once the sender changes the text; prior versions are MessageEdits.
+	EditedAt *time.Time
+	// RecalledAt marks a tombstone: the sender withdrew the content for everyone.
+	RecalledAt *time.Time
+	// RemovedAt marks a tombstone left by moderation; the original content is
+	// kept in a ModerationRemoval.
+	RemovedAt *time.Time
 }
 
-// MessageReceipt tracks read state.
-type MessageReceipt struct {
+// MessageEdit keeps the content a message had before an edit.
+type MessageEdit struct {
 	ID        string `gorm:"primaryKey;size:36"`
 	MessageID string `gorm:"size:36;index"`
-	AccountID string `gorm:"size:36;index"`
-	ReadAt    *time.Time
+	EditorID  string `gorm:"size:36"`
+	Text      string `gorm:"type:text"`
+	Metadata  string `gorm:"type:text"`
 	CreatedAt time.Time
 }
 
+// MessageReaction is one account's emoji on a message; each account can add
+// a given emoji to a message once.
+type MessageReaction struct {
+	ID             string `gorm:"primaryKey;size:36"`
+	MessageID      string `gorm:"size:36;uniqueIndex:idx_message_reaction"`
+	AccountID      string `gorm:"size:36;uniqueIndex:idx_message_reaction"`
+	Emoji          string `gorm:"size:32;uniqueIndex:idx_message_reaction"`
+	ConversationID string `gorm:"size:36;index"`
+	CreatedAt      time.Time
+}
+
+// MessageMention records an @mention of an account in a group message. Unread
+// mentions are surfaced ahead of ordinary unread messages.
+type MessageMention struct {
+	ID             string `gorm:"primaryKey;size:36"`
+	MessageID      string `gorm:"size:36;index"`
+	ConversationID string `gorm:"size:36;index"`
+	AccountID      string `gorm:"size:36;index"`
+	ReadAt         *time.Time
+	CreatedAt      time.Time
+}
+
+// MessageDeletion hides a message from one account's history only.
+type MessageDeletion struct {
+	ID             string `gorm:"primaryKey;size:36"`
+	MessageID      string `gorm:"size:36;uniqueIndex:idx_message_deletion"`
+	AccountID      string `gorm:"size:36;uniqueIndex:idx_message_deletion"`
+	ConversationID string `gorm:"size:36;index"`
+	CreatedAt      time.Time
+}
+
+// ChatMediaStatus enumerates the processing states of uploaded chat media.
+type ChatMediaStatus string
+
+const (
+	ChatMediaPending   ChatMediaStatus = "pending"   // upload URL issued
+	ChatMediaAttached  ChatMediaStatus = "attached"  // sent in a message, awaiting processing
+	ChatMediaProcessed ChatMediaStatus = "processed" // thumbnails and duration extracted
+	ChatMediaFailed    ChatMediaStatus = "failed"    // processing gave up; the original is still usable
+	ChatMediaRecalled  ChatMediaStatus = "recalled"  // the message was recalled and the objects deleted
+	ChatMediaRemoved   ChatMediaStatus = "removed"   // the message was removed by moderation; the objects are kept for review
+)
+
+// ChatMedia is an image, audio, video or file uploaded for a chat message.
+type ChatMedia struct {
+	ID             string `gorm:"primaryKey;size:36"`
+	OwnerID        string `gorm:"size:36;index"`
+	ConversationID string `gorm:"size:36;index"`
+	MessageID      string `gorm:"size:36;index"` // empty until sent
+	Kind           string `gorm:"size:16"`
+	ObjectKey      string `gorm:"size:512"`
+	FileName       string `gorm:"size:256"`
+	ContentType    string `gorm:"size:128"` // sniffed once the message is sent
+	Size           int64
+	Status         ChatMediaStatus `gorm:"size:16;index"`
+	Width          int
+	Height         int
+	DurationMS     int64
+	ThumbnailKey   string `gorm:"size:512"`
+	Error          string `gorm:"size:512"`
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+// PresenceSession counts an account's websocket connections on one server
+// instance. Instances refresh UpdatedAt periodically; the rows of an instance
+// that stopped doing so are stale and get swept.
+type PresenceSession struct {
+	InstanceID  string `gorm:"primaryKey;size:36"`
+	AccountID   string `gorm:"primaryKey;size:36;index"`
+	Connections int
+	Active      int       // connections not reporting away
+	UpdatedAt   time.Time `gorm:"index"`
+}
+
+// AccountLastSeen records when an account last had a connection open.
+type AccountLastSeen struct {
+	AccountID string `gorm:"primaryKey;size:36"`
+	SeenAt    time.Time
+}
+
 // Note stores personal or shared notes.
 type Note struct {
 	ID         string `gorm:"primaryKey;size:36"`
@@ -255,16 +570,147 @@ type Note struct {
 	Visibility string `gorm:"size:16"` // private, class, school
 	Status     string `gorm:"size:16"` // draft, published
 	DeletedAt  *time.Time
+	RemovedAt  *time.Time // tombstone left by moderation
 	CreatedAt  time.Time
 	UpdatedAt  time.Time
 }
 
 // NoteComment for collaborative feedback.
 type NoteComment struct {
-	ID         string `gorm:"primaryKey;size:36"`
-	NoteID     string `gorm:"size:36;index"`
-	AuthorID   string `gorm:"size:36;index"`
-	AuthorRole Role   `gorm:"size:16"`
-	Content    string `gorm:"type:text"`
+	ID         string     `gorm:"primaryKey;size:36"`
+	NoteID     string     `gorm:"size:36;index"`
+	AuthorID   string     `gorm:"size:36;index"`
+	AuthorRole Role       `gorm:"size:16"`
+	Content    string     `gorm:"type:text"`
+	RemovedAt  *time.Time // tombstone left by moderation
 	CreatedAt  time.Time
 }
+
+// ModerationAction is what a moderation rule does to matching content.
+type ModerationAction string
+
+const (
+	ModerationBlock ModerationAction = "block" // refuse the content
+	ModerationMask  ModerationAction = "mask"  // replace the matches with asterisks
+	ModerationFlag  ModerationAction = "flag"  // accept the content and queue it for review
+)
+
+// ModerationRule is an entry of a school's blocklist, checked against chat
+// messages, notes and note comments.
+type ModerationRule struct {
+	ID       string `gorm:"primaryKey;size:36"`
+	SchoolID string `gorm:"size:36;index"`
+	// Pattern is a keyword matched case-insensitively, or a regular
+	// expression when IsRegex is set.
+	Pattern   string `gorm:"size:256"`
+	IsRegex   bool
+	Action    ModerationAction `gorm:"size:16"`
+	CreatedBy string           `gorm:"size:36"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// ModerationTarget names the kind of content a moderation case is about.
+type ModerationTarget string
+
+const (
+	ModerationTargetMessage     ModerationTarget = "message"
+	ModerationTargetNote        ModerationTarget = "note"
+	ModerationTargetNoteComment ModerationTarget = "note_comment"
+)
+
+// ModerationCaseStatus enumerates the states of a review queue entry.
+type ModerationCaseStatus string
+
+const (
+	ModerationCaseOpen     ModerationCaseStatus = "open"
+	ModerationCaseResolved ModerationCaseStatus = "resolved" // reviewed and the content kept
+	ModerationCaseRemoved  ModerationCaseStatus = "removed"  // the content was replaced by a tombstone
+)
+
+// ModerationCase is an entry of the admin review queue: content a flag rule
+// matched, or that a user reported.
+type ModerationCase struct {
+	ID         string           `gorm:"primaryKey;size:36"`
+	SchoolID   string           `gorm:"size:36;index"`
+	TargetType ModerationTarget `gorm:"size:16;index:idx_moderation_case_target,priority:1"`
+	TargetID   string           `gorm:"size:36;index:idx_moderation_case_target,priority:2"`
+	// ParentID is the conversation of a message or the note of a comment.
+	ParentID string `gorm:"size:36"`
+	AuthorID string `gorm:"size:36;index"`
+	// RuleID is set for a case a flag rule opened, ReporterID for a report.
+	RuleID     string               `gorm:"size:36"`
+	ReporterID string               `gorm:"size:36;index"`
+	Reason     string               `gorm:"size:512"`
+	Status     ModerationCaseStatus `gorm:"size:16;index"`
+	ResolvedBy string               `gorm:"size:36"`
+	Resolution string               `gorm:"size:512"`
+	ResolvedAt *time.Time
+	CreatedAt  time.Time
+}
+
+// ModerationRemoval is the audit record of content removed by moderation,
+// keeping what the tombstone replaced.
+type ModerationRemoval struct {
+	ID         string           `gorm:"primaryKey;size:36"`
+	CaseID     string           `gorm:"size:36;index"`
+	TargetType ModerationTarget `gorm:"size:16;uniqueIndex:idx_moderation_removal_target,priority:1"`
+	TargetID   string           `gorm:"size:36;uniqueIndex:idx_moderation_removal_target,priority:2"`
+	AuthorID   string           `gorm:"size:36;index"`
+	Title      string           `gorm:"size:256"` // notes only
+	Content    string           `gorm:"type:text"`
+	MediaURI   string           `gorm:"size:256"`
+	MediaID    string           `gorm:"size:36"`
+	Metadata   string           `gorm:"type:text"`
+	RemovedBy  string           `gorm:"size:36"`
+	Reason     string           `gorm:"size:512"`
+	CreatedAt  time.Time
+}
+
+// NotificationCategory groups notifications for the per-account preferences.
+type NotificationCategory string
+
+const (
+	NotificationGrade      NotificationCategory = "grade"      // a submission was graded
+	NotificationComment    NotificationCategory = "comment"    // someone commented on the account's note
+	NotificationMessage    NotificationCategory = "message"    // a chat message the account should see
+	NotificationAssignment NotificationCategory = "assignment" // an assignment was published to the account's class
+)
+
+// NotificationCategories lists every category in display order.
+var NotificationCategories = []NotificationCategory{
+	NotificationGrade,
+	NotificationComment,
+	NotificationMessage,
+	NotificationAssignment,
+}
+
+// Notification is an entry of an account's inbox, kept so events reach users
+// that were offline when they happened.
+type Notification struct {
+	ID        string               `gorm:"primaryKey;size:36"`
+	AccountID string               `gorm:"size:36;index:idx_notification_account,priority:1"`
+	Category  NotificationCategory `gorm:"size:16"`
+	Title     string               `gorm:"size:256"`
+	Body      string               `gorm:"size:512"`
+	// SubjectType and SubjectID point at what the notification is about:
+	// an assignment, a note or a conversation.
+	SubjectType string `gorm:"size:16"`
+	SubjectID   string `gorm:"size:36"`
+	ActorID     string `gorm:"size:36"`
+	// GroupKey merges repeated events, such as the messages of one
+	// conversation, into a single unread notification counting them.
+	GroupKey  string `gorm:"size:96;index"`
+	Count     int
+	ReadAt    *time.Time
+	CreatedAt time.Time `gorm:"index:idx_notification_account,priority:2"`
+}
+
+// NotificationPreference turns a category on or off for an account. A
+// category without a row is enabled.
+type NotificationPreference struct {
+	AccountID string               `gorm:"primaryKey;size:36"`
+	Category  NotificationCategory `gorm:"primaryKey;size:16"`
+	Enabled   bool
+	UpdatedAt time.Time
+}
@@ -57,4 +57,15 @@ func (s *AccountStore) ListByRole(ctx context.Context, schoolID string, role dom
 	return accounts, total, nil
 }
 
+func (s *AccountStore) ListByIDs(ctx context.Context, ids []string) ([]domain.Account, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	var accounts []domain.Account
+	if err := s.db.WithContext(ctx).Where("id IN ?", ids).Find(&accounts).Error; err != nil {
+		return nil, err
+	}
+	return accounts, nil
+}
+
 var _ repository.AccountRepository = (*AccountStore)(nil)
@@ -2,6 +2,7 @@ package gormrepo
 
 import (
 	"context"
+	"time"
 
 	"learn-go/internal/domain"
 	"learn-go/internal/repository"
@@ -47,4 +48,145 @@ func (s *AssignmentStore) Get(ctx context.Context, id string) (*domain.Assignmen
 	return &assignment, questions, nil
 }
 
+func (s *AssignmentStore) ListByClass(ctx context.Context, classID, courseID string) ([]domain.Assignment, error) {
+	var assignments []domain.Assignment
+	query := s.db.WithContext(ctx).Where("class_id = ?", classID)
+	if courseID != "" {
+		query = query.Where("course_id = ?", courseID)
+	}
+	if err := query.Order("due_at").Order("created_at").Find(&assignments).Error; err != nil {
+		return nil, err
+	}
+	return assignments, nil
+}
+
+// ListByTeacher returns assignments created by a teacher, newest first.
+func (s *AssignmentStore) ListByTeacher(ctx context.Context, teacherID string, filter repository.AssignmentFilter) ([]domain.Assignment, error) {
+	var assignments []domain.Assignment
+	query := s.db.WithContext(ctx).Where("teacher_id = ?", teacherID)
+	if filter.ClassID != "" {
+		query = query.Where("class_id = ?", filter.ClassID)
+	}
+	if filter.CourseID != "" {
+		query = query.Where("course_id = ?", filter.CourseID)
+	}
+	if filter.Type != "" {
+		query = query.Where("type = ?", filter.Type)
+	}
+	if len(filter.Statuses) > 0 {
+		query = query.Where("status IN ?", filter.Statuses)
+	}
+	if err := query.Order("created_at DESC").Find(&assignments).Error; err != nil {
+		return nil, err
+	}
+	return assignments, nil
+}
+
+// Update saves the assignment while its status is still from, and optionally
+// replaces its questions and records a revision.
+func (s *AssignmentStore) Update(ctx context.Context, assignment *domain.Assignment, from domain.AssignmentStatus, questions []domain.AssignmentQuestion, revision *domain.AssignmentRevision) (bool, error) {
+	updated := false
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Guarding on the status read by the caller keeps an edit from
+		// undoing a status change made meanwhile, such as a scheduled publish.
+		result := tx.Model(assignment).Where("status = ?", from).Select("*").Updates(assignment)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		if questions != nil {
+			if err := tx.Where("assignment_id = ?", assignment.ID).Delete(&domain.AssignmentQuestion{}).Error; err != nil {
+				return err
+			}
+			for i := range questions {
+				questions[i].AssignmentID = assignment.ID
+			}
+			if len(questions) > 0 {
+				if err := tx.Create(&questions).Error; err != nil {
+					return err
+				}
+			}
+		}
+		if revision != nil {
+			if err := tx.Create(revision).Error; err != nil {
+				return err
+			}
+		}
+		updated = true
+		return nil
+	})
+	return updated, err
+}
+
+// TransitionStatus performs a compare-and-set on the status column so
+// concurrent schedulers cannot publish the same assignment twice.
+func (s *AssignmentStore) TransitionStatus(ctx context.Context, id string, from, to domain.AssignmentStatus, at time.Time) (bool, error) {
+	updates := map[string]interface{}{
+		"status":     to,
+		"updated_at": at,
+	}
+	if to == domain.AssignmentPublished {
+		updates["published_at"] = at
+	}
+	result := s.db.WithContext(ctx).Model(&domain.Assignment{}).
+		Where("id = ? AND status = ?", id, from).
+		Updates(updates)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// ListScheduledBefore returns scheduled assignments whose publish time has passed.
+func (s *AssignmentStore) ListScheduledBefore(ctx context.Context, at time.Time) ([]domain.Assignment, error) {
+	var assignments []domain.Assignment
+	if err := s.db.WithContext(ctx).
+		Where("status = ? AND publish_at <= ?", domain.AssignmentScheduled, at).
+		Order("publish_at").
+		Find(&assignments).Error; err != nil {
+		return nil, err
+	}
+	return assignments, nil
+}
+
+// ListRevisions returns the change log of an assignment, newest first.
+func (s *AssignmentStore) ListRevisions(ctx context.Context, assignmentID string) ([]domain.AssignmentRevision, error) {
+	var revisions []domain.AssignmentRevision
+	if err := s.db.WithContext(ctx).
+		Where("assignment_id = ?", assignmentID).
+		Order("version DESC").
+		Find(&revisions).Error; err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+// Delete removes an unsubmitted assignment and the records hanging off it.
+func (s *AssignmentStore) Delete(ctx context.Context, id string) (bool, error) {
+	deleted := false
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// The submission check is part of the delete itself, so a submission
+		// committed after a separate count cannot be orphaned.
+		result := tx.Where("id = ? AND NOT EXISTS (?)", id,
+			tx.Model(&domain.AssignmentSubmission{}).Select("1").Where("assignment_id = ?", id),
+		).Delete(&domain.Assignment{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		for _, model := range []interface{}{&domain.AssignmentQuestion{}, &domain.AssignmentRevision{}, &domain.Attachment{}, &domain.SimilarityCheck{}} {
+			if err := tx.Where("assignment_id = ?", id).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		deleted = true
+		return nil
+	})
+	return deleted, err
+}
+
 var _ repository.AssignmentRepository = (*AssignmentStore)(nil)
@@ -0,0 +1,99 @@
+package gormrepo
+
+import (
+	"context"
+
+	"learn-go/internal/domain"
+	"learn-go/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+// AssignmentStatsStore implements repository.AssignmentStatsRepository with GORM.
+type AssignmentStatsStore struct {
+	db *gorm.DB
+}
+
+// NewAssignmentStatsStore creates an assignment statistics store instance.
+func NewAssignmentStatsStore(db *gorm.DB) *AssignmentStatsStore {
+	return &AssignmentStatsStore{db: db}
+}
+
+func (s *AssignmentStatsStore) ScoreSummary(ctx context.Context, assignmentID string) (*repository.ScoreSummary, error) {
+	var summary repository.ScoreSummary
+	err := s.db.WithContext(ctx).Model(&domain.AssignmentSubmission{}).
+		Select(`COUNT(CASE WHEN submitted_at IS NOT NULL THEN 1 END) AS submitted,
+			COUNT(CASE WHEN status = 'graded' AND score IS NOT NULL THEN 1 END) AS graded,
+			AVG(CASE WHEN status = 'graded' THEN score END) AS mean,
+			MIN(CASE WHEN status = 'graded' THEN score END) AS min,
+			MAX(CASE WHEN status = 'graded' THEN score END) AS max`).
+		Where("assignment_id = ?", assignmentID).
+		Scan(&summary).Error
+	if err != nil {
+		return nil, err
+	}
+	return &summary, nil
+}
+
+func (s *AssignmentStatsStore) ListGradedTotals(ctx context.Context, assignmentID string) ([]repository.SubmissionTotal, error) {
+	var rows []repository.SubmissionTotal
+	err := s.db.WithContext(ctx).Model(&domain.AssignmentSubmission{}).
+		Select("id AS submission_id, score").
+		Where("assignment_id = ? AND status = ? AND score IS NOT NULL", assignmentID, "graded").
+		Order("score").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+func (s *AssignmentStatsStore) QuestionAggregates(ctx context.Context, assignmentID string) ([]repository.QuestionAggregate, error) {
+	var rows []repository.QuestionAggregate
+	err := s.db.WithContext(ctx).
+		Table("submission_items si").
+		Joins("JOIN assignment_submissions s ON s.id = si.submission_id").
+		Select("si.question_id AS question_id, COUNT(si.score) AS graded, AVG(si.score) AS avg_score").
+		Where("s.assignment_id = ? AND s.status = ?", assignmentID, "graded").
+		Group("si.question_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+func (s *AssignmentStatsStore) ListGradedItemScores(ctx context.Context, assignmentID string) ([]repository.ItemScore, error) {
+	var rows []repository.ItemScore
+	err := s.db.WithContext(ctx).
+		Table("submission_items si").
+		Joins("JOIN assignment_submissions s ON s.id = si.submission_id").
+		Select("si.submission_id AS submission_id, si.question_id AS question_id, si.score AS score").
+		Where("s.assignment_id = ? AND s.status = ? AND si.score IS NOT NULL", assignmentID, "graded").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// ChoiceFrequencies counts submitted answers for choice and judgement questions.
+func (s *AssignmentStatsStore) ChoiceFrequencies(ctx context.Context, assignmentID string) ([]repository.AnswerFrequency, error) {
+	var rows []repository.AnswerFrequency
+	err := s.db.WithContext(ctx).
+		Table("submission_items si").
+		Joins("JOIN assignment_submissions s ON s.id = si.submission_id").
+		Joins("JOIN assignment_questions q ON q.id = si.question_id").
+		Select("si.question_id AS question_id, si.answer AS answer, COUNT(*) AS count").
+		Where("s.assignment_id = ? AND s.submitted_at IS NOT NULL", assignmentID).
+		Where("q.type IN ?", []domain.QuestionType{domain.QuestionChoice, domain.QuestionJudgement}).
+		Group("si.question_id, si.answer").
+		Order("si.question_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+var _ repository.AssignmentStatsRepository = (*AssignmentStatsStore)(nil)
@@ -0,0 +1,87 @@
+package gormrepo
+
+import (
+	"context"
+	"time"
+
+	"learn-go/internal/domain"
+	"learn-go/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+// AttachmentStore implements repository.AttachmentRepository with GORM.
+type AttachmentStore struct {
+	db *gorm.DB
+}
+
+// NewAttachmentStore creates an attachment store instance.
+func NewAttachmentStore(db *gorm.DB) *AttachmentStore {
+	return &AttachmentStore{db: db}
+}
+
+// Create persists attachment metadata.
+func (s *AttachmentStore) Create(ctx context.Context, attachment *domain.Attachment) error {
+	return s.db.WithContext(ctx).Create(attachment).Error
+}
+
+// GetByID loads a single attachment.
+func (s *AttachmentStore) GetByID(ctx context.Context, id string) (*domain.Attachment, error) {
+	var attachment domain.Attachment
+	if err := s.db.WithContext(ctx).First(&attachment, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &attachment, nil
+}
+
+// ListByIDs loads attachments by ID.
+func (s *AttachmentStore) ListByIDs(ctx context.Context, ids []string) ([]domain.Attachment, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	var attachments []domain.Attachment
+	if err := s.db.WithContext(ctx).Where("id IN ?", ids).Find(&attachments).Error; err != nil {
+		return nil, err
+	}
+	return attachments, nil
+}
+
+// Bind points attachments at their target record.
+func (s *AttachmentStore) Bind(ctx context.Context, ids []string, targetID string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return s.db.WithContext(ctx).Model(&domain.Attachment{}).
+		Where("id IN ?", ids).
+		Updates(map[string]interface{}{
+			"target_id":  targetID,
+			"status":     domain.AttachmentAttached,
+			"updated_at": time.Now(),
+		}).Error
+}
+
+// ListByTargets returns attachments bound to any of the given records, oldest first.
+func (s *AttachmentStore) ListByTargets(ctx context.Context, targetIDs []string) ([]domain.Attachment, error) {
+	if len(targetIDs) == 0 {
+		return nil, nil
+	}
+	var attachments []domain.Attachment
+	if err := s.db.WithContext(ctx).
+		Where("target_id IN ?", targetIDs).
+		Order("created_at ASC").
+		Find(&attachments).Error; err != nil {
+		return nil, err
+	}
+	return attachments, nil
+}
+
+// ListByAssignment returns every attachment uploaded for an assignment.
+func (s *AttachmentStore) ListByAssignment(ctx context.Context, assignmentID string) ([]domain.Attachment, error) {
+	var attachments []domain.Attachment
+	if err := s.db.WithContext(ctx).Where("assignment_id = ?", assignmentID).Find(&attachments).Error; err != nil {
+		return nil, err
+	}
+	return attachments, nil
+}
+
+var _ repository.AttachmentRepository = (*AttachmentStore)(nil)
@@ -0,0 +1,59 @@
+package gormrepo
+
+import (
+	"context"
+	"time"
+
+	"learn-go/internal/domain"
+	"learn-go/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+// ChatMediaStore implements repository.ChatMediaRepository with GORM.
+type ChatMediaStore struct {
+	db *gorm.DB
+}
+
+// NewChatMediaStore creates a chat media store instance.
+func NewChatMediaStore(db *gorm.DB) *ChatMediaStore {
+	return &ChatMediaStore{db: db}
+}
+
+// Create persists a media record.
+func (s *ChatMediaStore) Create(ctx context.Context, media *domain.ChatMedia) error {
+	return s.db.WithContext(ctx).Create(media).Error
+}
+
+// GetByID loads a single media record.
+func (s *ChatMediaStore) GetByID(ctx context.Context, id string) (*domain.ChatMedia, error) {
+	var media domain.ChatMedia
+	if err := s.db.WithContext(ctx).First(&media, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &media, nil
+}
+
+// Update saves processing results.
+func (s *ChatMediaStore) Update(ctx context.Context, media *domain.ChatMedia) error {
+	media.UpdatedAt = time.Now()
+	return s.db.WithContext(ctx).Save(media).Error
+}
+
+// ListByStatus returns the oldest media in the given state.
+func (s *ChatMediaStore) ListByStatus(ctx context.Context, status domain.ChatMediaStatus, limit int) ([]domain.ChatMedia, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	var media []domain.ChatMedia
+	if err := s.db.WithContext(ctx).
+		Where("status = ?", status).
+		Order("updated_at ASC").
+		Limit(limit).
+		Find(&media).Error; err != nil {
+		return nil, err
+	}
+	return media, nil
+}
+
+var _ repository.ChatMediaRepository = (*ChatMediaStore)(nil)
@@ -2,6 +2,7 @@ package gormrepo
 
 import (
 	"context"
+	"time"
 
 	"learn-go/internal/domain"
 	"learn-go/internal/repository"
@@ -42,4 +43,17 @@ func (s *ClassStore) GetByID(ctx context.Context, id string) (*domain.Class, err
 	return &class, nil
 }
 
+func (s *ClassStore) SetHomeroom(ctx context.Context, classID string, teacherID *string) error {
+	result := s.db.WithContext(ctx).Model(&domain.Class{}).
+		Where("id = ?", classID).
+		Updates(map[string]interface{}{"homeroom_id": teacherID, "updated_at": time.Now()})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
 var _ repository.ClassRepository = (*ClassStore)(nil)
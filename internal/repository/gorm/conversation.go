@@ -2,12 +2,14 @@ package gormrepo
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"learn-go/internal/domain"
 	"learn-go/internal/repository"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // ConversationStore implements ConversationRepository using GORM.
@@ -34,6 +36,20 @@ func (s *ConversationStore) Create(ctx context.Context, conversation *domain.Con
 	})
 }
 
+// CreateManaged inserts an empty class or course chat. It reports false,
+// inserting nothing, when the class (and course) already has one.
+func (s *ConversationStore) CreateManaged(ctx context.Context, conversation *domain.Conversation) (bool, error) {
+	result := s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:     []clause.Column{{Name: "type"}, {Name: "class_id"}, {Name: "course_id"}},
+		TargetWhere: clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: managedChatPredicate}}},
+		DoNothing:   true,
+	}).Create(conversation)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
 func (s *ConversationStore) GetByID(ctx context.Context, id string) (*domain.Conversation, error) {
 	var conv domain.Conversation
 	if err := s.db.WithContext(ctx).First(&conv, "id = ?", id).Error; err != nil {
@@ -55,14 +71,77 @@ func (s *ConversationStore) ListByAccount(ctx context.Context, accountID string)
 	return convs, nil
 }
 
+func (s *ConversationStore) ListPage(ctx context.Context, filter repository.ConversationPageFilter) ([]domain.Conversation, error) {
+	query := s.db.WithContext(ctx).
+		Joins("JOIN conversation_members cm ON cm.conversation_id = conversations.id").
+		Where("cm.account_id = ?", filter.AccountID)
+	if filter.Archived {
+		query = query.Where("cm.archived_at IS NOT NULL")
+	} else {
+		query = query.Where("cm.archived_at IS NULL")
+	}
+	if after := filter.After; after != nil {
+		older := s.db.Where("conversations.updated_at < ?", after.UpdatedAt).
+			Or("conversations.updated_at = ? AND conversations.id < ?", after.UpdatedAt, after.ID)
+		if after.Pinned {
+			query = query.Where(s.db.Where("cm.pinned_at IS NULL").Or(older))
+		} else {
+			query = query.Where("cm.pinned_at IS NULL").Where(older)
+		}
+	}
+
+	var convs []domain.Conversation
+	err := query.
+		Order("CASE WHEN cm.pinned_at IS NULL THEN 1 ELSE 0 END").
+		Order("conversations.updated_at DESC").
+		Order("conversations.id DESC").
+		Limit(filter.Limit).
+		Find(&convs).Error
+	if err != nil {
+		return nil, err
+	}
+	return convs, nil
+}
+
 func (s *ConversationStore) GetMembers(ctx context.Context, conversationID string) ([]domain.ConversationMember, error) {
 	var members []domain.ConversationMember
-	if err := s.db.WithContext(ctx).Where("conversation_id = ?", conversationID).Find(&members).Error; err != nil {
+	if err := s.db.WithContext(ctx).Where("conversation_id = ?", conversationID).Order("created_at ASC").Find(&members).Error; err != nil {
 		return nil, err
 	}
 	return members, nil
 }
 
+func (s *ConversationStore) ListViewerMembers(ctx context.Context, accountID string, conversationIDs []string) ([]domain.ConversationMember, error) {
+	if len(conversationIDs) == 0 {
+		return nil, nil
+	}
+	direct := s.db.Model(&domain.Conversation{}).Select("id").Where("id IN ? AND type = ?", conversationIDs, domain.ConversationDirect)
+	var members []domain.ConversationMember
+	if err := s.db.WithContext(ctx).
+		Where("conversation_id IN ?", conversationIDs).
+		Where("account_id = ? OR conversation_id IN (?)", accountID, direct).
+		Order("created_at ASC").
+		Find(&members).Error; err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+func (s *ConversationStore) CountMembers(ctx context.Context, conversationIDs []string) (map[string]int64, error) {
+	if len(conversationIDs) == 0 {
+		return map[string]int64{}, nil
+	}
+	var rows conversationCounts
+	if err := s.db.WithContext(ctx).Model(&domain.ConversationMember{}).
+		Select("conversation_id, COUNT(*) AS count").
+		Where("conversation_id IN ?", conversationIDs).
+		Group("conversation_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows.toMap(), nil
+}
+
 func (s *ConversationStore) IsMember(ctx context.Context, conversationID, accountID string) (bool, error) {
 	var count int64
 	if err := s.db.WithContext(ctx).Model(&domain.ConversationMember{}).
@@ -103,4 +182,260 @@ func (s *ConversationStore) UpdateTimestamp(ctx context.Context, conversationID
 	return nil
 }
 
+func (s *ConversationStore) UpdateName(ctx context.Context, conversationID, name string) error {
+	return s.db.WithContext(ctx).Model(&domain.Conversation{}).
+		Where("id = ?", conversationID).
+		Updates(map[string]interface{}{"name": name, "updated_at": time.Now()}).Error
+}
+
+func (s *ConversationStore) GetMember(ctx context.Context, conversationID, accountID string) (*domain.ConversationMember, error) {
+	var member domain.ConversationMember
+	if err := s.db.WithContext(ctx).
+		Where("conversation_id = ? AND account_id = ?", conversationID, accountID).
+		First(&member).Error; err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+// AddMembers stores new members with everything sent before they joined
+// marked as read.
+func (s *ConversationStore) AddMembers(ctx context.Context, members []domain.ConversationMember, limit int) (bool, error) {
+	if len(members) == 0 {
+		return true, nil
+	}
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if limit > 0 {
+			conversationID := members[0].ConversationID
+			// The no-op update locks the conversation row, so concurrent
+			// additions count the members one after another.
+			if err := tx.Model(&domain.Conversation{}).
+				Where("id = ?", conversationID).
+				UpdateColumn("last_seq", gorm.Expr("last_seq")).Error; err != nil {
+				return err
+			}
+			var count int64
+			if err := tx.Model(&domain.ConversationMember{}).Where("conversation_id = ?", conversationID).Count(&count).Error; err != nil {
+				return err
+			}
+			if int(count)+len(members) > limit {
+				return errMemberLimit
+			}
+		}
+		for i := range members {
+			var lastSeq int64
+			if err := tx.Model(&domain.Conversation{}).Where("id = ?", members[i].ConversationID).Pluck("last_seq", &lastSeq).Error; err != nil {
+				return err
+			}
+			members[i].LastReadSeq = lastSeq
+		}
+		return tx.Create(&members).Error
+	})
+	if errors.Is(err, errMemberLimit) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// errMemberLimit rolls back AddMembers when the conversation would grow past its limit.
+var errMemberLimit = errors.New("conversation member limit reached")
+
+func (s *ConversationStore) RemoveMember(ctx context.Context, conversationID, accountID string) error {
+	return s.db.WithContext(ctx).
+		Where("conversation_id = ? AND account_id = ?", conversationID, accountID).
+		Delete(&domain.ConversationMember{}).Error
+}
+
+func (s *ConversationStore) UpdateMemberRole(ctx context.Context, conversationID, accountID string, role domain.GroupRole) error {
+	return s.db.WithContext(ctx).Model(&domain.ConversationMember{}).
+		Where("conversation_id = ? AND account_id = ?", conversationID, accountID).
+		Update("group_role", role).Error
+}
+
+// TransferOwnership makes successorID the owner in place of ownerID, who
+// becomes an admin or, when leave is set, is removed.
+func (s *ConversationStore) TransferOwnership(ctx context.Context, conversationID, ownerID, successorID string, leave bool) (bool, error) {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		owner := tx.Where("conversation_id = ? AND account_id = ? AND group_role = ?", conversationID, ownerID, domain.GroupOwner)
+		var result *gorm.DB
+		if leave {
+			result = owner.Delete(&domain.ConversationMember{})
+		} else {
+			result = owner.Model(&domain.ConversationMember{}).Update("group_role", domain.GroupAdmin)
+		}
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != 1 {
+			return errOwnershipChanged
+		}
+		result = tx.Model(&domain.ConversationMember{}).
+			Where("conversation_id = ? AND account_id = ?", conversationID, successorID).
+			Update("group_role", domain.GroupOwner)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != 1 {
+			return errOwnershipChanged
+		}
+		return nil
+	})
+	if errors.Is(err, errOwnershipChanged) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// errOwnershipChanged rolls back TransferOwnership when the owner or the
+// successor changed meanwhile.
+var errOwnershipChanged = errors.New("group ownership changed")
+
+func (s *ConversationStore) SetAnnouncementOnly(ctx context.Context, conversationID string, enabled bool) error {
+	return s.db.WithContext(ctx).Model(&domain.Conversation{}).
+		Where("id = ?", conversationID).
+		Updates(map[string]interface{}{"announcement_only": enabled, "updated_at": time.Now()}).Error
+}
+
+func (s *ConversationStore) FindManaged(ctx context.Context, conversationType, classID, courseID string) (*domain.Conversation, error) {
+	var conv domain.Conversation
+	if err := s.db.WithContext(ctx).
+		Where("type = ? AND class_id = ? AND course_id = ?", conversationType, classID, courseID).
+		First(&conv).Error; err != nil {
+		return nil, err
+	}
+	return &conv, nil
+}
+
+func (s *ConversationStore) ListManagedByClass(ctx context.Context, classID string) ([]domain.Conversation, error) {
+	var convs []domain.Conversation
+	if err := s.db.WithContext(ctx).
+		Where("class_id = ? AND type IN ?", classID, []string{domain.ConversationClass, domain.ConversationCourse}).
+		Order("created_at ASC").
+		Find(&convs).Error; err != nil {
+		return nil, err
+	}
+	return convs, nil
+}
+
+func (s *ConversationStore) ListContactIDs(ctx context.Context, accountID string) ([]string, error) {
+	var ids []string
+	if err := s.db.WithContext(ctx).Model(&domain.ConversationMember{}).
+		Distinct("account_id").
+		Where("conversation_id IN (?)", s.db.Model(&domain.ConversationMember{}).Select("conversation_id").Where("account_id = ?", accountID)).
+		Where("account_id <> ?", accountID).
+		Pluck("account_id", &ids).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func (s *ConversationStore) MarkRead(ctx context.Context, conversationID, accountID string, seq int64, at time.Time) error {
+	return s.db.WithContext(ctx).Model(&domain.ConversationMember{}).
+		Where("conversation_id = ? AND account_id = ? AND last_read_seq < ?", conversationID, accountID, seq).
+		Updates(map[string]interface{}{"last_read_seq": seq, "last_read_at": at}).Error
+}
+
+func (s *ConversationStore) CountUnread(ctx context.Context, accountID string, conversationIDs []string) (map[string]int64, error) {
+	if conversationIDs != nil && len(conversationIDs) == 0 {
+		return map[string]int64{}, nil
+	}
+	hidden := s.db.Model(&domain.MessageDeletion{}).
+		Select("1").
+		Where("message_deletions.message_id = m.id AND message_deletions.account_id = ?", accountID)
+	query := s.db.WithContext(ctx).
+		Table("messages m").
+		Select("m.conversation_id, COUNT(*) AS count").
+		Joins("JOIN conversation_members cm ON cm.conversation_id = m.conversation_id AND cm.account_id = ?", accountID).
+		Where("m.seq > cm.last_read_seq AND m.sender_id <> ? AND m.kind <> ?", accountID, "system").
+		Where("NOT EXISTS (?)", hidden)
+	if conversationIDs != nil {
+		query = query.Where("m.conversation_id IN ?", conversationIDs)
+	}
+	var rows conversationCounts
+	if err := query.Group("m.conversation_id").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows.toMap(), nil
+}
+
+// conversationCount is a row of a per-conversation count.
+type conversationCount struct {
+	ConversationID string
+	Count          int64
+}
+
+func (s *ConversationStore) SaveSettings(ctx context.Context, member *domain.ConversationMember) error {
+	return s.db.WithContext(ctx).Model(&domain.ConversationMember{}).
+		Where("id = ?", member.ID).
+		Updates(map[string]interface{}{
+			"pinned_at":   member.PinnedAt,
+			"muted_until": member.MutedUntil,
+			"archived_at": member.ArchivedAt,
+			"title":       member.Title,
+		}).Error
+}
+
+func (s *ConversationStore) CountPinned(ctx context.Context, accountID string) (int64, error) {
+	var count int64
+	err := s.db.WithContext(ctx).Model(&domain.ConversationMember{}).
+		Where("account_id = ? AND pinned_at IS NOT NULL", accountID).
+		Count(&count).Error
+	return count, err
+}
+
+type conversationCounts []conversationCount
+
+func (rows conversationCounts) toMap() map[string]int64 {
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.ConversationID] = row.Count
+	}
+	return counts
+}
+
+const managedChatPredicate = "type IN ('class', 'course')"
+
+// PrepareManagedChats adds the partial unique index that allows one chat per
+// class and course, so concurrent roster syncs cannot create two. Duplicates
+// left from before the index become plain groups, keeping their history; the
+// oldest chat of each class and course stays managed.
+func PrepareManagedChats(db *gorm.DB) error {
+	if err := db.Exec(`UPDATE conversations SET type = 'group'
+		WHERE ` + managedChatPredicate + ` AND EXISTS (
+			SELECT 1 FROM conversations AS older
+			WHERE older.type = conversations.type
+				AND older.class_id = conversations.class_id
+				AND older.course_id = conversations.course_id
+				AND (older.created_at < conversations.created_at
+					OR (older.created_at = conversations.created_at AND older.id < conversations.id)))`).Error; err != nil {
+		return err
+	}
+	return db.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_conversations_managed
+		ON conversations (type, class_id, course_id) WHERE ` + managedChatPredicate).Error
+}
+
+// MigrateMessageReceipts moves read state from the per-message receipts
+// used before members tracked a read position: each member's position is
+// set before its first unread receipt, or to the end of the conversation if
+// it read everything. The receipts table is dropped afterwards.
+func MigrateMessageReceipts(db *gorm.DB) error {
+	if !db.Migrator().HasTable("message_receipts") {
+		return nil
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec(`UPDATE conversation_members SET
+	last_read_seq = COALESCE(
+		(SELECT MIN(m.seq) - 1 FROM message_receipts r JOIN messages m ON m.id = r.message_id
+			WHERE r.account_id = conversation_members.account_id AND m.conversation_id = conversation_members.conversation_id AND r.read_at IS NULL),
+		(SELECT c.last_seq FROM conversations c WHERE c.id = conversation_members.conversation_id)),
+	last_read_at = (SELECT MAX(r.read_at) FROM message_receipts r JOIN messages m ON m.id = r.message_id
+		WHERE r.account_id = conversation_members.account_id AND m.conversation_id = conversation_members.conversation_id)
+WHERE last_read_seq = 0`).Error
+		if err != nil {
+			return err
+		}
+		return tx.Migrator().DropTable("message_receipts")
+	})
+}
+
 var _ repository.ConversationRepository = (*ConversationStore)(nil)
@@ -0,0 +1,41 @@
+package gormrepo
+
+import (
+	"context"
+
+	"learn-go/internal/domain"
+	"learn-go/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+// CourseStore implements repository.CourseRepository using GORM.
+type CourseStore struct {
+	db *gorm.DB
+}
+
+// NewCourseStore creates a course store instance.
+func NewCourseStore(db *gorm.DB) *CourseStore {
+	return &CourseStore{db: db}
+}
+
+func (s *CourseStore) GetByID(ctx context.Context, id string) (*domain.Course, error) {
+	var course domain.Course
+	if err := s.db.WithContext(ctx).First(&course, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &course, nil
+}
+
+func (s *CourseStore) ListTeacherIDs(ctx context.Context, classID, courseID string) ([]string, error) {
+	var ids []string
+	if err := s.db.WithContext(ctx).Model(&domain.CourseSession{}).
+		Where("class_id = ? AND course_id = ? AND teacher_id <> ''", classID, courseID).
+		Distinct("teacher_id").
+		Pluck("teacher_id", &ids).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+var _ repository.CourseRepository = (*CourseStore)(nil)
@@ -0,0 +1,48 @@
+package gormrepo
+
+import (
+	"context"
+
+	"learn-go/internal/domain"
+	"learn-go/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+// GradeCategoryStore implements repository.GradeCategoryRepository with GORM.
+type GradeCategoryStore struct {
+	db *gorm.DB
+}
+
+// NewGradeCategoryStore creates a grade category store instance.
+func NewGradeCategoryStore(db *gorm.DB) *GradeCategoryStore {
+	return &GradeCategoryStore{db: db}
+}
+
+func (s *GradeCategoryStore) ListByClassCourse(ctx context.Context, classID, courseID string) ([]domain.GradeCategory, error) {
+	var categories []domain.GradeCategory
+	if err := s.db.WithContext(ctx).
+		Where("class_id = ? AND course_id = ?", classID, courseID).
+		Order("created_at").
+		Find(&categories).Error; err != nil {
+		return nil, err
+	}
+	return categories, nil
+}
+
+// Replace swaps the whole category set for a class/course atomically.
+func (s *GradeCategoryStore) Replace(ctx context.Context, classID, courseID string, categories []domain.GradeCategory) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("class_id = ? AND course_id = ?", classID, courseID).Delete(&domain.GradeCategory{}).Error; err != nil {
+			return err
+		}
+		if len(categories) > 0 {
+			if err := tx.Create(&categories).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+var _ repository.GradeCategoryRepository = (*GradeCategoryStore)(nil)
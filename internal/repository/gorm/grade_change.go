@@ -0,0 +1,34 @@
+package gormrepo
+
+import (
+	"context"
+
+	"learn-go/internal/domain"
+	"learn-go/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+// GradeChangeStore implements repository.GradeHistoryRepository with GORM.
+type GradeChangeStore struct {
+	db *gorm.DB
+}
+
+// NewGradeChangeStore creates a grade change store instance.
+func NewGradeChangeStore(db *gorm.DB) *GradeChangeStore {
+	return &GradeChangeStore{db: db}
+}
+
+// ListBySubmission returns grade changes for a submission, oldest first.
+func (s *GradeChangeStore) ListBySubmission(ctx context.Context, submissionID string) ([]domain.GradeChange, error) {
+	var changes []domain.GradeChange
+	if err := s.db.WithContext(ctx).
+		Where("submission_id = ?", submissionID).
+		Order("created_at ASC").
+		Find(&changes).Error; err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+var _ repository.GradeHistoryRepository = (*GradeChangeStore)(nil)
@@ -3,11 +3,13 @@ package gormrepo
 import (
 	"context"
 	"errors"
+	"time"
 
 	"learn-go/internal/domain"
 	"learn-go/internal/repository"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // MessageStore implements MessageRepository using GORM.
@@ -21,10 +23,111 @@ func NewMessageStore(db *gorm.DB) *MessageStore {
 }
 
 func (s *MessageStore) Create(ctx context.Context, message *domain.Message) error {
-	return s.db.WithContext(ctx).Create(message).Error
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return createMessage(tx, message)
+	})
 }
 
-func (s *MessageStore) ListByConversation(ctx context.Context, conversationID string, limit int, beforeID string) ([]domain.Message, error) {
+func (s *MessageStore) CreateWithMedia(ctx context.Context, message *domain.Message, media *domain.ChatMedia) (bool, error) {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&domain.ChatMedia{}).
+			Where("id = ? AND status = ?", media.ID, domain.ChatMediaPending).
+			Updates(map[string]interface{}{
+				"message_id":   message.ID,
+				"content_type": media.ContentType,
+				"size":         media.Size,
+				"status":       domain.ChatMediaAttached,
+				"updated_at":   time.Now(),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != 1 {
+			return errMediaUsed
+		}
+		return createMessage(tx, message)
+	})
+	if errors.Is(err, errMediaUsed) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// errMediaUsed rolls back CreateWithMedia when the media is no longer pending.
+var errMediaUsed = errors.New("media already used")
+
+// createMessage inserts message under the next sequence number of its conversation.
+func createMessage(tx *gorm.DB, message *domain.Message) error {
+	// The update locks the conversation row until commit, so concurrent
+	// senders get consecutive numbers and commit in sequence order.
+	if err := tx.Model(&domain.Conversation{}).
+		Where("id = ?", message.ConversationID).
+		UpdateColumn("last_seq", gorm.Expr("last_seq + 1")).Error; err != nil {
+		return err
+	}
+	var conv domain.Conversation
+	if err := tx.Select("last_seq").First(&conv, "id = ?", message.ConversationID).Error; err != nil {
+		return err
+	}
+	message.Seq = conv.LastSeq
+	return tx.Create(message).Error
+}
+
+func (s *MessageStore) GetByClientMsgID(ctx context.Context, senderID, clientMsgID string) (*domain.Message, error) {
+	var msg domain.Message
+	if err := s.db.WithContext(ctx).First(&msg, "sender_id = ? AND client_msg_id = ?", senderID, clientMsgID).Error; err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+func (s *MessageStore) ListAfterSeq(ctx context.Context, conversationID, viewerID string, afterSeq, upToSeq int64, limit int) ([]domain.Message, error) {
+	query := s.db.WithContext(ctx).Where("conversation_id = ? AND seq > ? AND seq <= ?", conversationID, afterSeq, upToSeq)
+	if viewerID != "" {
+		hidden := s.db.Model(&domain.MessageDeletion{}).
+			Select("message_id").
+			Where("conversation_id = ? AND account_id = ?", conversationID, viewerID)
+		query = query.Where("id NOT IN (?)", hidden)
+	}
+
+	var messages []domain.Message
+	if err := query.Order("seq ASC").Limit(limit).Find(&messages).Error; err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// BackfillMessageSeq numbers messages stored before sequence numbers existed,
+// in creation order, and brings each conversation's last_seq up to date.
+func BackfillMessageSeq(db *gorm.DB) error {
+	var conversationIDs []string
+	if err := db.Model(&domain.Message{}).Distinct("conversation_id").Where("seq = 0").Pluck("conversation_id", &conversationIDs).Error; err != nil {
+		return err
+	}
+	for _, conversationID := range conversationIDs {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var ids []string
+			if err := tx.Model(&domain.Message{}).
+				Where("conversation_id = ?", conversationID).
+				Order("created_at ASC").Order("id ASC").
+				Pluck("id", &ids).Error; err != nil {
+				return err
+			}
+			for i, id := range ids {
+				if err := tx.Model(&domain.Message{}).Where("id = ?", id).UpdateColumn("seq", i+1).Error; err != nil {
+					return err
+				}
+			}
+			return tx.Model(&domain.Conversation{}).Where("id = ?", conversationID).UpdateColumn("last_seq", len(ids)).Error
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *MessageStore) ListByConversation(ctx context.Context, conversationID, viewerID string, limit int, beforeID string) ([]domain.Message, error) {
 	if limit <= 0 {
 		limit = 50
 	}
@@ -33,6 +136,12 @@ func (s *MessageStore) ListByConversation(ctx context.Context, conversationID st
 	}
 
 	query := s.db.WithContext(ctx).Where("conversation_id = ?", conversationID)
+	if viewerID != "" {
+		hidden := s.db.Model(&domain.MessageDeletion{}).
+			Select("message_id").
+			Where("conversation_id = ? AND account_id = ?", conversationID, viewerID)
+		query = query.Where("id NOT IN (?)", hidden)
+	}
 
 	if beforeID != "" {
 		var pivot domain.Message
@@ -63,6 +172,25 @@ func (s *MessageStore) GetLastByConversation(ctx context.Context, conversationID
 	return &msg, nil
 }
 
+func (s *MessageStore) ListLastByConversations(ctx context.Context, conversationIDs []string) ([]domain.Message, error) {
+	if len(conversationIDs) == 0 {
+		return nil, nil
+	}
+	newest := s.db.Model(&domain.Message{}).
+		Select("conversation_id, MAX(seq) AS seq").
+		Where("conversation_id IN ?", conversationIDs).
+		Group("conversation_id")
+
+	var messages []domain.Message
+	err := s.db.WithContext(ctx).
+		Joins("JOIN (?) newest ON newest.conversation_id = messages.conversation_id AND newest.seq = messages.seq", newest).
+		Find(&messages).Error
+	if err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
 func (s *MessageStore) GetByID(ctx context.Context, id string) (*domain.Message, error) {
 	var msg domain.Message
 	if err := s.db.WithContext(ctx).First(&msg, "id = ?", id).Error; err != nil {
@@ -71,4 +199,91 @@ func (s *MessageStore) GetByID(ctx context.Context, id string) (*domain.Message,
 	return &msg, nil
 }
 
+// ListByIDs loads messages by ID in no particular order.
+func (s *MessageStore) ListByIDs(ctx context.Context, ids []string) ([]domain.Message, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	var messages []domain.Message
+	if err := s.db.WithContext(ctx).Where("id IN ?", ids).Find(&messages).Error; err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// UpdateMetadata replaces a message's metadata JSON.
+func (s *MessageStore) UpdateMetadata(ctx context.Context, id, metadata string) error {
+	return s.db.WithContext(ctx).Model(&domain.Message{}).Where("id = ?", id).Update("metadata", metadata).Error
+}
+
+func (s *MessageStore) Edit(ctx context.Context, message *domain.Message, previous *domain.MessageEdit) (bool, error) {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(previous).Error; err != nil {
+			return err
+		}
+		// A recall or moderation removal committed since the message was
+		// read must not be overwritten by the edit.
+		result := tx.Model(&domain.Message{}).
+			Where("id = ? AND recalled_at IS NULL AND removed_at IS NULL", message.ID).
+			Updates(map[string]interface{}{
+				"text":      message.Text,
+				"metadata":  message.Metadata,
+				"edited_at": message.EditedAt,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != 1 {
+			return errMessageTombstoned
+		}
+		return nil
+	})
+	if errors.Is(err, errMessageTombstoned) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// errMessageTombstoned rolls back Edit when the message was recalled or removed.
+var errMessageTombstoned = errors.New("message recalled or removed")
+
+func (s *MessageStore) ListEdits(ctx context.Context, messageID string) ([]domain.MessageEdit, error) {
+	var edits []domain.MessageEdit
+	if err := s.db.WithContext(ctx).
+		Where("message_id = ?", messageID).
+		Order("created_at ASC").
+		Find(&edits).Error; err != nil {
+		return nil, err
+	}
+	return edits, nil
+}
+
+func (s *MessageStore) Recall(ctx context.Context, id string, at time.Time) (bool, error) {
+	recalled := false
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&domain.Message{}).
+			Where("id = ? AND recalled_at IS NULL", id).
+			Updates(map[string]interface{}{
+				"text":        "",
+				"media_uri":   "",
+				"media_id":    "",
+				"metadata":    "",
+				"recalled_at": at,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		recalled = true
+		return tx.Where("message_id = ?", id).Delete(&domain.MessageEdit{}).Error
+	})
+	return recalled, err
+}
+
+func (s *MessageStore) HideForAccount(ctx context.Context, deletion *domain.MessageDeletion) error {
+	return s.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(deletion).Error
+}
+
 var _ repository.MessageRepository = (*MessageStore)(nil)
@@ -0,0 +1,79 @@
+package gormrepo
+
+import (
+	"context"
+	"time"
+
+	"learn-go/internal/domain"
+	"learn-go/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+// MessageMentionStore implements MessageMentionRepository using GORM.
+type MessageMentionStore struct {
+	db *gorm.DB
+}
+
+// NewMessageMentionStore creates a message mention store instance.
+func NewMessageMentionStore(db *gorm.DB) *MessageMentionStore {
+	return &MessageMentionStore{db: db}
+}
+
+func (s *MessageMentionStore) CreateBatch(ctx context.Context, mentions []domain.MessageMention) error {
+	if len(mentions) == 0 {
+		return nil
+	}
+	return s.db.WithContext(ctx).Create(&mentions).Error
+}
+
+func (s *MessageMentionStore) ListByMessages(ctx context.Context, messageIDs []string) ([]domain.MessageMention, error) {
+	if len(messageIDs) == 0 {
+		return nil, nil
+	}
+	var mentions []domain.MessageMention
+	if err := s.db.WithContext(ctx).Where("message_id IN ?", messageIDs).Find(&mentions).Error; err != nil {
+		return nil, err
+	}
+	return mentions, nil
+}
+
+func (s *MessageMentionStore) ListByAccount(ctx context.Context, accountID string, unreadOnly bool, limit int) ([]domain.MessageMention, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 50
+	}
+	query := s.db.WithContext(ctx).Where("account_id = ?", accountID)
+	if unreadOnly {
+		query = query.Where("read_at IS NULL")
+	}
+	var mentions []domain.MessageMention
+	if err := query.Order("created_at DESC").Limit(limit).Find(&mentions).Error; err != nil {
+		return nil, err
+	}
+	return mentions, nil
+}
+
+func (s *MessageMentionStore) CountUnread(ctx context.Context, accountID string, conversationIDs []string) (map[string]int64, error) {
+	query := s.db.WithContext(ctx).Model(&domain.MessageMention{}).
+		Select("conversation_id, COUNT(*) AS count").
+		Where("account_id = ? AND read_at IS NULL", accountID)
+	if conversationIDs != nil {
+		if len(conversationIDs) == 0 {
+			return map[string]int64{}, nil
+		}
+		query = query.Where("conversation_id IN ?", conversationIDs)
+	}
+	var rows conversationCounts
+	if err := query.Group("conversation_id").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows.toMap(), nil
+}
+
+func (s *MessageMentionStore) MarkReadUpTo(ctx context.Context, accountID, conversationID string, ts time.Time) error {
+	return s.db.WithContext(ctx).Model(&domain.MessageMention{}).
+		Where("account_id = ? AND conversation_id = ? AND read_at IS NULL AND created_at <= ?", accountID, conversationID, ts).
+		Update("read_at", time.Now()).Error
+}
+
+var _ repository.MessageMentionRepository = (*MessageMentionStore)(nil)
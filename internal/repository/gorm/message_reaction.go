@@ -0,0 +1,68 @@
+package gormrepo
+
+import (
+	"context"
+
+	"learn-go/internal/domain"
+	"learn-go/internal/repository"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MessageReactionStore implements MessageReactionRepository using GORM.
+type MessageReactionStore struct {
+	db *gorm.DB
+}
+
+// NewMessageReactionStore creates a message reaction store instance.
+func NewMessageReactionStore(db *gorm.DB) *MessageReactionStore {
+	return &MessageReactionStore{db: db}
+}
+
+func (s *MessageReactionStore) Toggle(ctx context.Context, reaction *domain.MessageReaction) (bool, error) {
+	added := false
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("message_id = ? AND account_id = ? AND emoji = ?", reaction.MessageID, reaction.AccountID, reaction.Emoji).
+			Delete(&domain.MessageReaction{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected > 0 {
+			return nil
+		}
+		added = true
+		result = tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "message_id"}, {Name: "account_id"}, {Name: "emoji"}},
+			DoNothing: true,
+		}).Create(reaction)
+		if result.Error != nil || result.RowsAffected > 0 {
+			return result.Error
+		}
+		// A concurrent toggle added the same reaction since the delete; it
+		// counts as already added, so report the stored row.
+		return tx.Where("message_id = ? AND account_id = ? AND emoji = ?", reaction.MessageID, reaction.AccountID, reaction.Emoji).
+			First(reaction).Error
+	})
+	return added, err
+}
+
+func (s *MessageReactionStore) ListByMessages(ctx context.Context, messageIDs []string) ([]domain.MessageReaction, error) {
+	if len(messageIDs) == 0 {
+		return nil, nil
+	}
+	var reactions []domain.MessageReaction
+	if err := s.db.WithContext(ctx).
+		Where("message_id IN ?", messageIDs).
+		Order("created_at ASC").
+		Find(&reactions).Error; err != nil {
+		return nil, err
+	}
+	return reactions, nil
+}
+
+func (s *MessageReactionStore) DeleteByMessage(ctx context.Context, messageID string) error {
+	return s.db.WithContext(ctx).Where("message_id = ?", messageID).Delete(&domain.MessageReaction{}).Error
+}
+
+var _ repository.MessageReactionRepository = (*MessageReactionStore)(nil)
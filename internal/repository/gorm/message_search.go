@@ -0,0 +1,183 @@
+package gormrepo
+
+import (
+	"context"
+	"strings"
+	"unicode/utf8"
+
+	"learn-go/internal/domain"
+	"learn-go/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+// messageSearchTable is the SQLite FTS5 index over message text.
+const messageSearchTable = "messages_fts"
+
+// messageSearchMode is how a database matches search terms.
+type messageSearchMode int
+
+const (
+	// searchLike scans with LIKE; used when SQLite lacks FTS5.
+	searchLike messageSearchMode = iota
+	// searchFTS5 queries the SQLite trigram FTS5 table.
+	searchFTS5
+	// searchPostgres combines a 'simple' tsvector with pg_trgm for CJK text.
+	searchPostgres
+)
+
+// PrepareMessageSearch creates the full-text indexes for message search. On
+// PostgreSQL it adds a tsvector index and, if pg_trgm can be enabled, a
+// trigram index that serves ILIKE for Chinese text, which has no word
+// boundaries for the tsvector parser. On SQLite it builds a trigram FTS5
+// table kept current by triggers; binaries built without the sqlite_fts5 tag
+// lack FTS5 and search falls back to LIKE scans.
+func PrepareMessageSearch(db *gorm.DB) error {
+	switch db.Dialector.Name() {
+	case "postgres":
+		if err := db.Exec(`CREATE INDEX IF NOT EXISTS idx_messages_text_tsv ON messages USING gin (to_tsvector('simple', text))`).Error; err != nil {
+			return err
+		}
+		if err := db.Exec(`CREATE EXTENSION IF NOT EXISTS pg_trgm`).Error; err != nil {
+			// Needs a privileged role; ILIKE still works, just unindexed.
+			return nil
+		}
+		return db.Exec(`CREATE INDEX IF NOT EXISTS idx_messages_text_trgm ON messages USING gin (text gin_trgm_ops)`).Error
+	case "sqlite":
+		if db.Migrator().HasTable(messageSearchTable) {
+			return nil
+		}
+		err := db.Exec(`CREATE VIRTUAL TABLE ` + messageSearchTable + ` USING fts5(message_id UNINDEXED, text, tokenize = 'trigram')`).Error
+		if err != nil {
+			if strings.Contains(err.Error(), "no such module") {
+				return nil
+			}
+			return err
+		}
+		return db.Transaction(func(tx *gorm.DB) error {
+			for _, stmt := range []string{
+				`CREATE TRIGGER messages_fts_insert AFTER INSERT ON messages BEGIN
+					INSERT INTO messages_fts (message_id, text) VALUES (new.id, new.text);
+				END`,
+				`CREATE TRIGGER messages_fts_update AFTER UPDATE OF text ON messages BEGIN
+					DELETE FROM messages_fts WHERE message_id = old.id;
+					INSERT INTO messages_fts (message_id, text) VALUES (new.id, new.text);
+				END`,
+				`CREATE TRIGGER messages_fts_delete AFTER DELETE ON messages BEGIN
+					DELETE FROM messages_fts WHERE message_id = old.id;
+				END`,
+				`INSERT INTO messages_fts (message_id, text) SELECT id, text FROM messages`,
+			} {
+				if err := tx.Exec(stmt).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	default:
+		return nil
+	}
+}
+
+// MessageSearchStore implements MessageSearchRepository using GORM.
+type MessageSearchStore struct {
+	db   *gorm.DB
+	mode messageSearchMode
+}
+
+// NewMessageSearchStore creates a message search store, picking the matching
+// strategy PrepareMessageSearch set up for the database.
+func NewMessageSearchStore(db *gorm.DB) *MessageSearchStore {
+	mode := searchLike
+	switch {
+	case db.Dialector.Name() == "postgres":
+		mode = searchPostgres
+	case db.Migrator().HasTable(messageSearchTable):
+		mode = searchFTS5
+	}
+	return &MessageSearchStore{db: db, mode: mode}
+}
+
+func (s *MessageSearchStore) Search(ctx context.Context, filter repository.MessageSearchFilter) ([]domain.Message, int64, error) {
+	if filter.Page <= 0 {
+		filter.Page = 1
+	}
+	if filter.Size <= 0 || filter.Size > 100 {
+		filter.Size = 20
+	}
+
+	members := s.db.Model(&domain.ConversationMember{}).Select("conversation_id").Where("account_id = ?", filter.AccountID)
+	hidden := s.db.Model(&domain.MessageDeletion{}).Select("message_id").Where("account_id = ?", filter.AccountID)
+	query := s.db.WithContext(ctx).Model(&domain.Message{}).
+		Where("messages.conversation_id IN (?)", members).
+		Where("messages.id NOT IN (?)", hidden).
+		Where("messages.recalled_at IS NULL AND messages.removed_at IS NULL AND messages.kind <> ?", "system")
+	if filter.ConversationID != "" {
+		query = query.Where("messages.conversation_id = ?", filter.ConversationID)
+	}
+	if filter.SenderID != "" {
+		query = query.Where("messages.sender_id = ?", filter.SenderID)
+	}
+	if filter.Kind != "" {
+		query = query.Where("messages.kind = ?", filter.Kind)
+	}
+	if filter.From != nil {
+		query = query.Where("messages.created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("messages.created_at < ?", *filter.To)
+	}
+	query = s.matchTerms(query, filter.Terms)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var messages []domain.Message
+	offset := (filter.Page - 1) * filter.Size
+	if err := query.Order("messages.created_at DESC").Order("messages.id DESC").
+		Offset(offset).Limit(filter.Size).Find(&messages).Error; err != nil {
+		return nil, 0, err
+	}
+	return messages, total, nil
+}
+
+func (s *MessageSearchStore) matchTerms(query *gorm.DB, terms []string) *gorm.DB {
+	switch s.mode {
+	case searchPostgres:
+		for _, term := range terms {
+			query = query.Where("(to_tsvector('simple', messages.text) @@ plainto_tsquery('simple', ?) OR messages.text ILIKE ?)", term, likePattern(term))
+		}
+	case searchFTS5:
+		// Trigram MATCH needs at least three characters; shorter terms use
+		// LIKE on the FTS table, which it can still answer.
+		var phrases []string
+		fts := s.db.Table(messageSearchTable).Select("message_id")
+		for _, term := range terms {
+			if utf8.RuneCountInString(term) >= 3 {
+				phrases = append(phrases, `"`+strings.ReplaceAll(term, `"`, `""`)+`"`)
+				continue
+			}
+			fts = fts.Where("text LIKE ? ESCAPE '\\'", likePattern(term))
+		}
+		if len(phrases) > 0 {
+			fts = fts.Where(messageSearchTable+" MATCH ?", strings.Join(phrases, " AND "))
+		}
+		if len(terms) > 0 {
+			query = query.Where("messages.id IN (?)", fts)
+		}
+	default:
+		for _, term := range terms {
+			query = query.Where("messages.text LIKE ? ESCAPE '\\'", likePattern(term))
+		}
+	}
+	return query
+}
+
+// likePattern builds a substring pattern with LIKE wildcards escaped.
+func likePattern(term string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return "%" + replacer.Replace(term) + "%"
+}
+
+var _ repository.MessageSearchRepository = (*MessageSearchStore)(nil)
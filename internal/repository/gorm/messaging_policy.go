@@ -0,0 +1,34 @@
+package gormrepo
+
+import (
+	"context"
+
+	"learn-go/internal/domain"
+	"learn-go/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+// MessagingPolicyStore implements MessagingPolicyRepository using GORM.
+type MessagingPolicyStore struct {
+	db *gorm.DB
+}
+
+// NewMessagingPolicyStore creates a messaging policy store.
+func NewMessagingPolicyStore(db *gorm.DB) *MessagingPolicyStore {
+	return &MessagingPolicyStore{db: db}
+}
+
+func (s *MessagingPolicyStore) Get(ctx context.Context, schoolID string) (*domain.MessagingPolicy, error) {
+	var policy domain.MessagingPolicy
+	if err := s.db.WithContext(ctx).First(&policy, "school_id = ?", schoolID).Error; err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+func (s *MessagingPolicyStore) Save(ctx context.Context, policy *domain.MessagingPolicy) error {
+	return s.db.WithContext(ctx).Save(policy).Error
+}
+
+var _ repository.MessagingPolicyRepository = (*MessagingPolicyStore)(nil)
@@ -0,0 +1,223 @@
+package gormrepo
+
+import (
+	"context"
+	"fmt"
+
+	"learn-go/internal/domain"
+	"learn-go/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+// ModerationRuleStore implements ModerationRuleRepository using GORM.
+type ModerationRuleStore struct {
+	db *gorm.DB
+}
+
+// NewModerationRuleStore creates a moderation rule store.
+func NewModerationRuleStore(db *gorm.DB) *ModerationRuleStore {
+	return &ModerationRuleStore{db: db}
+}
+
+func (s *ModerationRuleStore) Create(ctx context.Context, rule *domain.ModerationRule) error {
+	return s.db.WithContext(ctx).Create(rule).Error
+}
+
+func (s *ModerationRuleStore) Update(ctx context.Context, rule *domain.ModerationRule) error {
+	return s.db.WithContext(ctx).Save(rule).Error
+}
+
+func (s *ModerationRuleStore) Delete(ctx context.Context, id string) error {
+	result := s.db.WithContext(ctx).Delete(&domain.ModerationRule{}, "id = ?", id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (s *ModerationRuleStore) GetByID(ctx context.Context, id string) (*domain.ModerationRule, error) {
+	var rule domain.ModerationRule
+	if err := s.db.WithContext(ctx).First(&rule, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+func (s *ModerationRuleStore) ListBySchool(ctx context.Context, schoolID string) ([]domain.ModerationRule, error) {
+	var rules []domain.ModerationRule
+	if err := s.db.WithContext(ctx).
+		Where("school_id = ?", schoolID).
+		Order("created_at ASC").Order("id ASC").
+		Find(&rules).Error; err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+var _ repository.ModerationRuleRepository = (*ModerationRuleStore)(nil)
+
+// ModerationCaseStore implements ModerationCaseRepository using GORM.
+type ModerationCaseStore struct {
+	db *gorm.DB
+}
+
+// NewModerationCaseStore creates a moderation case store.
+func NewModerationCaseStore(db *gorm.DB) *ModerationCaseStore {
+	return &ModerationCaseStore{db: db}
+}
+
+func (s *ModerationCaseStore) Create(ctx context.Context, c *domain.ModerationCase) error {
+	return s.db.WithContext(ctx).Create(c).Error
+}
+
+func (s *ModerationCaseStore) GetByID(ctx context.Context, id string) (*domain.ModerationCase, error) {
+	var c domain.ModerationCase
+	if err := s.db.WithContext(ctx).First(&c, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (s *ModerationCaseStore) FindOpen(ctx context.Context, targetType domain.ModerationTarget, targetID, reporterID string) (*domain.ModerationCase, error) {
+	var c domain.ModerationCase
+	if err := s.db.WithContext(ctx).
+		Where("target_type = ? AND target_id = ? AND reporter_id = ? AND status = ?", targetType, targetID, reporterID, domain.ModerationCaseOpen).
+		First(&c).Error; err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (s *ModerationCaseStore) List(ctx context.Context, filter repository.ModerationCaseFilter) ([]domain.ModerationCase, int64, error) {
+	if filter.Page <= 0 {
+		filter.Page = 1
+	}
+	if filter.Size <= 0 || filter.Size > 100 {
+		filter.Size = 20
+	}
+
+	query := s.db.WithContext(ctx).Model(&domain.ModerationCase{})
+	if filter.SchoolID != "" {
+		query = query.Where("school_id = ?", filter.SchoolID)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.TargetType != "" {
+		query = query.Where("target_type = ?", filter.TargetType)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var cases []domain.ModerationCase
+	offset := (filter.Page - 1) * filter.Size
+	if err := query.Order("created_at ASC").Order("id ASC").
+		Offset(offset).Limit(filter.Size).Find(&cases).Error; err != nil {
+		return nil, 0, err
+	}
+	return cases, total, nil
+}
+
+func (s *ModerationCaseStore) Resolve(ctx context.Context, c *domain.ModerationCase) (bool, error) {
+	result := s.db.WithContext(ctx).Model(&domain.ModerationCase{}).
+		Where("id = ? AND status = ?", c.ID, domain.ModerationCaseOpen).
+		Updates(map[string]interface{}{
+			"status":      c.Status,
+			"resolved_by": c.ResolvedBy,
+			"resolution":  c.Resolution,
+			"resolved_at": c.ResolvedAt,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (s *ModerationCaseStore) Remove(ctx context.Context, removal *domain.ModerationRemoval) (bool, error) {
+	removed := false
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result, err := tombstone(tx, removal)
+		if err != nil {
+			return err
+		}
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		removed = true
+
+		if removal.TargetType == domain.ModerationTargetMessage && removal.MediaID != "" {
+			if err := tx.Model(&domain.ChatMedia{}).
+				Where("id = ?", removal.MediaID).
+				Updates(map[string]interface{}{"status": domain.ChatMediaRemoved, "updated_at": removal.CreatedAt}).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Create(removal).Error; err != nil {
+			return err
+		}
+		return tx.Model(&domain.ModerationCase{}).
+			Where("target_type = ? AND target_id = ? AND status = ?", removal.TargetType, removal.TargetID, domain.ModerationCaseOpen).
+			Updates(map[string]interface{}{
+				"status":      domain.ModerationCaseRemoved,
+				"resolved_by": removal.RemovedBy,
+				"resolution":  removal.Reason,
+				"resolved_at": removal.CreatedAt,
+			}).Error
+	})
+	return removed, err
+}
+
+// tombstone clears the removed content, leaving its place in conversations
+// and note threads.
+func tombstone(tx *gorm.DB, removal *domain.ModerationRemoval) (*gorm.DB, error) {
+	switch removal.TargetType {
+	case domain.ModerationTargetMessage:
+		return tx.Model(&domain.Message{}).
+			Where("id = ? AND removed_at IS NULL", removal.TargetID).
+			Updates(map[string]interface{}{
+				"text":       "",
+				"media_uri":  "",
+				"media_id":   "",
+				"metadata":   "",
+				"removed_at": removal.CreatedAt,
+			}), nil
+	case domain.ModerationTargetNote:
+		return tx.Model(&domain.Note{}).
+			Where("id = ? AND removed_at IS NULL", removal.TargetID).
+			Updates(map[string]interface{}{
+				"title":      "",
+				"content":    "",
+				"removed_at": removal.CreatedAt,
+				"updated_at": removal.CreatedAt,
+			}), nil
+	case domain.ModerationTargetNoteComment:
+		return tx.Model(&domain.NoteComment{}).
+			Where("id = ? AND removed_at IS NULL", removal.TargetID).
+			Updates(map[string]interface{}{
+				"content":    "",
+				"removed_at": removal.CreatedAt,
+			}), nil
+	}
+	return nil, fmt.Errorf("unknown moderation target %q", removal.TargetType)
+}
+
+func (s *ModerationCaseStore) GetRemoval(ctx context.Context, targetType domain.ModerationTarget, targetID string) (*domain.ModerationRemoval, error) {
+	var removal domain.ModerationRemoval
+	if err := s.db.WithContext(ctx).
+		Where("target_type = ? AND target_id = ?", targetType, targetID).
+		First(&removal).Error; err != nil {
+		return nil, err
+	}
+	return &removal, nil
+}
+
+var _ repository.ModerationCaseRepository = (*ModerationCaseStore)(nil)
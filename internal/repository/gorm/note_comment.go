@@ -23,6 +23,14 @@ func (s *NoteCommentStore) Create(ctx context.Context, comment *domain.NoteComme
 	return s.db.WithContext(ctx).Create(comment).Error
 }
 
+func (s *NoteCommentStore) GetByID(ctx context.Context, id string) (*domain.NoteComment, error) {
+	var comment domain.NoteComment
+	if err := s.db.WithContext(ctx).First(&comment, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
+
 func (s *NoteCommentStore) ListByNote(ctx context.Context, noteID string) ([]domain.NoteComment, error) {
 	var comments []domain.NoteComment
 	if err := s.db.WithContext(ctx).
@@ -0,0 +1,238 @@
+package gormrepo
+
+import (
+	"context"
+	"time"
+
+	"learn-go/internal/domain"
+	"learn-go/internal/repository"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// NotificationStore implements NotificationRepository using GORM.
+type NotificationStore struct {
+	db *gorm.DB
+}
+
+// NewNotificationStore creates a notification store.
+func NewNotificationStore(db *gorm.DB) *NotificationStore {
+	return &NotificationStore{db: db}
+}
+
+func (s *NotificationStore) CreateBatch(ctx context.Context, notifications []domain.Notification) error {
+	if len(notifications) == 0 {
+		return nil
+	}
+	return s.db.WithContext(ctx).Create(&notifications).Error
+}
+
+func (s *NotificationStore) MergeBatch(ctx context.Context, notifications []domain.Notification) error {
+	if len(notifications) == 0 {
+		return nil
+	}
+	for i := range notifications {
+		if notifications[i].Count <= 0 {
+			notifications[i].Count = 1
+		}
+	}
+	return s.db.WithContext(ctx).Clauses(
+		clause.OnConflict{
+			Columns:     []clause.Column{{Name: "account_id"}, {Name: "group_key"}},
+			TargetWhere: clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: unreadGroupPredicate}}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"title":      gorm.Expr("excluded.title"),
+				"body":       gorm.Expr("excluded.body"),
+				"actor_id":   gorm.Expr("excluded.actor_id"),
+				"count":      gorm.Expr("notifications.count + 1"),
+				"created_at": gorm.Expr("excluded.created_at"),
+			}),
+		},
+		clause.Returning{Columns: []clause.Column{{Name: "id"}, {Name: "count"}}},
+	).Create(&notifications).Error
+}
+
+func (s *NotificationStore) List(ctx context.Context, filter repository.NotificationFilter) ([]domain.Notification, int64, error) {
+	if filter.Page <= 0 {
+		filter.Page = 1
+	}
+	if filter.Size <= 0 || filter.Size > 100 {
+		filter.Size = 20
+	}
+
+	query := s.db.WithContext(ctx).Model(&domain.Notification{}).Where("account_id = ?", filter.AccountID)
+	if filter.Category != "" {
+		query = query.Where("category = ?", filter.Category)
+	}
+	if filter.UnreadOnly {
+		query = query.Where("read_at IS NULL")
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var notifications []domain.Notification
+	offset := (filter.Page - 1) * filter.Size
+	if err := query.Order("created_at DESC").Order("id DESC").
+		Offset(offset).Limit(filter.Size).Find(&notifications).Error; err != nil {
+		return nil, 0, err
+	}
+	return notifications, total, nil
+}
+
+func (s *NotificationStore) CountUnread(ctx context.Context, accountID string) (map[domain.NotificationCategory]int64, error) {
+	var rows []struct {
+		Category domain.NotificationCategory
+		Count    int64
+	}
+	if err := s.db.WithContext(ctx).Model(&domain.Notification{}).
+		Select("category, COUNT(*) AS count").
+		Where("account_id = ? AND read_at IS NULL", accountID).
+		Group("category").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	counts := make(map[domain.NotificationCategory]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Category] = row.Count
+	}
+	return counts, nil
+}
+
+func (s *NotificationStore) SetRead(ctx context.Context, accountID string, ids []string, at *time.Time) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	if at == nil {
+		return s.setUnread(ctx, accountID, ids)
+	}
+	result := s.db.WithContext(ctx).Model(&domain.Notification{}).
+		Where("account_id = ? AND id IN ? AND read_at IS NULL", accountID, ids).
+		Update("read_at", at)
+	return result.RowsAffected, result.Error
+}
+
+// setUnread marks read notifications unread, keeping at most one unread
+// notification per group: the newest of the chosen ones, and none if the
+// group already has an unread notification.
+func (s *NotificationStore) setUnread(ctx context.Context, accountID string, ids []string) (int64, error) {
+	var changed int64
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var read []domain.Notification
+		if err := tx.Select("id", "group_key").
+			Where("account_id = ? AND id IN ? AND read_at IS NOT NULL", accountID, ids).
+			Order("created_at DESC").
+			Find(&read).Error; err != nil {
+			return err
+		}
+		groupKeys := make([]string, 0, len(read))
+		for _, n := range read {
+			if n.GroupKey != "" {
+				groupKeys = append(groupKeys, n.GroupKey)
+			}
+		}
+		var unreadGroups []string
+		if len(groupKeys) > 0 {
+			if err := tx.Model(&domain.Notification{}).
+				Where("account_id = ? AND group_key IN ? AND read_at IS NULL", accountID, groupKeys).
+				Pluck("group_key", &unreadGroups).Error; err != nil {
+				return err
+			}
+		}
+		taken := make(map[string]bool, len(unreadGroups))
+		for _, key := range unreadGroups {
+			taken[key] = true
+		}
+		chosen := make([]string, 0, len(read))
+		for _, n := range read {
+			if n.GroupKey != "" {
+				if taken[n.GroupKey] {
+					continue
+				}
+				taken[n.GroupKey] = true
+			}
+			chosen = append(chosen, n.ID)
+		}
+		if len(chosen) == 0 {
+			return nil
+		}
+		result := tx.Model(&domain.Notification{}).Where("id IN ?", chosen).Update("read_at", nil)
+		changed = result.RowsAffected
+		return result.Error
+	})
+	return changed, err
+}
+
+func (s *NotificationStore) MarkAllRead(ctx context.Context, accountID string, category domain.NotificationCategory, at time.Time) (int64, error) {
+	query := s.db.WithContext(ctx).Model(&domain.Notification{}).
+		Where("account_id = ? AND read_at IS NULL", accountID)
+	if category != "" {
+		query = query.Where("category = ?", category)
+	}
+	result := query.Update("read_at", at)
+	return result.RowsAffected, result.Error
+}
+
+func (s *NotificationStore) MarkGroupRead(ctx context.Context, accountID, groupKey string, at time.Time) (int64, error) {
+	result := s.db.WithContext(ctx).Model(&domain.Notification{}).
+		Where("account_id = ? AND group_key = ? AND read_at IS NULL", accountID, groupKey).
+		Update("read_at", at)
+	return result.RowsAffected, result.Error
+}
+
+func (s *NotificationStore) ListPreferences(ctx context.Context, accountID string) ([]domain.NotificationPreference, error) {
+	var prefs []domain.NotificationPreference
+	if err := s.db.WithContext(ctx).Where("account_id = ?", accountID).Find(&prefs).Error; err != nil {
+		return nil, err
+	}
+	return prefs, nil
+}
+
+func (s *NotificationStore) SavePreferences(ctx context.Context, prefs []domain.NotificationPreference) error {
+	if len(prefs) == 0 {
+		return nil
+	}
+	return s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "account_id"}, {Name: "category"}},
+		DoUpdates: clause.AssignmentColumns([]string{"enabled", "updated_at"}),
+	}).Create(&prefs).Error
+}
+
+func (s *NotificationStore) ListDisabled(ctx context.Context, accountIDs []string, category domain.NotificationCategory) ([]string, error) {
+	if len(accountIDs) == 0 {
+		return nil, nil
+	}
+	var ids []string
+	if err := s.db.WithContext(ctx).Model(&domain.NotificationPreference{}).
+		Where("account_id IN ? AND category = ? AND enabled = ?", accountIDs, category, false).
+		Pluck("account_id", &ids).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// unreadGroupPredicate selects the rows covered by the unread group index.
+const unreadGroupPredicate = "read_at IS NULL AND group_key <> ''"
+
+// PrepareNotificationGroups adds the partial unique index that allows one
+// unread notification per account and group, so concurrent sends merge
+// instead of adding duplicates. Duplicates left from before the index are
+// marked read first, keeping the newest of each group unread.
+func PrepareNotificationGroups(db *gorm.DB) error {
+	if err := db.Exec(`UPDATE notifications SET read_at = created_at
+		WHERE ` + unreadGroupPredicate + ` AND EXISTS (
+			SELECT 1 FROM notifications AS newer
+			WHERE newer.account_id = notifications.account_id
+				AND newer.group_key = notifications.group_key
+				AND newer.read_at IS NULL
+				AND (newer.created_at > notifications.created_at
+					OR (newer.created_at = notifications.created_at AND newer.id > notifications.id)))`).Error; err != nil {
+		return err
+	}
+	return db.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_notifications_unread_group
+		ON notifications (account_id, group_key) WHERE ` + unreadGroupPredicate).Error
+}
+
+var _ repository.NotificationRepository = (*NotificationStore)(nil)
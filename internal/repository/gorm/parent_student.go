@@ -0,0 +1,75 @@
+package gormrepo
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+
+	"learn-go/internal/domain"
+	"learn-go/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+// ParentStudentStore manages parent-student associations.
+type ParentStudentStore struct {
+	db *gorm.DB
+}
+
+// NewParentStudentStore creates a parent-student store.
+func NewParentStudentStore(db *gorm.DB) *ParentStudentStore {
+	return &ParentStudentStore{db: db}
+}
+
+func (s *ParentStudentStore) BindStudents(ctx context.Context, parentID string, studentIDs []string) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("parent_id = ?", parentID).Delete(&domain.ParentStudentLink{}).Error; err != nil {
+			return err
+		}
+
+		links := make([]domain.ParentStudentLink, 0, len(studentIDs))
+		for _, studentID := range studentIDs {
+			if studentID == "" {
+				continue
+			}
+			links = append(links, domain.ParentStudentLink{
+				ID:        uuid.NewString(),
+				ParentID:  parentID,
+				StudentID: studentID,
+			})
+		}
+
+		if len(links) > 0 {
+			if err := tx.Create(&links).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *ParentStudentStore) ListStudentIDs(ctx context.Context, parentID string) ([]string, error) {
+	var ids []string
+	if err := s.db.WithContext(ctx).Model(&domain.ParentStudentLink{}).
+		Where("parent_id = ?", parentID).
+		Pluck("student_id", &ids).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func (s *ParentStudentStore) ListParentIDs(ctx context.Context, studentIDs []string) ([]string, error) {
+	if len(studentIDs) == 0 {
+		return nil, nil
+	}
+	var ids []string
+	if err := s.db.WithContext(ctx).Model(&domain.ParentStudentLink{}).
+		Where("student_id IN ?", studentIDs).
+		Distinct("parent_id").
+		Pluck("parent_id", &ids).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+var _ repository.ParentStudentRepository = (*ParentStudentStore)(nil)
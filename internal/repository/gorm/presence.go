@@ -0,0 +1,96 @@
+package gormrepo
+
+import (
+	"context"
+	"time"
+
+	"learn-go/internal/domain"
+	"learn-go/internal/repository"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PresenceStore implements repository.PresenceRepository using GORM.
+type PresenceStore struct {
+	db *gorm.DB
+}
+
+// NewPresenceStore creates a presence store instance.
+func NewPresenceStore(db *gorm.DB) *PresenceStore {
+	return &PresenceStore{db: db}
+}
+
+func (s *PresenceStore) Save(ctx context.Context, session *domain.PresenceSession) error {
+	db := s.db.WithContext(ctx)
+	if session.Connections <= 0 {
+		return db.Delete(&domain.PresenceSession{}, "instance_id = ? AND account_id = ?", session.InstanceID, session.AccountID).Error
+	}
+	return db.Clauses(clause.OnConflict{UpdateAll: true}).Create(session).Error
+}
+
+func (s *PresenceStore) ListSessions(ctx context.Context, accountIDs []string, since time.Time) ([]domain.PresenceSession, error) {
+	if len(accountIDs) == 0 {
+		return nil, nil
+	}
+	var sessions []domain.PresenceSession
+	if err := s.db.WithContext(ctx).
+		Where("account_id IN ? AND updated_at >= ?", accountIDs, since).
+		Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+func (s *PresenceStore) Touch(ctx context.Context, instanceID string, at time.Time) error {
+	return s.db.WithContext(ctx).Model(&domain.PresenceSession{}).
+		Where("instance_id = ?", instanceID).
+		UpdateColumn("updated_at", at).Error
+}
+
+func (s *PresenceStore) DeleteStale(ctx context.Context, before time.Time) ([]domain.PresenceSession, error) {
+	var sessions []domain.PresenceSession
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("updated_at < ?", before).Find(&sessions).Error; err != nil {
+			return err
+		}
+		for _, session := range sessions {
+			if err := tx.Where("instance_id = ? AND account_id = ? AND updated_at < ?", session.InstanceID, session.AccountID, before).
+				Delete(&domain.PresenceSession{}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+func (s *PresenceStore) SetLastSeen(ctx context.Context, accountIDs []string, at time.Time) error {
+	if len(accountIDs) == 0 {
+		return nil
+	}
+	rows := make([]domain.AccountLastSeen, 0, len(accountIDs))
+	for _, id := range accountIDs {
+		rows = append(rows, domain.AccountLastSeen{AccountID: id, SeenAt: at})
+	}
+	return s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "account_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"seen_at"}),
+	}).Create(&rows).Error
+}
+
+func (s *PresenceStore) ListLastSeen(ctx context.Context, accountIDs []string) ([]domain.AccountLastSeen, error) {
+	if len(accountIDs) == 0 {
+		return nil, nil
+	}
+	var rows []domain.AccountLastSeen
+	if err := s.db.WithContext(ctx).Where("account_id IN ?", accountIDs).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+var _ repository.PresenceRepository = (*PresenceStore)(nil)
@@ -0,0 +1,139 @@
+package gormrepo
+
+import (
+	"context"
+	"errors"
+
+	"learn-go/internal/domain"
+	"learn-go/internal/repository"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RegradeRequestStore implements repository.RegradeRequestRepository with GORM.
+type RegradeRequestStore struct {
+	db *gorm.DB
+}
+
+// NewRegradeRequestStore creates a regrade request store instance.
+func NewRegradeRequestStore(db *gorm.DB) *RegradeRequestStore {
+	return &RegradeRequestStore{db: db}
+}
+
+// Create inserts a pending request together with the comment that opens its
+// discussion. It reports false, writing nothing, when the item already has a
+// pending request.
+func (s *RegradeRequestStore) Create(ctx context.Context, request *domain.RegradeRequest, comment *domain.SubmissionComment) (bool, error) {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(comment).Error; err != nil {
+			return err
+		}
+		result := tx.Clauses(clause.OnConflict{
+			Columns:     []clause.Column{{Name: "item_id"}},
+			TargetWhere: clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: pendingRegradePredicate}}},
+			DoNothing:   true,
+		}).Create(request)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != 1 {
+			return errRegradePending
+		}
+		return nil
+	})
+	if errors.Is(err, errRegradePending) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *RegradeRequestStore) GetByID(ctx context.Context, id string) (*domain.RegradeRequest, error) {
+	var request domain.RegradeRequest
+	if err := s.db.WithContext(ctx).First(&request, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+func (s *RegradeRequestStore) ListBySubmission(ctx context.Context, submissionID string) ([]domain.RegradeRequest, error) {
+	var requests []domain.RegradeRequest
+	if err := s.db.WithContext(ctx).
+		Where("submission_id = ?", submissionID).
+		Order("created_at DESC").
+		Find(&requests).Error; err != nil {
+		return nil, err
+	}
+	return requests, nil
+}
+
+func (s *RegradeRequestStore) ListByAssignment(ctx context.Context, assignmentID string, status domain.RegradeStatus) ([]domain.RegradeRequest, error) {
+	var requests []domain.RegradeRequest
+	query := s.db.WithContext(ctx).Where("assignment_id = ?", assignmentID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Order("created_at DESC").Find(&requests).Error; err != nil {
+		return nil, err
+	}
+	return requests, nil
+}
+
+// Resolve records the decision on a pending request and, when grades is not
+// nil, writes the regraded scores in the same transaction. It reports false,
+// writing nothing, when the request is no longer pending.
+func (s *RegradeRequestStore) Resolve(ctx context.Context, request *domain.RegradeRequest, grades *repository.GradeWrite) (bool, error) {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&domain.RegradeRequest{}).
+			Where("id = ? AND status = ?", request.ID, domain.RegradePending).
+			Updates(map[string]interface{}{
+				"status":          request.Status,
+				"resolver_id":     request.ResolverID,
+				"resolution_note": request.ResolutionNote,
+				"resolved_at":     request.ResolvedAt,
+				"updated_at":      request.UpdatedAt,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != 1 {
+			return errRegradeResolved
+		}
+		if grades == nil {
+			return nil
+		}
+		return writeGrades(tx, grades)
+	})
+	if errors.Is(err, errRegradeResolved) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+var (
+	// errRegradePending rolls back Create when the item already has a pending request.
+	errRegradePending = errors.New("regrade request already pending")
+	// errRegradeResolved rolls back Resolve when another decision got there first.
+	errRegradeResolved = errors.New("regrade request already resolved")
+)
+
+const pendingRegradePredicate = "status = 'pending'"
+
+// PrepareRegradeRequests adds the partial unique index that allows one pending
+// regrade request per submission item. Duplicates left from before the index
+// are rejected first, keeping the oldest request of each item pending.
+func PrepareRegradeRequests(db *gorm.DB) error {
+	if err := db.Exec(`UPDATE regrade_requests SET status = 'rejected', resolution_note = 'duplicate request'
+		WHERE ` + pendingRegradePredicate + ` AND EXISTS (
+			SELECT 1 FROM regrade_requests AS older
+			WHERE older.item_id = regrade_requests.item_id
+				AND older.status = 'pending'
+				AND (older.created_at < regrade_requests.created_at
+					OR (older.created_at = regrade_requests.created_at AND older.id < regrade_requests.id)))`).Error; err != nil {
+		return err
+	}
+	return db.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_regrade_requests_pending_item
+		ON regrade_requests (item_id) WHERE ` + pendingRegradePredicate).Error
+}
+
+var _ repository.RegradeRequestRepository = (*RegradeRequestStore)(nil)
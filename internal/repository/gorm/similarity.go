@@ -0,0 +1,124 @@
+package gormrepo
+
+import (
+	"context"
+
+	"learn-go/internal/domain"
+	"learn-go/internal/repository"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SimilarityStore implements repository.SimilarityRepository with GORM.
+type SimilarityStore struct {
+	db *gorm.DB
+}
+
+// NewSimilarityStore creates a similarity store instance.
+func NewSimilarityStore(db *gorm.DB) *SimilarityStore {
+	return &SimilarityStore{db: db}
+}
+
+func (s *SimilarityStore) staleQuery(ctx context.Context, assignmentID string) *gorm.DB {
+	query := s.db.WithContext(ctx).
+		Table("assignment_submissions AS s").
+		Joins("LEFT JOIN similarity_checks AS c ON c.submission_id = s.id").
+		Where("s.status <> ?", "draft").
+		Where("c.submission_id IS NULL OR s.updated_at > c.submission_updated_at")
+	if assignmentID != "" {
+		query = query.Where("s.assignment_id = ?", assignmentID)
+	}
+	return query
+}
+
+// ListStaleSubmissions returns submissions whose answers changed since the last check.
+func (s *SimilarityStore) ListStaleSubmissions(ctx context.Context, assignmentID string, limit int) ([]string, error) {
+	query := s.staleQuery(ctx, assignmentID).Order("s.updated_at ASC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	var ids []string
+	if err := query.Pluck("s.id", &ids).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// CountStale counts submissions of an assignment still waiting for analysis.
+func (s *SimilarityStore) CountStale(ctx context.Context, assignmentID string) (int64, error) {
+	var count int64
+	if err := s.staleQuery(ctx, assignmentID).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// MarkChecked records the submission version that was analyzed.
+func (s *SimilarityStore) MarkChecked(ctx context.Context, check *domain.SimilarityCheck) error {
+	return s.db.WithContext(ctx).Clauses(clause.OnConflict{UpdateAll: true}).Create(check).Error
+}
+
+// ResetChecks forces every submission of the assignment to be analyzed again.
+func (s *SimilarityStore) ResetChecks(ctx context.Context, assignmentID string) error {
+	return s.db.WithContext(ctx).Where("assignment_id = ?", assignmentID).Delete(&domain.SimilarityCheck{}).Error
+}
+
+// ReplaceFingerprints swaps a submission's fingerprints for a fresh set.
+func (s *SimilarityStore) ReplaceFingerprints(ctx context.Context, submissionID string, fingerprints []domain.AnswerFingerprint) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("submission_id = ?", submissionID).Delete(&domain.AnswerFingerprint{}).Error; err != nil {
+			return err
+		}
+		if len(fingerprints) == 0 {
+			return nil
+		}
+		return tx.Create(&fingerprints).Error
+	})
+}
+
+// ListFingerprints returns all fingerprints of an assignment.
+func (s *SimilarityStore) ListFingerprints(ctx context.Context, assignmentID string) ([]domain.AnswerFingerprint, error) {
+	var fingerprints []domain.AnswerFingerprint
+	if err := s.db.WithContext(ctx).Where("assignment_id = ?", assignmentID).Find(&fingerprints).Error; err != nil {
+		return nil, err
+	}
+	return fingerprints, nil
+}
+
+// ReplaceMatches swaps every pair involving the submission for the given set.
+func (s *SimilarityStore) ReplaceMatches(ctx context.Context, submissionID string, matches []domain.SimilarityMatch) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("submission_a_id = ? OR submission_b_id = ?", submissionID, submissionID).
+			Delete(&domain.SimilarityMatch{}).Error; err != nil {
+			return err
+		}
+		if len(matches) == 0 {
+			return nil
+		}
+		return tx.Create(&matches).Error
+	})
+}
+
+// ListMatches returns an assignment's pairs at or above minScore, most similar first.
+func (s *SimilarityStore) ListMatches(ctx context.Context, assignmentID string, minScore float64) ([]domain.SimilarityMatch, error) {
+	var matches []domain.SimilarityMatch
+	if err := s.db.WithContext(ctx).
+		Where("assignment_id = ? AND score >= ?", assignmentID, minScore).
+		Order("score DESC").
+		Find(&matches).Error; err != nil {
+		return nil, err
+	}
+	return matches, nil
+}
+
+// GetMatch loads a single pair.
+func (s *SimilarityStore) GetMatch(ctx context.Context, id string) (*domain.SimilarityMatch, error) {
+	var match domain.SimilarityMatch
+	if err := s.db.WithContext(ctx).First(&match, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &match, nil
+}
+
+var _ repository.SimilarityRepository = (*SimilarityStore)(nil)
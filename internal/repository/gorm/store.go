@@ -61,5 +61,16 @@ func (s *Store) ListByRole(ctx context.Context, schoolID string, role domain.Rol
 	return accounts, total, nil
 }
 
+func (s *Store) ListByIDs(ctx context.Context, ids []string) ([]domain.Account, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	var accounts []domain.Account
+	if err := s.db.WithContext(ctx).Where("id IN ?", ids).Find(&accounts).Error; err != nil {
+		return nil, err
+	}
+	return accounts, nil
+}
+
 // Ensure Store satisfies interfaces at compile time.
 var _ repository.AccountRepository = (*Store)(nil)
@@ -2,6 +2,7 @@ package gormrepo
 
 import (
 	"context"
+	"time"
 
 	"learn-go/internal/domain"
 	"learn-go/internal/repository"
@@ -39,4 +40,34 @@ func (s *StudentStore) GetByID(ctx context.Context, id string) (*domain.Student,
 	return &student, nil
 }
 
+func (s *StudentStore) GetByAccountID(ctx context.Context, accountID string) (*domain.Student, error) {
+	var student domain.Student
+	if err := s.db.WithContext(ctx).First(&student, "account_id = ?", accountID).Error; err != nil {
+		return nil, err
+	}
+	return &student, nil
+}
+
+func (s *StudentStore) ListByClass(ctx context.Context, classID string) ([]domain.Student, error) {
+	var students []domain.Student
+	if err := s.db.WithContext(ctx).Where("class_id = ?", classID).Order("number").Find(&students).Error; err != nil {
+		return nil, err
+	}
+	return students, nil
+}
+
+func (s *StudentStore) CountByClass(ctx context.Context, classID string) (int64, error) {
+	var count int64
+	if err := s.db.WithContext(ctx).Model(&domain.Student{}).Where("class_id = ?", classID).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (s *StudentStore) UpdateClass(ctx context.Context, studentID, classID string) error {
+	return s.db.WithContext(ctx).Model(&domain.Student{}).
+		Where("id = ?", studentID).
+		Updates(map[string]interface{}{"class_id": classID, "updated_at": time.Now()}).Error
+}
+
 var _ repository.StudentRepository = (*StudentStore)(nil)
@@ -91,31 +91,106 @@ func (s *SubmissionStore) GetByID(ctx context.Context, submissionID string) (*do
 	return &submission, items, nil
 }
 
-func (s *SubmissionStore) UpdateGrades(ctx context.Context, submission *domain.AssignmentSubmission, items []domain.SubmissionItem) error {
+func (s *SubmissionStore) UpdateGrades(ctx context.Context, grades *repository.GradeWrite) error {
 	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		if err := tx.Model(&domain.AssignmentSubmission{}).
-			Where("id = ?", submission.ID).
+		return writeGrades(tx, grades)
+	})
+}
+
+// writeGrades stores a graded submission, its changed items and their history.
+func writeGrades(tx *gorm.DB, grades *repository.GradeWrite) error {
+	submission := grades.Submission
+	if err := tx.Model(&domain.AssignmentSubmission{}).
+		Where("id = ?", submission.ID).
+		Updates(map[string]interface{}{
+			"score":        submission.Score,
+			"feedback":     submission.Feedback,
+			"status":       submission.Status,
+			"updated_at":   submission.UpdatedAt,
+			"submitted_at": submission.SubmittedAt,
+		}).Error; err != nil {
+		return err
+	}
+	for _, item := range grades.Items {
+		if err := tx.Model(&domain.SubmissionItem{}).
+			Where("id = ? AND submission_id = ?", item.ID, submission.ID).
 			Updates(map[string]interface{}{
-				"score":        submission.Score,
-				"feedback":     submission.Feedback,
-				"status":       submission.Status,
-				"updated_at":   submission.UpdatedAt,
-				"submitted_at": submission.SubmittedAt,
+				"score":  item.Score,
+				"answer": item.Answer,
 			}).Error; err != nil {
 			return err
 		}
-		for _, item := range items {
-			if err := tx.Model(&domain.SubmissionItem{}).
-				Where("id = ? AND submission_id = ?", item.ID, submission.ID).
-				Updates(map[string]interface{}{
-					"score":  item.Score,
-					"answer": item.Answer,
-				}).Error; err != nil {
-				return err
-			}
+	}
+	if len(grades.Changes) > 0 {
+		if err := tx.Create(&grades.Changes).Error; err != nil {
+			return err
 		}
-		return nil
-	})
+	}
+	return nil
+}
+
+// ListScoresByAssignments loads score projections for many assignments in one query.
+func (s *SubmissionStore) ListScoresByAssignments(ctx context.Context, assignmentIDs []string) ([]repository.SubmissionScore, error) {
+	if len(assignmentIDs) == 0 {
+		return nil, nil
+	}
+	var scores []repository.SubmissionScore
+	if err := s.db.WithContext(ctx).Model(&domain.AssignmentSubmission{}).
+		Select("assignment_id", "student_id", "status", "score", "submitted_at").
+		Where("assignment_id IN ?", assignmentIDs).
+		Scan(&scores).Error; err != nil {
+		return nil, err
+	}
+	return scores, nil
+}
+
+// ListScoresByStudent loads one student's score projections for the given assignments.
+func (s *SubmissionStore) ListScoresByStudent(ctx context.Context, studentID string, assignmentIDs []string) ([]repository.SubmissionScore, error) {
+	if len(assignmentIDs) == 0 {
+		return nil, nil
+	}
+	var scores []repository.SubmissionScore
+	if err := s.db.WithContext(ctx).Model(&domain.AssignmentSubmission{}).
+		Select("assignment_id", "student_id", "status", "score", "submitted_at").
+		Where("student_id = ? AND assignment_id IN ?", studentID, assignmentIDs).
+		Scan(&scores).Error; err != nil {
+		return nil, err
+	}
+	return scores, nil
+}
+
+// CountByAssignments aggregates submitted, graded and ungraded counts per assignment in one query.
+func (s *SubmissionStore) CountByAssignments(ctx context.Context, assignmentIDs []string) ([]repository.SubmissionCount, error) {
+	if len(assignmentIDs) == 0 {
+		return nil, nil
+	}
+	var counts []repository.SubmissionCount
+	if err := s.db.WithContext(ctx).Model(&domain.AssignmentSubmission{}).
+		Select(`assignment_id,
+			COALESCE(SUM(CASE WHEN submitted_at IS NOT NULL THEN 1 ELSE 0 END), 0) AS submitted,
+			COALESCE(SUM(CASE WHEN status = ? THEN 1 ELSE 0 END), 0) AS graded,
+			COALESCE(SUM(CASE WHEN submitted_at IS NOT NULL AND status <> ? THEN 1 ELSE 0 END), 0) AS ungraded`, "graded", "graded").
+		Where("assignment_id IN ?", assignmentIDs).
+		Group("assignment_id").
+		Scan(&counts).Error; err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// CountByAssignment counts all and graded submissions of an assignment.
+func (s *SubmissionStore) CountByAssignment(ctx context.Context, assignmentID string) (int64, int64, error) {
+	var row struct {
+		Total  int64
+		Graded int64
+	}
+	if err := s.db.WithContext(ctx).Model(&domain.AssignmentSubmission{}).
+		Select("COUNT(*) AS total, COALESCE(SUM(CASE WHEN status = ? THEN 1 ELSE 0 END), 0) AS graded", "graded").
+		Where("assignment_id = ?", assignmentID).
+		Scan(&row).Error; err != nil {
+		return 0, 0, err
+	}
+	return row.Total, row.Graded, nil
 }
 
 var _ repository.SubmissionRepository = (*SubmissionStore)(nil)
@@ -24,6 +24,15 @@ func (s *SubmissionCommentStore) Create(ctx context.Context, comment *domain.Sub
 	return s.db.WithContext(ctx).Create(comment).Error
 }
 
+// GetByID loads a single submission comment.
+func (s *SubmissionCommentStore) GetByID(ctx context.Context, id string) (*domain.SubmissionComment, error) {
+	var comment domain.SubmissionComment
+	if err := s.db.WithContext(ctx).First(&comment, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
+
 // ListBySubmission returns comments for a submission ordered by creation time.
 func (s *SubmissionCommentStore) ListBySubmission(ctx context.Context, submissionID string) ([]domain.SubmissionComment, error) {
 	var comments []domain.SubmissionComment
@@ -39,4 +39,23 @@ func (s *TeacherStore) GetByID(ctx context.Context, id string) (*domain.Teacher,
 	return &teacher, nil
 }
 
+func (s *TeacherStore) ListByIDs(ctx context.Context, ids []string) ([]domain.Teacher, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	var teachers []domain.Teacher
+	if err := s.db.WithContext(ctx).Where("id IN ?", ids).Find(&teachers).Error; err != nil {
+		return nil, err
+	}
+	return teachers, nil
+}
+
+func (s *TeacherStore) GetByAccountID(ctx context.Context, accountID string) (*domain.Teacher, error) {
+	var teacher domain.Teacher
+	if err := s.db.WithContext(ctx).First(&teacher, "account_id = ?", accountID).Error; err != nil {
+		return nil, err
+	}
+	return &teacher, nil
+}
+
 var _ repository.TeacherRepository = (*TeacherStore)(nil)
@@ -47,4 +47,18 @@ func (s *TeacherStudentStore) BindTeachers(ctx context.Context, studentID string
 	})
 }
 
+func (s *TeacherStudentStore) ListTeacherIDs(ctx context.Context, studentIDs []string) ([]string, error) {
+	if len(studentIDs) == 0 {
+		return nil, nil
+	}
+	var ids []string
+	if err := s.db.WithContext(ctx).Model(&domain.TeacherStudentLink{}).
+		Where("student_id IN ?", studentIDs).
+		Distinct("teacher_id").
+		Pluck("teacher_id", &ids).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
 var _ repository.TeacherStudentRepository = (*TeacherStudentStore)(nil)
@@ -13,6 +13,7 @@ type AccountRepository interface {
 	FindByIdentifier(ctx context.Context, schoolID, identifier string) (*domain.Account, error)
 	FindByID(ctx context.Context, id string) (*domain.Account, error)
 	ListByRole(ctx context.Context, schoolID string, role domain.Role, page, size int) ([]domain.Account, int64, error)
+	ListByIDs(ctx context.Context, ids []string) ([]domain.Account, error)
 }
 
 // TeacherRepository handles teacher profile persistence.
@@ -20,6 +21,8 @@ type TeacherRepository interface {
 	Create(ctx context.Context, teacher *domain.Teacher) error
 	GetByNumber(ctx context.Context, schoolID, number string) (*domain.Teacher, error)
 	GetByID(ctx context.Context, id string) (*domain.Teacher, error)
+	ListByIDs(ctx context.Context, ids []string) ([]domain.Teacher, error)
+	GetByAccountID(ctx context.Context, accountID string) (*domain.Teacher, error)
 }
 
 // StudentRepository handles student profile persistence.
@@ -27,11 +30,32 @@ type StudentRepository interface {
 	Create(ctx context.Context, student *domain.Student) error
 	GetByNumber(ctx context.Context, schoolID, number string) (*domain.Student, error)
 	GetByID(ctx context.Context, id string) (*domain.Student, error)
+	GetByAccountID(ctx context.Context, accountID string) (*domain.Student, error)
+	ListByClass(ctx context.Context, classID string) ([]domain.Student, error)
+	CountByClass(ctx context.Context, classID string) (int64, error)
+	UpdateClass(ctx context.Context, studentID, classID string) error
 }
 
 // TeacherStudentRepository manages relationships between teachers and students.
 type TeacherStudentRepository interface {
 	BindTeachers(ctx context.Context, studentID string, teacherIDs []string) error
+	// ListTeacherIDs returns the distinct teachers bound to any of the students.
+	ListTeacherIDs(ctx context.Context, studentIDs []string) ([]string, error)
+}
+
+// ParentStudentRepository manages relationships between parents and students.
+type ParentStudentRepository interface {
+	// BindStudents replaces the children of the parent account.
+	BindStudents(ctx context.Context, parentID string, studentIDs []string) error
+	ListStudentIDs(ctx context.Context, parentID string) ([]string, error)
+	// ListParentIDs returns the parent accounts of any of the students.
+	ListParentIDs(ctx context.Context, studentIDs []string) ([]string, error)
+}
+
+// MessagingPolicyRepository stores the messaging policy of each school.
+type MessagingPolicyRepository interface {
+	Get(ctx context.Context, schoolID string) (*domain.MessagingPolicy, error)
+	Save(ctx context.Context, policy *domain.MessagingPolicy) error
 }
 
 // DepartmentRepository handles departments.
@@ -45,12 +69,67 @@ type ClassRepository interface {
 	Create(ctx context.Context, class *domain.Class) error
 	ListByDepartment(ctx context.Context, schoolID, departmentID string) ([]domain.Class, error)
 	GetByID(ctx context.Context, id string) (*domain.Class, error)
+	SetHomeroom(ctx context.Context, classID string, teacherID *string) error
+}
+
+// CourseRepository reads courses and their scheduled teachers.
+type CourseRepository interface {
+	GetByID(ctx context.Context, id string) (*domain.Course, error)
+	// ListTeacherIDs returns the distinct teachers with sessions of the course in the class.
+	ListTeacherIDs(ctx context.Context, classID, courseID string) ([]string, error)
 }
 
 // AssignmentRepository handles assignments.
 type AssignmentRepository interface {
 	Create(ctx context.Context, assignment *domain.Assignment, questions []domain.AssignmentQuestion) error
 	Get(ctx context.Context, id string) (*domain.Assignment, []domain.AssignmentQuestion, error)
+	ListByClass(ctx context.Context, classID, courseID string) ([]domain.Assignment, error)
+	ListByTeacher(ctx context.Context, teacherID string, filter AssignmentFilter) ([]domain.Assignment, error)
+	// Update saves assignment fields if its status is still from, reporting
+	// false otherwise. Non-nil questions replace the existing set; a non-nil
+	// revision is recorded in the same transaction.
+	Update(ctx context.Context, assignment *domain.Assignment, from domain.AssignmentStatus, questions []domain.AssignmentQuestion, revision *domain.AssignmentRevision) (bool, error)
+	// TransitionStatus moves an assignment between states only if it is still in from.
+	TransitionStatus(ctx context.Context, id string, from, to domain.AssignmentStatus, at time.Time) (bool, error)
+	ListScheduledBefore(ctx context.Context, at time.Time) ([]domain.Assignment, error)
+	ListRevisions(ctx context.Context, assignmentID string) ([]domain.AssignmentRevision, error)
+	// Delete removes an assignment together with its questions, revisions and
+	// attachment records, only if nobody has submitted. It reports false when
+	// submissions exist or the assignment is already gone.
+	Delete(ctx context.Context, id string) (bool, error)
+}
+
+// AssignmentFilter narrows assignment listings. Empty fields match everything.
+type AssignmentFilter struct {
+	ClassID  string
+	CourseID string
+	Type     domain.AssignmentType
+	Statuses []domain.AssignmentStatus
+}
+
+// SubmissionCount aggregates submission progress for one assignment.
+type SubmissionCount struct {
+	AssignmentID string
+	Submitted    int64
+	Graded       int64
+	Ungraded     int64
+}
+
+// SubmissionScore is a lightweight submission projection used by aggregate views.
+type SubmissionScore struct {
+	AssignmentID string
+	StudentID    string
+	Status       string
+	Score        *float64
+	SubmittedAt  *time.Time
+}
+
+// GradeWrite bundles a graded submission with the items whose scores changed
+// and the matching grade history records.
+type GradeWrite struct {
+	Submission *domain.AssignmentSubmission
+	Items      []domain.SubmissionItem
+	Changes    []domain.GradeChange
 }
 
 // SubmissionRepository handles student submissions.
@@ -60,12 +139,114 @@ type SubmissionRepository interface {
 	ListItemsBySubmissionIDs(ctx context.Context, submissionIDs []string) ([]domain.SubmissionItem, error)
 	GetByAssignmentAndStudent(ctx context.Context, assignmentID, studentID string) (*domain.AssignmentSubmission, []domain.SubmissionItem, error)
 	GetByID(ctx context.Context, submissionID string) (*domain.AssignmentSubmission, []domain.SubmissionItem, error)
-	UpdateGrades(ctx context.Context, submission *domain.AssignmentSubmission, items []domain.SubmissionItem) error
+	UpdateGrades(ctx context.Context, grades *GradeWrite) error
+	ListScoresByAssignments(ctx context.Context, assignmentIDs []string) ([]SubmissionScore, error)
+	ListScoresByStudent(ctx context.Context, studentID string, assignmentIDs []string) ([]SubmissionScore, error)
+	CountByAssignments(ctx context.Context, assignmentIDs []string) ([]SubmissionCount, error)
+	// CountByAssignment returns how many submissions exist and how many of them are graded.
+	CountByAssignment(ctx context.Context, assignmentID string) (total int64, graded int64, err error)
+}
+
+// SimilarityRepository stores answer fingerprints and suspicious pairs.
+type SimilarityRepository interface {
+	// ListStaleSubmissions returns non-draft submissions changed since their
+	// last check, oldest first. An empty assignmentID searches all assignments.
+	ListStaleSubmissions(ctx context.Context, assignmentID string, limit int) ([]string, error)
+	CountStale(ctx context.Context, assignmentID string) (int64, error)
+	MarkChecked(ctx context.Context, check *domain.SimilarityCheck) error
+	ResetChecks(ctx context.Context, assignmentID string) error
+	ReplaceFingerprints(ctx context.Context, submissionID string, fingerprints []domain.AnswerFingerprint) error
+	ListFingerprints(ctx context.Context, assignmentID string) ([]domain.AnswerFingerprint, error)
+	// ReplaceMatches swaps every pair involving the submission for the given set.
+	ReplaceMatches(ctx context.Context, submissionID string, matches []domain.SimilarityMatch) error
+	ListMatches(ctx context.Context, assignmentID string, minScore float64) ([]domain.SimilarityMatch, error)
+	GetMatch(ctx context.Context, id string) (*domain.SimilarityMatch, error)
+}
+
+// ScoreSummary aggregates submission counts and graded score bounds for an assignment.
+type ScoreSummary struct {
+	Submitted int64
+	Graded    int64
+	Mean      *float64
+	Min       *float64
+	Max       *float64
+}
+
+// QuestionAggregate summarises graded item scores for a question.
+type QuestionAggregate struct {
+	QuestionID string
+	Graded     int64
+	AvgScore   *float64
+}
+
+// ItemScore is a graded item score together with its submission.
+type ItemScore struct {
+	SubmissionID string
+	QuestionID   string
+	Score        float64
+}
+
+// SubmissionTotal is a graded submission total.
+type SubmissionTotal struct {
+	SubmissionID string
+	Score        float64
+}
+
+// AnswerFrequency counts identical answers to a question.
+type AnswerFrequency struct {
+	QuestionID string
+	Answer     string
+	Count      int64
+}
+
+// AssignmentStatsRepository runs aggregate queries for assignment analytics.
+type AssignmentStatsRepository interface {
+	ScoreSummary(ctx context.Context, assignmentID string) (*ScoreSummary, error)
+	ListGradedTotals(ctx context.Context, assignmentID string) ([]SubmissionTotal, error)
+	QuestionAggregates(ctx context.Context, assignmentID string) ([]QuestionAggregate, error)
+	ListGradedItemScores(ctx context.Context, assignmentID string) ([]ItemScore, error)
+	ChoiceFrequencies(ctx context.Context, assignmentID string) ([]AnswerFrequency, error)
+}
+
+// GradeCategoryRepository handles gradebook weighting categories.
+type GradeCategoryRepository interface {
+	ListByClassCourse(ctx context.Context, classID, courseID string) ([]domain.GradeCategory, error)
+	Replace(ctx context.Context, classID, courseID string, categories []domain.GradeCategory) error
+}
+
+// GradeHistoryRepository reads the append-only grade audit trail.
+type GradeHistoryRepository interface {
+	ListBySubmission(ctx context.Context, submissionID string) ([]domain.GradeChange, error)
+}
+
+// RegradeRequestRepository handles student regrade requests.
+type RegradeRequestRepository interface {
+	// Create stores a pending request with its opening comment; false means
+	// the item already has a pending request.
+	Create(ctx context.Context, request *domain.RegradeRequest, comment *domain.SubmissionComment) (bool, error)
+	GetByID(ctx context.Context, id string) (*domain.RegradeRequest, error)
+	ListBySubmission(ctx context.Context, submissionID string) ([]domain.RegradeRequest, error)
+	ListByAssignment(ctx context.Context, assignmentID string, status domain.RegradeStatus) ([]domain.RegradeRequest, error)
+	// Resolve decides a pending request and writes grades, if any, atomically;
+	// false means the request was no longer pending.
+	Resolve(ctx context.Context, request *domain.RegradeRequest, grades *GradeWrite) (bool, error)
+}
+
+// AttachmentRepository stores file attachment metadata.
+type AttachmentRepository interface {
+	Create(ctx context.Context, attachment *domain.Attachment) error
+	GetByID(ctx context.Context, id string) (*domain.Attachment, error)
+	ListByIDs(ctx context.Context, ids []string) ([]domain.Attachment, error)
+	// Bind links attachments to a target record and marks them attached.
+	Bind(ctx context.Context, ids []string, targetID string) error
+	ListByTargets(ctx context.Context, targetIDs []string) ([]domain.Attachment, error)
+	ListByAssignment(ctx context.Context, assignmentID string) ([]domain.Attachment, error)
 }
 
 // SubmissionCommentRepository handles submission review comments.
 type SubmissionCommentRepository interface {
 	Create(ctx context.Context, comment *domain.SubmissionComment) error
+	GetByID(ctx context.Context, id string) (*domain.SubmissionComment, error)
 	ListBySubmission(ctx context.Context, submissionID string) ([]domain.SubmissionComment, error)
 }
 
@@ -83,31 +264,246 @@ type NoteRepository interface {
 // NoteCommentRepository handles note comment persistence.
 type NoteCommentRepository interface {
 	Create(ctx context.Context, comment *domain.NoteComment) error
+	GetByID(ctx context.Context, id string) (*domain.NoteComment, error)
 	ListByNote(ctx context.Context, noteID string) ([]domain.NoteComment, error)
 }
 
+// ModerationRuleRepository stores each school's moderation blocklist.
+type ModerationRuleRepository interface {
+	Create(ctx context.Context, rule *domain.ModerationRule) error
+	Update(ctx context.Context, rule *domain.ModerationRule) error
+	Delete(ctx context.Context, id string) error
+	GetByID(ctx context.Context, id string) (*domain.ModerationRule, error)
+	ListBySchool(ctx context.Context, schoolID string) ([]domain.ModerationRule, error)
+}
+
+// ModerationCaseFilter narrows the review queue; empty fields match all.
+type ModerationCaseFilter struct {
+	SchoolID   string
+	Status     domain.ModerationCaseStatus
+	TargetType domain.ModerationTarget
+	Page       int
+	Size       int
+}
+
+// ModerationCaseRepository stores the review queue and the audit records of
+// removed content.
+type ModerationCaseRepository interface {
+	Create(ctx context.Context, c *domain.ModerationCase) error
+	GetByID(ctx context.Context, id string) (*domain.ModerationCase, error)
+	// FindOpen returns the target's open case reported by reporterID, or
+	// opened by a flag rule when reporterID is empty.
+	FindOpen(ctx context.Context, targetType domain.ModerationTarget, targetID, reporterID string) (*domain.ModerationCase, error)
+	// List returns cases oldest first with the total matching the filter.
+	List(ctx context.Context, filter ModerationCaseFilter) ([]domain.ModerationCase, int64, error)
+	// Resolve stores the resolution of an open case. It reports false if the
+	// case was already closed.
+	Resolve(ctx context.Context, c *domain.ModerationCase) (bool, error)
+	// Remove replaces the target's content with a tombstone, stores the
+	// removal record and closes the target's open cases as removed. It
+	// reports false if the content was already removed.
+	Remove(ctx context.Context, removal *domain.ModerationRemoval) (bool, error)
+	GetRemoval(ctx context.Context, targetType domain.ModerationTarget, targetID string) (*domain.ModerationRemoval, error)
+}
+
+// ConversationCursor is the position of the last conversation of a page.
+// Conversations the account pinned come first, then newest UpdatedAt first,
+// ties broken by ID.
+type ConversationCursor struct {
+	Pinned    bool
+	UpdatedAt time.Time
+	ID        string
+}
+
+// ConversationPageFilter selects a page of an account's conversations.
+type ConversationPageFilter struct {
+	AccountID string
+	Archived  bool // list the archived conversations instead of the others
+	After     *ConversationCursor
+	Limit     int
+}
+
 // ConversationRepository handles conversation persistence and membership.
 type ConversationRepository interface {
 	Create(ctx context.Context, conversation *domain.Conversation, members []domain.ConversationMember) error
+	// CreateManaged inserts an empty class or course chat; false means the
+	// class (and course) already has one.
+	CreateManaged(ctx context.Context, conversation *domain.Conversation) (bool, error)
 	GetByID(ctx context.Context, id string) (*domain.Conversation, error)
 	ListByAccount(ctx context.Context, accountID string) ([]domain.Conversation, error)
+	// ListPage returns a page of the account's conversations in cursor order.
+	ListPage(ctx context.Context, filter ConversationPageFilter) ([]domain.Conversation, error)
 	GetMembers(ctx context.Context, conversationID string) ([]domain.ConversationMember, error)
+	// ListViewerMembers returns the account's own membership of each
+	// conversation and, for direct chats, the other participant's as well.
+	ListViewerMembers(ctx context.Context, accountID string, conversationIDs []string) ([]domain.ConversationMember, error)
+	CountMembers(ctx context.Context, conversationIDs []string) (map[string]int64, error)
 	IsMember(ctx context.Context, conversationID, accountID string) (bool, error)
 	FindDirectBetween(ctx context.Context, schoolID string, participantIDs [2]string) (*domain.Conversation, error)
 	UpdateTimestamp(ctx context.Context, conversationID string, ts time.Time) error
+	UpdateName(ctx context.Context, conversationID, name string) error
+	GetMember(ctx context.Context, conversationID, accountID string) (*domain.ConversationMember, error)
+	// AddMembers adds members to one conversation. A positive limit caps the
+	// resulting member count; it reports false, adding nobody, when exceeded.
+	AddMembers(ctx context.Context, members []domain.ConversationMember, limit int) (bool, error)
+	RemoveMember(ctx context.Context, conversationID, accountID string) error
+	UpdateMemberRole(ctx context.Context, conversationID, accountID string, role domain.GroupRole) error
+	// TransferOwnership hands a group from its owner to another member in one
+	// transaction. The previous owner becomes an admin, or leaves when leave
+	// is set. It reports false when ownerID is no longer the owner or
+	// successorID is no longer a member.
+	TransferOwnership(ctx context.Context, conversationID, ownerID, successorID string, leave bool) (bool, error)
+	SetAnnouncementOnly(ctx context.Context, conversationID string, enabled bool) error
+	// FindManaged returns the class or course chat for the class (and course).
+	FindManaged(ctx context.Context, conversationType, classID, courseID string) (*domain.Conversation, error)
+	ListManagedByClass(ctx context.Context, classID string) ([]domain.Conversation, error)
+	// ListContactIDs returns the accounts sharing a conversation with the
+	// account, excluding itself.
+	ListContactIDs(ctx context.Context, accountID string) ([]string, error)
+	// MarkRead moves the member's read position forward to seq; it never
+	// moves back.
+	MarkRead(ctx context.Context, conversationID, accountID string, seq int64, at time.Time) error
+	// CountUnread counts, per conversation, the messages from others after
+	// the account's read position, leaving out system messages and those it
+	// deleted for itself. Nil conversationIDs counts all its conversations.
+	CountUnread(ctx context.Context, accountID string, conversationIDs []string) (map[string]int64, error)
+	// SaveSettings stores the member's pin, mute, archive and title settings.
+	SaveSettings(ctx context.Context, member *domain.ConversationMember) error
+	CountPinned(ctx context.Context, accountID string) (int64, error)
+}
+
+// PresenceRepository tracks connected accounts across server instances.
+type PresenceRepository interface {
+	// Save stores the instance's session for the account, deleting it when
+	// it has no connections left.
+	Save(ctx context.Context, session *domain.PresenceSession) error
+	// ListSessions returns the sessions of the accounts updated since the time.
+	ListSessions(ctx context.Context, accountIDs []string, since time.Time) ([]domain.PresenceSession, error)
+	// Touch refreshes every session of the instance.
+	Touch(ctx context.Context, instanceID string, at time.Time) error
+	// DeleteStale removes and returns the sessions last updated before the time.
+	DeleteStale(ctx context.Context, before time.Time) ([]domain.PresenceSession, error)
+	SetLastSeen(ctx context.Context, accountIDs []string, at time.Time) error
+	ListLastSeen(ctx context.Context, accountIDs []string) ([]domain.AccountLastSeen, error)
 }
 
 // MessageRepository handles chat messages.
 type MessageRepository interface {
+	// Create stores the message under the next sequence number of its
+	// conversation, setting message.Seq.
 	Create(ctx context.Context, message *domain.Message) error
-	ListByConversation(ctx context.Context, conversationID string, limit int, beforeID string) ([]domain.Message, error)
+	// CreateWithMedia stores a media message like Create and binds its pending
+	// upload in the same transaction. It reports false, storing nothing, if the
+	// media was already used.
+	CreateWithMedia(ctx context.Context, message *domain.Message, media *domain.ChatMedia) (bool, error)
+	GetByClientMsgID(ctx context.Context, senderID, clientMsgID string) (*domain.Message, error)
+	// ListAfterSeq returns messages with afterSeq < seq <= upToSeq in order.
+	// A non-empty viewerID leaves out messages that account deleted for itself.
+	ListAfterSeq(ctx context.Context, conversationID, viewerID string, afterSeq, upToSeq int64, limit int) ([]domain.Message, error)
+	// ListByConversation pages backwards from beforeID. A non-empty viewerID
+	// leaves out messages that account deleted for itself.
+	ListByConversation(ctx context.Context, conversationID, viewerID string, limit int, beforeID string) ([]domain.Message, error)
 	GetLastByConversation(ctx context.Context, conversationID string) (*domain.Message, error)
+	// ListLastByConversations returns the newest message of each conversation.
+	ListLastByConversations(ctx context.Context, conversationIDs []string) ([]domain.Message, error)
 	GetByID(ctx context.Context, id string) (*domain.Message, error)
+	ListByIDs(ctx context.Context, ids []string) ([]domain.Message, error)
+	UpdateMetadata(ctx context.Context, id, metadata string) error
+	// Edit stores the new text and metadata and records the previous version.
+	// It reports false, storing nothing, when the message was recalled or removed.
+	Edit(ctx context.Context, message *domain.Message, previous *domain.MessageEdit) (bool, error)
+	ListEdits(ctx context.Context, messageID string) ([]domain.MessageEdit, error)
+	// Recall turns the message into a tombstone and drops its edit history.
+	// It reports false if the message was already recalled.
+	Recall(ctx context.Context, id string, at time.Time) (bool, error)
+	// HideForAccount records a delete-for-me; repeating it is a no-op.
+	HideForAccount(ctx context.Context, deletion *domain.MessageDeletion) error
+}
+
+// MessageSearchFilter narrows a message search. Terms must all appear in the
+// text; the other fields are optional.
+type MessageSearchFilter struct {
+	AccountID      string // searcher; only their conversations are searched
+	Terms          []string
+	ConversationID string
+	SenderID       string
+	Kind           string
+	From           *time.Time
+	To             *time.Time
+	Page           int
+	Size           int
+}
+
+// MessageSearchRepository finds messages by text, newest first.
+type MessageSearchRepository interface {
+	Search(ctx context.Context, filter MessageSearchFilter) ([]domain.Message, int64, error)
 }
 
-// MessageReceiptRepository records read state for messages.
-type MessageReceiptRepository interface {
-	CreateBatch(ctx context.Context, receipts []domain.MessageReceipt) error
-	CountUnread(ctx context.Context, accountID, conversationID string) (int64, error)
+// MessageReactionRepository stores emoji reactions.
+type MessageReactionRepository interface {
+	// Toggle adds the reaction, or removes it if the account already added
+	// that emoji, and reports whether it was added.
+	Toggle(ctx context.Context, reaction *domain.MessageReaction) (bool, error)
+	ListByMessages(ctx context.Context, messageIDs []string) ([]domain.MessageReaction, error)
+	DeleteByMessage(ctx context.Context, messageID string) error
+}
+
+// MessageMentionRepository stores @mentions.
+type MessageMentionRepository interface {
+	CreateBatch(ctx context.Context, mentions []domain.MessageMention) error
+	ListByMessages(ctx context.Context, messageIDs []string) ([]domain.MessageMention, error)
+	// ListByAccount returns the account's mentions, newest first.
+	ListByAccount(ctx context.Context, accountID string, unreadOnly bool, limit int) ([]domain.MessageMention, error)
+	// CountUnread counts unread mentions per conversation; nil
+	// conversationIDs counts all of them.
+	CountUnread(ctx context.Context, accountID string, conversationIDs []string) (map[string]int64, error)
 	MarkReadUpTo(ctx context.Context, accountID, conversationID string, ts time.Time) error
 }
+
+// ChatMediaRepository stores uploaded chat media and its processing state.
+type ChatMediaRepository interface {
+	Create(ctx context.Context, media *domain.ChatMedia) error
+	GetByID(ctx context.Context, id string) (*domain.ChatMedia, error)
+	Update(ctx context.Context, media *domain.ChatMedia) error
+	ListByStatus(ctx context.Context, status domain.ChatMediaStatus, limit int) ([]domain.ChatMedia, error)
+}
+
+// NotificationFilter selects a page of an account's inbox; an empty
+// Category matches all.
+type NotificationFilter struct {
+	AccountID  string
+	Category   domain.NotificationCategory
+	UnreadOnly bool
+	Page       int
+	Size       int
+}
+
+// NotificationRepository stores account inboxes and notification preferences.
+type NotificationRepository interface {
+	CreateBatch(ctx context.Context, notifications []domain.Notification) error
+	// MergeBatch folds each notification into its account's unread
+	// notification with the same GroupKey, bumping its count and replacing
+	// its text, or creates it if there is none, in one statement. At most one
+	// notification per account may be given; the stored IDs and counts are
+	// written back.
+	MergeBatch(ctx context.Context, notifications []domain.Notification) error
+	// List returns notifications newest first with the total matching the filter.
+	List(ctx context.Context, filter NotificationFilter) ([]domain.Notification, int64, error)
+	// CountUnread counts unread notifications per category.
+	CountUnread(ctx context.Context, accountID string) (map[domain.NotificationCategory]int64, error)
+	// SetRead marks the account's notifications read at the given time, or
+	// unread when at is nil, and reports how many changed. Only one
+	// notification per group can be unread, so marking unread skips grouped
+	// notifications whose group already has one.
+	SetRead(ctx context.Context, accountID string, ids []string, at *time.Time) (int64, error)
+	// MarkAllRead marks every unread notification of the account read, only
+	// those of the category when it is not empty.
+	MarkAllRead(ctx context.Context, accountID string, category domain.NotificationCategory, at time.Time) (int64, error)
+	// MarkGroupRead marks the account's unread notification with the
+	// GroupKey read.
+	MarkGroupRead(ctx context.Context, accountID, groupKey string, at time.Time) (int64, error)
+	ListPreferences(ctx context.Context, accountID string) ([]domain.NotificationPreference, error)
+	SavePreferences(ctx context.Context, prefs []domain.NotificationPreference) error
+	// ListDisabled returns which of the accounts turned the category off.
+	ListDisabled(ctx context.Context, accountIDs []string, category domain.NotificationCategory) ([]string, error)
+}
@@ -9,6 +9,17 @@ import (
 	"learn-go/internal/domain"
 	"learn-go/internal/repository"
 	"learn-go/pkg/crypto"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrStudentNotFound indicates the student profile does not exist.
+	ErrStudentNotFound = errors.New("student not found")
+	// ErrTeacherNotFound indicates the teacher profile does not exist.
+	ErrTeacherNotFound = errors.New("teacher not found")
+	// ErrParentNotFound indicates the parent account does not exist.
+	ErrParentNotFound = errors.New("parent not found")
 )
 
 // AdminService manages administrative operations.
@@ -19,10 +30,12 @@ type AdminService struct {
 	departments  repository.DepartmentRepository
 	classes      repository.ClassRepository
 	teacherLinks repository.TeacherStudentRepository
+	parentLinks  repository.ParentStudentRepository
+	classChats   *ClassChatService
 }
 
 // NewAdminService constructs an AdminService.
-func NewAdminService(acc repository.AccountRepository, teachers repository.TeacherRepository, students repository.StudentRepository, departments repository.DepartmentRepository, classes repository.ClassRepository, links repository.TeacherStudentRepository) *AdminService {
+func NewAdminService(acc repository.AccountRepository, teachers repository.TeacherRepository, students repository.StudentRepository, departments repository.DepartmentRepository, classes repository.ClassRepository, links repository.TeacherStudentRepository, parentLinks repository.ParentStudentRepository, classChats *ClassChatService) *AdminService {
 	return &AdminService{
 		accounts:     acc,
 		teachers:     teachers,
@@ -30,6 +43,8 @@ func NewAdminService(acc repository.AccountRepository, teachers repository.Teach
 		departments:  departments,
 		classes:      classes,
 		teacherLinks: links,
+		parentLinks:  parentLinks,
+		classChats:   classChats,
 	}
 }
 
@@ -101,6 +116,12 @@ func (s *AdminService) CreateStudent(ctx context.Context, input CreateStudentInp
 	if len(input.TeacherIDs) == 0 {
 		return nil, errors.New("at least one teacher required")
 	}
+	if _, err := s.classes.GetByID(ctx, input.ClassID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrClassNotFound
+		}
+		return nil, err
+	}
 
 	hash, err := crypto.HashPassword(input.DefaultPwd)
 	if err != nil {
@@ -138,9 +159,182 @@ func (s *AdminService) CreateStudent(ctx context.Context, input CreateStudentInp
 		return nil, err
 	}
 
+	if _, err := s.classChats.SyncClass(ctx, student.ClassID); err != nil {
+		return nil, err
+	}
+
+	return student, nil
+}
+
+// TransferStudent moves a student to another class of the same school and
+// updates the chats of both classes.
+func (s *AdminService) TransferStudent(ctx context.Context, studentID, classID string) (*domain.Student, error) {
+	student, err := s.students.GetByID(ctx, studentID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrStudentNotFound
+		}
+		return nil, err
+	}
+	class, err := s.classes.GetByID(ctx, classID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrClassNotFound
+		}
+		return nil, err
+	}
+	if class.SchoolID != student.SchoolID {
+		return nil, ErrClassNotFound
+	}
+	if student.ClassID == classID {
+		return student, nil
+	}
+
+	previous := student.ClassID
+	if err := s.students.UpdateClass(ctx, student.ID, classID); err != nil {
+		return nil, err
+	}
+	student.ClassID = classID
+	if previous != "" {
+		if _, err := s.classChats.SyncClass(ctx, previous); err != nil && !errors.Is(err, ErrClassNotFound) {
+			return nil, err
+		}
+	}
+	if _, err := s.classChats.SyncClass(ctx, classID); err != nil {
+		return nil, err
+	}
 	return student, nil
 }
 
+// BindStudentTeachers replaces the teachers bound to a student.
+func (s *AdminService) BindStudentTeachers(ctx context.Context, studentID string, teacherIDs []string) error {
+	if len(teacherIDs) == 0 {
+		return errors.New("at least one teacher required")
+	}
+	student, err := s.students.GetByID(ctx, studentID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrStudentNotFound
+		}
+		return err
+	}
+	if err := s.teacherLinks.BindTeachers(ctx, student.ID, teacherIDs); err != nil {
+		return err
+	}
+	_, err = s.classChats.SyncClass(ctx, student.ClassID)
+	return err
+}
+
+// SetClassHomeroom assigns the homeroom teacher of a class, or clears it when
+// teacherID is empty. The homeroom teacher owns the class chat.
+func (s *AdminService) SetClassHomeroom(ctx context.Context, classID, teacherID string) error {
+	class, err := s.classes.GetByID(ctx, classID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrClassNotFound
+		}
+		return err
+	}
+	var homeroom *string
+	if teacherID != "" {
+		teacher, err := s.teachers.GetByID(ctx, teacherID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrTeacherNotFound
+			}
+			return err
+		}
+		if teacher.SchoolID != class.SchoolID {
+			return errors.New("teacher belongs to another school")
+		}
+		homeroom = &teacher.ID
+	}
+	if err := s.classes.SetHomeroom(ctx, classID, homeroom); err != nil {
+		return err
+	}
+	_, err = s.classChats.SyncClass(ctx, classID)
+	return err
+}
+
+// CreateParentInput describes a parent account. Parents log in with their
+// phone number.
+type CreateParentInput struct {
+	SchoolID   string
+	Phone      string
+	Name       string
+	DefaultPwd string
+	StudentIDs []string
+}
+
+// CreateParent creates a parent account bound to its children.
+func (s *AdminService) CreateParent(ctx context.Context, input CreateParentInput) (*domain.Account, error) {
+	if input.DefaultPwd == "" {
+		return nil, errors.New("default password required")
+	}
+	if err := s.checkChildren(ctx, input.SchoolID, input.StudentIDs); err != nil {
+		return nil, err
+	}
+
+	hash, err := crypto.HashPassword(input.DefaultPwd)
+	if err != nil {
+		return nil, err
+	}
+
+	account := &domain.Account{
+		ID:           uuid.NewString(),
+		SchoolID:     input.SchoolID,
+		Role:         domain.RoleParent,
+		Identifier:   input.Phone,
+		PasswordHash: hash,
+		DisplayName:  input.Name,
+	}
+	if err := s.accounts.Create(ctx, account); err != nil {
+		return nil, err
+	}
+	if err := s.parentLinks.BindStudents(ctx, account.ID, input.StudentIDs); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// BindParentStudents replaces the children of a parent.
+func (s *AdminService) BindParentStudents(ctx context.Context, parentID string, studentIDs []string) error {
+	parent, err := s.accounts.FindByID(ctx, parentID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrParentNotFound
+		}
+		return err
+	}
+	if parent.Role != domain.RoleParent {
+		return ErrParentNotFound
+	}
+	if err := s.checkChildren(ctx, parent.SchoolID, studentIDs); err != nil {
+		return err
+	}
+	return s.parentLinks.BindStudents(ctx, parent.ID, studentIDs)
+}
+
+// checkChildren requires at least one student, all of them in the school.
+func (s *AdminService) checkChildren(ctx context.Context, schoolID string, studentIDs []string) error {
+	if len(studentIDs) == 0 {
+		return errors.New("at least one student required")
+	}
+	for _, id := range studentIDs {
+		student, err := s.students.GetByID(ctx, id)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrStudentNotFound
+			}
+			return err
+		}
+		if student.SchoolID != schoolID {
+			return ErrStudentNotFound
+		}
+	}
+	return nil
+}
+
 // CreateDepartment registers a new department.
 func (s *AdminService) CreateDepartment(ctx context.Context, schoolID, name string) (*domain.Department, error) {
 	department := &domain.Department{
@@ -165,6 +359,9 @@ func (s *AdminService) CreateClass(ctx context.Context, schoolID, departmentID,
 	if err := s.classes.Create(ctx, class); err != nil {
 		return nil, err
 	}
+	if _, err := s.classChats.SyncClass(ctx, class.ID); err != nil {
+		return nil, err
+	}
 	return class, nil
 }
 
@@ -3,6 +3,8 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
+	"math"
 	"strings"
 	"time"
 
@@ -10,23 +12,47 @@ import (
 
 	"learn-go/internal/domain"
 	"learn-go/internal/repository"
+	"learn-go/pkg/oss"
 
 	"gorm.io/gorm"
 )
 
 // AssignmentService manages assignments and submissions.
 type AssignmentService struct {
-	assignments repository.AssignmentRepository
-	submissions repository.SubmissionRepository
-	comments    repository.SubmissionCommentRepository
+	assignments   repository.AssignmentRepository
+	submissions   repository.SubmissionRepository
+	comments      repository.SubmissionCommentRepository
+	gradeHistory  repository.GradeHistoryRepository
+	regrades      repository.RegradeRequestRepository
+	stats         repository.AssignmentStatsRepository
+	students      repository.StudentRepository
+	classes       repository.ClassRepository
+	teachers      repository.TeacherRepository
+	teacherLinks  repository.TeacherStudentRepository
+	courses       repository.CourseRepository
+	attachments   repository.AttachmentRepository
+	storage       oss.Client
+	notifications *NotificationService
+	statsCache    statsCache
 }
 
 // NewAssignmentService creates a new AssignmentService.
-func NewAssignmentService(assignments repository.AssignmentRepository, submissions repository.SubmissionRepository, comments repository.SubmissionCommentRepository) *AssignmentService {
+func NewAssignmentService(assignments repository.AssignmentRepository, submissions repository.SubmissionRepository, comments repository.SubmissionCommentRepository, gradeHistory repository.GradeHistoryRepository, regrades repository.RegradeRequestRepository, stats repository.AssignmentStatsRepository, students repository.StudentRepository, classes repository.ClassRepository, teachers repository.TeacherRepository, teacherLinks repository.TeacherStudentRepository, courses repository.CourseRepository, attachments repository.AttachmentRepository, storage oss.Client, notifications *NotificationService) *AssignmentService {
 	return &AssignmentService{
-		assignments: assignments,
-		submissions: submissions,
-		comments:    comments,
+		assignments:   assignments,
+		submissions:   submissions,
+		comments:      comments,
+		gradeHistory:  gradeHistory,
+		regrades:      regrades,
+		stats:         stats,
+		students:      students,
+		classes:       classes,
+		teachers:      teachers,
+		teacherLinks:  teacherLinks,
+		courses:       courses,
+		attachments:   attachments,
+		storage:       storage,
+		notifications: notifications,
 	}
 }
 
@@ -39,7 +65,25 @@ var ErrSubmissionNotFound = errors.New("submission not found")
 // ErrSubmissionForbidden indicates the caller cannot access the submission.
 var ErrSubmissionForbidden = errors.New("submission forbidden")
 
+// ErrInvalidItemScore indicates an item score is outside its question's range.
+var ErrInvalidItemScore = errors.New("item score out of range")
+
+// ErrInvalidTotalScore indicates a submission total is outside the assignment's range.
+var ErrInvalidTotalScore = errors.New("total score out of range")
+
+// ErrTotalScoreMismatch indicates a supplied total disagrees with the item scores without an explicit override.
+var ErrTotalScoreMismatch = errors.New("total score does not match item scores")
+
+// ErrQuestionScoreMismatch indicates question scores do not add up to the assignment's max score.
+var ErrQuestionScoreMismatch = errors.New("question scores do not sum to max score")
+
+// scoreEpsilon absorbs floating point noise when comparing scores.
+const scoreEpsilon = 1e-6
+
 // CreateAssignmentInput contains data for creating an assignment.
+//
+// Status defaults to published so existing clients keep their behaviour; a
+// future PublishAt without a status schedules the assignment instead.
 type CreateAssignmentInput struct {
 	CourseID      string
 	TeacherID     string
@@ -47,11 +91,16 @@ type CreateAssignmentInput struct {
 	Type          domain.AssignmentType
 	Title         string
 	Description   string
+	Status        domain.AssignmentStatus
+	PublishAt     *time.Time
 	StartAt       *time.Time
 	DueAt         *time.Time
 	MaxScore      float64
 	AllowResubmit bool
 	Questions     []QuestionInput
+	// MaxAttachmentBytes and AllowedAttachmentTypes limit uploaded files; zero values use server defaults.
+	MaxAttachmentBytes     int64
+	AllowedAttachmentTypes []string
 }
 
 // QuestionInput describes a single question.
@@ -70,6 +119,17 @@ func (s *AssignmentService) CreateAssignment(ctx context.Context, input CreateAs
 		return nil, errors.New("course, teacher and class are required")
 	}
 
+	maxScore, err := resolveMaxScore(input.MaxScore, input.Questions)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	status, err := initialAssignmentStatus(input.Status, input.PublishAt, now)
+	if err != nil {
+		return nil, err
+	}
+
 	assignment := &domain.Assignment{
 		ID:            uuid.NewString(),
 		CourseID:      input.CourseID,
@@ -78,16 +138,64 @@ func (s *AssignmentService) CreateAssignment(ctx context.Context, input CreateAs
 		Type:          input.Type,
 		Title:         input.Title,
 		Description:   input.Description,
+		Status:        status,
+		Version:       1,
 		StartAt:       input.StartAt,
 		DueAt:         input.DueAt,
-		MaxScore:      input.MaxScore,
+		MaxScore:      maxScore,
 		AllowResubmit: input.AllowResubmit,
-		CreatedAt:     time.Now(),
-		UpdatedAt:     time.Now(),
+		CreatedAt:     now,
+		UpdatedAt:     now,
+
+		MaxAttachmentBytes:     input.MaxAttachmentBytes,
+		AllowedAttachmentTypes: normalizeAttachmentTypes(input.AllowedAttachmentTypes),
+	}
+	switch status {
+	case domain.AssignmentScheduled:
+		assignment.PublishAt = input.PublishAt
+	case domain.AssignmentPublished:
+		assignment.PublishedAt = &now
 	}
 
-	questions := make([]domain.AssignmentQuestion, 0, len(input.Questions))
-	for _, q := range input.Questions {
+	questions := buildQuestions(input.Questions)
+	if err := s.assignments.Create(ctx, assignment, questions); err != nil {
+		return nil, err
+	}
+	if assignment.Status == domain.AssignmentPublished {
+		s.notifications.logFailure(s.notifyPublished(ctx, assignment))
+	}
+	return assignment, nil
+}
+
+// resolveMaxScore checks question scores against the max score.
+// A zero max score means "derive from questions"; otherwise both must agree.
+// Assignments without questions keep the max score as given.
+func resolveMaxScore(maxScore float64, questions []QuestionInput) (float64, error) {
+	if maxScore < 0 {
+		return 0, fmt.Errorf("%w: max score must not be negative", ErrQuestionScoreMismatch)
+	}
+	if len(questions) == 0 {
+		return maxScore, nil
+	}
+	var questionTotal float64
+	for _, q := range questions {
+		if q.Score < 0 {
+			return 0, fmt.Errorf("%w: question scores must not be negative", ErrQuestionScoreMismatch)
+		}
+		questionTotal += q.Score
+	}
+	if maxScore == 0 {
+		return questionTotal, nil
+	}
+	if math.Abs(questionTotal-maxScore) > scoreEpsilon {
+		return 0, fmt.Errorf("%w: questions sum to %g, max score is %g", ErrQuestionScoreMismatch, questionTotal, maxScore)
+	}
+	return maxScore, nil
+}
+
+func buildQuestions(inputs []QuestionInput) []domain.AssignmentQuestion {
+	questions := make([]domain.AssignmentQuestion, 0, len(inputs))
+	for _, q := range inputs {
 		questions = append(questions, domain.AssignmentQuestion{
 			ID:         uuid.NewString(),
 			Type:       q.Type,
@@ -98,11 +206,7 @@ func (s *AssignmentService) CreateAssignment(ctx context.Context, input CreateAs
 			OrderIndex: q.OrderIndex,
 		})
 	}
-
-	if err := s.assignments.Create(ctx, assignment, questions); err != nil {
-		return nil, err
-	}
-	return assignment, nil
+	return questions
 }
 
 // SubmitAssignmentInput captures student submission payload.
@@ -117,9 +221,10 @@ type SubmitAssignmentInput struct {
 
 // AnswerInput ties an answer to a question.
 type AnswerInput struct {
-	QuestionID string
-	Answer     string
-	Score      *float64
+	QuestionID    string
+	Answer        string
+	Score         *float64
+	AttachmentIDs []string // files previously uploaded via CreateAttachmentUpload
 }
 
 // Submit records or updates a student submission.
@@ -128,6 +233,14 @@ func (s *AssignmentService) Submit(ctx context.Context, input SubmitAssignmentIn
 		return errors.New("assignment and student required")
 	}
 
+	assignment, _, err := s.GetAssignment(ctx, input.AssignmentID)
+	if err != nil {
+		return err
+	}
+	if assignment.Status != domain.AssignmentPublished {
+		return ErrAssignmentNotOpen
+	}
+
 	submission := &domain.AssignmentSubmission{
 		ID:           uuid.NewString(),
 		AssignmentID: input.AssignmentID,
@@ -146,6 +259,12 @@ func (s *AssignmentService) Submit(ctx context.Context, input SubmitAssignmentIn
 
 	items := make([]domain.SubmissionItem, 0, len(input.Answers))
 	for _, ans := range input.Answers {
+		if strings.TrimSpace(ans.Answer) == "" && len(ans.AttachmentIDs) == 0 {
+			return fmt.Errorf("%w: question %s needs an answer or attachment", ErrInvalidAttachment, ans.QuestionID)
+		}
+		if err := s.checkAttachments(ctx, input.StudentID, assignment, domain.AttachmentTargetSubmissionItem, ans.AttachmentIDs); err != nil {
+			return err
+		}
 		items = append(items, domain.SubmissionItem{
 			ID:         uuid.NewString(),
 			QuestionID: ans.QuestionID,
@@ -154,13 +273,24 @@ func (s *AssignmentService) Submit(ctx context.Context, input SubmitAssignmentIn
 		})
 	}
 
-	return s.submissions.CreateOrUpdate(ctx, submission, items)
+	if err := s.submissions.CreateOrUpdate(ctx, submission, items); err != nil {
+		return err
+	}
+	for i, ans := range input.Answers {
+		if err := s.attachments.Bind(ctx, ans.AttachmentIDs, items[i].ID); err != nil {
+			return err
+		}
+	}
+	s.statsCache.invalidate(input.AssignmentID)
+	return nil
 }
 
 // SubmissionDetail aggregates a submission and its items.
 type SubmissionDetail struct {
 	Submission domain.AssignmentSubmission
 	Items      []domain.SubmissionItem
+	// Attachments holds files bound to the items and to the submission's comments, keyed by TargetID.
+	Attachments []domain.Attachment
 }
 
 // GetAssignment retrieves an assignment with its questions.
@@ -237,7 +367,11 @@ func (s *AssignmentService) GetSubmissionForStudent(ctx context.Context, assignm
 		return nil, nil, err
 	}
 
-	return &SubmissionDetail{Submission: *submission, Items: items}, comments, nil
+	detail := &SubmissionDetail{Submission: *submission, Items: items}
+	if err := s.loadSubmissionAttachments(ctx, detail, comments); err != nil {
+		return nil, nil, err
+	}
+	return detail, comments, nil
 }
 
 // GetSubmissionForTeacher returns a submission ensuring the teacher owns the assignment.
@@ -270,22 +404,32 @@ func (s *AssignmentService) GetSubmissionForTeacher(ctx context.Context, teacher
 		return nil, nil, err
 	}
 
-	return &SubmissionDetail{Submission: *submission, Items: items}, comments, nil
+	detail := &SubmissionDetail{Submission: *submission, Items: items}
+	if err := s.loadSubmissionAttachments(ctx, detail, comments); err != nil {
+		return nil, nil, err
+	}
+	return detail, comments, nil
 }
 
 // SubmissionCommentInput captures submission comment payload.
 type SubmissionCommentInput struct {
-	Content string
+	Content       string
+	AttachmentIDs []string
 }
 
 // GradeSubmissionInput captures grading updates.
+//
+// The submission total is recomputed from item scores unless OverrideTotal is
+// set, in which case Score is taken as-is (still bounded by the max score).
 type GradeSubmissionInput struct {
-	AssignmentID string
-	SubmissionID string
-	Score        *float64
-	Feedback     string
-	ItemScores   map[string]*float64
-	Comment      *SubmissionCommentInput
+	AssignmentID  string
+	SubmissionID  string
+	Score         *float64
+	OverrideTotal bool
+	Feedback      string
+	ItemScores    map[string]*float64
+	Comment       *SubmissionCommentInput
+	Reason        string // recorded in the grade history
 }
 
 // GradeSubmission applies scoring updates and optional comment.
@@ -297,7 +441,7 @@ func (s *AssignmentService) GradeSubmission(ctx context.Context, teacherID strin
 		return nil, nil, errors.New("assignment and submission required")
 	}
 
-	assignment, _, err := s.GetAssignment(ctx, input.AssignmentID)
+	assignment, questions, err := s.GetAssignment(ctx, input.AssignmentID)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -316,23 +460,124 @@ func (s *AssignmentService) GradeSubmission(ctx context.Context, teacherID strin
 		return nil, nil, ErrSubmissionForbidden
 	}
 
+	if input.Comment != nil {
+		if err := s.checkAttachments(ctx, teacherID, assignment, domain.AttachmentTargetSubmissionComment, input.Comment.AttachmentIDs); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	mergedItems, err := s.applyGrades(ctx, teacherID, assignment, questions, submission, items, input)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if input.Comment != nil && (strings.TrimSpace(input.Comment.Content) != "" || len(input.Comment.AttachmentIDs) > 0) {
+		comment, err := s.addComment(ctx, submission.ID, teacherID, domain.RoleTeacher, input.Comment.Content)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := s.attachments.Bind(ctx, input.Comment.AttachmentIDs, comment.ID); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	comments, err := s.comments.ListBySubmission(ctx, submission.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	detail := &SubmissionDetail{Submission: *submission, Items: mergedItems}
+	if err := s.loadSubmissionAttachments(ctx, detail, comments); err != nil {
+		return nil, nil, err
+	}
+	s.notifications.logFailure(s.notifyGraded(ctx, teacherID, assignment, submission))
+	return detail, comments, nil
+}
+
+// notifyGraded tells the student their submission was graded. Repeated
+// grading merges into the student's unread notification of the submission.
+func (s *AssignmentService) notifyGraded(ctx context.Context, teacherID string, assignment *domain.Assignment, submission *domain.AssignmentSubmission) error {
+	body := "Your submission was graded."
+	if submission.Score != nil {
+		body = fmt.Sprintf("Score: %g / %g.", *submission.Score, assignment.MaxScore)
+	}
+	if submission.Feedback != "" {
+		body += " " + submission.Feedback
+	}
+	return s.notifications.Notify(ctx, []string{submission.StudentID}, NotificationInput{
+		Category:    domain.NotificationGrade,
+		Title:       fmt.Sprintf("%s was graded", assignment.Title),
+		Body:        body,
+		SubjectType: NotificationSubjectAssignment,
+		SubjectID:   assignment.ID,
+		ActorID:     teacherID,
+		GroupKey:    "submission:" + submission.ID,
+	})
+}
+
+// applyGrades validates and persists score updates together with their audit
+// records, returning the submission items as they are after the update.
+func (s *AssignmentService) applyGrades(ctx context.Context, graderID string, assignment *domain.Assignment, questions []domain.AssignmentQuestion, submission *domain.AssignmentSubmission, items []domain.SubmissionItem, input GradeSubmissionInput) ([]domain.SubmissionItem, error) {
+	grades, merged, err := prepareGrades(graderID, assignment, questions, submission, items, input)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.submissions.UpdateGrades(ctx, grades); err != nil {
+		return nil, err
+	}
+	s.statsCache.invalidate(assignment.ID)
+	return merged, nil
+}
+
+// prepareGrades validates score updates and applies them to submission,
+// returning the rows to write and the submission items as they will be after
+// the write.
+func prepareGrades(graderID string, assignment *domain.Assignment, questions []domain.AssignmentQuestion, submission *domain.AssignmentSubmission, items []domain.SubmissionItem, input GradeSubmissionInput) (*repository.GradeWrite, []domain.SubmissionItem, error) {
 	itemByID := make(map[string]domain.SubmissionItem, len(items))
 	for _, item := range items {
 		itemByID[item.ID] = item
 	}
 
+	questionByID := make(map[string]domain.AssignmentQuestion, len(questions))
+	for _, q := range questions {
+		questionByID[q.ID] = q
+	}
+
+	now := time.Now()
+	var changes []domain.GradeChange
+
 	updates := make([]domain.SubmissionItem, 0, len(input.ItemScores))
 	for itemID, score := range input.ItemScores {
 		original, ok := itemByID[itemID]
 		if !ok {
 			return nil, nil, errors.New("invalid item id")
 		}
+		if score != nil {
+			question, ok := questionByID[original.QuestionID]
+			if !ok {
+				return nil, nil, fmt.Errorf("%w: item %s has no matching question", ErrInvalidItemScore, itemID)
+			}
+			if *score < 0 || *score > question.Score+scoreEpsilon {
+				return nil, nil, fmt.Errorf("%w: item %s must be between 0 and %g", ErrInvalidItemScore, itemID, question.Score)
+			}
+		}
+		if !sameScore(original.Score, score) {
+			changes = append(changes, newGradeChange(submission.ID, itemID, original.Score, score, graderID, input.Reason, now))
+		}
 		original.Score = score
 		updates = append(updates, original)
 	}
 
-	if input.Score != nil {
-		submission.Score = input.Score
+	merged := mergeItems(items, updates)
+	total, err := resolveTotalScore(assignment, merged, input)
+	if err != nil {
+		return nil, nil, err
+	}
+	if total != nil {
+		if !sameScore(submission.Score, total) {
+			changes = append(changes, newGradeChange(submission.ID, "", submission.Score, total, graderID, input.Reason, now))
+		}
+		submission.Score = total
 	}
 	if input.Feedback != "" {
 		submission.Feedback = input.Feedback
@@ -340,33 +585,108 @@ func (s *AssignmentService) GradeSubmission(ctx context.Context, teacherID strin
 	if submission.Status != "graded" {
 		submission.Status = "graded"
 	}
-	submission.UpdatedAt = time.Now()
+	submission.UpdatedAt = now
 
-	if err := s.submissions.UpdateGrades(ctx, submission, updates); err != nil {
-		return nil, nil, err
+	return &repository.GradeWrite{Submission: submission, Items: updates, Changes: changes}, merged, nil
+}
+
+func (s *AssignmentService) addComment(ctx context.Context, submissionID, authorID string, role domain.Role, content string) (*domain.SubmissionComment, error) {
+	comment := &domain.SubmissionComment{
+		ID:           uuid.NewString(),
+		SubmissionID: submissionID,
+		AuthorID:     authorID,
+		AuthorRole:   role,
+		Content:      content,
+		CreatedAt:    time.Now(),
+	}
+	if err := s.comments.Create(ctx, comment); err != nil {
+		return nil, err
+	}
+	return comment, nil
+}
+
+func newGradeChange(submissionID, itemID string, oldScore, newScore *float64, graderID, reason string, at time.Time) domain.GradeChange {
+	return domain.GradeChange{
+		ID:           uuid.NewString(),
+		SubmissionID: submissionID,
+		ItemID:       itemID,
+		OldScore:     copyScore(oldScore),
+		NewScore:     copyScore(newScore),
+		GraderID:     graderID,
+		Reason:       reason,
+		CreatedAt:    at,
 	}
+}
+
+func sameScore(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return math.Abs(*a-*b) <= scoreEpsilon
+}
 
-	if input.Comment != nil && strings.TrimSpace(input.Comment.Content) != "" {
-		comment := &domain.SubmissionComment{
-			ID:           uuid.NewString(),
-			SubmissionID: submission.ID,
-			AuthorID:     teacherID,
-			AuthorRole:   domain.RoleTeacher,
-			Content:      input.Comment.Content,
-			CreatedAt:    time.Now(),
+func copyScore(score *float64) *float64 {
+	if score == nil {
+		return nil
+	}
+	v := *score
+	return &v
+}
+
+// resolveTotalScore determines the submission total after item updates.
+// Without an override the total is the sum of scored items; a caller-supplied
+// total must then agree with it. A nil result leaves the stored total as-is.
+func resolveTotalScore(assignment *domain.Assignment, items []domain.SubmissionItem, input GradeSubmissionInput) (*float64, error) {
+	if input.OverrideTotal {
+		if input.Score == nil {
+			return nil, fmt.Errorf("%w: override requires a score", ErrInvalidTotalScore)
 		}
-		if err := s.comments.Create(ctx, comment); err != nil {
-			return nil, nil, err
+		if err := checkTotalRange(assignment, *input.Score); err != nil {
+			return nil, err
 		}
+		return input.Score, nil
 	}
 
-	comments, err := s.comments.ListBySubmission(ctx, submission.ID)
-	if err != nil {
-		return nil, nil, err
+	var (
+		sum    float64
+		scored bool
+	)
+	for _, item := range items {
+		if item.Score != nil {
+			sum += *item.Score
+			scored = true
+		}
+	}
+
+	if !scored {
+		if input.Score == nil {
+			return nil, nil
+		}
+		if err := checkTotalRange(assignment, *input.Score); err != nil {
+			return nil, err
+		}
+		return input.Score, nil
+	}
+
+	if input.Score != nil && math.Abs(*input.Score-sum) > scoreEpsilon {
+		return nil, fmt.Errorf("%w: items sum to %g, got %g (set override_total to keep it)", ErrTotalScoreMismatch, sum, *input.Score)
+	}
+	if err := checkTotalRange(assignment, sum); err != nil {
+		return nil, err
 	}
+	return &sum, nil
+}
 
-	mergedItems := mergeItems(items, updates)
-	return &SubmissionDetail{Submission: *submission, Items: mergedItems}, comments, nil
+// checkTotalRange bounds a total by the max score; an assignment without a
+// max score only rules out negative totals.
+func checkTotalRange(assignment *domain.Assignment, score float64) error {
+	if score < 0 {
+		return fmt.Errorf("%w: must not be negative", ErrInvalidTotalScore)
+	}
+	if assignment.MaxScore > 0 && score > assignment.MaxScore+scoreEpsilon {
+		return fmt.Errorf("%w: must be between 0 and %g", ErrInvalidTotalScore, assignment.MaxScore)
+	}
+	return nil
 }
 
 func mergeItems(original []domain.SubmissionItem, updates []domain.SubmissionItem) []domain.SubmissionItem {
@@ -0,0 +1,222 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"learn-go/internal/domain"
+	"learn-go/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+// ErrStudentProfileNotFound indicates the account has no student record.
+var ErrStudentProfileNotFound = errors.New("student profile not found")
+
+// StudentAssignmentStatus is a student's progress on one assignment.
+type StudentAssignmentStatus string
+
+const (
+	StudentAssignmentNotStarted StudentAssignmentStatus = "not_started"
+	StudentAssignmentDraft      StudentAssignmentStatus = "draft"
+	StudentAssignmentSubmitted  StudentAssignmentStatus = "submitted"
+	StudentAssignmentLate       StudentAssignmentStatus = "late"
+	StudentAssignmentGraded     StudentAssignmentStatus = "graded"
+)
+
+// StudentAssignmentFilter narrows the student inbox. Empty fields match everything.
+type StudentAssignmentFilter struct {
+	CourseID string
+	Type     domain.AssignmentType
+	Status   StudentAssignmentStatus
+	DueDesc  bool // sort by due date descending instead of ascending
+}
+
+// StudentAssignmentItem is one row of the student inbox.
+type StudentAssignmentItem struct {
+	Assignment  domain.Assignment
+	Status      StudentAssignmentStatus
+	Score       *float64
+	SubmittedAt *time.Time
+	Overdue     bool // past due with nothing submitted
+}
+
+// StudentInbox lists a student's assignments with status counts.
+type StudentInbox struct {
+	Items  []StudentAssignmentItem
+	Counts map[StudentAssignmentStatus]int
+	// Pending counts open assignments still waiting for a submission, for the dashboard badge.
+	Pending int
+}
+
+// TeacherAssignmentFilter narrows the teacher's assignment listing.
+type TeacherAssignmentFilter struct {
+	ClassID  string
+	CourseID string
+	Type     domain.AssignmentType
+	Status   domain.AssignmentStatus
+}
+
+// TeacherAssignmentItem pairs an assignment with its submission progress.
+type TeacherAssignmentItem struct {
+	Assignment domain.Assignment
+	Submitted  int64
+	Graded     int64
+	Ungraded   int64
+}
+
+// ListStudentAssignments returns the released assignments of the student's
+// class together with the student's own progress on each.
+func (s *AssignmentService) ListStudentAssignments(ctx context.Context, accountID string, filter StudentAssignmentFilter) (*StudentInbox, error) {
+	if accountID == "" {
+		return nil, errors.New("account id required")
+	}
+	student, err := s.students.GetByAccountID(ctx, accountID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrStudentProfileNotFound
+		}
+		return nil, err
+	}
+
+	assignments, err := s.assignments.ListByClass(ctx, student.ClassID, filter.CourseID)
+	if err != nil {
+		return nil, err
+	}
+
+	visible := make([]domain.Assignment, 0, len(assignments))
+	ids := make([]string, 0, len(assignments))
+	for _, assignment := range assignments {
+		if !assignmentReleased(assignment) || assignment.Status == domain.AssignmentArchived {
+			continue
+		}
+		if filter.Type != "" && assignment.Type != filter.Type {
+			continue
+		}
+		visible = append(visible, assignment)
+		ids = append(ids, assignment.ID)
+	}
+
+	// Submissions are keyed by the student's account ID.
+	scores, err := s.submissions.ListScoresByStudent(ctx, accountID, ids)
+	if err != nil {
+		return nil, err
+	}
+	scoreByAssignment := make(map[string]repository.SubmissionScore, len(scores))
+	for _, score := range scores {
+		scoreByAssignment[score.AssignmentID] = score
+	}
+
+	now := time.Now()
+	inbox := &StudentInbox{
+		Items:  make([]StudentAssignmentItem, 0, len(visible)),
+		Counts: make(map[StudentAssignmentStatus]int),
+	}
+	for _, assignment := range visible {
+		score, found := scoreByAssignment[assignment.ID]
+		item := studentAssignmentItem(assignment, score, found, now)
+
+		inbox.Counts[item.Status]++
+		if assignment.Status == domain.AssignmentPublished &&
+			(item.Status == StudentAssignmentNotStarted || item.Status == StudentAssignmentDraft) {
+			inbox.Pending++
+		}
+		if filter.Status != "" && item.Status != filter.Status {
+			continue
+		}
+		inbox.Items = append(inbox.Items, item)
+	}
+
+	sortByDueDate(inbox.Items, filter.DueDesc)
+	return inbox, nil
+}
+
+// ListTeacherAssignments returns assignments the teacher created with
+// submitted, graded and ungraded counts.
+func (s *AssignmentService) ListTeacherAssignments(ctx context.Context, teacherID string, filter TeacherAssignmentFilter) ([]TeacherAssignmentItem, error) {
+	if teacherID == "" {
+		return nil, errors.New("teacher id required")
+	}
+
+	repoFilter := repository.AssignmentFilter{
+		ClassID:  filter.ClassID,
+		CourseID: filter.CourseID,
+		Type:     filter.Type,
+	}
+	if filter.Status != "" {
+		repoFilter.Statuses = []domain.AssignmentStatus{filter.Status}
+	}
+	assignments, err := s.assignments.ListByTeacher(ctx, teacherID, repoFilter)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(assignments))
+	for _, assignment := range assignments {
+		ids = append(ids, assignment.ID)
+	}
+	counts, err := s.submissions.CountByAssignments(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	countByAssignment := make(map[string]repository.SubmissionCount, len(counts))
+	for _, count := range counts {
+		countByAssignment[count.AssignmentID] = count
+	}
+
+	items := make([]TeacherAssignmentItem, 0, len(assignments))
+	for _, assignment := range assignments {
+		count := countByAssignment[assignment.ID]
+		items = append(items, TeacherAssignmentItem{
+			Assignment: assignment,
+			Submitted:  count.Submitted,
+			Graded:     count.Graded,
+			Ungraded:   count.Ungraded,
+		})
+	}
+	return items, nil
+}
+
+func studentAssignmentItem(assignment domain.Assignment, score repository.SubmissionScore, found bool, now time.Time) StudentAssignmentItem {
+	item := StudentAssignmentItem{Assignment: assignment, Status: StudentAssignmentNotStarted}
+	switch {
+	case !found:
+	case score.Status == "graded":
+		item.Status = StudentAssignmentGraded
+		item.Score = score.Score
+		item.SubmittedAt = score.SubmittedAt
+	case score.SubmittedAt == nil:
+		item.Status = StudentAssignmentDraft
+	case assignment.DueAt != nil && score.SubmittedAt.After(*assignment.DueAt):
+		item.Status = StudentAssignmentLate
+		item.SubmittedAt = score.SubmittedAt
+	default:
+		item.Status = StudentAssignmentSubmitted
+		item.SubmittedAt = score.SubmittedAt
+	}
+	if item.SubmittedAt == nil && item.Status != StudentAssignmentGraded {
+		item.Overdue = assignment.DueAt != nil && now.After(*assignment.DueAt)
+	}
+	return item
+}
+
+// sortByDueDate orders items by due date; assignments without one go last.
+func sortByDueDate(items []StudentAssignmentItem, desc bool) {
+	sort.SliceStable(items, func(i, j int) bool {
+		a, b := items[i].Assignment.DueAt, items[j].Assignment.DueAt
+		switch {
+		case a == nil && b == nil:
+			return false
+		case a == nil:
+			return false
+		case b == nil:
+			return true
+		case desc:
+			return a.After(*b)
+		default:
+			return a.Before(*b)
+		}
+	})
+}
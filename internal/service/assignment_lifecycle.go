@@ -0,0 +1,515 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"learn-go/internal/domain"
+)
+
+var (
+	// ErrAssignmentForbidden indicates the caller does not own the assignment.
+	ErrAssignmentForbidden = errors.New("assignment forbidden")
+	// ErrAssignmentLocked indicates the requested edit is not allowed in the assignment's current state.
+	ErrAssignmentLocked = errors.New("assignment locked")
+	// ErrInvalidAssignmentStatus indicates an unsupported status or lifecycle transition.
+	ErrInvalidAssignmentStatus = errors.New("invalid assignment status")
+	// ErrAssignmentHasSubmissions indicates the assignment cannot be deleted because students have submitted.
+	ErrAssignmentHasSubmissions = errors.New("assignment has submissions")
+	// ErrAssignmentNotOpen indicates the assignment is not accepting submissions.
+	ErrAssignmentNotOpen = errors.New("assignment not open for submissions")
+)
+
+// UpdateAssignmentInput carries a partial assignment edit. Nil fields are left unchanged.
+type UpdateAssignmentInput struct {
+	AssignmentID  string
+	Title         *string
+	Description   *string
+	StartAt       *time.Time
+	DueAt         *time.Time
+	MaxScore      *float64
+	AllowResubmit *bool
+	Questions     []QuestionInput // nil keeps the current questions
+	Summary       string          // recorded in the change log for published assignments
+
+	MaxAttachmentBytes     *int64
+	AllowedAttachmentTypes []string // nil keeps the current list
+}
+
+// DuplicateAssignmentInput selects where a copy of an assignment goes.
+type DuplicateAssignmentInput struct {
+	AssignmentID string
+	ClassID      string
+	CourseID     string // empty keeps the source course
+}
+
+// assignmentSnapshot is the JSON stored in a revision.
+type assignmentSnapshot struct {
+	Assignment domain.Assignment           `json:"assignment"`
+	Questions  []domain.AssignmentQuestion `json:"questions"`
+}
+
+// assignmentReleased reports whether students can see the assignment.
+func assignmentReleased(assignment domain.Assignment) bool {
+	switch assignment.Status {
+	case domain.AssignmentDraft, domain.AssignmentScheduled:
+		return false
+	default:
+		return true
+	}
+}
+
+func initialAssignmentStatus(status domain.AssignmentStatus, publishAt *time.Time, now time.Time) (domain.AssignmentStatus, error) {
+	switch status {
+	case "":
+		if publishAt != nil && publishAt.After(now) {
+			return domain.AssignmentScheduled, nil
+		}
+		return domain.AssignmentPublished, nil
+	case domain.AssignmentDraft, domain.AssignmentPublished:
+		return status, nil
+	case domain.AssignmentScheduled:
+		if publishAt == nil || !publishAt.After(now) {
+			return "", fmt.Errorf("%w: scheduling requires a future publish_at", ErrInvalidAssignmentStatus)
+		}
+		return status, nil
+	default:
+		return "", fmt.Errorf("%w: cannot create an assignment as %s", ErrInvalidAssignmentStatus, status)
+	}
+}
+
+// GetAssignmentForViewer loads an assignment, hiding unreleased work from students.
+func (s *AssignmentService) GetAssignmentForViewer(ctx context.Context, role domain.Role, assignmentID string) (*domain.Assignment, []domain.AssignmentQuestion, error) {
+	assignment, questions, err := s.GetAssignment(ctx, assignmentID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if role == domain.RoleStudent && !assignmentReleased(*assignment) {
+		return nil, nil, ErrAssignmentNotFound
+	}
+	return assignment, questions, nil
+}
+
+// UpdateAssignment edits an assignment. Drafts and scheduled assignments are
+// edited in place. Published or closed assignments bump their version and keep
+// the previous state in the change log; their questions and max score are
+// frozen once any student has submitted. Archived assignments are read-only.
+func (s *AssignmentService) UpdateAssignment(ctx context.Context, accountID string, role domain.Role, input UpdateAssignmentInput) (*domain.Assignment, []domain.AssignmentQuestion, error) {
+	assignment, questions, err := s.loadOwnedAssignment(ctx, accountID, role, input.AssignmentID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if assignment.Status == domain.AssignmentArchived {
+		return nil, nil, fmt.Errorf("%w: archived assignments are read-only", ErrAssignmentLocked)
+	}
+
+	released := assignmentReleased(*assignment)
+	changesScoring := input.Questions != nil || (input.MaxScore != nil && *input.MaxScore != assignment.MaxScore)
+	if released && changesScoring {
+		total, _, err := s.submissions.CountByAssignment(ctx, assignment.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if total > 0 {
+			return nil, nil, fmt.Errorf("%w: questions cannot change after students have submitted", ErrAssignmentLocked)
+		}
+	}
+
+	snapshot, err := json.Marshal(assignmentSnapshot{Assignment: *assignment, Questions: questions})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if input.Title != nil {
+		assignment.Title = *input.Title
+	}
+	if input.Description != nil {
+		assignment.Description = *input.Description
+	}
+	if input.StartAt != nil {
+		assignment.StartAt = input.StartAt
+	}
+	if input.DueAt != nil {
+		assignment.DueAt = input.DueAt
+	}
+	if input.AllowResubmit != nil {
+		assignment.AllowResubmit = *input.AllowResubmit
+	}
+	if input.MaxAttachmentBytes != nil {
+		assignment.MaxAttachmentBytes = *input.MaxAttachmentBytes
+	}
+	if input.AllowedAttachmentTypes != nil {
+		assignment.AllowedAttachmentTypes = normalizeAttachmentTypes(input.AllowedAttachmentTypes)
+	}
+
+	var newQuestions []domain.AssignmentQuestion
+	if changesScoring {
+		maxScore := assignment.MaxScore
+		if input.MaxScore != nil {
+			maxScore = *input.MaxScore
+		}
+		scoring := input.Questions
+		if scoring == nil {
+			scoring = questionInputs(questions)
+		}
+		resolved, err := resolveMaxScore(maxScore, scoring)
+		if err != nil {
+			return nil, nil, err
+		}
+		assignment.MaxScore = resolved
+		if input.Questions != nil {
+			newQuestions = buildQuestions(input.Questions)
+			questions = newQuestions
+		}
+	}
+
+	now := time.Now()
+	assignment.UpdatedAt = now
+
+	var revision *domain.AssignmentRevision
+	if released {
+		assignment.Version++
+		revision = &domain.AssignmentRevision{
+			ID:           uuid.NewString(),
+			AssignmentID: assignment.ID,
+			Version:      assignment.Version,
+			EditorID:     accountID,
+			Summary:      strings.TrimSpace(input.Summary),
+			Snapshot:     string(snapshot),
+			CreatedAt:    now,
+		}
+	}
+
+	if err := s.saveAssignment(ctx, assignment, assignment.Status, newQuestions, revision); err != nil {
+		return nil, nil, err
+	}
+	s.statsCache.invalidate(assignment.ID)
+	return assignment, questions, nil
+}
+
+// PublishAssignment releases a draft, scheduled or closed assignment. A future
+// publishAt schedules a draft instead; the background publisher releases it later.
+func (s *AssignmentService) PublishAssignment(ctx context.Context, accountID string, role domain.Role, assignmentID string, publishAt *time.Time) (*domain.Assignment, error) {
+	assignment, _, err := s.loadOwnedAssignment(ctx, accountID, role, assignmentID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	schedule := publishAt != nil && publishAt.After(now)
+	switch assignment.Status {
+	case domain.AssignmentDraft, domain.AssignmentScheduled:
+	case domain.AssignmentClosed:
+		if schedule {
+			return nil, fmt.Errorf("%w: closed assignments can only be reopened immediately", ErrInvalidAssignmentStatus)
+		}
+	default:
+		return nil, fmt.Errorf("%w: cannot publish a %s assignment", ErrInvalidAssignmentStatus, assignment.Status)
+	}
+
+	from := assignment.Status
+	firstPublish := false
+	if schedule {
+		assignment.Status = domain.AssignmentScheduled
+		assignment.PublishAt = publishAt
+	} else {
+		assignment.Status = domain.AssignmentPublished
+		assignment.PublishAt = nil
+		if assignment.PublishedAt == nil {
+			assignment.PublishedAt = &now
+			firstPublish = true
+		}
+	}
+	assignment.UpdatedAt = now
+
+	if err := s.saveAssignment(ctx, assignment, from, nil, nil); err != nil {
+		return nil, err
+	}
+	// Reopening a closed assignment is not news to the class.
+	if firstPublish {
+		s.notifications.logFailure(s.notifyPublished(ctx, assignment))
+	}
+	return assignment, nil
+}
+
+// CloseAssignment stops accepting submissions for a published assignment.
+func (s *AssignmentService) CloseAssignment(ctx context.Context, accountID string, role domain.Role, assignmentID string) (*domain.Assignment, error) {
+	return s.transitionAssignment(ctx, accountID, role, assignmentID, domain.AssignmentClosed, domain.AssignmentPublished)
+}
+
+// ArchiveAssignment makes a published or closed assignment read-only.
+func (s *AssignmentService) ArchiveAssignment(ctx context.Context, accountID string, role domain.Role, assignmentID string) (*domain.Assignment, error) {
+	return s.transitionAssignment(ctx, accountID, role, assignmentID, domain.AssignmentArchived, domain.AssignmentPublished, domain.AssignmentClosed)
+}
+
+// DuplicateAssignment copies an assignment and its questions into another
+// class as a new draft.
+func (s *AssignmentService) DuplicateAssignment(ctx context.Context, accountID string, role domain.Role, input DuplicateAssignmentInput) (*domain.Assignment, error) {
+	if input.ClassID == "" {
+		return nil, errors.New("target class required")
+	}
+	source, questions, err := s.loadOwnedAssignment(ctx, accountID, role, input.AssignmentID)
+	if err != nil {
+		return nil, err
+	}
+
+	courseID := source.CourseID
+	if input.CourseID != "" {
+		courseID = input.CourseID
+	}
+	if err := s.checkAssignableClass(ctx, accountID, role, *source, input.ClassID, courseID); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	copied := &domain.Assignment{
+		ID:            uuid.NewString(),
+		CourseID:      courseID,
+		TeacherID:     source.TeacherID,
+		ClassID:       input.ClassID,
+		Type:          source.Type,
+		Title:         source.Title,
+		Description:   source.Description,
+		Status:        domain.AssignmentDraft,
+		Version:       1,
+		StartAt:       source.StartAt,
+		DueAt:         source.DueAt,
+		MaxScore:      source.MaxScore,
+		AllowResubmit: source.AllowResubmit,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+
+		MaxAttachmentBytes:     source.MaxAttachmentBytes,
+		AllowedAttachmentTypes: source.AllowedAttachmentTypes,
+	}
+	if err := s.assignments.Create(ctx, copied, buildQuestions(questionInputs(questions))); err != nil {
+		return nil, err
+	}
+	return copied, nil
+}
+
+// DeleteAssignment removes an assignment nobody has submitted to, along with
+// its uploaded attachments. Assignments with submissions are kept so student
+// work is never lost; archive them instead.
+func (s *AssignmentService) DeleteAssignment(ctx context.Context, accountID string, role domain.Role, assignmentID string) error {
+	assignment, _, err := s.loadOwnedAssignment(ctx, accountID, role, assignmentID)
+	if err != nil {
+		return err
+	}
+	attachments, err := s.attachments.ListByAssignment(ctx, assignment.ID)
+	if err != nil {
+		return err
+	}
+	deleted, err := s.assignments.Delete(ctx, assignment.ID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrAssignmentHasSubmissions
+	}
+	for _, attachment := range attachments {
+		_ = s.storage.Delete(ctx, attachment.ObjectKey)
+	}
+	s.statsCache.invalidate(assignment.ID)
+	return nil
+}
+
+// ListAssignmentRevisions returns the change log of an assignment, newest first.
+func (s *AssignmentService) ListAssignmentRevisions(ctx context.Context, accountID string, role domain.Role, assignmentID string) ([]domain.AssignmentRevision, error) {
+	if _, _, err := s.loadOwnedAssignment(ctx, accountID, role, assignmentID); err != nil {
+		return nil, err
+	}
+	return s.assignments.ListRevisions(ctx, assignmentID)
+}
+
+// PublishScheduled releases every scheduled assignment whose publish time has
+// passed and returns how many were published.
+func (s *AssignmentService) PublishScheduled(ctx context.Context, now time.Time) (int, error) {
+	due, err := s.assignments.ListScheduledBefore(ctx, now)
+	if err != nil {
+		return 0, err
+	}
+	published := 0
+	for _, assignment := range due {
+		ok, err := s.assignments.TransitionStatus(ctx, assignment.ID, domain.AssignmentScheduled, domain.AssignmentPublished, now)
+		if err != nil {
+			return published, err
+		}
+		if ok {
+			published++
+			if assignment.PublishedAt == nil {
+				assignment.Status = domain.AssignmentPublished
+				assignment.PublishedAt = &now
+				s.notifications.logFailure(s.notifyPublished(ctx, &assignment))
+			}
+		}
+	}
+	return published, nil
+}
+
+// notifyPublished tells the students of the class about a newly published
+// assignment.
+func (s *AssignmentService) notifyPublished(ctx context.Context, assignment *domain.Assignment) error {
+	students, err := s.students.ListByClass(ctx, assignment.ClassID)
+	if err != nil {
+		return err
+	}
+	recipients := make([]string, 0, len(students))
+	for _, student := range students {
+		recipients = append(recipients, student.AccountID)
+	}
+	body := assignment.Description
+	if assignment.DueAt != nil {
+		due := "Due " + assignment.DueAt.Format("2006-01-02 15:04") + "."
+		if body == "" {
+			body = due
+		} else {
+			body = due + " " + body
+		}
+	}
+	return s.notifications.Notify(ctx, recipients, NotificationInput{
+		Category:    domain.NotificationAssignment,
+		Title:       fmt.Sprintf("New assignment: %s", assignment.Title),
+		Body:        body,
+		SubjectType: NotificationSubjectAssignment,
+		SubjectID:   assignment.ID,
+		ActorID:     assignment.TeacherID,
+	})
+}
+
+func (s *AssignmentService) transitionAssignment(ctx context.Context, accountID string, role domain.Role, assignmentID string, to domain.AssignmentStatus, from ...domain.AssignmentStatus) (*domain.Assignment, error) {
+	assignment, _, err := s.loadOwnedAssignment(ctx, accountID, role, assignmentID)
+	if err != nil {
+		return nil, err
+	}
+	allowed := false
+	for _, status := range from {
+		if assignment.Status == status {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return nil, fmt.Errorf("%w: cannot move a %s assignment to %s", ErrInvalidAssignmentStatus, assignment.Status, to)
+	}
+
+	current := assignment.Status
+	assignment.Status = to
+	assignment.UpdatedAt = time.Now()
+	if err := s.saveAssignment(ctx, assignment, current, nil, nil); err != nil {
+		return nil, err
+	}
+	return assignment, nil
+}
+
+// saveAssignment stores an edited assignment, failing if its status moved
+// away from from since it was loaded.
+func (s *AssignmentService) saveAssignment(ctx context.Context, assignment *domain.Assignment, from domain.AssignmentStatus, questions []domain.AssignmentQuestion, revision *domain.AssignmentRevision) error {
+	updated, err := s.assignments.Update(ctx, assignment, from, questions, revision)
+	if err != nil {
+		return err
+	}
+	if !updated {
+		return fmt.Errorf("%w: assignment is no longer %s", ErrInvalidAssignmentStatus, from)
+	}
+	return nil
+}
+
+// loadOwnedAssignment loads an assignment the account may manage.
+func (s *AssignmentService) loadOwnedAssignment(ctx context.Context, accountID string, role domain.Role, assignmentID string) (*domain.Assignment, []domain.AssignmentQuestion, error) {
+	if accountID == "" {
+		return nil, nil, errors.New("account id required")
+	}
+	assignment, questions, err := s.GetAssignment(ctx, assignmentID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if role != domain.RoleAdmin && assignment.TeacherID != accountID {
+		return nil, nil, ErrAssignmentForbidden
+	}
+	return assignment, questions, nil
+}
+
+// checkAssignableClass verifies that the target class exists in the same
+// school as the source assignment and, for teachers, that they teach it: as
+// homeroom teacher, through a bound student, or through a scheduled session of
+// the course.
+func (s *AssignmentService) checkAssignableClass(ctx context.Context, accountID string, role domain.Role, source domain.Assignment, classID, courseID string) error {
+	target, err := s.classes.GetByID(ctx, classID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrClassNotFound
+		}
+		return err
+	}
+	origin, err := s.classes.GetByID(ctx, source.ClassID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if origin == nil || origin.SchoolID != target.SchoolID {
+		return ErrClassNotFound
+	}
+	if role == domain.RoleAdmin {
+		return nil
+	}
+
+	teacher, err := s.teachers.GetByAccountID(ctx, accountID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrAssignmentForbidden
+		}
+		return err
+	}
+	if teacher.SchoolID != target.SchoolID {
+		return ErrClassNotFound
+	}
+	if target.HomeroomID != nil && *target.HomeroomID == teacher.ID {
+		return nil
+	}
+	sessionTeachers, err := s.courses.ListTeacherIDs(ctx, classID, courseID)
+	if err != nil {
+		return err
+	}
+	if slices.Contains(sessionTeachers, teacher.ID) {
+		return nil
+	}
+	students, err := s.students.ListByClass(ctx, classID)
+	if err != nil {
+		return err
+	}
+	studentIDs := make([]string, 0, len(students))
+	for _, student := range students {
+		studentIDs = append(studentIDs, student.ID)
+	}
+	linkedTeachers, err := s.teacherLinks.ListTeacherIDs(ctx, studentIDs)
+	if err != nil {
+		return err
+	}
+	if slices.Contains(linkedTeachers, teacher.ID) {
+		return nil
+	}
+	return ErrAssignmentForbidden
+}
+
+func questionInputs(questions []domain.AssignmentQuestion) []QuestionInput {
+	inputs := make([]QuestionInput, 0, len(questions))
+	for _, q := range questions {
+		inputs = append(inputs, QuestionInput{
+			Type:       q.Type,
+			Prompt:     q.Prompt,
+			Options:    q.Options,
+			Answer:     q.Answer,
+			Score:      q.Score,
+			OrderIndex: q.OrderIndex,
+		})
+	}
+	return inputs
+}
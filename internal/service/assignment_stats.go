@@ -0,0 +1,296 @@
+package service
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"learn-go/internal/domain"
+	"learn-go/internal/repository"
+)
+
+const (
+	statsHistogramBuckets = 10
+	// statsGroupFraction is the classic upper/lower 27% split used for the discrimination index.
+	statsGroupFraction = 0.27
+)
+
+// AssignmentStats summarises results for an assignment.
+type AssignmentStats struct {
+	AssignmentID   string
+	ClassSize      int64
+	Submitted      int64
+	Graded         int64
+	SubmissionRate float64
+	Mean           *float64
+	Median         *float64
+	StdDev         *float64
+	Min            *float64
+	Max            *float64
+	Histogram      []HistogramBucket
+	Questions      []QuestionStats
+	ComputedAt     time.Time
+}
+
+// HistogramBucket counts graded totals within [Lower, Upper).
+// The last bucket also includes the max score.
+type HistogramBucket struct {
+	Lower float64
+	Upper float64
+	Count int64
+}
+
+// QuestionStats holds item analysis for a single question.
+type QuestionStats struct {
+	Question domain.AssignmentQuestion
+	Graded   int64
+	AvgScore *float64
+	// Difficulty is the mean proportion of the question's score earned (0 hard – 1 easy).
+	Difficulty *float64
+	// Discrimination compares the upper and lower 27% of students by total (-1 – 1).
+	Discrimination *float64
+	// OptionCounts maps each submitted answer to its frequency, for choice and judgement questions.
+	OptionCounts map[string]int64
+}
+
+// statsCacheTTL bounds how long cached statistics are served. The cache is
+// per process and invalidated only by changes made through this instance, so
+// the TTL is what bounds staleness when several replicas serve the API.
+const statsCacheTTL = time.Minute
+
+// statsCache keeps computed statistics until a grade or submission changes
+// on this instance, or statsCacheTTL passes.
+type statsCache struct {
+	mu      sync.Mutex
+	entries map[string]*AssignmentStats
+}
+
+func (c *statsCache) get(assignmentID string) (*AssignmentStats, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats, ok := c.entries[assignmentID]
+	if ok && time.Since(stats.ComputedAt) > statsCacheTTL {
+		delete(c.entries, assignmentID)
+		return nil, false
+	}
+	return stats, ok
+}
+
+func (c *statsCache) put(stats *AssignmentStats) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]*AssignmentStats)
+	}
+	c.entries[stats.AssignmentID] = stats
+}
+
+func (c *statsCache) invalidate(assignmentID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, assignmentID)
+}
+
+// GetAssignmentStats returns analytics for an assignment, served from cache
+// until a new grade or submission arrives or the cache entry expires.
+func (s *AssignmentService) GetAssignmentStats(ctx context.Context, accountID string, role domain.Role, assignmentID string) (*AssignmentStats, error) {
+	assignment, questions, err := s.GetAssignment(ctx, assignmentID)
+	if err != nil {
+		return nil, err
+	}
+	if role != domain.RoleAdmin && assignment.TeacherID != accountID {
+		return nil, ErrSubmissionForbidden
+	}
+
+	if cached, ok := s.statsCache.get(assignmentID); ok {
+		return cached, nil
+	}
+
+	stats, err := s.computeAssignmentStats(ctx, assignment, questions)
+	if err != nil {
+		return nil, err
+	}
+	s.statsCache.put(stats)
+	return stats, nil
+}
+
+func (s *AssignmentService) computeAssignmentStats(ctx context.Context, assignment *domain.Assignment, questions []domain.AssignmentQuestion) (*AssignmentStats, error) {
+	classSize, err := s.students.CountByClass(ctx, assignment.ClassID)
+	if err != nil {
+		return nil, err
+	}
+	summary, err := s.stats.ScoreSummary(ctx, assignment.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &AssignmentStats{
+		AssignmentID: assignment.ID,
+		ClassSize:    classSize,
+		Submitted:    summary.Submitted,
+		Graded:       summary.Graded,
+		Mean:         summary.Mean,
+		Min:          summary.Min,
+		Max:          summary.Max,
+		ComputedAt:   time.Now(),
+	}
+	if classSize > 0 {
+		stats.SubmissionRate = float64(summary.Submitted) / float64(classSize)
+	}
+
+	totals, err := s.stats.ListGradedTotals(ctx, assignment.ID)
+	if err != nil {
+		return nil, err
+	}
+	stats.Histogram = buildHistogram(assignment.MaxScore, totals)
+	stats.Median, stats.StdDev = medianAndStdDev(totals, summary.Mean)
+
+	aggregates, err := s.stats.QuestionAggregates(ctx, assignment.ID)
+	if err != nil {
+		return nil, err
+	}
+	itemScores, err := s.stats.ListGradedItemScores(ctx, assignment.ID)
+	if err != nil {
+		return nil, err
+	}
+	frequencies, err := s.stats.ChoiceFrequencies(ctx, assignment.ID)
+	if err != nil {
+		return nil, err
+	}
+	stats.Questions = buildQuestionStats(questions, aggregates, itemScores, frequencies, totals)
+
+	return stats, nil
+}
+
+// buildHistogram counts graded totals into equal-width buckets of the max
+// score. Bucketing happens here rather than in SQL because integer casts
+// round on Postgres but truncate on SQLite.
+func buildHistogram(maxScore float64, totals []repository.SubmissionTotal) []HistogramBucket {
+	if maxScore <= 0 {
+		return nil
+	}
+	width := maxScore / statsHistogramBuckets
+	histogram := make([]HistogramBucket, statsHistogramBuckets)
+	for i := range histogram {
+		histogram[i] = HistogramBucket{Lower: width * float64(i), Upper: width * float64(i+1)}
+	}
+	for _, total := range totals {
+		idx := int(math.Floor(total.Score / width))
+		if idx >= statsHistogramBuckets {
+			idx = statsHistogramBuckets - 1
+		}
+		if idx < 0 {
+			idx = 0
+		}
+		histogram[idx].Count++
+	}
+	return histogram
+}
+
+// medianAndStdDev expects totals sorted ascending by score.
+func medianAndStdDev(totals []repository.SubmissionTotal, mean *float64) (*float64, *float64) {
+	n := len(totals)
+	if n == 0 || mean == nil {
+		return nil, nil
+	}
+
+	var median float64
+	if n%2 == 1 {
+		median = totals[n/2].Score
+	} else {
+		median = (totals[n/2-1].Score + totals[n/2].Score) / 2
+	}
+
+	var sumSq float64
+	for _, t := range totals {
+		d := t.Score - *mean
+		sumSq += d * d
+	}
+	stddev := math.Sqrt(sumSq / float64(n))
+	return &median, &stddev
+}
+
+func buildQuestionStats(questions []domain.AssignmentQuestion, aggregates []repository.QuestionAggregate, itemScores []repository.ItemScore, frequencies []repository.AnswerFrequency, totals []repository.SubmissionTotal) []QuestionStats {
+	aggregateByQuestion := make(map[string]repository.QuestionAggregate, len(aggregates))
+	for _, agg := range aggregates {
+		aggregateByQuestion[agg.QuestionID] = agg
+	}
+
+	optionsByQuestion := make(map[string]map[string]int64)
+	for _, f := range frequencies {
+		counts := optionsByQuestion[f.QuestionID]
+		if counts == nil {
+			counts = make(map[string]int64)
+			optionsByQuestion[f.QuestionID] = counts
+		}
+		counts[f.Answer] = f.Count
+	}
+
+	upper, lower := splitDiscriminationGroups(totals)
+	scoreBySubmissionQuestion := make(map[string]float64, len(itemScores))
+	for _, item := range itemScores {
+		scoreBySubmissionQuestion[item.SubmissionID+"/"+item.QuestionID] = item.Score
+	}
+
+	result := make([]QuestionStats, 0, len(questions))
+	for _, q := range questions {
+		qs := QuestionStats{Question: q, OptionCounts: optionsByQuestion[q.ID]}
+		if agg, ok := aggregateByQuestion[q.ID]; ok {
+			qs.Graded = agg.Graded
+			qs.AvgScore = agg.AvgScore
+		}
+		if q.Score > 0 {
+			if qs.AvgScore != nil {
+				difficulty := *qs.AvgScore / q.Score
+				qs.Difficulty = &difficulty
+			}
+			if len(upper) > 0 && len(lower) > 0 {
+				upperAvg := groupAverage(upper, q.ID, scoreBySubmissionQuestion)
+				lowerAvg := groupAverage(lower, q.ID, scoreBySubmissionQuestion)
+				discrimination := (upperAvg - lowerAvg) / q.Score
+				qs.Discrimination = &discrimination
+			}
+		}
+		result = append(result, qs)
+	}
+	return result
+}
+
+// splitDiscriminationGroups returns the top and bottom 27% of submissions by
+// total score. Fewer than two graded submissions yields no groups.
+func splitDiscriminationGroups(totals []repository.SubmissionTotal) ([]string, []string) {
+	n := len(totals)
+	if n < 2 {
+		return nil, nil
+	}
+	sorted := make([]repository.SubmissionTotal, n)
+	copy(sorted, totals)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Score < sorted[j].Score })
+
+	size := int(math.Round(float64(n) * statsGroupFraction))
+	if size < 1 {
+		size = 1
+	}
+	if size > n/2 {
+		size = n / 2
+	}
+
+	lower := make([]string, 0, size)
+	upper := make([]string, 0, size)
+	for i := 0; i < size; i++ {
+		lower = append(lower, sorted[i].SubmissionID)
+		upper = append(upper, sorted[n-1-i].SubmissionID)
+	}
+	return upper, lower
+}
+
+// groupAverage treats ungraded items as zero so that skipped questions count against the group.
+func groupAverage(submissionIDs []string, questionID string, scores map[string]float64) float64 {
+	var sum float64
+	for _, id := range submissionIDs {
+		sum += scores[id+"/"+questionID]
+	}
+	return sum / float64(len(submissionIDs))
+}
@@ -0,0 +1,301 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"learn-go/internal/domain"
+	"learn-go/pkg/oss"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// defaultMaxAttachmentBytes applies when an assignment sets no size limit.
+	defaultMaxAttachmentBytes int64 = 20 << 20
+	// defaultAllowedAttachmentTypes covers photos of handwritten work and PDFs.
+	defaultAllowedAttachmentTypes = "image/jpeg,image/png,image/gif,image/webp,image/heic,application/pdf"
+	// attachmentUploadTTL bounds how long a presigned upload URL stays valid.
+	attachmentUploadTTL = 15 * time.Minute
+	// attachmentDownloadTTL bounds how long a presigned download URL stays valid.
+	attachmentDownloadTTL = 10 * time.Minute
+)
+
+var (
+	// ErrAttachmentTooLarge indicates a file exceeds the assignment's size limit.
+	ErrAttachmentTooLarge = errors.New("attachment too large")
+	// ErrAttachmentTypeNotAllowed indicates a file type the assignment does not accept.
+	ErrAttachmentTypeNotAllowed = errors.New("attachment type not allowed")
+	// ErrInvalidAttachment indicates an attachment that is unknown or belongs elsewhere.
+	ErrInvalidAttachment = errors.New("invalid attachment")
+	// ErrAttachmentNotFound indicates the attachment does not exist or is not visible to the caller.
+	ErrAttachmentNotFound = errors.New("attachment not found")
+)
+
+// AttachmentUploadInput describes a file the client wants to upload.
+type AttachmentUploadInput struct {
+	AssignmentID string
+	FileName     string
+	ContentType  string
+	Size         int64
+}
+
+// AttachmentUpload is a pending attachment plus the credentials to upload it.
+type AttachmentUpload struct {
+	Attachment  domain.Attachment
+	Credentials *oss.UploadCredentials
+}
+
+// CreateAttachmentUpload registers a pending attachment and issues upload
+// credentials for it. Students upload files for their own submission items;
+// the assignment's teacher (or an admin) uploads files for review comments.
+func (s *AssignmentService) CreateAttachmentUpload(ctx context.Context, accountID string, role domain.Role, input AttachmentUploadInput) (*AttachmentUpload, error) {
+	if accountID == "" || input.AssignmentID == "" {
+		return nil, errors.New("account and assignment required")
+	}
+	fileName := path.Base(strings.ReplaceAll(strings.TrimSpace(input.FileName), "\\", "/"))
+	if fileName == "" || fileName == "." || fileName == "/" {
+		return nil, fmt.Errorf("%w: file name required", ErrInvalidAttachment)
+	}
+
+	assignment, _, err := s.GetAssignment(ctx, input.AssignmentID)
+	if err != nil {
+		return nil, err
+	}
+
+	target := domain.AttachmentTargetSubmissionItem
+	if role == domain.RoleStudent {
+		if assignment.Status != domain.AssignmentPublished {
+			return nil, ErrAssignmentNotOpen
+		}
+		student, err := s.students.GetByAccountID(ctx, accountID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrSubmissionForbidden
+			}
+			return nil, err
+		}
+		if student.ClassID != assignment.ClassID {
+			return nil, ErrSubmissionForbidden
+		}
+	} else {
+		if role != domain.RoleAdmin && assignment.TeacherID != accountID {
+			return nil, ErrSubmissionForbidden
+		}
+		target = domain.AttachmentTargetSubmissionComment
+	}
+
+	contentType := strings.ToLower(strings.TrimSpace(input.ContentType))
+	if err := checkAttachmentLimits(assignment, contentType, input.Size); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	id := uuid.NewString()
+	attachment := &domain.Attachment{
+		ID:           id,
+		OwnerID:      accountID,
+		AssignmentID: assignment.ID,
+		TargetType:   target,
+		ObjectKey:    fmt.Sprintf("assignments/%s/%s/%s", assignment.ID, id, fileName),
+		FileName:     fileName,
+		ContentType:  contentType,
+		Size:         input.Size,
+		Status:       domain.AttachmentPending,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+
+	credentials, err := s.storage.PresignUpload(ctx, attachment.ObjectKey, contentType, attachment.Size, attachmentUploadTTL)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.attachments.Create(ctx, attachment); err != nil {
+		return nil, err
+	}
+	return &AttachmentUpload{Attachment: *attachment, Credentials: credentials}, nil
+}
+
+func checkAttachmentLimits(assignment *domain.Assignment, contentType string, size int64) error {
+	maxBytes := assignment.MaxAttachmentBytes
+	if maxBytes <= 0 {
+		maxBytes = defaultMaxAttachmentBytes
+	}
+	if size <= 0 {
+		return fmt.Errorf("%w: size required", ErrInvalidAttachment)
+	}
+	if size > maxBytes {
+		return fmt.Errorf("%w: %d bytes exceeds %d", ErrAttachmentTooLarge, size, maxBytes)
+	}
+
+	allowed := assignment.AllowedAttachmentTypes
+	if strings.TrimSpace(allowed) == "" {
+		allowed = defaultAllowedAttachmentTypes
+	}
+	if !attachmentTypeAllowed(allowed, contentType) {
+		return fmt.Errorf("%w: %s", ErrAttachmentTypeNotAllowed, contentType)
+	}
+	return nil
+}
+
+// attachmentTypeAllowed matches a MIME type against a comma-separated list
+// that may contain wildcards such as image/*.
+func attachmentTypeAllowed(allowed, contentType string) bool {
+	if contentType == "" {
+		return false
+	}
+	for _, pattern := range strings.Split(allowed, ",") {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		switch {
+		case pattern == "":
+		case pattern == "*/*" || pattern == contentType:
+			return true
+		case strings.HasSuffix(pattern, "/*") && strings.HasPrefix(contentType, strings.TrimSuffix(pattern, "*")):
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeAttachmentTypes cleans a list of MIME types for storage on an assignment.
+func normalizeAttachmentTypes(types []string) string {
+	cleaned := make([]string, 0, len(types))
+	for _, t := range types {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t != "" {
+			cleaned = append(cleaned, t)
+		}
+	}
+	return strings.Join(cleaned, ",")
+}
+
+// checkAttachments validates attachment IDs before anything is written: each
+// must belong to the caller and this assignment, and pending uploads must have
+// actually reached storage within the assignment's size limit. Attachments may
+// be rebound, so a resubmission can keep its files.
+func (s *AssignmentService) checkAttachments(ctx context.Context, ownerID string, assignment *domain.Assignment, target domain.AttachmentTarget, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	attachments, err := s.attachments.ListByIDs(ctx, ids)
+	if err != nil {
+		return err
+	}
+	if len(attachments) != len(uniqueStrings(ids)) {
+		return fmt.Errorf("%w: unknown attachment id", ErrInvalidAttachment)
+	}
+	for _, attachment := range attachments {
+		if attachment.OwnerID != ownerID || attachment.AssignmentID != assignment.ID || attachment.TargetType != target {
+			return fmt.Errorf("%w: %s cannot be attached here", ErrInvalidAttachment, attachment.ID)
+		}
+		if attachment.Status != domain.AttachmentPending {
+			continue
+		}
+		info, err := s.storage.Head(ctx, attachment.ObjectKey)
+		if err != nil {
+			if errors.Is(err, oss.ErrObjectNotFound) {
+				return fmt.Errorf("%w: %s has not been uploaded", ErrInvalidAttachment, attachment.ID)
+			}
+			return err
+		}
+		if err := checkAttachmentLimits(assignment, attachment.ContentType, info.Size); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AttachmentDownloadURL returns a short-lived download URL. Uploaders, the
+// assignment's teacher and admins can always download; students can also
+// download teacher files attached to comments on their own submission.
+func (s *AssignmentService) AttachmentDownloadURL(ctx context.Context, accountID string, role domain.Role, assignmentID, attachmentID string) (string, error) {
+	if accountID == "" || assignmentID == "" || attachmentID == "" {
+		return "", errors.New("account, assignment and attachment required")
+	}
+	attachment, err := s.attachments.GetByID(ctx, attachmentID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", ErrAttachmentNotFound
+		}
+		return "", err
+	}
+	if attachment.AssignmentID != assignmentID {
+		return "", ErrAttachmentNotFound
+	}
+
+	allowed, err := s.canDownloadAttachment(ctx, accountID, role, attachment)
+	if err != nil {
+		return "", err
+	}
+	if !allowed {
+		return "", ErrAttachmentNotFound
+	}
+	return s.storage.PresignDownload(ctx, attachment.ObjectKey, attachmentDownloadTTL)
+}
+
+func (s *AssignmentService) canDownloadAttachment(ctx context.Context, accountID string, role domain.Role, attachment *domain.Attachment) (bool, error) {
+	if role == domain.RoleAdmin || attachment.OwnerID == accountID {
+		return true, nil
+	}
+	assignment, _, err := s.GetAssignment(ctx, attachment.AssignmentID)
+	if err != nil {
+		return false, err
+	}
+	if assignment.TeacherID == accountID {
+		return true, nil
+	}
+	if attachment.TargetType != domain.AttachmentTargetSubmissionComment || attachment.TargetID == "" {
+		return false, nil
+	}
+	comment, err := s.comments.GetByID(ctx, attachment.TargetID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	submission, _, err := s.submissions.GetByID(ctx, comment.SubmissionID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return submission.StudentID == accountID, nil
+}
+
+// loadSubmissionAttachments fills in the files attached to a submission's items and comments.
+func (s *AssignmentService) loadSubmissionAttachments(ctx context.Context, detail *SubmissionDetail, comments []domain.SubmissionComment) error {
+	targetIDs := make([]string, 0, len(detail.Items)+len(comments))
+	for _, item := range detail.Items {
+		targetIDs = append(targetIDs, item.ID)
+	}
+	for _, comment := range comments {
+		targetIDs = append(targetIDs, comment.ID)
+	}
+	attachments, err := s.attachments.ListByTargets(ctx, targetIDs)
+	if err != nil {
+		return err
+	}
+	detail.Attachments = attachments
+	return nil
+}
+
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]struct{}, len(values))
+	result := make([]string, 0, len(values))
+	for _, v := range values {
+		if _, ok := seen[v]; ok {
+			continue
+		}
+		seen[v] = struct{}{}
+		result = append(result, v)
+	}
+	return result
+}
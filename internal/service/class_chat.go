@@ -0,0 +1,324 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"learn-go/internal/domain"
+	"learn-go/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrClassNotFound indicates the class does not exist.
+	ErrClassNotFound = errors.New("class not found")
+	// ErrCourseNotFound indicates the course does not exist.
+	ErrCourseNotFound = errors.New("course not found")
+)
+
+// ClassChatService keeps class and course group chats in step with the
+// roster: class students are members, the homeroom teacher owns the class
+// chat and teachers bound to its students are admins. A course chat holds the
+// class students with the teachers scheduled for that course as admins.
+type ClassChatService struct {
+	chats        *ConversationService
+	classes      repository.ClassRepository
+	students     repository.StudentRepository
+	teachers     repository.TeacherRepository
+	teacherLinks repository.TeacherStudentRepository
+	courses      repository.CourseRepository
+	onChange     func(*GroupChange)
+}
+
+// NewClassChatService constructs a ClassChatService.
+func NewClassChatService(chats *ConversationService, classes repository.ClassRepository, students repository.StudentRepository, teachers repository.TeacherRepository, links repository.TeacherStudentRepository, courses repository.CourseRepository) *ClassChatService {
+	return &ClassChatService{
+		chats:        chats,
+		classes:      classes,
+		students:     students,
+		teachers:     teachers,
+		teacherLinks: links,
+		courses:      courses,
+	}
+}
+
+// OnChange registers a callback invoked for every chat whose membership a
+// sync changed, so connected clients can be told.
+func (s *ClassChatService) OnChange(fn func(*GroupChange)) {
+	s.onChange = fn
+}
+
+// SyncClass creates the class chat if needed and reconciles the members of
+// every managed chat of the class. It returns the chats that changed.
+func (s *ClassChatService) SyncClass(ctx context.Context, classID string) ([]GroupChange, error) {
+	class, roster, err := s.loadRoster(ctx, classID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.ensureChat(ctx, class, domain.ConversationClass, "", class.Name); err != nil {
+		return nil, err
+	}
+	convs, err := s.chats.conversations.ListManagedByClass(ctx, classID)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []GroupChange
+	for _, conv := range convs {
+		desired := roster.classMembers()
+		if conv.Type == domain.ConversationCourse {
+			if desired, err = s.courseMembers(ctx, roster, conv.CourseID); err != nil {
+				return nil, err
+			}
+		}
+		change, err := s.reconcile(ctx, conv, desired)
+		if err != nil {
+			return nil, err
+		}
+		if change != nil {
+			changes = append(changes, *change)
+		}
+	}
+	return changes, nil
+}
+
+// EnableCourseChat creates the course chat of a class, or returns the
+// existing one, with its members up to date.
+func (s *ClassChatService) EnableCourseChat(ctx context.Context, classID, courseID string) (*ConversationSummary, error) {
+	class, roster, err := s.loadRoster(ctx, classID)
+	if err != nil {
+		return nil, err
+	}
+	course, err := s.courses.GetByID(ctx, courseID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCourseNotFound
+		}
+		return nil, err
+	}
+	if course.SchoolID != class.SchoolID {
+		return nil, ErrCourseNotFound
+	}
+
+	conv, err := s.ensureChat(ctx, class, domain.ConversationCourse, course.ID, class.Name+" · "+course.Name)
+	if err != nil {
+		return nil, err
+	}
+	desired, err := s.courseMembers(ctx, roster, course.ID)
+	if err != nil {
+		return nil, err
+	}
+	change, err := s.reconcile(ctx, *conv, desired)
+	if err != nil {
+		return nil, err
+	}
+	if change != nil {
+		return change.Summary, nil
+	}
+	members, err := s.chats.conversations.GetMembers(ctx, conv.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &ConversationSummary{Conversation: *conv, Members: members, MemberCount: int64(len(members))}, nil
+}
+
+// classRoster maps the accounts of a class to their place in its chats.
+type classRoster struct {
+	classID    string
+	students   []string
+	teachers   []string
+	homeroomID string
+}
+
+func (r classRoster) classMembers() map[string]domain.GroupRole {
+	desired := make(map[string]domain.GroupRole, len(r.students)+len(r.teachers)+1)
+	for _, id := range r.students {
+		desired[id] = domain.GroupMember
+	}
+	for _, id := range r.teachers {
+		desired[id] = domain.GroupAdmin
+	}
+	if r.homeroomID != "" {
+		desired[r.homeroomID] = domain.GroupOwner
+	}
+	return desired
+}
+
+func (s *ClassChatService) courseMembers(ctx context.Context, roster classRoster, courseID string) (map[string]domain.GroupRole, error) {
+	teacherIDs, err := s.courses.ListTeacherIDs(ctx, roster.classID, courseID)
+	if err != nil {
+		return nil, err
+	}
+	ids, err := s.teacherAccounts(ctx, teacherIDs)
+	if err != nil {
+		return nil, err
+	}
+	desired := make(map[string]domain.GroupRole, len(roster.students)+len(ids))
+	for _, id := range roster.students {
+		desired[id] = domain.GroupMember
+	}
+	for _, id := range ids {
+		desired[id] = domain.GroupAdmin
+	}
+	return desired, nil
+}
+
+// loadRoster resolves the student and teacher accounts of a class.
+func (s *ClassChatService) loadRoster(ctx context.Context, classID string) (*domain.Class, classRoster, error) {
+	roster := classRoster{classID: classID}
+	class, err := s.classes.GetByID(ctx, classID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, roster, ErrClassNotFound
+		}
+		return nil, roster, err
+	}
+	students, err := s.students.ListByClass(ctx, classID)
+	if err != nil {
+		return nil, roster, err
+	}
+	studentIDs := make([]string, 0, len(students))
+	for _, student := range students {
+		studentIDs = append(studentIDs, student.ID)
+		roster.students = append(roster.students, student.AccountID)
+	}
+
+	teacherIDs, err := s.teacherLinks.ListTeacherIDs(ctx, studentIDs)
+	if err != nil {
+		return nil, roster, err
+	}
+	if class.HomeroomID != nil && *class.HomeroomID != "" {
+		teacherIDs = append(teacherIDs, *class.HomeroomID)
+	}
+	teachers, err := s.teachers.ListByIDs(ctx, uniqueStrings(teacherIDs))
+	if err != nil {
+		return nil, roster, err
+	}
+	for _, teacher := range teachers {
+		if class.HomeroomID != nil && teacher.ID == *class.HomeroomID {
+			roster.homeroomID = teacher.AccountID
+		}
+		roster.teachers = append(roster.teachers, teacher.AccountID)
+	}
+	return class, roster, nil
+}
+
+func (s *ClassChatService) teacherAccounts(ctx context.Context, teacherIDs []string) ([]string, error) {
+	teachers, err := s.teachers.ListByIDs(ctx, uniqueStrings(teacherIDs))
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(teachers))
+	for _, teacher := range teachers {
+		ids = append(ids, teacher.AccountID)
+	}
+	return ids, nil
+}
+
+// ensureChat returns the managed chat of the given type, creating it empty.
+func (s *ClassChatService) ensureChat(ctx context.Context, class *domain.Class, conversationType, courseID, name string) (*domain.Conversation, error) {
+	conv, err := s.chats.conversations.FindManaged(ctx, conversationType, class.ID, courseID)
+	if err == nil {
+		return conv, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	now := time.Now()
+	conv = &domain.Conversation{
+		ID:        uuid.NewString(),
+		Type:      conversationType,
+		Name:      name,
+		SchoolID:  class.SchoolID,
+		ClassID:   class.ID,
+		CourseID:  courseID,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	created, err := s.chats.conversations.CreateManaged(ctx, conv)
+	if err != nil {
+		return nil, err
+	}
+	if !created {
+		// A concurrent sync created it first.
+		return s.chats.conversations.FindManaged(ctx, conversationType, class.ID, courseID)
+	}
+	return conv, nil
+}
+
+// reconcile adds, removes and re-roles members so the chat matches desired.
+// It returns nil when nothing changed.
+func (s *ClassChatService) reconcile(ctx context.Context, conv domain.Conversation, desired map[string]domain.GroupRole) (*GroupChange, error) {
+	current, err := s.chats.conversations.GetMembers(ctx, conv.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(desired) > maxGroupMembers {
+		return nil, fmt.Errorf("%w: at most %d members", ErrConversationMemberLimit, maxGroupMembers)
+	}
+
+	existing := make(map[string]domain.GroupRole, len(current))
+	var removed []string
+	changed := false
+	for _, member := range current {
+		existing[member.AccountID] = member.GroupRole
+		role, keep := desired[member.AccountID]
+		switch {
+		case !keep:
+			if err := s.chats.dropMember(ctx, conv.ID, member.AccountID); err != nil {
+				return nil, err
+			}
+			removed = append(removed, member.AccountID)
+		case role != member.GroupRole:
+			if err := s.chats.conversations.UpdateMemberRole(ctx, conv.ID, member.AccountID, role); err != nil {
+				return nil, err
+			}
+			changed = true
+		}
+	}
+
+	var added []string
+	for id := range desired {
+		if _, ok := existing[id]; !ok {
+			added = append(added, id)
+		}
+	}
+	if len(added) > 0 {
+		accounts, err := s.chats.accounts.ListByIDs(ctx, added)
+		if err != nil {
+			return nil, err
+		}
+		now := time.Now()
+		members := make([]domain.ConversationMember, 0, len(accounts))
+		added = added[:0]
+		for _, account := range accounts {
+			members = append(members, newGroupMember(conv.ID, account, desired[account.ID], now))
+			added = append(added, account.ID)
+		}
+		ok, err := s.chats.conversations.AddMembers(ctx, members, maxGroupMembers)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fmt.Errorf("%w: at most %d members", ErrConversationMemberLimit, maxGroupMembers)
+		}
+	}
+	if !changed && len(added) == 0 && len(removed) == 0 {
+		return nil, nil
+	}
+
+	members, err := s.chats.conversations.GetMembers(ctx, conv.ID)
+	if err != nil {
+		return nil, err
+	}
+	change := &GroupChange{Summary: &ConversationSummary{Conversation: conv, Members: members, MemberCount: int64(len(members))}, Added: added, Removed: removed}
+	if s.onChange != nil {
+		s.onChange(change)
+	}
+	return change, nil
+}
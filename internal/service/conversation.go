@@ -3,6 +3,7 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
 	"sort"
 	"time"
 
@@ -10,6 +11,7 @@ import (
 
 	"learn-go/internal/domain"
 	"learn-go/internal/repository"
+	"learn-go/pkg/oss"
 
 	"gorm.io/gorm"
 )
@@ -21,23 +23,44 @@ var (
 	ErrConversationForbidden = errors.New("conversation forbidden")
 	// ErrConversationInvalid indicates invalid parameters when creating conversations.
 	ErrConversationInvalid = errors.New("invalid conversation request")
+	// ErrConversationMuted indicates only owners and admins may post in an announcement-only chat.
+	ErrConversationMuted = errors.New("conversation is announcement only")
 )
 
 // ConversationService handles IM related operations.
 type ConversationService struct {
 	conversations repository.ConversationRepository
 	messages      repository.MessageRepository
-	receipts      repository.MessageReceiptRepository
+	reactions     repository.MessageReactionRepository
+	mentions      repository.MessageMentionRepository
+	search        repository.MessageSearchRepository
 	accounts      repository.AccountRepository
+	media         repository.ChatMediaRepository
+	storage       oss.Client
+	policy        *MessagingPolicyService
+	moderation    *ModerationService
+	notifications *NotificationService
+	mediaJobs     chan string
+	// recallWindow is how long after sending a sender may recall a message.
+	recallWindow time.Duration
 }
 
 // NewConversationService constructs a ConversationService instance.
-func NewConversationService(conversations repository.ConversationRepository, messages repository.MessageRepository, receipts repository.MessageReceiptRepository, accounts repository.AccountRepository) *ConversationService {
+func NewConversationService(conversations repository.ConversationRepository, messages repository.MessageRepository, reactions repository.MessageReactionRepository, mentions repository.MessageMentionRepository, search repository.MessageSearchRepository, accounts repository.AccountRepository, media repository.ChatMediaRepository, storage oss.Client, recallWindow time.Duration, policy *MessagingPolicyService, moderation *ModerationService, notifications *NotificationService) *ConversationService {
 	return &ConversationService{
 		conversations: conversations,
 		messages:      messages,
-		receipts:      receipts,
+		reactions:     reactions,
+		mentions:      mentions,
+		search:        search,
 		accounts:      accounts,
+		media:         media,
+		storage:       storage,
+		policy:        policy,
+		moderation:    moderation,
+		notifications: notifications,
+		mediaJobs:     make(chan string, mediaJobQueueSize),
+		recallWindow:  recallWindow,
 	}
 }
 
@@ -47,6 +70,14 @@ type ConversationSummary struct {
 	Members      []domain.ConversationMember
 	LastMessage  *domain.Message
 	UnreadCount  int64
+	// MemberCount is the number of members. In the conversation list Members
+	// holds only the viewer and, for direct chats, the other participant.
+	MemberCount int64
+	// MentionCount is the number of unread messages mentioning the account.
+	MentionCount int64
+	// Member is the viewing account's membership with its own settings; nil
+	// for summaries shared with every member.
+	Member *domain.ConversationMember
 }
 
 // SendMessageInput describes payload for sending a message.
@@ -54,8 +85,14 @@ type SendMessageInput struct {
 	ConversationID string
 	Kind           string
 	Text           string
-	MediaURI       string
+	MediaID        string
 	Metadata       string
+	ReplyToID      string
+	// MentionIDs are accounts @mentioned in a group message; they must be members.
+	MentionIDs []string
+	// ClientMsgID makes the send idempotent: repeating it returns the message
+	// already stored instead of creating another.
+	ClientMsgID string
 }
 
 // CreateDirectConversation ensures a direct conversation exists between two accounts.
@@ -81,32 +118,23 @@ func (s *ConversationService) CreateDirectConversation(ctx context.Context, init
 
 	conv, err := s.conversations.FindDirectBetween(ctx, initiator.SchoolID, ids)
 	if err == nil {
-		members, err := s.conversations.GetMembers(ctx, conv.ID)
+		summaries, err := s.summarize(ctx, initiatorID, []domain.Conversation{*conv})
 		if err != nil {
 			return nil, err
 		}
-		var last *domain.Message
-		lastMsg, err := s.messages.GetLastByConversation(ctx, conv.ID)
-		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, err
-		}
-		if err == nil {
-			last = lastMsg
-		}
-		unread, err := s.receipts.CountUnread(ctx, initiatorID, conv.ID)
-		if err != nil {
-			return nil, err
-		}
-		return &ConversationSummary{Conversation: *conv, Members: members, LastMessage: last, UnreadCount: unread}, nil
+		return &summaries[0], nil
 	}
 	if !errors.Is(err, gorm.ErrRecordNotFound) {
 		return nil, err
 	}
+	if err := s.policy.CheckContacts(ctx, *initiator, []domain.Account{*participant}); err != nil {
+		return nil, err
+	}
 
 	now := time.Now()
 	conv = &domain.Conversation{
 		ID:        uuid.NewString(),
-		Type:      "direct",
+		Type:      domain.ConversationDirect,
 		SchoolID:  initiator.SchoolID,
 		CreatedAt: now,
 		UpdatedAt: now,
@@ -117,6 +145,7 @@ func (s *ConversationService) CreateDirectConversation(ctx context.Context, init
 			ConversationID: conv.ID,
 			AccountID:      initiator.ID,
 			Role:           initiator.Role,
+			GroupRole:      domain.GroupMember,
 			CreatedAt:      now,
 		},
 		{
@@ -124,6 +153,7 @@ func (s *ConversationService) CreateDirectConversation(ctx context.Context, init
 			ConversationID: conv.ID,
 			AccountID:      participant.ID,
 			Role:           participant.Role,
+			GroupRole:      domain.GroupMember,
 			CreatedAt:      now,
 		},
 	}
@@ -132,105 +162,278 @@ func (s *ConversationService) CreateDirectConversation(ctx context.Context, init
 		return nil, err
 	}
 
-	return &ConversationSummary{Conversation: *conv, Members: members, UnreadCount: 0}, nil
+	return &ConversationSummary{Conversation: *conv, Members: members, MemberCount: int64(len(members)), Member: &members[0]}, nil
 }
 
-// ListConversations returns conversations for an account.
-func (s *ConversationService) ListConversations(ctx context.Context, accountID string) ([]ConversationSummary, error) {
+// ConversationIDs lists the conversations the account is a member of.
+func (s *ConversationService) ConversationIDs(ctx context.Context, accountID string) ([]string, error) {
 	convs, err := s.conversations.ListByAccount(ctx, accountID)
 	if err != nil {
 		return nil, err
 	}
-
-	summaries := make([]ConversationSummary, 0, len(convs))
+	ids := make([]string, 0, len(convs))
 	for _, conv := range convs {
-		members, err := s.conversations.GetMembers(ctx, conv.ID)
-		if err != nil {
-			return nil, err
-		}
-		var last *domain.Message
-		lastMsg, err := s.messages.GetLastByConversation(ctx, conv.ID)
-		if err != nil {
-			if !errors.Is(err, gorm.ErrRecordNotFound) {
-				return nil, err
-			}
-		} else {
-			last = lastMsg
-		}
-		unread, err := s.receipts.CountUnread(ctx, accountID, conv.ID)
-		if err != nil {
-			return nil, err
-		}
-		summaries = append(summaries, ConversationSummary{
-			Conversation: conv,
-			Members:      members,
-			LastMessage:  last,
-			UnreadCount:  unread,
-		})
+		ids = append(ids, conv.ID)
 	}
-	return summaries, nil
+	return ids, nil
 }
 
-// SendMessage stores a message in a conversation.
-func (s *ConversationService) SendMessage(ctx context.Context, senderID string, input SendMessageInput) (*domain.Message, error) {
-	ok, err := s.conversations.IsMember(ctx, input.ConversationID, senderID)
+// MemberIDs lists the accounts in a conversation.
+func (s *ConversationService) MemberIDs(ctx context.Context, conversationID string) ([]string, error) {
+	members, err := s.conversations.GetMembers(ctx, conversationID)
 	if err != nil {
 		return nil, err
 	}
-	if !ok {
-		return nil, ErrConversationForbidden
+	ids := make([]string, 0, len(members))
+	for _, member := range members {
+		ids = append(ids, member.AccountID)
+	}
+	return ids, nil
+}
+
+// UnreadCounts returns the account's unread message and mention counts in a
+// conversation.
+func (s *ConversationService) UnreadCounts(ctx context.Context, accountID, conversationID string) (unread, mentions int64, err error) {
+	ids := []string{conversationID}
+	unreadCounts, err := s.conversations.CountUnread(ctx, accountID, ids)
+	if err != nil {
+		return 0, 0, err
+	}
+	mentionCounts, err := s.mentions.CountUnread(ctx, accountID, ids)
+	if err != nil {
+		return 0, 0, err
+	}
+	return unreadCounts[conversationID], mentionCounts[conversationID], nil
+}
+
+// SendMessage stores a message in a conversation. created is false when a
+// send with the same ClientMsgID was already stored; that message is returned.
+func (s *ConversationService) SendMessage(ctx context.Context, senderID string, input SendMessageInput) (msg *domain.Message, created bool, err error) {
+	member, err := s.conversations.GetMember(ctx, input.ConversationID, senderID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, false, ErrConversationForbidden
+		}
+		return nil, false, err
+	}
+	if input.ClientMsgID != "" {
+		if len(input.ClientMsgID) > maxClientMsgIDLength {
+			return nil, false, fmt.Errorf("%w: client_msg_id longer than %d characters", ErrConversationInvalid, maxClientMsgIDLength)
+		}
+		if existing, err := s.findClientMessage(ctx, senderID, input); existing != nil || err != nil {
+			return existing, false, err
+		}
+	}
+	conv, err := s.conversations.GetByID(ctx, input.ConversationID)
+	if err != nil {
+		return nil, false, err
+	}
+	if conv.AnnouncementOnly && !canManageGroup(member.GroupRole) {
+		return nil, false, ErrConversationMuted
 	}
 
 	sender, err := s.accounts.FindByID(ctx, senderID)
 	if err != nil {
-		return nil, err
+		return nil, false, err
+	}
+	if conv.Type == domain.ConversationDirect {
+		if err := s.checkDirectMessage(ctx, conv.ID, *sender); err != nil {
+			return nil, false, err
+		}
+	}
+
+	if input.ReplyToID != "" {
+		parent, err := s.messages.GetByID(ctx, input.ReplyToID)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, false, err
+		}
+		if parent == nil || parent.ConversationID != input.ConversationID {
+			return nil, false, fmt.Errorf("%w: reply target not found", ErrConversationInvalid)
+		}
+	}
+	mentioned, err := s.resolveMentions(ctx, conv, senderID, input.MentionIDs)
+	if err != nil {
+		return nil, false, err
+	}
+	screening, err := s.moderation.Screen(ctx, conv.SchoolID, input.Text)
+	if err != nil {
+		return nil, false, err
 	}
 
 	now := time.Now()
-	msg := &domain.Message{
+	msg = &domain.Message{
 		ID:             uuid.NewString(),
 		ConversationID: input.ConversationID,
 		SenderID:       sender.ID,
 		SenderRole:     sender.Role,
 		Kind:           input.Kind,
-		Text:           input.Text,
-		MediaURI:       input.MediaURI,
+		Text:           screening.Text,
 		Metadata:       input.Metadata,
+		ReplyToID:      input.ReplyToID,
 		CreatedAt:      now,
 	}
+	if input.ClientMsgID != "" {
+		clientMsgID := input.ClientMsgID
+		msg.ClientMsgID = &clientMsgID
+	}
 
-	if err := s.messages.Create(ctx, msg); err != nil {
-		return nil, err
+	var media *domain.ChatMedia
+	if input.Kind != "text" {
+		media, err = s.attachMedia(ctx, senderID, input, msg)
+		if err != nil {
+			return nil, false, err
+		}
+	}
+
+	if err := s.storeMessage(ctx, msg, media); err != nil {
+		if input.ClientMsgID != "" {
+			// A concurrent retry may have stored it first.
+			if existing, findErr := s.findClientMessage(ctx, senderID, input); existing != nil {
+				return existing, false, findErr
+			}
+		}
+		return nil, false, err
+	}
+	if media != nil {
+		s.enqueueMedia(media.ID)
 	}
 	if err := s.conversations.UpdateTimestamp(ctx, input.ConversationID, now); err != nil {
-		return nil, err
+		return nil, false, err
+	}
+
+	// Sending implies the sender has read the conversation so far.
+	if err := s.conversations.MarkRead(ctx, input.ConversationID, senderID, msg.Seq, now); err != nil {
+		return nil, false, err
+	}
+
+	mentions := make([]domain.MessageMention, 0, len(mentioned))
+	for _, accountID := range mentioned {
+		mentions = append(mentions, domain.MessageMention{
+			ID:             uuid.NewString(),
+			MessageID:      msg.ID,
+			ConversationID: msg.ConversationID,
+			AccountID:      accountID,
+			CreatedAt:      now,
+		})
+	}
+	if err := s.mentions.CreateBatch(ctx, mentions); err != nil {
+		return nil, false, err
+	}
+	if err := s.moderation.Flag(ctx, messageCase(conv, msg), screening.Flags); err != nil {
+		return nil, false, err
 	}
+	s.notifications.logFailure(s.notifyMessage(ctx, conv, sender, msg, mentioned))
 
-	members, err := s.conversations.GetMembers(ctx, input.ConversationID)
+	return msg, true, nil
+}
+
+// storeMessage inserts msg, binding its uploaded media in the same transaction
+// so an upload is never marked sent without its message.
+func (s *ConversationService) storeMessage(ctx context.Context, msg *domain.Message, media *domain.ChatMedia) error {
+	if media == nil {
+		return s.messages.Create(ctx, msg)
+	}
+	bound, err := s.messages.CreateWithMedia(ctx, msg, media)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	if !bound {
+		return fmt.Errorf("%w: media already sent", ErrInvalidMedia)
+	}
+	return nil
+}
+
+// notifyMessage adds the message to the inbox of the other members, merged
+// into one notification per conversation. Members who muted the
+// conversation are skipped unless the message mentions them.
+func (s *ConversationService) notifyMessage(ctx context.Context, conv *domain.Conversation, sender *domain.Account, msg *domain.Message, mentioned []string) error {
+	// Sending implies the sender has caught up with their own notification too.
+	if err := s.notifications.ReadConversation(ctx, sender.ID, conv.ID); err != nil {
+		return err
 	}
 
-	receipts := make([]domain.MessageReceipt, 0, len(members))
+	members, err := s.conversations.GetMembers(ctx, conv.ID)
+	if err != nil {
+		return err
+	}
+	isMentioned := make(map[string]bool, len(mentioned))
+	for _, id := range mentioned {
+		isMentioned[id] = true
+	}
+	now := time.Now()
+	recipients := make([]string, 0, len(members))
 	for _, member := range members {
-		if member.AccountID == senderID {
+		if member.MutedUntil != nil && member.MutedUntil.After(now) && !isMentioned[member.AccountID] {
 			continue
 		}
-		receipts = append(receipts, domain.MessageReceipt{
-			ID:        uuid.NewString(),
-			MessageID: msg.ID,
-			AccountID: member.AccountID,
-			CreatedAt: now,
-		})
+		recipients = append(recipients, member.AccountID)
+	}
+
+	body := msg.Text
+	if msg.Kind != "text" {
+		body = "[" + msg.Kind + "]"
+	}
+	title := sender.DisplayName
+	if conv.Type != domain.ConversationDirect {
+		title = conv.Name
+		body = sender.DisplayName + ": " + body
+	}
+	return s.notifications.Notify(ctx, recipients, NotificationInput{
+		Category:    domain.NotificationMessage,
+		Title:       title,
+		Body:        body,
+		SubjectType: NotificationSubjectConversation,
+		SubjectID:   conv.ID,
+		ActorID:     sender.ID,
+		GroupKey:    conversationGroupKey(conv.ID),
+	})
+}
+
+// messageCase names a message for the moderation queue.
+func messageCase(conv *domain.Conversation, msg *domain.Message) domain.ModerationCase {
+	return domain.ModerationCase{
+		SchoolID:   conv.SchoolID,
+		TargetType: domain.ModerationTargetMessage,
+		TargetID:   msg.ID,
+		ParentID:   conv.ID,
+		AuthorID:   msg.SenderID,
 	}
+}
 
-	if len(receipts) > 0 {
-		if err := s.receipts.CreateBatch(ctx, receipts); err != nil {
-			return nil, err
+// findClientMessage returns the message the sender already stored under the
+// input's ClientMsgID, or nil if there is none.
+func (s *ConversationService) findClientMessage(ctx context.Context, senderID string, input SendMessageInput) (*domain.Message, error) {
+	existing, err := s.messages.GetByClientMsgID(ctx, senderID, input.ClientMsgID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
 		}
+		return nil, err
+	}
+	if existing.ConversationID != input.ConversationID {
+		return nil, fmt.Errorf("%w: client_msg_id already used in another conversation", ErrConversationInvalid)
 	}
+	return existing, nil
+}
 
-	return msg, nil
+// checkDirectMessage applies the messaging policy to a message from sender
+// to the other member of a direct chat.
+func (s *ConversationService) checkDirectMessage(ctx context.Context, conversationID string, sender domain.Account) error {
+	members, err := s.conversations.GetMembers(ctx, conversationID)
+	if err != nil {
+		return err
+	}
+	for _, member := range members {
+		if member.AccountID == sender.ID {
+			continue
+		}
+		recipient, err := s.accounts.FindByID(ctx, member.AccountID)
+		if err != nil {
+			return err
+		}
+		return s.policy.CheckDirectMessage(ctx, sender, *recipient, time.Now())
+	}
+	return nil
 }
 
 // ListMessages returns messages in a conversation respecting membership.
@@ -243,7 +446,7 @@ func (s *ConversationService) ListMessages(ctx context.Context, accountID, conve
 		return nil, ErrConversationForbidden
 	}
 
-	messages, err := s.messages.ListByConversation(ctx, conversationID, limit, beforeID)
+	messages, err := s.messages.ListByConversation(ctx, conversationID, accountID, limit, beforeID)
 	if err != nil {
 		return nil, err
 	}
@@ -274,59 +477,23 @@ func (s *ConversationService) GetConversationSummary(ctx context.Context, accoun
 		return nil, err
 	}
 
-	members, err := s.conversations.GetMembers(ctx, conversationID)
+	summaries, err := s.summarize(ctx, accountID, []domain.Conversation{*conv})
 	if err != nil {
 		return nil, err
 	}
-
-	var last *domain.Message
-	lastMsg, err := s.messages.GetLastByConversation(ctx, conversationID)
-	if err != nil {
-		if !errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, err
-		}
-	} else {
-		last = lastMsg
-	}
-
-	unread, err := s.receipts.CountUnread(ctx, accountID, conversationID)
+	summary := &summaries[0]
+	members, err := s.conversations.GetMembers(ctx, conversationID)
 	if err != nil {
 		return nil, err
 	}
-
-	return &ConversationSummary{
-		Conversation: *conv,
-		Members:      members,
-		LastMessage:  last,
-		UnreadCount:  unread,
-	}, nil
-}
-
-// MarkRead marks messages as read up to the provided message.
-func (s *ConversationService) MarkRead(ctx context.Context, accountID, conversationID, messageID string) error {
-	ok, err := s.conversations.IsMember(ctx, conversationID, accountID)
-	if err != nil {
-		return err
-	}
-	if !ok {
-		return ErrConversationForbidden
-	}
-
-	msg, err := s.messages.GetByID(ctx, messageID)
-	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return ErrConversationNotFound
+	summary.Members = members
+	summary.Member = nil
+	for i := range members {
+		if members[i].AccountID == accountID {
+			summary.Member = &members[i]
 		}
-		return err
-	}
-	if msg.ConversationID != conversationID {
-		return ErrConversationNotFound
 	}
-
-	if err := s.receipts.MarkReadUpTo(ctx, accountID, conversationID, msg.CreatedAt); err != nil {
-		return err
-	}
-	return nil
+	return summary, nil
 }
 
 // IsMember returns whether the account participates in the conversation.
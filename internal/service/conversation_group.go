@@ -0,0 +1,499 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	"learn-go/internal/domain"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// maxGroupMembers caps group size, owner included.
+	maxGroupMembers = 500
+	// maxGroupNameLength bounds group names in characters.
+	maxGroupNameLength = 64
+	// MessageKindSystem marks messages the server records for membership changes.
+	MessageKindSystem = "system"
+)
+
+// ErrConversationMemberLimit indicates the group would exceed maxGroupMembers.
+var ErrConversationMemberLimit = errors.New("conversation member limit reached")
+
+// errGroupChanged reports an ownership change that lost a race with another
+// membership change.
+var errGroupChanged = fmt.Errorf("%w: group membership changed, try again", ErrConversationInvalid)
+
+// GroupChange is the outcome of a group operation: the updated conversation
+// and the system message recorded in its history, if any.
+type GroupChange struct {
+	Summary *ConversationSummary
+	Message *domain.Message
+	// Added lists accounts that just became members.
+	Added []string
+	// Removed lists accounts that are no longer members.
+	Removed []string
+}
+
+// CreateGroupConversation creates a named group owned by the creator.
+func (s *ConversationService) CreateGroupConversation(ctx context.Context, creatorID, name string, memberIDs []string) (*GroupChange, error) {
+	name, err := cleanGroupName(name)
+	if err != nil {
+		return nil, err
+	}
+	creator, err := s.accounts.FindByID(ctx, creatorID)
+	if err != nil {
+		return nil, err
+	}
+
+	others := make([]string, 0, len(memberIDs))
+	for _, id := range uniqueStrings(memberIDs) {
+		if id != "" && id != creatorID {
+			others = append(others, id)
+		}
+	}
+	if len(others) == 0 {
+		return nil, fmt.Errorf("%w: a group needs at least one other member", ErrConversationInvalid)
+	}
+	if len(others)+1 > maxGroupMembers {
+		return nil, fmt.Errorf("%w: at most %d members", ErrConversationMemberLimit, maxGroupMembers)
+	}
+	accounts, err := s.loadSchoolAccounts(ctx, creator.SchoolID, others)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.policy.CheckContacts(ctx, *creator, accounts); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	conv := &domain.Conversation{
+		ID:        uuid.NewString(),
+		Type:      domain.ConversationGroup,
+		Name:      name,
+		SchoolID:  creator.SchoolID,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	members := make([]domain.ConversationMember, 0, len(accounts)+1)
+	members = append(members, newGroupMember(conv.ID, *creator, domain.GroupOwner, now))
+	for _, account := range accounts {
+		members = append(members, newGroupMember(conv.ID, account, domain.GroupMember, now))
+	}
+	if err := s.conversations.Create(ctx, conv, members); err != nil {
+		return nil, err
+	}
+
+	msg, err := s.recordSystemMessage(ctx, conv.ID, *creator, "group.created", fmt.Sprintf("created group %q", name), map[string]interface{}{
+		"name":        name,
+		"account_ids": others,
+	})
+	if err != nil {
+		return nil, err
+	}
+	added := make([]string, 0, len(members))
+	for _, member := range members {
+		added = append(added, member.AccountID)
+	}
+	summary := &ConversationSummary{Conversation: *conv, Members: members, MemberCount: int64(len(members)), LastMessage: msg, Member: &members[0]}
+	return &GroupChange{Summary: summary, Message: msg, Added: added}, nil
+}
+
+// RenameGroup changes the group name; owners and admins only.
+func (s *ConversationService) RenameGroup(ctx context.Context, actorID, conversationID, name string) (*GroupChange, error) {
+	name, err := cleanGroupName(name)
+	if err != nil {
+		return nil, err
+	}
+	_, actor, err := s.loadGroup(ctx, conversationID, actorID)
+	if err != nil {
+		return nil, err
+	}
+	if !canManageGroup(actor.GroupRole) {
+		return nil, ErrConversationForbidden
+	}
+	if err := s.conversations.UpdateName(ctx, conversationID, name); err != nil {
+		return nil, err
+	}
+	return s.groupChange(ctx, actorID, conversationID, "group.renamed", fmt.Sprintf("renamed the group to %q", name), map[string]interface{}{"name": name}, nil)
+}
+
+// AddGroupMembers adds accounts from the same school; owners and admins only.
+// Accounts that are already members are ignored.
+func (s *ConversationService) AddGroupMembers(ctx context.Context, actorID, conversationID string, accountIDs []string) (*GroupChange, error) {
+	conv, actor, err := s.loadGroup(ctx, conversationID, actorID)
+	if err != nil {
+		return nil, err
+	}
+	if !canManageGroup(actor.GroupRole) {
+		return nil, ErrConversationForbidden
+	}
+
+	current, err := s.conversations.GetMembers(ctx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	existing := make(map[string]bool, len(current))
+	for _, member := range current {
+		existing[member.AccountID] = true
+	}
+	added := make([]string, 0, len(accountIDs))
+	for _, id := range uniqueStrings(accountIDs) {
+		if id != "" && !existing[id] {
+			added = append(added, id)
+		}
+	}
+	if len(added) == 0 {
+		summary, err := s.GetConversationSummary(ctx, actorID, conversationID)
+		if err != nil {
+			return nil, err
+		}
+		return &GroupChange{Summary: summary}, nil
+	}
+	if len(current)+len(added) > maxGroupMembers {
+		return nil, fmt.Errorf("%w: at most %d members", ErrConversationMemberLimit, maxGroupMembers)
+	}
+
+	accounts, err := s.loadSchoolAccounts(ctx, conv.SchoolID, added)
+	if err != nil {
+		return nil, err
+	}
+	actorAccount, err := s.accounts.FindByID(ctx, actorID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.policy.CheckContacts(ctx, *actorAccount, accounts); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	members := make([]domain.ConversationMember, 0, len(accounts))
+	for _, account := range accounts {
+		members = append(members, newGroupMember(conversationID, account, domain.GroupMember, now))
+	}
+	// The cap is checked again under lock in case members joined meanwhile.
+	ok, err := s.conversations.AddMembers(ctx, members, maxGroupMembers)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: at most %d members", ErrConversationMemberLimit, maxGroupMembers)
+	}
+	change, err := s.groupChange(ctx, actorID, conversationID, "member.added", fmt.Sprintf("added %d member(s)", len(added)), map[string]interface{}{"account_ids": added}, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, member := range members {
+		change.Added = append(change.Added, member.AccountID)
+	}
+	return change, nil
+}
+
+// RemoveGroupMember removes another member. Owners can remove anyone; admins
+// can remove plain members only. Members leave with LeaveGroup instead.
+func (s *ConversationService) RemoveGroupMember(ctx context.Context, actorID, conversationID, accountID string) (*GroupChange, error) {
+	if accountID == actorID {
+		return nil, fmt.Errorf("%w: use leave to remove yourself", ErrConversationInvalid)
+	}
+	_, actor, err := s.loadGroup(ctx, conversationID, actorID)
+	if err != nil {
+		return nil, err
+	}
+	target, err := s.conversations.GetMember(ctx, conversationID, accountID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrConversationNotFound
+		}
+		return nil, err
+	}
+	switch {
+	case actor.GroupRole == domain.GroupOwner:
+	case actor.GroupRole == domain.GroupAdmin && target.GroupRole == domain.GroupMember:
+	default:
+		return nil, ErrConversationForbidden
+	}
+
+	if err := s.dropMember(ctx, conversationID, accountID); err != nil {
+		return nil, err
+	}
+	return s.groupChange(ctx, actorID, conversationID, "member.removed", "removed a member", map[string]interface{}{"account_ids": []string{accountID}}, []string{accountID})
+}
+
+// LeaveGroup removes the caller. A departing owner hands the group to the
+// longest-standing admin, or failing that the longest-standing member.
+func (s *ConversationService) LeaveGroup(ctx context.Context, accountID, conversationID string) (*GroupChange, error) {
+	_, actor, err := s.loadGroup(ctx, conversationID, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	extra := map[string]interface{}{"account_ids": []string{accountID}}
+	successor := ""
+	if actor.GroupRole == domain.GroupOwner {
+		members, err := s.conversations.GetMembers(ctx, conversationID)
+		if err != nil {
+			return nil, err
+		}
+		successor = groupSuccessor(members, accountID)
+	}
+
+	if successor != "" {
+		ok, err := s.conversations.TransferOwnership(ctx, conversationID, accountID, successor, true)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, errGroupChanged
+		}
+		extra["new_owner_id"] = successor
+	} else if err := s.dropMember(ctx, conversationID, accountID); err != nil {
+		return nil, err
+	}
+	return s.groupChange(ctx, accountID, conversationID, "member.left", "left the group", extra, []string{accountID})
+}
+
+// SetGroupMemberRole promotes or demotes a member; owner only. Granting
+// GroupOwner transfers ownership and makes the previous owner an admin.
+func (s *ConversationService) SetGroupMemberRole(ctx context.Context, actorID, conversationID, accountID string, role domain.GroupRole) (*GroupChange, error) {
+	if role != domain.GroupOwner && role != domain.GroupAdmin && role != domain.GroupMember {
+		return nil, fmt.Errorf("%w: unknown role %q", ErrConversationInvalid, role)
+	}
+	if accountID == actorID {
+		return nil, fmt.Errorf("%w: cannot change your own role", ErrConversationInvalid)
+	}
+	_, actor, err := s.loadGroup(ctx, conversationID, actorID)
+	if err != nil {
+		return nil, err
+	}
+	if actor.GroupRole != domain.GroupOwner {
+		return nil, ErrConversationForbidden
+	}
+	if _, err := s.conversations.GetMember(ctx, conversationID, accountID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrConversationNotFound
+		}
+		return nil, err
+	}
+
+	event, text := "member.role_changed", fmt.Sprintf("changed a member's role to %s", role)
+	if role == domain.GroupOwner {
+		ok, err := s.conversations.TransferOwnership(ctx, conversationID, actorID, accountID, false)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, errGroupChanged
+		}
+		event, text = "group.owner_transferred", "transferred group ownership"
+	} else if err := s.conversations.UpdateMemberRole(ctx, conversationID, accountID, role); err != nil {
+		return nil, err
+	}
+	return s.groupChange(ctx, actorID, conversationID, event, text, map[string]interface{}{
+		"account_ids": []string{accountID},
+		"role":        role,
+	}, nil)
+}
+
+// SetAnnouncementMode lets only owners and admins post when enabled; owners
+// and admins of group, class and course chats only.
+func (s *ConversationService) SetAnnouncementMode(ctx context.Context, actorID, conversationID string, enabled bool) (*GroupChange, error) {
+	conv, err := s.conversations.GetByID(ctx, conversationID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrConversationNotFound
+		}
+		return nil, err
+	}
+	actor, err := s.conversations.GetMember(ctx, conversationID, actorID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrConversationForbidden
+		}
+		return nil, err
+	}
+	if conv.Type == domain.ConversationDirect {
+		return nil, fmt.Errorf("%w: not a group conversation", ErrConversationInvalid)
+	}
+	if !canManageGroup(actor.GroupRole) {
+		return nil, ErrConversationForbidden
+	}
+	if conv.AnnouncementOnly == enabled {
+		summary, err := s.GetConversationSummary(ctx, actorID, conversationID)
+		if err != nil {
+			return nil, err
+		}
+		return &GroupChange{Summary: summary}, nil
+	}
+
+	if err := s.conversations.SetAnnouncementOnly(ctx, conversationID, enabled); err != nil {
+		return nil, err
+	}
+	event, text := "announcement.enabled", "turned on announcement mode"
+	if !enabled {
+		event, text = "announcement.disabled", "turned off announcement mode"
+	}
+	return s.groupChange(ctx, actorID, conversationID, event, text, map[string]interface{}{"announcement_only": enabled}, nil)
+}
+
+// loadGroup returns a group conversation and the caller's membership.
+func (s *ConversationService) loadGroup(ctx context.Context, conversationID, accountID string) (*domain.Conversation, *domain.ConversationMember, error) {
+	conv, err := s.conversations.GetByID(ctx, conversationID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrConversationNotFound
+		}
+		return nil, nil, err
+	}
+	member, err := s.conversations.GetMember(ctx, conversationID, accountID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrConversationForbidden
+		}
+		return nil, nil, err
+	}
+	if conv.Type != domain.ConversationGroup {
+		return nil, nil, fmt.Errorf("%w: not a group conversation", ErrConversationInvalid)
+	}
+	return conv, member, nil
+}
+
+// loadSchoolAccounts loads accounts, requiring all of them to exist in the school.
+func (s *ConversationService) loadSchoolAccounts(ctx context.Context, schoolID string, ids []string) ([]domain.Account, error) {
+	accounts, err := s.accounts.ListByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	if len(accounts) != len(ids) {
+		return nil, fmt.Errorf("%w: unknown account", ErrConversationInvalid)
+	}
+	for _, account := range accounts {
+		if account.SchoolID != schoolID {
+			return nil, fmt.Errorf("%w: members must belong to the same school", ErrConversationInvalid)
+		}
+	}
+	return accounts, nil
+}
+
+// dropMember removes a membership along with its read position.
+func (s *ConversationService) dropMember(ctx context.Context, conversationID, accountID string) error {
+	return s.conversations.RemoveMember(ctx, conversationID, accountID)
+}
+
+// groupChange records a system message and reloads the conversation as seen by the actor.
+func (s *ConversationService) groupChange(ctx context.Context, actorID, conversationID, event, text string, extra map[string]interface{}, removed []string) (*GroupChange, error) {
+	actor, err := s.accounts.FindByID(ctx, actorID)
+	if err != nil {
+		return nil, err
+	}
+	msg, err := s.recordSystemMessage(ctx, conversationID, *actor, event, text, extra)
+	if err != nil {
+		return nil, err
+	}
+
+	conv, err := s.conversations.GetByID(ctx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	members, err := s.conversations.GetMembers(ctx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	summary := &ConversationSummary{Conversation: *conv, Members: members, MemberCount: int64(len(members)), LastMessage: msg}
+	for i, member := range members {
+		if member.AccountID == actorID {
+			summary.Member = &members[i]
+			if summary.UnreadCount, summary.MentionCount, err = s.UnreadCounts(ctx, actorID, conversationID); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return &GroupChange{Summary: summary, Message: msg, Removed: removed}, nil
+}
+
+// recordSystemMessage appends a membership event to the conversation history.
+// The event and its details live in Metadata so clients can render them in
+// their own language; Text is a plain fallback. System messages do not count
+// towards unread totals.
+func (s *ConversationService) recordSystemMessage(ctx context.Context, conversationID string, actor domain.Account, event, text string, extra map[string]interface{}) (*domain.Message, error) {
+	meta := map[string]interface{}{"event": event, "actor_id": actor.ID}
+	for k, v := range extra {
+		meta[k] = v
+	}
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return nil, err
+	}
+
+	name := actor.DisplayName
+	if name == "" {
+		name = actor.Identifier
+	}
+	now := time.Now()
+	msg := &domain.Message{
+		ID:             uuid.NewString(),
+		ConversationID: conversationID,
+		SenderID:       actor.ID,
+		SenderRole:     actor.Role,
+		Kind:           MessageKindSystem,
+		Text:           strings.TrimSpace(name + " " + text),
+		Metadata:       string(data),
+		CreatedAt:      now,
+	}
+	if err := s.messages.Create(ctx, msg); err != nil {
+		return nil, err
+	}
+	if err := s.conversations.UpdateTimestamp(ctx, conversationID, now); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+func newGroupMember(conversationID string, account domain.Account, role domain.GroupRole, now time.Time) domain.ConversationMember {
+	return domain.ConversationMember{
+		ID:             uuid.NewString(),
+		ConversationID: conversationID,
+		AccountID:      account.ID,
+		Role:           account.Role,
+		GroupRole:      role,
+		CreatedAt:      now,
+	}
+}
+
+func canManageGroup(role domain.GroupRole) bool {
+	return role == domain.GroupOwner || role == domain.GroupAdmin
+}
+
+// groupSuccessor picks the next owner from members ordered by join time.
+func groupSuccessor(members []domain.ConversationMember, leavingID string) string {
+	fallback := ""
+	for _, member := range members {
+		if member.AccountID == leavingID {
+			continue
+		}
+		if member.GroupRole == domain.GroupAdmin {
+			return member.AccountID
+		}
+		if fallback == "" {
+			fallback = member.AccountID
+		}
+	}
+	return fallback
+}
+
+func cleanGroupName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("%w: group name required", ErrConversationInvalid)
+	}
+	if utf8.RuneCountInString(name) > maxGroupNameLength {
+		return "", fmt.Errorf("%w: group name longer than %d characters", ErrConversationInvalid, maxGroupNameLength)
+	}
+	return name, nil
+}
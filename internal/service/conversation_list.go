@@ -0,0 +1,255 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"learn-go/internal/domain"
+	"learn-go/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// defaultConversationPage and maxConversationPage bound a page of the
+	// conversation list.
+	defaultConversationPage = 50
+	maxConversationPage     = 200
+	// maxPinnedConversations caps how many conversations an account pins.
+	maxPinnedConversations = 10
+	// maxConversationTitleLength bounds a member's custom title in characters.
+	maxConversationTitleLength = 64
+)
+
+// ListConversationsInput selects a page of the conversation list. Cursor is
+// the NextCursor of the previous page, empty for the first.
+type ListConversationsInput struct {
+	Archived bool
+	Cursor   string
+	Limit    int
+}
+
+// ConversationPage is a page of the conversation list. NextCursor is empty
+// on the last page.
+type ConversationPage struct {
+	Conversations []ConversationSummary
+	NextCursor    string
+}
+
+// ConversationSettingsInput changes the caller's own settings of a
+// conversation; nil fields are left as they are. A mute lasts MuteFor, or
+// until unmuted when MuteFor is zero.
+type ConversationSettingsInput struct {
+	Pinned   *bool
+	Archived *bool
+	Muted    *bool
+	MuteFor  time.Duration
+	Title    *string
+}
+
+// cursorToken is the encoded form of a repository.ConversationCursor.
+type cursorToken struct {
+	Pinned    bool      `json:"p,omitempty"`
+	UpdatedAt time.Time `json:"u"`
+	ID        string    `json:"i"`
+}
+
+// ListConversations returns a page of the account's conversations, pinned
+// ones first and then the most recently active. Archived conversations are
+// listed only when asked for.
+func (s *ConversationService) ListConversations(ctx context.Context, accountID string, input ListConversationsInput) (*ConversationPage, error) {
+	limit := input.Limit
+	if limit <= 0 {
+		limit = defaultConversationPage
+	}
+	if limit > maxConversationPage {
+		limit = maxConversationPage
+	}
+	after, err := decodeConversationCursor(input.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	// One extra row tells whether another page follows.
+	convs, err := s.conversations.ListPage(ctx, repository.ConversationPageFilter{
+		AccountID: accountID,
+		Archived:  input.Archived,
+		After:     after,
+		Limit:     limit + 1,
+	})
+	if err != nil {
+		return nil, err
+	}
+	more := len(convs) > limit
+	if more {
+		convs = convs[:limit]
+	}
+
+	summaries, err := s.summarize(ctx, accountID, convs)
+	if err != nil {
+		return nil, err
+	}
+	page := &ConversationPage{Conversations: summaries}
+	if more {
+		last := summaries[len(summaries)-1]
+		page.NextCursor = encodeConversationCursor(repository.ConversationCursor{
+			Pinned:    last.Member != nil && last.Member.PinnedAt != nil,
+			UpdatedAt: last.Conversation.UpdatedAt,
+			ID:        last.Conversation.ID,
+		})
+	}
+	return page, nil
+}
+
+// UpdateConversationSettings pins, mutes, archives or retitles a
+// conversation for the caller alone and returns it as the caller sees it.
+func (s *ConversationService) UpdateConversationSettings(ctx context.Context, accountID, conversationID string, input ConversationSettingsInput) (*ConversationSummary, error) {
+	member, err := s.conversations.GetMember(ctx, conversationID, accountID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrConversationForbidden
+		}
+		return nil, err
+	}
+	if input.MuteFor < 0 {
+		return nil, fmt.Errorf("%w: mute duration must not be negative", ErrConversationInvalid)
+	}
+
+	now := time.Now().UTC()
+	if input.Pinned != nil {
+		switch {
+		case !*input.Pinned:
+			member.PinnedAt = nil
+		case member.PinnedAt == nil:
+			pinned, err := s.conversations.CountPinned(ctx, accountID)
+			if err != nil {
+				return nil, err
+			}
+			if pinned >= maxPinnedConversations {
+				return nil, fmt.Errorf("%w: at most %d pinned conversations", ErrConversationInvalid, maxPinnedConversations)
+			}
+			member.PinnedAt = &now
+		}
+	}
+	if input.Archived != nil {
+		switch {
+		case !*input.Archived:
+			member.ArchivedAt = nil
+		case member.ArchivedAt == nil:
+			member.ArchivedAt = &now
+		}
+	}
+	if input.Muted != nil {
+		switch {
+		case !*input.Muted:
+			member.MutedUntil = nil
+		case input.MuteFor > 0:
+			until := now.Add(input.MuteFor)
+			member.MutedUntil = &until
+		default:
+			until := domain.MutedForever
+			member.MutedUntil = &until
+		}
+	}
+	if input.Title != nil {
+		title := strings.TrimSpace(*input.Title)
+		if utf8.RuneCountInString(title) > maxConversationTitleLength {
+			return nil, fmt.Errorf("%w: title longer than %d characters", ErrConversationInvalid, maxConversationTitleLength)
+		}
+		member.Title = title
+	}
+
+	if err := s.conversations.SaveSettings(ctx, member); err != nil {
+		return nil, err
+	}
+	return s.GetConversationSummary(ctx, accountID, conversationID)
+}
+
+// summarize loads members, last messages and unread counts for the
+// conversations with one query each, whatever their number. Only the
+// account's own membership, and the other participant of direct chats, is
+// loaded; other members are left to GetConversationSummary.
+func (s *ConversationService) summarize(ctx context.Context, accountID string, convs []domain.Conversation) ([]ConversationSummary, error) {
+	if len(convs) == 0 {
+		return []ConversationSummary{}, nil
+	}
+	ids := make([]string, 0, len(convs))
+	for _, conv := range convs {
+		ids = append(ids, conv.ID)
+	}
+
+	members, err := s.conversations.ListViewerMembers(ctx, accountID, ids)
+	if err != nil {
+		return nil, err
+	}
+	memberCounts, err := s.conversations.CountMembers(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	lastMessages, err := s.messages.ListLastByConversations(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	unread, err := s.conversations.CountUnread(ctx, accountID, ids)
+	if err != nil {
+		return nil, err
+	}
+	mentioned, err := s.mentions.CountUnread(ctx, accountID, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	membersByConversation := make(map[string][]domain.ConversationMember, len(convs))
+	for _, member := range members {
+		membersByConversation[member.ConversationID] = append(membersByConversation[member.ConversationID], member)
+	}
+	lastByConversation := make(map[string]*domain.Message, len(lastMessages))
+	for i := range lastMessages {
+		lastByConversation[lastMessages[i].ConversationID] = &lastMessages[i]
+	}
+
+	summaries := make([]ConversationSummary, 0, len(convs))
+	for _, conv := range convs {
+		summary := ConversationSummary{
+			Conversation: conv,
+			Members:      membersByConversation[conv.ID],
+			MemberCount:  memberCounts[conv.ID],
+			LastMessage:  lastByConversation[conv.ID],
+			UnreadCount:  unread[conv.ID],
+			MentionCount: mentioned[conv.ID],
+		}
+		for i := range summary.Members {
+			if summary.Members[i].AccountID == accountID {
+				summary.Member = &summary.Members[i]
+			}
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries, nil
+}
+
+func encodeConversationCursor(cursor repository.ConversationCursor) string {
+	data, _ := json.Marshal(cursorToken{Pinned: cursor.Pinned, UpdatedAt: cursor.UpdatedAt, ID: cursor.ID})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeConversationCursor(value string) (*repository.ConversationCursor, error) {
+	if value == "" {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid cursor", ErrConversationInvalid)
+	}
+	var token cursorToken
+	if err := json.Unmarshal(data, &token); err != nil || token.ID == "" {
+		return nil, fmt.Errorf("%w: invalid cursor", ErrConversationInvalid)
+	}
+	return &repository.ConversationCursor{Pinned: token.Pinned, UpdatedAt: token.UpdatedAt, ID: token.ID}, nil
+}
@@ -0,0 +1,463 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"learn-go/internal/domain"
+	"learn-go/pkg/media"
+	"learn-go/pkg/oss"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// Per-kind upload limits for chat media.
+	chatImageMaxBytes int64 = 10 << 20
+	chatAudioMaxBytes int64 = 20 << 20
+	chatVideoMaxBytes int64 = 100 << 20
+	chatFileMaxBytes  int64 = 50 << 20
+	// chatMediaUploadTTL bounds how long a presigned upload URL stays valid.
+	chatMediaUploadTTL = 15 * time.Minute
+	// chatMediaDownloadTTL bounds how long a presigned download URL stays valid.
+	chatMediaDownloadTTL = 10 * time.Minute
+	// chatThumbnailSide is the longest edge of generated image thumbnails.
+	chatThumbnailSide = 320
+	// mediaJobQueueSize buffers freshly sent media; overflow is picked up by the sweep.
+	mediaJobQueueSize = 256
+)
+
+var (
+	// ErrMediaTooLarge indicates an upload exceeds the limit for its kind.
+	ErrMediaTooLarge = errors.New("media too large")
+	// ErrMediaTypeMismatch indicates the uploaded bytes do not match the message kind.
+	ErrMediaTypeMismatch = errors.New("media content does not match message kind")
+	// ErrInvalidMedia indicates a media reference that is unknown, foreign, reused or not uploaded.
+	ErrInvalidMedia = errors.New("invalid media")
+	// ErrMediaNotFound indicates the media does not exist or is not visible to the caller.
+	ErrMediaNotFound = errors.New("media not found")
+)
+
+// MediaUploadInput describes a chat media file the client wants to upload.
+type MediaUploadInput struct {
+	ConversationID string
+	Kind           string
+	FileName       string
+	ContentType    string
+	Size           int64
+}
+
+// MediaUpload is a pending media record plus the credentials to upload it.
+type MediaUpload struct {
+	Media       domain.ChatMedia
+	Credentials *oss.UploadCredentials
+}
+
+// MediaLinks are short-lived download URLs for a media file.
+type MediaLinks struct {
+	URL          string
+	ThumbnailURL string
+}
+
+// CreateMediaUpload registers pending media in a conversation and issues
+// upload credentials. The returned media ID is then sent with the message.
+func (s *ConversationService) CreateMediaUpload(ctx context.Context, accountID string, input MediaUploadInput) (*MediaUpload, error) {
+	ok, err := s.conversations.IsMember(ctx, input.ConversationID, accountID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrConversationForbidden
+	}
+
+	kind := media.Kind(input.Kind)
+	maxBytes, known := chatMediaLimit(kind)
+	if !known {
+		return nil, fmt.Errorf("%w: unsupported kind %q", ErrInvalidMedia, input.Kind)
+	}
+	if input.Size <= 0 {
+		return nil, fmt.Errorf("%w: size required", ErrInvalidMedia)
+	}
+	if input.Size > maxBytes {
+		return nil, fmt.Errorf("%w: %d bytes exceeds %d", ErrMediaTooLarge, input.Size, maxBytes)
+	}
+	contentType := strings.ToLower(strings.TrimSpace(input.ContentType))
+	if contentType != "" && !media.Compatible(kind, contentType) {
+		return nil, fmt.Errorf("%w: %s", ErrMediaTypeMismatch, contentType)
+	}
+	fileName := path.Base(strings.ReplaceAll(strings.TrimSpace(input.FileName), "\\", "/"))
+	if fileName == "." || fileName == "/" {
+		fileName = ""
+	}
+
+	now := time.Now()
+	id := uuid.NewString()
+	record := &domain.ChatMedia{
+		ID:             id,
+		OwnerID:        accountID,
+		ConversationID: input.ConversationID,
+		Kind:           string(kind),
+		ObjectKey:      chatMediaKey(input.ConversationID, id, fileName),
+		FileName:       fileName,
+		ContentType:    contentType,
+		Size:           input.Size,
+		Status:         domain.ChatMediaPending,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+
+	credentials, err := s.storage.PresignUpload(ctx, record.ObjectKey, contentType, record.Size, chatMediaUploadTTL)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.media.Create(ctx, record); err != nil {
+		return nil, err
+	}
+	return &MediaUpload{Media: *record, Credentials: credentials}, nil
+}
+
+// MediaDownload returns download URLs for media in a conversation the account belongs to.
+func (s *ConversationService) MediaDownload(ctx context.Context, accountID, conversationID, mediaID string) (*domain.ChatMedia, *MediaLinks, error) {
+	ok, err := s.conversations.IsMember(ctx, conversationID, accountID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !ok {
+		return nil, nil, ErrConversationForbidden
+	}
+	record, err := s.media.GetByID(ctx, mediaID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrMediaNotFound
+		}
+		return nil, nil, err
+	}
+	// Unsent uploads are only visible to their uploader.
+	if record.ConversationID != conversationID || (record.MessageID == "" && record.OwnerID != accountID) {
+		return nil, nil, ErrMediaNotFound
+	}
+	if record.Status == domain.ChatMediaRecalled || record.Status == domain.ChatMediaRemoved {
+		return nil, nil, ErrMediaNotFound
+	}
+
+	links := &MediaLinks{}
+	if links.URL, err = s.storage.PresignDownload(ctx, record.ObjectKey, chatMediaDownloadTTL); err != nil {
+		return nil, nil, err
+	}
+	if record.ThumbnailKey != "" {
+		if links.ThumbnailURL, err = s.storage.PresignDownload(ctx, record.ThumbnailKey, chatMediaDownloadTTL); err != nil {
+			return nil, nil, err
+		}
+	}
+	return record, links, nil
+}
+
+// attachMedia verifies the uploaded object behind a media message and points
+// msg at it: the upload must exist in storage, fit the kind's size limit and
+// sniff as the declared kind. msg's MediaURI, MediaID and Metadata are filled
+// in; the returned record is bound when the message is stored.
+func (s *ConversationService) attachMedia(ctx context.Context, senderID string, input SendMessageInput, msg *domain.Message) (*domain.ChatMedia, error) {
+	if input.MediaID == "" {
+		return nil, fmt.Errorf("%w: media_id required", ErrInvalidMedia)
+	}
+	record, err := s.media.GetByID(ctx, input.MediaID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: unknown media id", ErrInvalidMedia)
+		}
+		return nil, err
+	}
+	if record.OwnerID != senderID || record.ConversationID != input.ConversationID || record.Status != domain.ChatMediaPending {
+		return nil, fmt.Errorf("%w: media cannot be sent here", ErrInvalidMedia)
+	}
+	if record.Kind != input.Kind {
+		return nil, fmt.Errorf("%w: media was uploaded as %s", ErrMediaTypeMismatch, record.Kind)
+	}
+
+	info, err := s.storage.Head(ctx, record.ObjectKey)
+	if err != nil {
+		if errors.Is(err, oss.ErrObjectNotFound) {
+			return nil, fmt.Errorf("%w: media has not been uploaded", ErrInvalidMedia)
+		}
+		return nil, err
+	}
+	kind := media.Kind(record.Kind)
+	if maxBytes, _ := chatMediaLimit(kind); info.Size > maxBytes {
+		return nil, fmt.Errorf("%w: %d bytes exceeds %d", ErrMediaTooLarge, info.Size, maxBytes)
+	}
+
+	sniffed, err := s.sniffObject(ctx, record.ObjectKey)
+	if err != nil {
+		return nil, err
+	}
+	if !media.Compatible(kind, sniffed) {
+		return nil, fmt.Errorf("%w: uploaded file is %s", ErrMediaTypeMismatch, sniffed)
+	}
+
+	record.MessageID = msg.ID
+	record.ContentType = sniffed
+	record.Size = info.Size
+	record.Status = domain.ChatMediaAttached
+
+	msg.MediaID = record.ID
+	msg.MediaURI = record.ObjectKey
+	msg.Metadata = mergeMediaMetadata(msg.Metadata, record)
+	return record, nil
+}
+
+func (s *ConversationService) sniffObject(ctx context.Context, key string) (string, error) {
+	body, _, err := s.storage.Get(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+	head := make([]byte, media.SniffLen)
+	n, err := io.ReadFull(body, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	return media.Sniff(head[:n]), nil
+}
+
+// MediaJobs delivers IDs of freshly sent media to the background worker.
+func (s *ConversationService) MediaJobs() <-chan string {
+	return s.mediaJobs
+}
+
+func (s *ConversationService) enqueueMedia(id string) {
+	select {
+	case s.mediaJobs <- id:
+	default:
+		// Queue full: the worker's periodic sweep will pick it up.
+	}
+}
+
+// PendingMedia lists media that has been sent but not yet processed, for
+// recovery after restarts or queue overflow.
+func (s *ConversationService) PendingMedia(ctx context.Context, limit int) ([]string, error) {
+	records, err := s.media.ListByStatus(ctx, domain.ChatMediaAttached, limit)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(records))
+	for _, record := range records {
+		ids = append(ids, record.ID)
+	}
+	return ids, nil
+}
+
+// ProcessMedia extracts image dimensions and thumbnails or audio/video
+// durations, records them on the media and merges them into the message's
+// metadata. It returns the updated message, or nil if there was nothing to do.
+// Storage errors are returned so the media is retried; undecodable files are
+// marked failed and the original stays downloadable.
+func (s *ConversationService) ProcessMedia(ctx context.Context, mediaID string) (*domain.Message, error) {
+	record, err := s.media.GetByID(ctx, mediaID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if record.Status != domain.ChatMediaAttached {
+		return nil, nil
+	}
+	msg, err := s.messages.GetByID(ctx, record.MessageID)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		// The message insert failed after the media was attached.
+		record.Status = domain.ChatMediaFailed
+		record.Error = "message not found"
+		return nil, s.media.Update(ctx, record)
+	}
+	if msg.RecalledAt != nil || msg.RemovedAt != nil {
+		return nil, nil
+	}
+
+	record.Status = domain.ChatMediaProcessed
+	if procErr := s.extractMedia(ctx, record); procErr != nil {
+		var storageErr *mediaStorageError
+		if errors.As(procErr, &storageErr) {
+			return nil, storageErr.err
+		}
+		record.Status = domain.ChatMediaFailed
+		record.Error = truncate(procErr.Error(), 512)
+	}
+	if err := s.media.Update(ctx, record); err != nil {
+		return nil, err
+	}
+
+	msg.Metadata = mergeMediaMetadata(msg.Metadata, record)
+	if err := s.messages.UpdateMetadata(ctx, msg.ID, msg.Metadata); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// mediaStorageError marks failures talking to storage, which are worth retrying.
+type mediaStorageError struct{ err error }
+
+func (e *mediaStorageError) Error() string { return e.err.Error() }
+
+func (s *ConversationService) extractMedia(ctx context.Context, record *domain.ChatMedia) error {
+	switch media.Kind(record.Kind) {
+	case media.KindImage:
+		return s.extractImage(ctx, record)
+	case media.KindAudio, media.KindVideo:
+		return s.extractDuration(ctx, record)
+	default:
+		return nil
+	}
+}
+
+func (s *ConversationService) extractImage(ctx context.Context, record *domain.ChatMedia) error {
+	body, _, err := s.storage.Get(ctx, record.ObjectKey)
+	if err != nil {
+		return &mediaStorageError{err}
+	}
+	data, err := io.ReadAll(io.LimitReader(body, chatImageMaxBytes+1))
+	body.Close()
+	if err != nil {
+		return &mediaStorageError{err}
+	}
+
+	width, height, _, err := media.ImageConfig(bytes.NewReader(data))
+	if err != nil {
+		// Formats without a standard library decoder (HEIC, WebP) are
+		// delivered as-is, just without dimensions or a thumbnail.
+		return nil
+	}
+	record.Width, record.Height = width, height
+
+	thumb, _, _, err := media.Thumbnail(data, chatThumbnailSide)
+	if err != nil {
+		return err
+	}
+	thumbKey := chatThumbnailKey(record.ConversationID, record.ID)
+	if err := s.storage.Put(ctx, thumbKey, bytes.NewReader(thumb), int64(len(thumb)), "image/jpeg"); err != nil {
+		return &mediaStorageError{err}
+	}
+	record.ThumbnailKey = thumbKey
+	return nil
+}
+
+func (s *ConversationService) extractDuration(ctx context.Context, record *domain.ChatMedia) error {
+	body, _, err := s.storage.Get(ctx, record.ObjectKey)
+	if err != nil {
+		return &mediaStorageError{err}
+	}
+	defer body.Close()
+
+	// Containers keep the duration wherever the muxer put it, so we need to
+	// seek. Local files already can; remote bodies are spooled to disk.
+	seeker, ok := body.(io.ReadSeeker)
+	if !ok {
+		tmp, err := os.CreateTemp("", "chat-media-*")
+		if err != nil {
+			return &mediaStorageError{err}
+		}
+		defer os.Remove(tmp.Name())
+		defer tmp.Close()
+		if _, err := io.Copy(tmp, body); err != nil {
+			return &mediaStorageError{err}
+		}
+		seeker = tmp
+	}
+
+	duration, err := media.Duration(seeker, record.ContentType)
+	if err != nil {
+		if errors.Is(err, media.ErrUnsupportedContainer) {
+			return nil
+		}
+		return err
+	}
+	record.DurationMS = duration.Milliseconds()
+	return nil
+}
+
+// mergeMediaMetadata writes the server's view of the media under the "media"
+// key of the message metadata, preserving whatever else the client sent.
+// Metadata that is not a JSON object is kept under "client".
+func mergeMediaMetadata(existing string, record *domain.ChatMedia) string {
+	meta := map[string]interface{}{}
+	if strings.TrimSpace(existing) != "" {
+		if err := json.Unmarshal([]byte(existing), &meta); err != nil || meta == nil {
+			meta = map[string]interface{}{"client": existing}
+		}
+	}
+
+	info := map[string]interface{}{
+		"id":           record.ID,
+		"status":       record.Status,
+		"content_type": record.ContentType,
+		"size":         record.Size,
+	}
+	if record.FileName != "" {
+		info["file_name"] = record.FileName
+	}
+	if record.Width > 0 && record.Height > 0 {
+		info["width"] = record.Width
+		info["height"] = record.Height
+	}
+	if record.DurationMS > 0 {
+		info["duration_ms"] = record.DurationMS
+	}
+	if record.ThumbnailKey != "" {
+		info["has_thumbnail"] = true
+	}
+	meta["media"] = info
+
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return existing
+	}
+	return string(data)
+}
+
+func chatMediaLimit(kind media.Kind) (int64, bool) {
+	switch kind {
+	case media.KindImage:
+		return chatImageMaxBytes, true
+	case media.KindAudio:
+		return chatAudioMaxBytes, true
+	case media.KindVideo:
+		return chatVideoMaxBytes, true
+	case media.KindFile:
+		return chatFileMaxBytes, true
+	default:
+		return 0, false
+	}
+}
+
+// chatMediaKey keeps only the extension of the client's file name so keys stay
+// short and predictable; the full name is kept on the record.
+func chatMediaKey(conversationID, id, fileName string) string {
+	ext := strings.ToLower(path.Ext(fileName))
+	if len(ext) > 16 || strings.ContainsAny(ext, "?#% ") {
+		ext = ""
+	}
+	return fmt.Sprintf("conversations/%s/%s%s", conversationID, id, ext)
+}
+
+func chatThumbnailKey(conversationID, id string) string {
+	return fmt.Sprintf("conversations/%s/%s.thumb.jpg", conversationID, id)
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
@@ -0,0 +1,192 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"learn-go/internal/domain"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrMessageNotFound indicates the message does not exist in the conversation.
+	ErrMessageNotFound = errors.New("message not found")
+	// ErrMessageNotEditable indicates the caller may not change the message,
+	// e.g. it belongs to someone else, is not text, or was recalled.
+	ErrMessageNotEditable = errors.New("message cannot be modified")
+	// ErrRecallWindowExpired indicates the message is too old to recall.
+	ErrRecallWindowExpired = errors.New("message recall window expired")
+)
+
+// EditMessageInput carries the new content of a text message.
+type EditMessageInput struct {
+	ConversationID string
+	MessageID      string
+	Text           string
+	Metadata       string
+}
+
+// EditMessage replaces the text of the caller's own text message and keeps
+// the previous version in its history.
+func (s *ConversationService) EditMessage(ctx context.Context, accountID string, input EditMessageInput) (*domain.Message, error) {
+	msg, err := s.loadMessage(ctx, accountID, input.ConversationID, input.MessageID)
+	if err != nil {
+		return nil, err
+	}
+	if msg.SenderID != accountID || msg.Kind != "text" || msg.RecalledAt != nil || msg.RemovedAt != nil {
+		return nil, ErrMessageNotEditable
+	}
+	text := strings.TrimSpace(input.Text)
+	if text == "" {
+		return nil, fmt.Errorf("%w: text required", ErrConversationInvalid)
+	}
+	conv, err := s.conversations.GetByID(ctx, msg.ConversationID)
+	if err != nil {
+		return nil, err
+	}
+	screening, err := s.moderation.Screen(ctx, conv.SchoolID, text)
+	if err != nil {
+		return nil, err
+	}
+	text = screening.Text
+	if text == msg.Text && input.Metadata == msg.Metadata {
+		return msg, nil
+	}
+
+	now := time.Now()
+	previous := &domain.MessageEdit{
+		ID:        uuid.NewString(),
+		MessageID: msg.ID,
+		EditorID:  accountID,
+		Text:      msg.Text,
+		Metadata:  msg.Metadata,
+		CreatedAt: now,
+	}
+	msg.Text = text
+	msg.Metadata = input.Metadata
+	msg.EditedAt = &now
+	edited, err := s.messages.Edit(ctx, msg, previous)
+	if err != nil {
+		return nil, err
+	}
+	if !edited {
+		return nil, ErrMessageNotEditable
+	}
+	if err := s.moderation.Flag(ctx, messageCase(conv, msg), screening.Flags); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// ListMessageEdits returns the earlier versions of a message, oldest first.
+// Those of a message removed by moderation are kept for review only.
+func (s *ConversationService) ListMessageEdits(ctx context.Context, accountID, conversationID, messageID string) ([]domain.MessageEdit, error) {
+	msg, err := s.loadMessage(ctx, accountID, conversationID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if msg.RemovedAt != nil {
+		return []domain.MessageEdit{}, nil
+	}
+	return s.messages.ListEdits(ctx, messageID)
+}
+
+// RecallMessage withdraws the caller's message for everyone within the
+// recall window. The message stays in history as a tombstone; its text,
+// media, reactions and edit history are discarded.
+func (s *ConversationService) RecallMessage(ctx context.Context, accountID, conversationID, messageID string) (*domain.Message, error) {
+	msg, err := s.loadMessage(ctx, accountID, conversationID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if msg.SenderID != accountID || msg.Kind == MessageKindSystem || msg.RemovedAt != nil {
+		return nil, ErrMessageNotEditable
+	}
+	if msg.RecalledAt != nil {
+		return msg, nil
+	}
+	now := time.Now()
+	if now.Sub(msg.CreatedAt) > s.recallWindow {
+		return nil, ErrRecallWindowExpired
+	}
+
+	recalled, err := s.messages.Recall(ctx, msg.ID, now)
+	if err != nil {
+		return nil, err
+	}
+	if recalled {
+		if err := s.reactions.DeleteByMessage(ctx, msg.ID); err != nil {
+			return nil, err
+		}
+		if msg.MediaID != "" {
+			if err := s.discardMedia(ctx, msg.MediaID); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return s.messages.GetByID(ctx, msg.ID)
+}
+
+// DeleteMessageForMe hides a message from the caller's history only. It no
+// longer counts as unread for the caller.
+func (s *ConversationService) DeleteMessageForMe(ctx context.Context, accountID, conversationID, messageID string) error {
+	msg, err := s.loadMessage(ctx, accountID, conversationID, messageID)
+	if err != nil {
+		return err
+	}
+	return s.messages.HideForAccount(ctx, &domain.MessageDeletion{
+		ID:             uuid.NewString(),
+		MessageID:      msg.ID,
+		AccountID:      accountID,
+		ConversationID: conversationID,
+		CreatedAt:      time.Now(),
+	})
+}
+
+// loadMessage returns a message of a conversation the account belongs to.
+func (s *ConversationService) loadMessage(ctx context.Context, accountID, conversationID, messageID string) (*domain.Message, error) {
+	ok, err := s.conversations.IsMember(ctx, conversationID, accountID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrConversationForbidden
+	}
+	msg, err := s.messages.GetByID(ctx, messageID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrMessageNotFound
+		}
+		return nil, err
+	}
+	if msg.ConversationID != conversationID {
+		return nil, ErrMessageNotFound
+	}
+	return msg, nil
+}
+
+// discardMedia deletes the stored objects of recalled media. Storage errors
+// are ignored: the record is marked recalled either way, which already stops
+// downloads.
+func (s *ConversationService) discardMedia(ctx context.Context, mediaID string) error {
+	record, err := s.media.GetByID(ctx, mediaID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	_ = s.storage.Delete(ctx, record.ObjectKey)
+	if record.ThumbnailKey != "" {
+		_ = s.storage.Delete(ctx, record.ThumbnailKey)
+	}
+	record.Status = domain.ChatMediaRecalled
+	record.ThumbnailKey = ""
+	return s.media.Update(ctx, record)
+}
@@ -0,0 +1,214 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	"learn-go/internal/domain"
+)
+
+const (
+	// maxReactionRunes bounds an emoji sequence, e.g. a flag or skin-tone variant.
+	maxReactionRunes = 8
+	// replyPreviewRunes is how much of the parent text a quote preview shows.
+	replyPreviewRunes = 100
+)
+
+// MessageDetail is a message with what clients render around it: the quoted
+// parent, aggregated reactions and mentioned accounts.
+type MessageDetail struct {
+	Message   domain.Message
+	ReplyTo   *ReplyPreview
+	Reactions []ReactionSummary
+	Mentions  []string
+}
+
+// ReplyPreview is a short quote of the message being replied to.
+type ReplyPreview struct {
+	ID       string
+	SenderID string
+	Kind     string
+	Text     string
+	Recalled bool
+	Removed  bool // removed by moderation
+}
+
+// ReactionSummary aggregates one emoji on a message.
+type ReactionSummary struct {
+	Emoji      string
+	Count      int
+	AccountIDs []string
+}
+
+// ReactionChange is the outcome of toggling a reaction.
+type ReactionChange struct {
+	MessageID string
+	Added     bool
+	Reactions []ReactionSummary
+}
+
+// ToggleReaction adds the caller's emoji to a message, or removes it if
+// already present.
+func (s *ConversationService) ToggleReaction(ctx context.Context, accountID, conversationID, messageID, emoji string) (*ReactionChange, error) {
+	emoji = strings.TrimSpace(emoji)
+	if emoji == "" || utf8.RuneCountInString(emoji) > maxReactionRunes || strings.IndexFunc(emoji, unicode.IsSpace) >= 0 {
+		return nil, fmt.Errorf("%w: invalid emoji", ErrConversationInvalid)
+	}
+	msg, err := s.loadMessage(ctx, accountID, conversationID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if msg.RecalledAt != nil || msg.RemovedAt != nil || msg.Kind == MessageKindSystem {
+		return nil, ErrMessageNotEditable
+	}
+
+	added, err := s.reactions.Toggle(ctx, &domain.MessageReaction{
+		ID:             uuid.NewString(),
+		MessageID:      msg.ID,
+		AccountID:      accountID,
+		Emoji:          emoji,
+		ConversationID: conversationID,
+		CreatedAt:      time.Now(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	reactions, err := s.reactions.ListByMessages(ctx, []string{msg.ID})
+	if err != nil {
+		return nil, err
+	}
+	return &ReactionChange{MessageID: msg.ID, Added: added, Reactions: summarizeReactions(reactions)}, nil
+}
+
+// DescribeMessages attaches reply previews, reactions and mentions to
+// messages, loading each kind of data in one batch.
+func (s *ConversationService) DescribeMessages(ctx context.Context, messages []domain.Message) ([]MessageDetail, error) {
+	ids := make([]string, 0, len(messages))
+	parentIDs := make([]string, 0)
+	for _, msg := range messages {
+		ids = append(ids, msg.ID)
+		if msg.ReplyToID != "" {
+			parentIDs = append(parentIDs, msg.ReplyToID)
+		}
+	}
+
+	// A missing parent just renders without a quote.
+	parentList, err := s.messages.ListByIDs(ctx, uniqueStrings(parentIDs))
+	if err != nil {
+		return nil, err
+	}
+	parents := make(map[string]*domain.Message, len(parentList))
+	for i := range parentList {
+		parents[parentList[i].ID] = &parentList[i]
+	}
+	reactions, err := s.reactions.ListByMessages(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	byMessage := make(map[string][]domain.MessageReaction)
+	for _, reaction := range reactions {
+		byMessage[reaction.MessageID] = append(byMessage[reaction.MessageID], reaction)
+	}
+	mentions, err := s.mentions.ListByMessages(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	mentioned := make(map[string][]string)
+	for _, mention := range mentions {
+		mentioned[mention.MessageID] = append(mentioned[mention.MessageID], mention.AccountID)
+	}
+
+	details := make([]MessageDetail, 0, len(messages))
+	for _, msg := range messages {
+		detail := MessageDetail{
+			Message:   msg,
+			Reactions: summarizeReactions(byMessage[msg.ID]),
+			Mentions:  mentioned[msg.ID],
+		}
+		if parent := parents[msg.ReplyToID]; parent != nil {
+			detail.ReplyTo = newReplyPreview(parent)
+		}
+		details = append(details, detail)
+	}
+	return details, nil
+}
+
+// ListMentions returns messages mentioning the account, newest first.
+func (s *ConversationService) ListMentions(ctx context.Context, accountID string, unreadOnly bool, limit int) ([]domain.MessageMention, error) {
+	return s.mentions.ListByAccount(ctx, accountID, unreadOnly, limit)
+}
+
+// resolveMentions validates @mentions: only group-like chats support them,
+// everyone mentioned must be a member, and the sender is skipped.
+func (s *ConversationService) resolveMentions(ctx context.Context, conv *domain.Conversation, senderID string, accountIDs []string) ([]string, error) {
+	ids := make([]string, 0, len(accountIDs))
+	for _, id := range uniqueStrings(accountIDs) {
+		if id != "" && id != senderID {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	if conv.Type == domain.ConversationDirect {
+		return nil, fmt.Errorf("%w: mentions are only supported in group chats", ErrConversationInvalid)
+	}
+	members, err := s.conversations.GetMembers(ctx, conv.ID)
+	if err != nil {
+		return nil, err
+	}
+	isMember := make(map[string]bool, len(members))
+	for _, member := range members {
+		isMember[member.AccountID] = true
+	}
+	for _, id := range ids {
+		if !isMember[id] {
+			return nil, fmt.Errorf("%w: mentioned account is not a member", ErrConversationInvalid)
+		}
+	}
+	return ids, nil
+}
+
+func newReplyPreview(parent *domain.Message) *ReplyPreview {
+	preview := &ReplyPreview{
+		ID:       parent.ID,
+		SenderID: parent.SenderID,
+		Kind:     parent.Kind,
+		Recalled: parent.RecalledAt != nil,
+		Removed:  parent.RemovedAt != nil,
+	}
+	if !preview.Recalled && !preview.Removed {
+		preview.Text = truncateRunes(parent.Text, replyPreviewRunes)
+	}
+	return preview
+}
+
+// summarizeReactions groups reactions by emoji in order of first use.
+func summarizeReactions(reactions []domain.MessageReaction) []ReactionSummary {
+	summaries := make([]ReactionSummary, 0)
+	index := make(map[string]int)
+	for _, reaction := range reactions {
+		i, ok := index[reaction.Emoji]
+		if !ok {
+			i = len(summaries)
+			index[reaction.Emoji] = i
+			summaries = append(summaries, ReactionSummary{Emoji: reaction.Emoji})
+		}
+		summaries[i].Count++
+		summaries[i].AccountIDs = append(summaries[i].AccountIDs, reaction.AccountID)
+	}
+	return summaries
+}
+
+func truncateRunes(text string, limit int) string {
+	if utf8.RuneCountInString(text) <= limit {
+		return text
+	}
+	return string([]rune(text)[:limit]) + "…"
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"learn-go/internal/domain"
+
+	"gorm.io/gorm"
+)
+
+// ReadState is a member's read position after marking a conversation read.
+type ReadState struct {
+	ConversationID   string
+	ConversationType string
+	AccountID        string
+	LastReadSeq      int64
+	LastReadAt       time.Time
+}
+
+// MessageReaders tells who of the other members have read a message. Readers
+// lists them only in direct chats; groups get the counts alone.
+type MessageReaders struct {
+	Message     domain.Message
+	ReadCount   int
+	UnreadCount int
+	Readers     []domain.ConversationMember
+}
+
+// MarkRead marks messages as read up to the provided message. The read
+// position only moves forward.
+func (s *ConversationService) MarkRead(ctx context.Context, accountID, conversationID, messageID string) (*ReadState, error) {
+	ok, err := s.conversations.IsMember(ctx, conversationID, accountID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrConversationForbidden
+	}
+
+	msg, err := s.messages.GetByID(ctx, messageID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrConversationNotFound
+		}
+		return nil, err
+	}
+	if msg.ConversationID != conversationID {
+		return nil, ErrConversationNotFound
+	}
+	conv, err := s.conversations.GetByID(ctx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if err := s.conversations.MarkRead(ctx, conversationID, accountID, msg.Seq, now); err != nil {
+		return nil, err
+	}
+	if err := s.mentions.MarkReadUpTo(ctx, accountID, conversationID, msg.CreatedAt); err != nil {
+		return nil, err
+	}
+	s.notifications.logFailure(s.notifications.ReadConversation(ctx, accountID, conversationID))
+	member, err := s.conversations.GetMember(ctx, conversationID, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	state := &ReadState{
+		ConversationID:   conversationID,
+		ConversationType: conv.Type,
+		AccountID:        accountID,
+		LastReadSeq:      member.LastReadSeq,
+		LastReadAt:       now,
+	}
+	if member.LastReadAt != nil {
+		state.LastReadAt = *member.LastReadAt
+	}
+	return state, nil
+}
+
+// ListMessageReaders reports which members other than the sender have read
+// up to a message.
+func (s *ConversationService) ListMessageReaders(ctx context.Context, accountID, conversationID, messageID string) (*MessageReaders, error) {
+	msg, err := s.loadMessage(ctx, accountID, conversationID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	conv, err := s.conversations.GetByID(ctx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	members, err := s.conversations.GetMembers(ctx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &MessageReaders{Message: *msg}
+	for _, member := range members {
+		if member.AccountID == msg.SenderID {
+			continue
+		}
+		if member.LastReadSeq < msg.Seq {
+			result.UnreadCount++
+			continue
+		}
+		result.ReadCount++
+		if conv.Type == domain.ConversationDirect {
+			result.Readers = append(result.Readers, member)
+		}
+	}
+	return result, nil
+}
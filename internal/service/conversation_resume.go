@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"learn-go/internal/domain"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// maxClientMsgIDLength matches the column size of Message.ClientMsgID.
+	maxClientMsgIDLength = 64
+	// maxGapMessages bounds one resume reply; clients page for the rest.
+	maxGapMessages = 200
+)
+
+// MessageGap is what a client missed in a conversation after a sequence
+// number. Messages it deleted for itself are left out, so Seq values may
+// skip; LastSeq is the sequence number the gap is complete up to.
+type MessageGap struct {
+	ConversationID string
+	Messages       []domain.Message
+	LastSeq        int64
+	// Complete is false when more messages follow LastSeq.
+	Complete bool
+}
+
+// MessagesAfter returns the messages of a conversation after afterSeq in
+// sequence order, at most limit of them.
+func (s *ConversationService) MessagesAfter(ctx context.Context, accountID, conversationID string, afterSeq int64, limit int) (*MessageGap, error) {
+	ok, err := s.conversations.IsMember(ctx, conversationID, accountID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrConversationForbidden
+	}
+	if limit <= 0 || limit > maxGapMessages {
+		limit = maxGapMessages
+	}
+	if afterSeq < 0 {
+		afterSeq = 0
+	}
+
+	// Everything up to the conversation's last_seq has committed, so the gap
+	// can be declared complete up to it; later messages arrive live.
+	conv, err := s.conversations.GetByID(ctx, conversationID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrConversationNotFound
+		}
+		return nil, err
+	}
+	gap := &MessageGap{ConversationID: conversationID, LastSeq: conv.LastSeq, Complete: true}
+	if afterSeq >= conv.LastSeq {
+		return gap, nil
+	}
+
+	messages, err := s.messages.ListAfterSeq(ctx, conversationID, accountID, afterSeq, conv.LastSeq, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	if len(messages) > limit {
+		messages = messages[:limit]
+		gap.LastSeq = messages[limit-1].Seq
+		gap.Complete = false
+	}
+	gap.Messages = messages
+	return gap, nil
+}
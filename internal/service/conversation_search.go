@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"learn-go/internal/repository"
+)
+
+const (
+	// maxSearchQueryRunes bounds the length of a search query.
+	maxSearchQueryRunes = 100
+	// maxSearchTerms bounds how many space-separated terms are matched.
+	maxSearchTerms = 5
+	// snippetRadius is how much context a snippet keeps around the first hit.
+	snippetRadius = 30
+)
+
+// SearchMessagesInput filters a message search. Query terms are separated by
+// spaces and must all appear in a message.
+type SearchMessagesInput struct {
+	Query          string
+	ConversationID string
+	SenderID       string
+	Kind           string
+	From           *time.Time
+	To             *time.Time
+	Page           int
+	Size           int
+}
+
+// SearchHit is a matching message with a snippet of its text. Highlights are
+// rune offsets into Snippet, as [start, end) pairs.
+type SearchHit struct {
+	MessageDetail
+	Snippet    string
+	Highlights [][2]int
+}
+
+// SearchMessages finds messages containing every query term across the
+// conversations the account belongs to, newest first.
+func (s *ConversationService) SearchMessages(ctx context.Context, accountID string, input SearchMessagesInput) ([]SearchHit, int64, error) {
+	query := strings.TrimSpace(input.Query)
+	if query == "" {
+		return nil, 0, fmt.Errorf("%w: query required", ErrConversationInvalid)
+	}
+	if utf8.RuneCountInString(query) > maxSearchQueryRunes {
+		return nil, 0, fmt.Errorf("%w: query longer than %d characters", ErrConversationInvalid, maxSearchQueryRunes)
+	}
+	terms := uniqueStrings(strings.Fields(query))
+	if len(terms) > maxSearchTerms {
+		return nil, 0, fmt.Errorf("%w: at most %d search terms", ErrConversationInvalid, maxSearchTerms)
+	}
+	if input.From != nil && input.To != nil && !input.From.Before(*input.To) {
+		return nil, 0, fmt.Errorf("%w: from must be before to", ErrConversationInvalid)
+	}
+	if input.ConversationID != "" {
+		ok, err := s.conversations.IsMember(ctx, input.ConversationID, accountID)
+		if err != nil {
+			return nil, 0, err
+		}
+		if !ok {
+			return nil, 0, ErrConversationForbidden
+		}
+	}
+
+	messages, total, err := s.search.Search(ctx, repository.MessageSearchFilter{
+		AccountID:      accountID,
+		Terms:          terms,
+		ConversationID: input.ConversationID,
+		SenderID:       input.SenderID,
+		Kind:           input.Kind,
+		From:           input.From,
+		To:             input.To,
+		Page:           input.Page,
+		Size:           input.Size,
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	details, err := s.DescribeMessages(ctx, messages)
+	if err != nil {
+		return nil, 0, err
+	}
+	hits := make([]SearchHit, 0, len(details))
+	for _, detail := range details {
+		snippet, highlights := buildSnippet(detail.Message.Text, terms)
+		hits = append(hits, SearchHit{MessageDetail: detail, Snippet: snippet, Highlights: highlights})
+	}
+	return hits, total, nil
+}
+
+// buildSnippet cuts text around the first term occurrence and marks every
+// case-insensitive occurrence of the terms inside the cut. It works on runes
+// so Chinese text is never split mid-character and offsets suit clients.
+func buildSnippet(text string, terms []string) (string, [][2]int) {
+	runes := []rune(text)
+	lower := []rune(strings.ToLower(text))
+	if len(lower) != len(runes) {
+		// Lowercasing changed the length; match on the original instead.
+		lower = runes
+	}
+
+	var matches [][2]int
+	for _, term := range terms {
+		needle := []rune(strings.ToLower(term))
+		if len(needle) == 0 {
+			continue
+		}
+		for i := 0; i+len(needle) <= len(lower); i++ {
+			if string(lower[i:i+len(needle)]) == string(needle) {
+				matches = append(matches, [2]int{i, i + len(needle)})
+				i += len(needle) - 1
+			}
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i][0] < matches[j][0] })
+
+	start, end := 0, len(runes)
+	if len(matches) > 0 {
+		start = max(matches[0][0]-snippetRadius, 0)
+		end = min(matches[0][1]+snippetRadius, len(runes))
+	} else {
+		end = min(2*snippetRadius, len(runes))
+	}
+
+	highlights := make([][2]int, 0, len(matches))
+	last := -1
+	for _, m := range matches {
+		if m[0] < start || m[1] > end || m[0] < last {
+			continue
+		}
+		highlights = append(highlights, [2]int{m[0] - start, m[1] - start})
+		last = m[1]
+	}
+
+	snippet := string(runes[start:end])
+	if start > 0 {
+		snippet = "…" + snippet
+		for i := range highlights {
+			highlights[i][0]++
+			highlights[i][1]++
+		}
+	}
+	if end < len(runes) {
+		snippet += "…"
+	}
+	return snippet, highlights
+}
@@ -0,0 +1,411 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"learn-go/internal/domain"
+	"learn-go/internal/repository"
+	"learn-go/pkg/spreadsheet"
+)
+
+var (
+	// ErrGradebookForbidden indicates the caller cannot view or configure the gradebook.
+	ErrGradebookForbidden = errors.New("gradebook forbidden")
+	// ErrInvalidGradeCategory indicates a malformed weighting configuration.
+	ErrInvalidGradeCategory = errors.New("invalid grade category")
+)
+
+// GradebookService builds class/course gradebooks from submission aggregates.
+type GradebookService struct {
+	assignments repository.AssignmentRepository
+	submissions repository.SubmissionRepository
+	students    repository.StudentRepository
+	accounts    repository.AccountRepository
+	categories  repository.GradeCategoryRepository
+}
+
+// NewGradebookService creates a gradebook service instance.
+func NewGradebookService(assignments repository.AssignmentRepository, submissions repository.SubmissionRepository, students repository.StudentRepository, accounts repository.AccountRepository, categories repository.GradeCategoryRepository) *GradebookService {
+	return &GradebookService{
+		assignments: assignments,
+		submissions: submissions,
+		students:    students,
+		accounts:    accounts,
+		categories:  categories,
+	}
+}
+
+// Gradebook is a students × assignments matrix with weighted averages.
+type Gradebook struct {
+	ClassID     string
+	CourseID    string
+	Assignments []domain.Assignment
+	Categories  []domain.GradeCategory
+	Rows        []GradebookRow
+}
+
+// GradebookRow holds one student's cells and computed averages.
+type GradebookRow struct {
+	Student domain.Student
+	Name    string
+	// Cells are aligned with Gradebook.Assignments.
+	Cells []GradebookCell
+	// CategoryAverages maps category name to a 0-100 percentage; nil when nothing counted yet.
+	CategoryAverages map[string]*float64
+	TermAverage      *float64
+	MissingCount     int
+}
+
+// GradebookCell is a single student/assignment intersection.
+type GradebookCell struct {
+	AssignmentID string
+	Status       string
+	Score        *float64
+	Percent      *float64
+	Missing      bool
+	Late         bool
+	Dropped      bool
+}
+
+// GradeCategoryInput describes one weighting category.
+type GradeCategoryInput struct {
+	Name           string
+	AssignmentType domain.AssignmentType
+	Weight         float64
+	DropLowest     int
+}
+
+// GetGradebook computes the gradebook for a class, optionally limited to one course.
+// Admins see every assignment of the class; teachers only their own.
+func (s *GradebookService) GetGradebook(ctx context.Context, accountID string, role domain.Role, classID, courseID string) (*Gradebook, error) {
+	if classID == "" {
+		return nil, errors.New("class id required")
+	}
+
+	assignments, err := s.assignments.ListByClass(ctx, classID, courseID)
+	if err != nil {
+		return nil, err
+	}
+	assignments, err = gradebookAssignments(accountID, role, assignments)
+	if err != nil {
+		return nil, err
+	}
+	assignments = releasedAssignments(assignments)
+
+	categories, err := s.categories.ListByClassCourse(ctx, classID, courseID)
+	if err != nil {
+		return nil, err
+	}
+	if len(categories) == 0 {
+		categories = defaultGradeCategories(assignments)
+	}
+
+	students, err := s.students.ListByClass(ctx, classID)
+	if err != nil {
+		return nil, err
+	}
+
+	accountIDs := make([]string, 0, len(students))
+	for _, student := range students {
+		accountIDs = append(accountIDs, student.AccountID)
+	}
+	accounts, err := s.accounts.ListByIDs(ctx, accountIDs)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]string, len(accounts))
+	for _, account := range accounts {
+		names[account.ID] = account.DisplayName
+	}
+
+	assignmentIDs := make([]string, 0, len(assignments))
+	for _, assignment := range assignments {
+		assignmentIDs = append(assignmentIDs, assignment.ID)
+	}
+	scores, err := s.submissions.ListScoresByAssignments(ctx, assignmentIDs)
+	if err != nil {
+		return nil, err
+	}
+	// Submissions are keyed by the student's account ID.
+	scoreByKey := make(map[string]repository.SubmissionScore, len(scores))
+	for _, score := range scores {
+		scoreByKey[score.AssignmentID+"/"+score.StudentID] = score
+	}
+
+	now := time.Now()
+	book := &Gradebook{
+		ClassID:     classID,
+		CourseID:    courseID,
+		Assignments: assignments,
+		Categories:  categories,
+		Rows:        make([]GradebookRow, 0, len(students)),
+	}
+	for _, student := range students {
+		row := GradebookRow{
+			Student: student,
+			Name:    names[student.AccountID],
+			Cells:   make([]GradebookCell, 0, len(assignments)),
+		}
+		for _, assignment := range assignments {
+			score, ok := scoreByKey[assignment.ID+"/"+student.AccountID]
+			cell := buildGradebookCell(assignment, score, ok, now)
+			if cell.Missing {
+				row.MissingCount++
+			}
+			row.Cells = append(row.Cells, cell)
+		}
+		applyCategoryWeights(&row, assignments, categories)
+		book.Rows = append(book.Rows, row)
+	}
+	return book, nil
+}
+
+// ListCategories returns the configured categories, or the defaults when none are set.
+func (s *GradebookService) ListCategories(ctx context.Context, accountID string, role domain.Role, classID, courseID string) ([]domain.GradeCategory, error) {
+	assignments, err := s.assignments.ListByClass(ctx, classID, courseID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := gradebookAssignments(accountID, role, assignments); err != nil {
+		return nil, err
+	}
+	categories, err := s.categories.ListByClassCourse(ctx, classID, courseID)
+	if err != nil {
+		return nil, err
+	}
+	if len(categories) == 0 {
+		return defaultGradeCategories(assignments), nil
+	}
+	return categories, nil
+}
+
+// ReplaceCategories sets the weighting categories for a class/course. The
+// class-wide weights apply to every teacher of the class, so teachers must
+// name a course they assigned work in; only admins set class-wide weights.
+func (s *GradebookService) ReplaceCategories(ctx context.Context, accountID string, role domain.Role, classID, courseID string, inputs []GradeCategoryInput) ([]domain.GradeCategory, error) {
+	if classID == "" {
+		return nil, errors.New("class id required")
+	}
+	if courseID == "" && role != domain.RoleAdmin {
+		return nil, fmt.Errorf("%w: course_id required", ErrGradebookForbidden)
+	}
+
+	assignments, err := s.assignments.ListByClass(ctx, classID, courseID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := gradebookAssignments(accountID, role, assignments); err != nil {
+		return nil, err
+	}
+
+	seenTypes := make(map[domain.AssignmentType]struct{}, len(inputs))
+	now := time.Now()
+	categories := make([]domain.GradeCategory, 0, len(inputs))
+	for _, input := range inputs {
+		name := strings.TrimSpace(input.Name)
+		if name == "" {
+			return nil, fmt.Errorf("%w: name required", ErrInvalidGradeCategory)
+		}
+		if input.Weight <= 0 {
+			return nil, fmt.Errorf("%w: %s weight must be positive", ErrInvalidGradeCategory, name)
+		}
+		if input.DropLowest < 0 {
+			return nil, fmt.Errorf("%w: %s drop_lowest must not be negative", ErrInvalidGradeCategory, name)
+		}
+		if _, dup := seenTypes[input.AssignmentType]; dup {
+			return nil, fmt.Errorf("%w: assignment type %s used twice", ErrInvalidGradeCategory, input.AssignmentType)
+		}
+		seenTypes[input.AssignmentType] = struct{}{}
+		categories = append(categories, domain.GradeCategory{
+			ID:             uuid.NewString(),
+			ClassID:        classID,
+			CourseID:       courseID,
+			Name:           name,
+			AssignmentType: input.AssignmentType,
+			Weight:         input.Weight,
+			DropLowest:     input.DropLowest,
+			CreatedAt:      now,
+			UpdatedAt:      now,
+		})
+	}
+
+	if err := s.categories.Replace(ctx, classID, courseID, categories); err != nil {
+		return nil, err
+	}
+	return categories, nil
+}
+
+// GradebookTable flattens a gradebook into rows suitable for CSV/XLSX export.
+// Student numbers, names and titles are text; scores and counts are numbers.
+func GradebookTable(book *Gradebook) [][]spreadsheet.Cell {
+	header := []spreadsheet.Cell{spreadsheet.Text("学号"), spreadsheet.Text("姓名")}
+	for _, assignment := range book.Assignments {
+		header = append(header, spreadsheet.Text(assignment.Title))
+	}
+	for _, category := range book.Categories {
+		header = append(header, spreadsheet.Text(category.Name+" (%)"))
+	}
+	header = append(header, spreadsheet.Text("总评 (%)"), spreadsheet.Text("缺交"))
+
+	rows := make([][]spreadsheet.Cell, 0, len(book.Rows)+1)
+	rows = append(rows, header)
+	for _, row := range book.Rows {
+		line := []spreadsheet.Cell{spreadsheet.Text(row.Student.Number), spreadsheet.Text(row.Name)}
+		for _, cell := range row.Cells {
+			switch {
+			case cell.Missing:
+				line = append(line, spreadsheet.Text("缺交"))
+			case cell.Score != nil:
+				line = append(line, spreadsheet.Number(*cell.Score))
+			default:
+				line = append(line, spreadsheet.Text(""))
+			}
+		}
+		for _, category := range book.Categories {
+			line = append(line, percentCell(row.CategoryAverages[category.Name]))
+		}
+		line = append(line, percentCell(row.TermAverage), spreadsheet.Number(float64(row.MissingCount)))
+		rows = append(rows, line)
+	}
+	return rows
+}
+
+// gradebookAssignments narrows the assignments to those the caller may see in
+// a gradebook: all of them for admins, the teacher's own otherwise. A teacher
+// without any assignment in the class or course is refused.
+func gradebookAssignments(accountID string, role domain.Role, assignments []domain.Assignment) ([]domain.Assignment, error) {
+	if role == domain.RoleAdmin {
+		return assignments, nil
+	}
+	own := make([]domain.Assignment, 0, len(assignments))
+	for _, assignment := range assignments {
+		if assignment.TeacherID == accountID {
+			own = append(own, assignment)
+		}
+	}
+	if len(own) == 0 {
+		return nil, ErrGradebookForbidden
+	}
+	return own, nil
+}
+
+// releasedAssignments drops drafts and scheduled work that students cannot see yet.
+func releasedAssignments(assignments []domain.Assignment) []domain.Assignment {
+	released := make([]domain.Assignment, 0, len(assignments))
+	for _, assignment := range assignments {
+		if assignmentReleased(assignment) {
+			released = append(released, assignment)
+		}
+	}
+	return released
+}
+
+// defaultGradeCategories weights every assignment type present equally.
+func defaultGradeCategories(assignments []domain.Assignment) []domain.GradeCategory {
+	seen := make(map[domain.AssignmentType]struct{})
+	var categories []domain.GradeCategory
+	for _, assignment := range assignments {
+		if _, ok := seen[assignment.Type]; ok {
+			continue
+		}
+		seen[assignment.Type] = struct{}{}
+		categories = append(categories, domain.GradeCategory{
+			Name:           string(assignment.Type),
+			AssignmentType: assignment.Type,
+			Weight:         1,
+		})
+	}
+	return categories
+}
+
+// buildGradebookCell classifies a submission. Work that is past due without a
+// submission is flagged missing and counts as zero; ungraded work is left out.
+func buildGradebookCell(assignment domain.Assignment, score repository.SubmissionScore, found bool, now time.Time) GradebookCell {
+	cell := GradebookCell{AssignmentID: assignment.ID}
+	pastDue := assignment.DueAt != nil && now.After(*assignment.DueAt)
+
+	if !found || score.SubmittedAt == nil {
+		if found {
+			cell.Status = score.Status
+		}
+		if pastDue {
+			cell.Missing = true
+			zero := 0.0
+			cell.Percent = &zero
+		}
+		return cell
+	}
+
+	cell.Status = score.Status
+	cell.Late = assignment.DueAt != nil && score.SubmittedAt.After(*assignment.DueAt)
+	if score.Status == "graded" && score.Score != nil {
+		cell.Score = score.Score
+		if assignment.MaxScore > 0 {
+			percent := *score.Score / assignment.MaxScore * 100
+			cell.Percent = &percent
+		}
+	}
+	return cell
+}
+
+// applyCategoryWeights computes per-category averages (after dropping the lowest
+// scores) and the weighted term average over categories that have data.
+func applyCategoryWeights(row *GradebookRow, assignments []domain.Assignment, categories []domain.GradeCategory) {
+	row.CategoryAverages = make(map[string]*float64, len(categories))
+
+	var weighted, totalWeight float64
+	for _, category := range categories {
+		var indexes []int
+		for i, assignment := range assignments {
+			if assignment.Type == category.AssignmentType && row.Cells[i].Percent != nil {
+				indexes = append(indexes, i)
+			}
+		}
+		if len(indexes) == 0 {
+			row.CategoryAverages[category.Name] = nil
+			continue
+		}
+
+		sort.SliceStable(indexes, func(a, b int) bool {
+			return *row.Cells[indexes[a]].Percent < *row.Cells[indexes[b]].Percent
+		})
+		drop := category.DropLowest
+		if drop >= len(indexes) {
+			drop = len(indexes) - 1
+		}
+		for _, i := range indexes[:drop] {
+			row.Cells[i].Dropped = true
+		}
+
+		var sum float64
+		for _, i := range indexes[drop:] {
+			sum += *row.Cells[i].Percent
+		}
+		avg := sum / float64(len(indexes)-drop)
+		row.CategoryAverages[category.Name] = &avg
+
+		weighted += avg * category.Weight
+		totalWeight += category.Weight
+	}
+
+	if totalWeight > 0 {
+		term := weighted / totalWeight
+		row.TermAverage = &term
+	}
+}
+
+// percentCell rounds an average to two decimals; a nil average is an empty cell.
+func percentCell(v *float64) spreadsheet.Cell {
+	if v == nil {
+		return spreadsheet.Text("")
+	}
+	return spreadsheet.Number(math.Round(*v*100) / 100)
+}
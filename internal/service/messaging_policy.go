@@ -0,0 +1,301 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+	// Schools name their time zone; embed the database so it loads anywhere.
+	_ "time/tzdata"
+
+	"learn-go/internal/domain"
+	"learn-go/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+// Rules reported by a PolicyViolation.
+const (
+	PolicyRuleStudentContacts = "student_contacts"
+	PolicyRuleParentContacts  = "parent_contacts"
+	PolicyRuleQuietHours      = "quiet_hours"
+)
+
+// ErrMessagingPolicyInvalid indicates a messaging policy update with invalid settings.
+var ErrMessagingPolicyInvalid = errors.New("invalid messaging policy")
+
+// PolicyViolation explains why the messaging policy refused a conversation
+// or message. It matches ErrConversationForbidden with errors.Is.
+type PolicyViolation struct {
+	Rule   string
+	Reason string
+}
+
+func (v *PolicyViolation) Error() string {
+	return fmt.Sprintf("%s: %s", ErrConversationForbidden, v.Reason)
+}
+
+func (v *PolicyViolation) Unwrap() error {
+	return ErrConversationForbidden
+}
+
+// MessagingPolicyInput replaces a school's messaging policy.
+type MessagingPolicyInput struct {
+	RestrictStudents bool
+	RestrictParents  bool
+	QuietHoursStart  string
+	QuietHoursEnd    string
+	TimeZone         string
+}
+
+// MessagingPolicyService applies each school's rules on who may message whom.
+type MessagingPolicyService struct {
+	policies     repository.MessagingPolicyRepository
+	students     repository.StudentRepository
+	teachers     repository.TeacherRepository
+	teacherLinks repository.TeacherStudentRepository
+	parentLinks  repository.ParentStudentRepository
+}
+
+// NewMessagingPolicyService constructs a MessagingPolicyService.
+func NewMessagingPolicyService(policies repository.MessagingPolicyRepository, students repository.StudentRepository, teachers repository.TeacherRepository, teacherLinks repository.TeacherStudentRepository, parentLinks repository.ParentStudentRepository) *MessagingPolicyService {
+	return &MessagingPolicyService{
+		policies:     policies,
+		students:     students,
+		teachers:     teachers,
+		teacherLinks: teacherLinks,
+		parentLinks:  parentLinks,
+	}
+}
+
+// DefaultMessagingPolicy is the policy of a school that has not set one:
+// nothing is restricted beyond the school boundary, as before policies
+// existed, and there are no quiet hours. Admins opt in to restrictions.
+func DefaultMessagingPolicy(schoolID string) domain.MessagingPolicy {
+	return domain.MessagingPolicy{SchoolID: schoolID}
+}
+
+// Get returns the school's policy, or the default if it has none.
+func (s *MessagingPolicyService) Get(ctx context.Context, schoolID string) (*domain.MessagingPolicy, error) {
+	policy, err := s.policies.Get(ctx, schoolID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			defaults := DefaultMessagingPolicy(schoolID)
+			return &defaults, nil
+		}
+		return nil, err
+	}
+	return policy, nil
+}
+
+// Update replaces the school's policy.
+func (s *MessagingPolicyService) Update(ctx context.Context, schoolID string, input MessagingPolicyInput) (*domain.MessagingPolicy, error) {
+	if schoolID == "" {
+		return nil, fmt.Errorf("%w: school_id required", ErrMessagingPolicyInvalid)
+	}
+	if (input.QuietHoursStart == "") != (input.QuietHoursEnd == "") {
+		return nil, fmt.Errorf("%w: quiet hours need both a start and an end", ErrMessagingPolicyInvalid)
+	}
+	for _, clock := range []string{input.QuietHoursStart, input.QuietHoursEnd} {
+		if _, err := parseClock(clock); clock != "" && err != nil {
+			return nil, fmt.Errorf("%w: quiet hours must be HH:MM", ErrMessagingPolicyInvalid)
+		}
+	}
+	if input.TimeZone != "" {
+		if _, err := time.LoadLocation(input.TimeZone); err != nil {
+			return nil, fmt.Errorf("%w: unknown time zone %q", ErrMessagingPolicyInvalid, input.TimeZone)
+		}
+	}
+
+	policy := &domain.MessagingPolicy{
+		SchoolID:         schoolID,
+		RestrictStudents: input.RestrictStudents,
+		RestrictParents:  input.RestrictParents,
+		QuietHoursStart:  input.QuietHoursStart,
+		QuietHoursEnd:    input.QuietHoursEnd,
+		TimeZone:         input.TimeZone,
+		UpdatedAt:        time.Now(),
+	}
+	if err := s.policies.Save(ctx, policy); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// CheckContacts reports whether from may start a conversation with each of
+// the accounts, returning a PolicyViolation for the first it may not.
+func (s *MessagingPolicyService) CheckContacts(ctx context.Context, from domain.Account, to []domain.Account) error {
+	policy, err := s.Get(ctx, from.SchoolID)
+	if err != nil {
+		return err
+	}
+	for _, account := range to {
+		violation, err := s.contactViolation(ctx, policy, from, account)
+		if err != nil {
+			return err
+		}
+		if violation != nil {
+			return violation
+		}
+	}
+	return nil
+}
+
+// CheckDirectMessage reports whether from may send to to in their direct
+// chat at the given time. Either of them may have started the chat, so a
+// reply is allowed wherever the other side could have written first.
+func (s *MessagingPolicyService) CheckDirectMessage(ctx context.Context, from, to domain.Account, at time.Time) error {
+	policy, err := s.Get(ctx, from.SchoolID)
+	if err != nil {
+		return err
+	}
+	if violation := quietHoursViolation(policy, from, to, at); violation != nil {
+		return violation
+	}
+	violation, err := s.contactViolation(ctx, policy, from, to)
+	if err != nil || violation == nil {
+		return err
+	}
+	reverse, err := s.contactViolation(ctx, policy, to, from)
+	if err != nil || reverse == nil {
+		return err
+	}
+	return violation
+}
+
+func (s *MessagingPolicyService) contactViolation(ctx context.Context, policy *domain.MessagingPolicy, from, to domain.Account) (*PolicyViolation, error) {
+	if to.Role == domain.RoleAdmin {
+		return nil, nil
+	}
+	switch {
+	case from.Role == domain.RoleStudent && policy.RestrictStudents:
+		ok, err := s.studentMayContact(ctx, from, to)
+		if err != nil || ok {
+			return nil, err
+		}
+		return &PolicyViolation{Rule: PolicyRuleStudentContacts, Reason: "students may only message their teachers, classmates and parents"}, nil
+	case from.Role == domain.RoleParent && policy.RestrictParents:
+		ok, err := s.parentMayContact(ctx, from, to)
+		if err != nil || ok {
+			return nil, err
+		}
+		return &PolicyViolation{Rule: PolicyRuleParentContacts, Reason: "parents may only message their children and their children's teachers"}, nil
+	}
+	return nil, nil
+}
+
+func (s *MessagingPolicyService) studentMayContact(ctx context.Context, from, to domain.Account) (bool, error) {
+	student, err := s.studentByAccount(ctx, from.ID)
+	if err != nil || student == nil {
+		return false, err
+	}
+	switch to.Role {
+	case domain.RoleTeacher:
+		return s.teaches(ctx, to.ID, []string{student.ID})
+	case domain.RoleStudent:
+		other, err := s.studentByAccount(ctx, to.ID)
+		if err != nil || other == nil {
+			return false, err
+		}
+		return student.ClassID != "" && other.ClassID == student.ClassID, nil
+	case domain.RoleParent:
+		parentIDs, err := s.parentLinks.ListParentIDs(ctx, []string{student.ID})
+		if err != nil {
+			return false, err
+		}
+		return slices.Contains(parentIDs, to.ID), nil
+	}
+	return false, nil
+}
+
+func (s *MessagingPolicyService) parentMayContact(ctx context.Context, from, to domain.Account) (bool, error) {
+	children, err := s.parentLinks.ListStudentIDs(ctx, from.ID)
+	if err != nil {
+		return false, err
+	}
+	switch to.Role {
+	case domain.RoleTeacher:
+		return s.teaches(ctx, to.ID, children)
+	case domain.RoleStudent:
+		child, err := s.studentByAccount(ctx, to.ID)
+		if err != nil || child == nil {
+			return false, err
+		}
+		return slices.Contains(children, child.ID), nil
+	}
+	return false, nil
+}
+
+// teaches reports whether the teacher account is bound to any of the students.
+func (s *MessagingPolicyService) teaches(ctx context.Context, teacherAccountID string, studentIDs []string) (bool, error) {
+	if len(studentIDs) == 0 {
+		return false, nil
+	}
+	teacher, err := s.teachers.GetByAccountID(ctx, teacherAccountID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	teacherIDs, err := s.teacherLinks.ListTeacherIDs(ctx, studentIDs)
+	if err != nil {
+		return false, err
+	}
+	return slices.Contains(teacherIDs, teacher.ID), nil
+}
+
+// studentByAccount returns the student profile of the account, or nil if it
+// has none.
+func (s *MessagingPolicyService) studentByAccount(ctx context.Context, accountID string) (*domain.Student, error) {
+	student, err := s.students.GetByAccountID(ctx, accountID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return student, err
+}
+
+func quietHoursViolation(policy *domain.MessagingPolicy, from, to domain.Account, at time.Time) *PolicyViolation {
+	if from.Role != domain.RoleStudent || to.Role != domain.RoleTeacher || !inQuietHours(policy, at) {
+		return nil
+	}
+	return &PolicyViolation{
+		Rule:   PolicyRuleQuietHours,
+		Reason: fmt.Sprintf("students may not message teachers between %s and %s", policy.QuietHoursStart, policy.QuietHoursEnd),
+	}
+}
+
+// inQuietHours reports whether the time falls in the policy's daily quiet
+// window, which may span midnight.
+func inQuietHours(policy *domain.MessagingPolicy, at time.Time) bool {
+	start, err := parseClock(policy.QuietHoursStart)
+	if err != nil {
+		return false
+	}
+	end, err := parseClock(policy.QuietHoursEnd)
+	if err != nil || start == end {
+		return false
+	}
+	loc := time.Local
+	if policy.TimeZone != "" {
+		if zone, err := time.LoadLocation(policy.TimeZone); err == nil {
+			loc = zone
+		}
+	}
+	local := at.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+	if start < end {
+		return minute >= start && minute < end
+	}
+	return minute >= start || minute < end
+}
+
+// parseClock returns the minutes since midnight of an "HH:MM" time.
+func parseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
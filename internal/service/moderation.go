@@ -0,0 +1,551 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	"learn-go/internal/domain"
+	"learn-go/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// maxModerationPatternLength bounds a rule's pattern in characters.
+	maxModerationPatternLength = 256
+	// maxModerationReasonLength bounds report reasons and resolutions in characters.
+	maxModerationReasonLength = 512
+)
+
+var (
+	// ErrContentBlocked indicates the content matched a block rule of the school.
+	ErrContentBlocked = errors.New("content blocked by moderation")
+	// ErrModerationInvalid indicates invalid moderation rules, reports or filters.
+	ErrModerationInvalid = errors.New("invalid moderation request")
+	// ErrModerationRuleNotFound indicates the rule does not exist.
+	ErrModerationRuleNotFound = errors.New("moderation rule not found")
+	// ErrModerationCaseNotFound indicates the case does not exist.
+	ErrModerationCaseNotFound = errors.New("moderation case not found")
+	// ErrModerationCaseClosed indicates the case was already resolved or its content removed.
+	ErrModerationCaseClosed = errors.New("moderation case already closed")
+	// ErrReportTargetNotFound indicates the reported content does not exist
+	// or the reporter cannot see it.
+	ErrReportTargetNotFound = errors.New("reported content not found")
+)
+
+var (
+	moderationActions = map[domain.ModerationAction]struct{}{
+		domain.ModerationBlock: {},
+		domain.ModerationMask:  {},
+		domain.ModerationFlag:  {},
+	}
+	moderationStatuses = map[domain.ModerationCaseStatus]struct{}{
+		domain.ModerationCaseOpen:     {},
+		domain.ModerationCaseResolved: {},
+		domain.ModerationCaseRemoved:  {},
+	}
+	moderationTargets = map[domain.ModerationTarget]struct{}{
+		domain.ModerationTargetMessage:     {},
+		domain.ModerationTargetNote:        {},
+		domain.ModerationTargetNoteComment: {},
+	}
+)
+
+// ModerationRuleInput describes a blocklist entry.
+type ModerationRuleInput struct {
+	Pattern string
+	IsRegex bool
+	Action  domain.ModerationAction
+}
+
+// Screening is the outcome of checking content against a school's rules.
+type Screening struct {
+	// Text is the content with the matches of mask rules replaced.
+	Text string
+	// Flags are the flag rules the content matched.
+	Flags []domain.ModerationRule
+}
+
+// ModerationCaseDetail is a case with the content it is about. One of
+// Message, Note and Comment is set unless the content no longer exists.
+type ModerationCaseDetail struct {
+	Case    domain.ModerationCase
+	Message *domain.Message
+	Note    *domain.Note
+	Comment *domain.NoteComment
+	// Removal keeps the original of content replaced by a tombstone.
+	Removal *domain.ModerationRemoval
+}
+
+// ModerationService screens user content against each school's blocklist
+// and runs the review queue of flagged and reported content.
+type ModerationService struct {
+	rules         repository.ModerationRuleRepository
+	cases         repository.ModerationCaseRepository
+	accounts      repository.AccountRepository
+	conversations repository.ConversationRepository
+	messages      repository.MessageRepository
+	notes         repository.NoteRepository
+	comments      repository.NoteCommentRepository
+
+	// patterns caches compiled rule expressions by source.
+	mu       sync.Mutex
+	patterns map[string]*regexp.Regexp
+}
+
+// NewModerationService constructs a ModerationService.
+func NewModerationService(rules repository.ModerationRuleRepository, cases repository.ModerationCaseRepository, accounts repository.AccountRepository, conversations repository.ConversationRepository, messages repository.MessageRepository, notes repository.NoteRepository, comments repository.NoteCommentRepository) *ModerationService {
+	return &ModerationService{
+		rules:         rules,
+		cases:         cases,
+		accounts:      accounts,
+		conversations: conversations,
+		messages:      messages,
+		notes:         notes,
+		comments:      comments,
+		patterns:      make(map[string]*regexp.Regexp),
+	}
+}
+
+// ListRules returns the school's blocklist, oldest first.
+func (s *ModerationService) ListRules(ctx context.Context, schoolID string) ([]domain.ModerationRule, error) {
+	return s.rules.ListBySchool(ctx, schoolID)
+}
+
+// CreateRule adds an entry to the school's blocklist.
+func (s *ModerationService) CreateRule(ctx context.Context, schoolID, actorID string, input ModerationRuleInput) (*domain.ModerationRule, error) {
+	if schoolID == "" {
+		return nil, fmt.Errorf("%w: school_id required", ErrModerationInvalid)
+	}
+	pattern, err := s.checkRule(input)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	rule := &domain.ModerationRule{
+		ID:        uuid.NewString(),
+		SchoolID:  schoolID,
+		Pattern:   pattern,
+		IsRegex:   input.IsRegex,
+		Action:    input.Action,
+		CreatedBy: actorID,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := s.rules.Create(ctx, rule); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+// UpdateRule replaces the pattern and action of a rule.
+func (s *ModerationService) UpdateRule(ctx context.Context, ruleID string, input ModerationRuleInput) (*domain.ModerationRule, error) {
+	rule, err := s.rules.GetByID(ctx, ruleID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrModerationRuleNotFound
+		}
+		return nil, err
+	}
+	pattern, err := s.checkRule(input)
+	if err != nil {
+		return nil, err
+	}
+
+	rule.Pattern = pattern
+	rule.IsRegex = input.IsRegex
+	rule.Action = input.Action
+	rule.UpdatedAt = time.Now()
+	if err := s.rules.Update(ctx, rule); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+// DeleteRule removes a rule from its school's blocklist. Cases it opened stay
+// in the queue.
+func (s *ModerationService) DeleteRule(ctx context.Context, ruleID string) error {
+	if err := s.rules.Delete(ctx, ruleID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrModerationRuleNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// checkRule validates a rule and returns its trimmed pattern.
+func (s *ModerationService) checkRule(input ModerationRuleInput) (string, error) {
+	pattern := strings.TrimSpace(input.Pattern)
+	if pattern == "" {
+		return "", fmt.Errorf("%w: pattern required", ErrModerationInvalid)
+	}
+	if utf8.RuneCountInString(pattern) > maxModerationPatternLength {
+		return "", fmt.Errorf("%w: pattern longer than %d characters", ErrModerationInvalid, maxModerationPatternLength)
+	}
+	if _, ok := moderationActions[input.Action]; !ok {
+		return "", fmt.Errorf("%w: action must be block, mask or flag", ErrModerationInvalid)
+	}
+	re, err := s.compile(domain.ModerationRule{Pattern: pattern, IsRegex: input.IsRegex})
+	if err != nil {
+		return "", fmt.Errorf("%w: invalid regular expression: %v", ErrModerationInvalid, err)
+	}
+	// A pattern matching nothing at all would match every piece of content.
+	if re.MatchString("") {
+		return "", fmt.Errorf("%w: pattern matches empty text", ErrModerationInvalid)
+	}
+	return pattern, nil
+}
+
+// compile returns the expression of a rule. Keywords match case-insensitively;
+// regular expressions are used as written.
+func (s *ModerationService) compile(rule domain.ModerationRule) (*regexp.Regexp, error) {
+	expr := rule.Pattern
+	if !rule.IsRegex {
+		expr = "(?i)" + regexp.QuoteMeta(rule.Pattern)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if re, ok := s.patterns[expr]; ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	s.patterns[expr] = re
+	return re, nil
+}
+
+// Screen checks text against the school's rules. It returns ErrContentBlocked
+// if a block rule matches; otherwise mask rule matches are replaced with
+// asterisks and the matching flag rules are reported for Flag.
+func (s *ModerationService) Screen(ctx context.Context, schoolID, text string) (*Screening, error) {
+	screening := &Screening{Text: text}
+	if strings.TrimSpace(text) == "" {
+		return screening, nil
+	}
+	rules, err := s.rules.ListBySchool(ctx, schoolID)
+	if err != nil {
+		return nil, err
+	}
+
+	var masks []*regexp.Regexp
+	for _, rule := range rules {
+		re, err := s.compile(rule)
+		if err != nil || !re.MatchString(text) {
+			continue
+		}
+		switch rule.Action {
+		case domain.ModerationBlock:
+			return nil, ErrContentBlocked
+		case domain.ModerationMask:
+			masks = append(masks, re)
+		case domain.ModerationFlag:
+			screening.Flags = append(screening.Flags, rule)
+		}
+	}
+	for _, re := range masks {
+		screening.Text = re.ReplaceAllStringFunc(screening.Text, func(match string) string {
+			return strings.Repeat("*", utf8.RuneCountInString(match))
+		})
+	}
+	return screening, nil
+}
+
+// Flag queues stored content for review when it matched flag rules. target
+// names the content; content flagged again while its case is open joins
+// that case.
+func (s *ModerationService) Flag(ctx context.Context, target domain.ModerationCase, flags []domain.ModerationRule) error {
+	if len(flags) == 0 {
+		return nil
+	}
+	if _, err := s.cases.FindOpen(ctx, target.TargetType, target.TargetID, ""); err == nil {
+		return nil
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	patterns := make([]string, 0, len(flags))
+	for _, rule := range flags {
+		patterns = append(patterns, rule.Pattern)
+	}
+	target.ID = uuid.NewString()
+	target.RuleID = flags[0].ID
+	target.ReporterID = ""
+	target.Reason = truncateRunes("matched "+strings.Join(patterns, ", "), maxModerationReasonLength)
+	target.Status = domain.ModerationCaseOpen
+	target.CreatedAt = time.Now()
+	return s.cases.Create(ctx, &target)
+}
+
+// ReportMessage lets a member of a conversation report one of its messages.
+func (s *ModerationService) ReportMessage(ctx context.Context, reporterID, conversationID, messageID, reason string) (*domain.ModerationCase, error) {
+	ok, err := s.conversations.IsMember(ctx, conversationID, reporterID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrReportTargetNotFound
+	}
+	msg, err := s.messages.GetByID(ctx, messageID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrReportTargetNotFound
+		}
+		return nil, err
+	}
+	if msg.ConversationID != conversationID {
+		return nil, ErrReportTargetNotFound
+	}
+	if msg.Kind == MessageKindSystem {
+		return nil, fmt.Errorf("%w: system messages cannot be reported", ErrModerationInvalid)
+	}
+	conv, err := s.conversations.GetByID(ctx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.report(ctx, reporterID, reason, msg.RecalledAt != nil || msg.RemovedAt != nil, domain.ModerationCase{
+		SchoolID:   conv.SchoolID,
+		TargetType: domain.ModerationTargetMessage,
+		TargetID:   msg.ID,
+		ParentID:   conversationID,
+		AuthorID:   msg.SenderID,
+	})
+}
+
+// ReportNote lets an account report a note it can see.
+func (s *ModerationService) ReportNote(ctx context.Context, reporterID, noteID, reason string) (*domain.ModerationCase, error) {
+	note, err := s.visibleNote(ctx, reporterID, noteID)
+	if err != nil {
+		return nil, err
+	}
+	return s.report(ctx, reporterID, reason, note.RemovedAt != nil, domain.ModerationCase{
+		SchoolID:   note.SchoolID,
+		TargetType: domain.ModerationTargetNote,
+		TargetID:   note.ID,
+		AuthorID:   note.OwnerID,
+	})
+}
+
+// ReportNoteComment lets an account report a comment on a note it can see.
+func (s *ModerationService) ReportNoteComment(ctx context.Context, reporterID, noteID, commentID, reason string) (*domain.ModerationCase, error) {
+	note, err := s.visibleNote(ctx, reporterID, noteID)
+	if err != nil {
+		return nil, err
+	}
+	comment, err := s.comments.GetByID(ctx, commentID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrReportTargetNotFound
+		}
+		return nil, err
+	}
+	if comment.NoteID != note.ID {
+		return nil, ErrReportTargetNotFound
+	}
+	return s.report(ctx, reporterID, reason, comment.RemovedAt != nil, domain.ModerationCase{
+		SchoolID:   note.SchoolID,
+		TargetType: domain.ModerationTargetNoteComment,
+		TargetID:   comment.ID,
+		ParentID:   note.ID,
+		AuthorID:   comment.AuthorID,
+	})
+}
+
+// visibleNote returns a note the account may read and comment on.
+func (s *ModerationService) visibleNote(ctx context.Context, accountID, noteID string) (*domain.Note, error) {
+	account, err := s.accounts.FindByID(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	note, err := s.notes.FindByID(ctx, noteID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrReportTargetNotFound
+		}
+		return nil, err
+	}
+	if note.DeletedAt != nil || !noteVisibleTo(account, note) {
+		return nil, ErrReportTargetNotFound
+	}
+	return note, nil
+}
+
+// report opens a case for a user's report, or returns the reporter's open
+// report of the same content.
+func (s *ModerationService) report(ctx context.Context, reporterID, reason string, gone bool, target domain.ModerationCase) (*domain.ModerationCase, error) {
+	if target.AuthorID == reporterID {
+		return nil, fmt.Errorf("%w: cannot report your own content", ErrModerationInvalid)
+	}
+	if gone {
+		return nil, fmt.Errorf("%w: content already removed", ErrModerationInvalid)
+	}
+	reason = strings.TrimSpace(reason)
+	if utf8.RuneCountInString(reason) > maxModerationReasonLength {
+		return nil, fmt.Errorf("%w: reason longer than %d characters", ErrModerationInvalid, maxModerationReasonLength)
+	}
+
+	existing, err := s.cases.FindOpen(ctx, target.TargetType, target.TargetID, reporterID)
+	if err == nil {
+		return existing, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	target.ID = uuid.NewString()
+	target.ReporterID = reporterID
+	target.Reason = reason
+	target.Status = domain.ModerationCaseOpen
+	target.CreatedAt = time.Now()
+	if err := s.cases.Create(ctx, &target); err != nil {
+		return nil, err
+	}
+	return &target, nil
+}
+
+// ListCases returns a page of the review queue, oldest first, with the
+// number of matching cases.
+func (s *ModerationService) ListCases(ctx context.Context, filter repository.ModerationCaseFilter) ([]domain.ModerationCase, int64, error) {
+	if filter.Status != "" {
+		if _, ok := moderationStatuses[filter.Status]; !ok {
+			return nil, 0, fmt.Errorf("%w: status must be open, resolved or removed", ErrModerationInvalid)
+		}
+	}
+	if filter.TargetType != "" {
+		if _, ok := moderationTargets[filter.TargetType]; !ok {
+			return nil, 0, fmt.Errorf("%w: target_type must be message, note or note_comment", ErrModerationInvalid)
+		}
+	}
+	return s.cases.List(ctx, filter)
+}
+
+// GetCase returns a case with its content and, once removed, the original.
+func (s *ModerationService) GetCase(ctx context.Context, caseID string) (*ModerationCaseDetail, error) {
+	c, err := s.cases.GetByID(ctx, caseID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrModerationCaseNotFound
+		}
+		return nil, err
+	}
+	return s.describeCase(ctx, *c)
+}
+
+// ResolveCase closes an open case, keeping the content.
+func (s *ModerationService) ResolveCase(ctx context.Context, adminID, caseID, resolution string) (*ModerationCaseDetail, error) {
+	resolution = strings.TrimSpace(resolution)
+	if utf8.RuneCountInString(resolution) > maxModerationReasonLength {
+		return nil, fmt.Errorf("%w: resolution longer than %d characters", ErrModerationInvalid, maxModerationReasonLength)
+	}
+	c, err := s.cases.GetByID(ctx, caseID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrModerationCaseNotFound
+		}
+		return nil, err
+	}
+
+	now := time.Now()
+	c.Status = domain.ModerationCaseResolved
+	c.ResolvedBy = adminID
+	c.Resolution = resolution
+	c.ResolvedAt = &now
+	resolved, err := s.cases.Resolve(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	if !resolved {
+		return nil, ErrModerationCaseClosed
+	}
+	return s.describeCase(ctx, *c)
+}
+
+// RemoveContent replaces the content of an open case with a tombstone and
+// closes every open case about it. The original is kept in the removal record.
+func (s *ModerationService) RemoveContent(ctx context.Context, adminID, caseID, reason string) (*ModerationCaseDetail, error) {
+	reason = strings.TrimSpace(reason)
+	if utf8.RuneCountInString(reason) > maxModerationReasonLength {
+		return nil, fmt.Errorf("%w: reason longer than %d characters", ErrModerationInvalid, maxModerationReasonLength)
+	}
+	detail, err := s.GetCase(ctx, caseID)
+	if err != nil {
+		return nil, err
+	}
+	if detail.Case.Status != domain.ModerationCaseOpen {
+		return nil, ErrModerationCaseClosed
+	}
+
+	removal := &domain.ModerationRemoval{
+		ID:         uuid.NewString(),
+		CaseID:     detail.Case.ID,
+		TargetType: detail.Case.TargetType,
+		TargetID:   detail.Case.TargetID,
+		AuthorID:   detail.Case.AuthorID,
+		RemovedBy:  adminID,
+		Reason:     reason,
+		CreatedAt:  time.Now(),
+	}
+	switch {
+	case detail.Message != nil:
+		removal.Content = detail.Message.Text
+		removal.MediaURI = detail.Message.MediaURI
+		removal.MediaID = detail.Message.MediaID
+		removal.Metadata = detail.Message.Metadata
+	case detail.Note != nil:
+		removal.Title = detail.Note.Title
+		removal.Content = detail.Note.Content
+	case detail.Comment != nil:
+		removal.Content = detail.Comment.Content
+	default:
+		return nil, fmt.Errorf("%w: content no longer exists", ErrModerationInvalid)
+	}
+
+	removed, err := s.cases.Remove(ctx, removal)
+	if err != nil {
+		return nil, err
+	}
+	if !removed {
+		return nil, ErrModerationCaseClosed
+	}
+	return s.GetCase(ctx, caseID)
+}
+
+// describeCase loads the content of a case and its removal record.
+func (s *ModerationService) describeCase(ctx context.Context, c domain.ModerationCase) (*ModerationCaseDetail, error) {
+	detail := &ModerationCaseDetail{Case: c}
+	var err error
+	switch c.TargetType {
+	case domain.ModerationTargetMessage:
+		detail.Message, err = s.messages.GetByID(ctx, c.TargetID)
+	case domain.ModerationTargetNote:
+		detail.Note, err = s.notes.FindByID(ctx, c.TargetID)
+	case domain.ModerationTargetNoteComment:
+		detail.Comment, err = s.comments.GetByID(ctx, c.TargetID)
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	removal, err := s.cases.GetRemoval(ctx, c.TargetType, c.TargetID)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		return detail, nil
+	}
+	detail.Removal = removal
+	return detail, nil
+}
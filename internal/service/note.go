@@ -35,13 +35,14 @@ var (
 
 // NoteService manages note lifecycle.
 type NoteService struct {
-	notes    repository.NoteRepository
-	accounts repository.AccountRepository
+	notes      repository.NoteRepository
+	accounts   repository.AccountRepository
+	moderation *ModerationService
 }
 
 // NewNoteService creates a note service instance.
-func NewNoteService(notes repository.NoteRepository, accounts repository.AccountRepository) *NoteService {
-	return &NoteService{notes: notes, accounts: accounts}
+func NewNoteService(notes repository.NoteRepository, accounts repository.AccountRepository, moderation *ModerationService) *NoteService {
+	return &NoteService{notes: notes, accounts: accounts, moderation: moderation}
 }
 
 // CreateNoteInput describes note creation payload.
@@ -77,14 +78,23 @@ func (s *NoteService) CreateNote(ctx context.Context, accountID string, input Cr
 		return nil, errors.New("invalid status")
 	}
 
+	title, err := s.moderation.Screen(ctx, account.SchoolID, input.Title)
+	if err != nil {
+		return nil, err
+	}
+	content, err := s.moderation.Screen(ctx, account.SchoolID, input.Content)
+	if err != nil {
+		return nil, err
+	}
+
 	now := time.Now()
 	note := &domain.Note{
 		ID:         uuid.NewString(),
 		SchoolID:   account.SchoolID,
 		OwnerID:    account.ID,
 		OwnerRole:  account.Role,
-		Title:      input.Title,
-		Content:    input.Content,
+		Title:      title.Text,
+		Content:    content.Text,
 		Visibility: visibility,
 		Status:     status,
 		CreatedAt:  now,
@@ -94,6 +104,9 @@ func (s *NoteService) CreateNote(ctx context.Context, accountID string, input Cr
 	if err := s.notes.Create(ctx, note); err != nil {
 		return nil, err
 	}
+	if err := s.flagNote(ctx, note, title, content); err != nil {
+		return nil, err
+	}
 	return note, nil
 }
 
@@ -103,12 +116,23 @@ func (s *NoteService) UpdateNote(ctx context.Context, accountID, noteID string,
 	if err != nil {
 		return nil, err
 	}
+	if note.RemovedAt != nil {
+		return nil, ErrNoteForbidden
+	}
 
+	title, err := s.moderation.Screen(ctx, note.SchoolID, input.Title)
+	if err != nil {
+		return nil, err
+	}
+	content, err := s.moderation.Screen(ctx, note.SchoolID, input.Content)
+	if err != nil {
+		return nil, err
+	}
 	if input.Title != "" {
-		note.Title = input.Title
+		note.Title = title.Text
 	}
 	if input.Content != "" {
-		note.Content = input.Content
+		note.Content = content.Text
 	}
 	if input.Visibility != "" {
 		visibility := normalizeVisibility(input.Visibility)
@@ -130,6 +154,9 @@ func (s *NoteService) UpdateNote(ctx context.Context, accountID, noteID string,
 	if err := s.notes.Update(ctx, note); err != nil {
 		return nil, err
 	}
+	if err := s.flagNote(ctx, note, title, content); err != nil {
+		return nil, err
+	}
 	return note, nil
 }
 
@@ -180,6 +207,16 @@ func (s *NoteService) RestoreNote(ctx context.Context, accountID, noteID string)
 	return nil
 }
 
+// flagNote queues the note for review if its title or content matched flag rules.
+func (s *NoteService) flagNote(ctx context.Context, note *domain.Note, title, content *Screening) error {
+	return s.moderation.Flag(ctx, domain.ModerationCase{
+		SchoolID:   note.SchoolID,
+		TargetType: domain.ModerationTargetNote,
+		TargetID:   note.ID,
+		AuthorID:   note.OwnerID,
+	}, append(title.Flags, content.Flags...))
+}
+
 func (s *NoteService) findOwnedNote(ctx context.Context, accountID, noteID string) (*domain.Note, error) {
 	note, err := s.notes.FindByID(ctx, noteID)
 	if err != nil {
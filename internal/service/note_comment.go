@@ -21,14 +21,16 @@ var (
 
 // NoteCommentService manages note comments lifecycle.
 type NoteCommentService struct {
-	notes    repository.NoteRepository
-	comments repository.NoteCommentRepository
-	accounts repository.AccountRepository
+	notes         repository.NoteRepository
+	comments      repository.NoteCommentRepository
+	accounts      repository.AccountRepository
+	moderation    *ModerationService
+	notifications *NotificationService
 }
 
 // NewNoteCommentService creates a note comment service instance.
-func NewNoteCommentService(notes repository.NoteRepository, comments repository.NoteCommentRepository, accounts repository.AccountRepository) *NoteCommentService {
-	return &NoteCommentService{notes: notes, comments: comments, accounts: accounts}
+func NewNoteCommentService(notes repository.NoteRepository, comments repository.NoteCommentRepository, accounts repository.AccountRepository, moderation *ModerationService, notifications *NotificationService) *NoteCommentService {
+	return &NoteCommentService{notes: notes, comments: comments, accounts: accounts, moderation: moderation, notifications: notifications}
 }
 
 // AddComment creates a comment on a note if the account has permission.
@@ -50,10 +52,18 @@ func (s *NoteCommentService) AddComment(ctx context.Context, accountID, noteID,
 		return nil, err
 	}
 
+	if note.RemovedAt != nil {
+		return nil, ErrNoteCommentNotAllowed
+	}
+
 	content = strings.TrimSpace(content)
 	if content == "" {
 		return nil, errors.New("empty comment content")
 	}
+	screening, err := s.moderation.Screen(ctx, note.SchoolID, content)
+	if err != nil {
+		return nil, err
+	}
 
 	now := time.Now()
 	comment := &domain.NoteComment{
@@ -61,13 +71,31 @@ func (s *NoteCommentService) AddComment(ctx context.Context, accountID, noteID,
 		NoteID:     note.ID,
 		AuthorID:   account.ID,
 		AuthorRole: account.Role,
-		Content:    content,
+		Content:    screening.Text,
 		CreatedAt:  now,
 	}
 
 	if err := s.comments.Create(ctx, comment); err != nil {
 		return nil, err
 	}
+	if err := s.moderation.Flag(ctx, domain.ModerationCase{
+		SchoolID:   note.SchoolID,
+		TargetType: domain.ModerationTargetNoteComment,
+		TargetID:   comment.ID,
+		ParentID:   note.ID,
+		AuthorID:   account.ID,
+	}, screening.Flags); err != nil {
+		return nil, err
+	}
+
+	s.notifications.logFailure(s.notifications.Notify(ctx, []string{note.OwnerID}, NotificationInput{
+		Category:    domain.NotificationComment,
+		Title:       account.DisplayName + " commented on your note",
+		Body:        comment.Content,
+		SubjectType: NotificationSubjectNote,
+		SubjectID:   note.ID,
+		ActorID:     account.ID,
+	}))
 	return comment, nil
 }
 
@@ -94,21 +122,26 @@ func (s *NoteCommentService) ListComments(ctx context.Context, accountID, noteID
 }
 
 func (s *NoteCommentService) ensureCanInteract(account *domain.Account, note *domain.Note) error {
+	if !noteVisibleTo(account, note) {
+		return ErrNoteCommentNotAllowed
+	}
+	return nil
+}
+
+// noteVisibleTo reports whether the account may read and comment on the note:
+// its own, or a published note shared within its school.
+func noteVisibleTo(account *domain.Account, note *domain.Note) bool {
 	if note.OwnerID == account.ID {
-		return nil
+		return true
 	}
 
 	if strings.EqualFold(note.Visibility, "private") {
-		return ErrNoteCommentNotAllowed
+		return false
 	}
 
 	if account.SchoolID != note.SchoolID {
-		return ErrNoteCommentNotAllowed
+		return false
 	}
 
-	if note.Status != "published" {
-		return ErrNoteCommentNotAllowed
-	}
-
-	return nil
+	return note.Status == "published"
 }
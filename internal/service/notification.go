@@ -0,0 +1,304 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"learn-go/internal/domain"
+	"learn-go/internal/repository"
+	"learn-go/pkg/logger"
+)
+
+const (
+	// maxNotificationBodyLength bounds the excerpt of content quoted in a
+	// notification, in characters.
+	maxNotificationBodyLength = 120
+	// maxNotificationBatch bounds how many notifications one request marks.
+	maxNotificationBatch = 100
+)
+
+// ErrNotificationInvalid indicates an unknown category or malformed request.
+var ErrNotificationInvalid = errors.New("invalid notification request")
+
+var notificationCategories = map[domain.NotificationCategory]struct{}{
+	domain.NotificationGrade:      {},
+	domain.NotificationComment:    {},
+	domain.NotificationMessage:    {},
+	domain.NotificationAssignment: {},
+}
+
+// Subjects a notification can point at.
+const (
+	NotificationSubjectAssignment   = "assignment"
+	NotificationSubjectNote         = "note"
+	NotificationSubjectConversation = "conversation"
+)
+
+// NotificationInput describes an event to deliver to a set of accounts.
+type NotificationInput struct {
+	Category    domain.NotificationCategory
+	Title       string
+	Body        string
+	SubjectType string
+	SubjectID   string
+	ActorID     string
+	// GroupKey merges the event into the recipient's unread notification
+	// with the same key instead of adding a new one.
+	GroupKey string
+}
+
+// ListNotificationsInput selects a page of the inbox.
+type ListNotificationsInput struct {
+	Category   domain.NotificationCategory
+	UnreadOnly bool
+	Page       int
+	Size       int
+}
+
+// NotificationPage is a page of the inbox with the unread counts.
+type NotificationPage struct {
+	Notifications []domain.Notification
+	Total         int64
+	Unread        UnreadNotifications
+}
+
+// UnreadNotifications counts unread notifications, in all and per category.
+type UnreadNotifications struct {
+	Total      int64
+	ByCategory map[domain.NotificationCategory]int64
+}
+
+// NotificationService keeps account inboxes so events such as grades,
+// comments and messages reach users that were offline when they happened.
+type NotificationService struct {
+	notifications repository.NotificationRepository
+	log           *logger.Logger
+	onCreate      func([]domain.Notification)
+}
+
+// NewNotificationService constructs a NotificationService.
+func NewNotificationService(notifications repository.NotificationRepository, log *logger.Logger) *NotificationService {
+	return &NotificationService{notifications: notifications, log: log}
+}
+
+// logFailure records an error from notifying about work that already
+// succeeded. Notifications are best effort, so callers never fail on them.
+func (s *NotificationService) logFailure(err error) {
+	if err != nil {
+		s.log.Printf("notifications: %v", err)
+	}
+}
+
+// OnCreate registers a callback invoked with every batch of stored
+// notifications, so recipients that are online can be told at once.
+func (s *NotificationService) OnCreate(fn func([]domain.Notification)) {
+	s.onCreate = fn
+}
+
+// Notify stores the event in the inbox of every recipient except the actor
+// and those that turned the category off.
+func (s *NotificationService) Notify(ctx context.Context, recipients []string, input NotificationInput) error {
+	seen := make(map[string]struct{}, len(recipients))
+	accountIDs := make([]string, 0, len(recipients))
+	for _, id := range recipients {
+		if id == "" || id == input.ActorID {
+			continue
+		}
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		accountIDs = append(accountIDs, id)
+	}
+	if len(accountIDs) == 0 {
+		return nil
+	}
+
+	disabled, err := s.notifications.ListDisabled(ctx, accountIDs, input.Category)
+	if err != nil {
+		return err
+	}
+	for _, id := range disabled {
+		delete(seen, id)
+	}
+
+	now := time.Now()
+	created := make([]domain.Notification, 0, len(accountIDs))
+	for _, id := range accountIDs {
+		if _, ok := seen[id]; !ok {
+			continue
+		}
+		created = append(created, domain.Notification{
+			ID:          uuid.NewString(),
+			AccountID:   id,
+			Category:    input.Category,
+			Title:       input.Title,
+			Body:        truncateRunes(input.Body, maxNotificationBodyLength),
+			SubjectType: input.SubjectType,
+			SubjectID:   input.SubjectID,
+			ActorID:     input.ActorID,
+			GroupKey:    input.GroupKey,
+			Count:       1,
+			CreatedAt:   now,
+		})
+	}
+	if len(created) == 0 {
+		return nil
+	}
+
+	if input.GroupKey == "" {
+		if err := s.notifications.CreateBatch(ctx, created); err != nil {
+			return err
+		}
+	} else if err := s.notifications.MergeBatch(ctx, created); err != nil {
+		return err
+	}
+
+	if s.onCreate != nil {
+		s.onCreate(created)
+	}
+	return nil
+}
+
+// ListNotifications returns a page of the account's inbox, newest first.
+func (s *NotificationService) ListNotifications(ctx context.Context, accountID string, input ListNotificationsInput) (*NotificationPage, error) {
+	if input.Category != "" {
+		if err := checkNotificationCategory(input.Category); err != nil {
+			return nil, err
+		}
+	}
+	notifications, total, err := s.notifications.List(ctx, repository.NotificationFilter{
+		AccountID:  accountID,
+		Category:   input.Category,
+		UnreadOnly: input.UnreadOnly,
+		Page:       input.Page,
+		Size:       input.Size,
+	})
+	if err != nil {
+		return nil, err
+	}
+	unread, err := s.UnreadCount(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	return &NotificationPage{Notifications: notifications, Total: total, Unread: *unread}, nil
+}
+
+// UnreadCount counts the account's unread notifications.
+func (s *NotificationService) UnreadCount(ctx context.Context, accountID string) (*UnreadNotifications, error) {
+	counts, err := s.notifications.CountUnread(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	unread := &UnreadNotifications{ByCategory: make(map[domain.NotificationCategory]int64, len(domain.NotificationCategories))}
+	for _, category := range domain.NotificationCategories {
+		unread.ByCategory[category] = counts[category]
+		unread.Total += counts[category]
+	}
+	return unread, nil
+}
+
+// MarkRead marks the account's notifications read; notifications already
+// read or belonging to someone else are left alone. It returns how many
+// changed.
+func (s *NotificationService) MarkRead(ctx context.Context, accountID string, ids []string) (int64, error) {
+	if err := checkNotificationIDs(ids); err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	return s.notifications.SetRead(ctx, accountID, ids, &now)
+}
+
+// MarkUnread marks the account's notifications unread again.
+func (s *NotificationService) MarkUnread(ctx context.Context, accountID string, ids []string) (int64, error) {
+	if err := checkNotificationIDs(ids); err != nil {
+		return 0, err
+	}
+	return s.notifications.SetRead(ctx, accountID, ids, nil)
+}
+
+// MarkAllRead marks every unread notification of the account read, only
+// those of the category when it is not empty.
+func (s *NotificationService) MarkAllRead(ctx context.Context, accountID string, category domain.NotificationCategory) (int64, error) {
+	if category != "" {
+		if err := checkNotificationCategory(category); err != nil {
+			return 0, err
+		}
+	}
+	return s.notifications.MarkAllRead(ctx, accountID, category, time.Now())
+}
+
+// ReadConversation marks the account's message notification of the
+// conversation read, as reading the conversation has caught up with it.
+func (s *NotificationService) ReadConversation(ctx context.Context, accountID, conversationID string) error {
+	_, err := s.notifications.MarkGroupRead(ctx, accountID, conversationGroupKey(conversationID), time.Now())
+	return err
+}
+
+// Preferences returns the account's setting for every category.
+func (s *NotificationService) Preferences(ctx context.Context, accountID string) ([]domain.NotificationPreference, error) {
+	stored, err := s.notifications.ListPreferences(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	byCategory := make(map[domain.NotificationCategory]domain.NotificationPreference, len(stored))
+	for _, pref := range stored {
+		byCategory[pref.Category] = pref
+	}
+
+	prefs := make([]domain.NotificationPreference, 0, len(domain.NotificationCategories))
+	for _, category := range domain.NotificationCategories {
+		pref, ok := byCategory[category]
+		if !ok {
+			pref = domain.NotificationPreference{AccountID: accountID, Category: category, Enabled: true}
+		}
+		prefs = append(prefs, pref)
+	}
+	return prefs, nil
+}
+
+// UpdatePreferences turns the given categories on or off and returns the
+// account's settings. Categories left out keep their setting.
+func (s *NotificationService) UpdatePreferences(ctx context.Context, accountID string, enabled map[domain.NotificationCategory]bool) ([]domain.NotificationPreference, error) {
+	if len(enabled) == 0 {
+		return nil, fmt.Errorf("%w: no categories given", ErrNotificationInvalid)
+	}
+	now := time.Now()
+	prefs := make([]domain.NotificationPreference, 0, len(enabled))
+	for category, value := range enabled {
+		if err := checkNotificationCategory(category); err != nil {
+			return nil, err
+		}
+		prefs = append(prefs, domain.NotificationPreference{AccountID: accountID, Category: category, Enabled: value, UpdatedAt: now})
+	}
+	if err := s.notifications.SavePreferences(ctx, prefs); err != nil {
+		return nil, err
+	}
+	return s.Preferences(ctx, accountID)
+}
+
+func checkNotificationCategory(category domain.NotificationCategory) error {
+	if _, ok := notificationCategories[category]; !ok {
+		return fmt.Errorf("%w: unknown category %q", ErrNotificationInvalid, category)
+	}
+	return nil
+}
+
+func checkNotificationIDs(ids []string) error {
+	if len(ids) == 0 {
+		return fmt.Errorf("%w: ids required", ErrNotificationInvalid)
+	}
+	if len(ids) > maxNotificationBatch {
+		return fmt.Errorf("%w: at most %d ids", ErrNotificationInvalid, maxNotificationBatch)
+	}
+	return nil
+}
+
+// conversationGroupKey merges the message notifications of a conversation.
+func conversationGroupKey(conversationID string) string {
+	return NotificationSubjectConversation + ":" + conversationID
+}
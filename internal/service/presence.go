@@ -0,0 +1,202 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"learn-go/internal/domain"
+	"learn-go/internal/repository"
+)
+
+const (
+	// PresenceHeartbeat is how often an instance refreshes its sessions.
+	PresenceHeartbeat = 30 * time.Second
+	// presenceTTL is how long sessions of a silent instance still count.
+	presenceTTL = 3 * PresenceHeartbeat
+	// maxPresenceQuery bounds the accounts of one presence query.
+	maxPresenceQuery = 200
+)
+
+// ErrPresenceForbidden indicates presence was requested for an account that
+// shares no conversation with the viewer.
+var ErrPresenceForbidden = errors.New("presence forbidden")
+
+// PresenceStatus is an account's aggregated connection state.
+type PresenceStatus string
+
+const (
+	PresenceOnline  PresenceStatus = "online"
+	PresenceAway    PresenceStatus = "away" // connected, but every client reports away
+	PresenceOffline PresenceStatus = "offline"
+)
+
+// Presence describes whether an account is connected. LastSeenAt is when it
+// last had a connection open, nil if it never connected.
+type Presence struct {
+	AccountID  string
+	Status     PresenceStatus
+	LastSeenAt *time.Time
+}
+
+// PresenceService aggregates the websocket connections of accounts across
+// server instances. Each instance stores its own connection counts per
+// account; an account is online if any instance holds an active connection.
+type PresenceService struct {
+	presence      repository.PresenceRepository
+	conversations repository.ConversationRepository
+}
+
+// NewPresenceService constructs a PresenceService.
+func NewPresenceService(presence repository.PresenceRepository, conversations repository.ConversationRepository) *PresenceService {
+	return &PresenceService{presence: presence, conversations: conversations}
+}
+
+// Update records the connections an instance holds for the account and
+// returns its presence along with whether the aggregated status changed.
+func (s *PresenceService) Update(ctx context.Context, instanceID, accountID string, connections, active int) (*Presence, bool, error) {
+	before, err := s.lookup(ctx, []string{accountID})
+	if err != nil {
+		return nil, false, err
+	}
+
+	now := time.Now().UTC()
+	session := &domain.PresenceSession{InstanceID: instanceID, AccountID: accountID, Connections: connections, Active: active, UpdatedAt: now}
+	if err := s.presence.Save(ctx, session); err != nil {
+		return nil, false, err
+	}
+	if connections <= 0 {
+		if err := s.presence.SetLastSeen(ctx, []string{accountID}, now); err != nil {
+			return nil, false, err
+		}
+	}
+
+	after, err := s.lookup(ctx, []string{accountID})
+	if err != nil {
+		return nil, false, err
+	}
+	return &after[0], after[0].Status != before[0].Status, nil
+}
+
+// Heartbeat keeps the instance's sessions alive and sweeps those of
+// instances that went away, returning the accounts that went offline.
+func (s *PresenceService) Heartbeat(ctx context.Context, instanceID string) ([]Presence, error) {
+	now := time.Now().UTC()
+	if err := s.presence.Touch(ctx, instanceID, now); err != nil {
+		return nil, err
+	}
+	stale, err := s.presence.DeleteStale(ctx, now.Add(-presenceTTL))
+	if err != nil {
+		return nil, err
+	}
+	if len(stale) == 0 {
+		return nil, nil
+	}
+
+	seen := make(map[string]time.Time, len(stale))
+	for _, session := range stale {
+		if session.UpdatedAt.After(seen[session.AccountID]) {
+			seen[session.AccountID] = session.UpdatedAt
+		}
+	}
+	ids := make([]string, 0, len(seen))
+	for id := range seen {
+		ids = append(ids, id)
+	}
+	current, err := s.lookup(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	var offline []Presence
+	for _, p := range current {
+		if p.Status != PresenceOffline {
+			continue
+		}
+		at := seen[p.AccountID]
+		if err := s.presence.SetLastSeen(ctx, []string{p.AccountID}, at); err != nil {
+			return nil, err
+		}
+		p.LastSeenAt = &at
+		offline = append(offline, p)
+	}
+	return offline, nil
+}
+
+// Query returns the presence of accounts the viewer shares a conversation
+// with; the viewer may also query itself.
+func (s *PresenceService) Query(ctx context.Context, viewerID string, accountIDs []string) ([]Presence, error) {
+	ids := uniqueStrings(accountIDs)
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("%w: account_ids required", ErrConversationInvalid)
+	}
+	if len(ids) > maxPresenceQuery {
+		return nil, fmt.Errorf("%w: at most %d accounts", ErrConversationInvalid, maxPresenceQuery)
+	}
+
+	contacts, err := s.conversations.ListContactIDs(ctx, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	visible := make(map[string]bool, len(contacts)+1)
+	visible[viewerID] = true
+	for _, id := range contacts {
+		visible[id] = true
+	}
+	for _, id := range ids {
+		if !visible[id] {
+			return nil, ErrPresenceForbidden
+		}
+	}
+	return s.lookup(ctx, ids)
+}
+
+// Audience lists the accounts told about the account's presence changes:
+// its contacts and the account itself, for its other devices.
+func (s *PresenceService) Audience(ctx context.Context, accountID string) ([]string, error) {
+	contacts, err := s.conversations.ListContactIDs(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	return append(contacts, accountID), nil
+}
+
+// lookup aggregates the live sessions of the accounts, in the given order.
+func (s *PresenceService) lookup(ctx context.Context, accountIDs []string) ([]Presence, error) {
+	sessions, err := s.presence.ListSessions(ctx, accountIDs, time.Now().UTC().Add(-presenceTTL))
+	if err != nil {
+		return nil, err
+	}
+	lastSeen, err := s.presence.ListLastSeen(ctx, accountIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	connections := make(map[string]int, len(sessions))
+	active := make(map[string]int, len(sessions))
+	for _, session := range sessions {
+		connections[session.AccountID] += session.Connections
+		active[session.AccountID] += session.Active
+	}
+	seen := make(map[string]time.Time, len(lastSeen))
+	for _, row := range lastSeen {
+		seen[row.AccountID] = row.SeenAt
+	}
+
+	result := make([]Presence, 0, len(accountIDs))
+	for _, id := range accountIDs {
+		p := Presence{AccountID: id, Status: PresenceOffline}
+		switch {
+		case active[id] > 0:
+			p.Status = PresenceOnline
+		case connections[id] > 0:
+			p.Status = PresenceAway
+		}
+		if at, ok := seen[id]; ok {
+			p.LastSeenAt = &at
+		}
+		result = append(result, p)
+	}
+	return result, nil
+}
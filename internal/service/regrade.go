@@ -0,0 +1,252 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"learn-go/internal/domain"
+	"learn-go/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrRegradeNotFound indicates the regrade request does not exist.
+	ErrRegradeNotFound = errors.New("regrade request not found")
+	// ErrRegradeNotAllowed indicates the submission or item cannot be regraded right now.
+	ErrRegradeNotAllowed = errors.New("regrade not allowed")
+	// ErrRegradePending indicates the item already has an open regrade request.
+	ErrRegradePending = errors.New("regrade request already pending")
+	// ErrRegradeResolved indicates the regrade request has already been decided.
+	ErrRegradeResolved = errors.New("regrade request already resolved")
+)
+
+// ListGradeHistory returns the grade audit trail for a submission.
+// Admins may read any submission; teachers only those of their own assignments.
+func (s *AssignmentService) ListGradeHistory(ctx context.Context, accountID string, role domain.Role, assignmentID, submissionID string) ([]domain.GradeChange, error) {
+	if _, err := s.loadReviewableSubmission(ctx, accountID, role, assignmentID, submissionID); err != nil {
+		return nil, err
+	}
+	return s.gradeHistory.ListBySubmission(ctx, submissionID)
+}
+
+// RegradeRequestInput captures a student's regrade request.
+type RegradeRequestInput struct {
+	AssignmentID string
+	ItemID       string
+	Reason       string
+}
+
+// RequestRegrade opens a regrade request on a graded submission item.
+// The reason is also posted as a submission comment so the discussion stays in one thread.
+func (s *AssignmentService) RequestRegrade(ctx context.Context, studentID string, input RegradeRequestInput) (*domain.RegradeRequest, error) {
+	reason := strings.TrimSpace(input.Reason)
+	if studentID == "" || input.AssignmentID == "" || input.ItemID == "" {
+		return nil, errors.New("student, assignment and item required")
+	}
+	if reason == "" {
+		return nil, errors.New("reason required")
+	}
+
+	submission, items, err := s.submissions.GetByAssignmentAndStudent(ctx, input.AssignmentID, studentID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSubmissionNotFound
+		}
+		return nil, err
+	}
+	if submission.Status != "graded" {
+		return nil, fmt.Errorf("%w: submission has not been graded", ErrRegradeNotAllowed)
+	}
+
+	found := false
+	for _, item := range items {
+		if item.ID == input.ItemID {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("%w: item does not belong to submission", ErrRegradeNotAllowed)
+	}
+
+	now := time.Now()
+	comment := &domain.SubmissionComment{
+		ID:           uuid.NewString(),
+		SubmissionID: submission.ID,
+		AuthorID:     studentID,
+		AuthorRole:   domain.RoleStudent,
+		Content:      reason,
+		CreatedAt:    now,
+	}
+	request := &domain.RegradeRequest{
+		ID:           uuid.NewString(),
+		AssignmentID: input.AssignmentID,
+		SubmissionID: submission.ID,
+		ItemID:       input.ItemID,
+		StudentID:    studentID,
+		Reason:       reason,
+		Status:       domain.RegradePending,
+		CommentID:    comment.ID,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	created, err := s.regrades.Create(ctx, request, comment)
+	if err != nil {
+		return nil, err
+	}
+	if !created {
+		return nil, ErrRegradePending
+	}
+	return request, nil
+}
+
+// ListMyRegradeRequests returns the student's regrade requests for an assignment.
+func (s *AssignmentService) ListMyRegradeRequests(ctx context.Context, studentID, assignmentID string) ([]domain.RegradeRequest, error) {
+	submission, _, err := s.submissions.GetByAssignmentAndStudent(ctx, assignmentID, studentID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSubmissionNotFound
+		}
+		return nil, err
+	}
+	return s.regrades.ListBySubmission(ctx, submission.ID)
+}
+
+// ListAssignmentRegradeRequests returns regrade requests for an assignment, optionally filtered by status.
+func (s *AssignmentService) ListAssignmentRegradeRequests(ctx context.Context, accountID string, role domain.Role, assignmentID string, status domain.RegradeStatus) ([]domain.RegradeRequest, error) {
+	assignment, _, err := s.GetAssignment(ctx, assignmentID)
+	if err != nil {
+		return nil, err
+	}
+	if role != domain.RoleAdmin && assignment.TeacherID != accountID {
+		return nil, ErrSubmissionForbidden
+	}
+	return s.regrades.ListByAssignment(ctx, assignmentID, status)
+}
+
+// ResolveRegradeInput captures a teacher's decision on a regrade request.
+type ResolveRegradeInput struct {
+	AssignmentID string
+	RequestID    string
+	Accept       bool
+	Score        *float64 // new item score when accepting; nil keeps the current score
+	Note         string
+}
+
+// ResolveRegrade accepts or rejects a pending regrade request. Accepted
+// requests with a score go through the regular grading path so the change is
+// validated and recorded in the grade history.
+func (s *AssignmentService) ResolveRegrade(ctx context.Context, teacherID string, input ResolveRegradeInput) (*domain.RegradeRequest, error) {
+	if teacherID == "" || input.AssignmentID == "" || input.RequestID == "" {
+		return nil, errors.New("teacher, assignment and request required")
+	}
+
+	assignment, questions, err := s.GetAssignment(ctx, input.AssignmentID)
+	if err != nil {
+		return nil, err
+	}
+	if assignment.TeacherID != teacherID {
+		return nil, ErrSubmissionForbidden
+	}
+
+	request, err := s.regrades.GetByID(ctx, input.RequestID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRegradeNotFound
+		}
+		return nil, err
+	}
+	if request.AssignmentID != input.AssignmentID {
+		return nil, ErrRegradeNotFound
+	}
+	if request.Status != domain.RegradePending {
+		return nil, ErrRegradeResolved
+	}
+
+	note := strings.TrimSpace(input.Note)
+	var grades *repository.GradeWrite
+	if input.Accept && input.Score != nil {
+		submission, items, err := s.submissions.GetByID(ctx, request.SubmissionID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrSubmissionNotFound
+			}
+			return nil, err
+		}
+		reason := "regrade request " + request.ID
+		if note != "" {
+			reason += ": " + note
+		}
+		grades, _, err = prepareGrades(teacherID, assignment, questions, submission, items, GradeSubmissionInput{
+			AssignmentID: input.AssignmentID,
+			SubmissionID: submission.ID,
+			ItemScores:   map[string]*float64{request.ItemID: input.Score},
+			Reason:       reason,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	request.Status = domain.RegradeRejected
+	if input.Accept {
+		request.Status = domain.RegradeAccepted
+	}
+	request.ResolverID = &teacherID
+	request.ResolutionNote = note
+	request.ResolvedAt = &now
+	request.UpdatedAt = now
+	// The status flips only while the request is still pending, so of two
+	// concurrent decisions only one writes grades and history.
+	resolved, err := s.regrades.Resolve(ctx, request, grades)
+	if err != nil {
+		return nil, err
+	}
+	if !resolved {
+		return nil, ErrRegradeResolved
+	}
+	if grades != nil {
+		s.statsCache.invalidate(assignment.ID)
+	}
+
+	if note != "" {
+		if _, err := s.addComment(ctx, request.SubmissionID, teacherID, domain.RoleTeacher, note); err != nil {
+			return nil, err
+		}
+	}
+	return request, nil
+}
+
+// loadReviewableSubmission loads a submission the account may review.
+func (s *AssignmentService) loadReviewableSubmission(ctx context.Context, accountID string, role domain.Role, assignmentID, submissionID string) (*domain.AssignmentSubmission, error) {
+	if accountID == "" || assignmentID == "" || submissionID == "" {
+		return nil, errors.New("account, assignment and submission required")
+	}
+
+	assignment, _, err := s.GetAssignment(ctx, assignmentID)
+	if err != nil {
+		return nil, err
+	}
+	if role != domain.RoleAdmin && assignment.TeacherID != accountID {
+		return nil, ErrSubmissionForbidden
+	}
+
+	submission, _, err := s.submissions.GetByID(ctx, submissionID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSubmissionNotFound
+		}
+		return nil, err
+	}
+	if submission.AssignmentID != assignmentID {
+		return nil, ErrSubmissionForbidden
+	}
+	return submission, nil
+}
@@ -0,0 +1,390 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"learn-go/internal/domain"
+	"learn-go/internal/repository"
+	"learn-go/pkg/similarity"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// similarityStoreThreshold is the lowest score worth keeping a pair for.
+	similarityStoreThreshold = 0.3
+	// DefaultSimilarityThreshold is the score at which a pair is reported as suspicious.
+	DefaultSimilarityThreshold = 0.5
+	// similarityMinShingles skips essay answers too short to judge, which
+	// would otherwise match trivially. Fill answers are compared whole instead.
+	similarityMinShingles = 10
+)
+
+// ErrSimilarityMatchNotFound indicates the pair does not exist in the assignment.
+var ErrSimilarityMatchNotFound = errors.New("similarity match not found")
+
+// SimilarityService finds essay and fill answers that look copied between students.
+type SimilarityService struct {
+	assignments repository.AssignmentRepository
+	submissions repository.SubmissionRepository
+	similarity  repository.SimilarityRepository
+}
+
+// NewSimilarityService constructs a SimilarityService.
+func NewSimilarityService(assignments repository.AssignmentRepository, submissions repository.SubmissionRepository, similarity repository.SimilarityRepository) *SimilarityService {
+	return &SimilarityService{assignments: assignments, submissions: submissions, similarity: similarity}
+}
+
+// SimilarityReport lists suspicious pairs of an assignment.
+type SimilarityReport struct {
+	AssignmentID string
+	Threshold    float64
+	Matches      []domain.SimilarityMatch
+	// Pending counts submissions not analyzed since their last change.
+	Pending int64
+}
+
+// SimilarityMatchDetail is a pair with both answers and highlighted overlaps.
+type SimilarityMatchDetail struct {
+	Match    domain.SimilarityMatch
+	Question *domain.AssignmentQuestion
+	AnswerA  string
+	AnswerB  string
+	SpansA   []similarity.Span
+	SpansB   []similarity.Span
+}
+
+// AnalyzePending fingerprints submissions that changed since their last check
+// and compares them against the rest of their assignment. It returns how many
+// submissions were analyzed.
+func (s *SimilarityService) AnalyzePending(ctx context.Context, limit int) (int, error) {
+	ids, err := s.similarity.ListStaleSubmissions(ctx, "", limit)
+	if err != nil {
+		return 0, err
+	}
+	run := make(map[string]*answerIndex)
+	for i, id := range ids {
+		if err := s.analyzeSubmission(ctx, run, id); err != nil {
+			return i, err
+		}
+	}
+	return len(ids), nil
+}
+
+// answerIndex holds an assignment's comparable questions and an LSH index of
+// its stored fingerprints per question. AnalyzePending loads it once per
+// assignment and keeps it current as submissions are re-fingerprinted.
+type answerIndex struct {
+	questions  map[string]domain.AssignmentQuestion
+	byQuestion map[string]*similarity.Index
+	students   map[string]string
+}
+
+// loadIndex returns the run's index for an assignment, loading it on first
+// use. It returns nil if the assignment no longer exists.
+func (s *SimilarityService) loadIndex(ctx context.Context, run map[string]*answerIndex, assignmentID string) (*answerIndex, error) {
+	if idx, ok := run[assignmentID]; ok {
+		return idx, nil
+	}
+	_, questions, err := s.assignments.Get(ctx, assignmentID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			run[assignmentID] = nil
+			return nil, nil
+		}
+		return nil, err
+	}
+	all, err := s.similarity.ListFingerprints(ctx, assignmentID)
+	if err != nil {
+		return nil, err
+	}
+	idx := &answerIndex{
+		questions:  comparableQuestions(questions),
+		byQuestion: make(map[string]*similarity.Index),
+		students:   make(map[string]string),
+	}
+	for _, fp := range all {
+		sig, err := similarity.DecodeSignature(fp.Signature)
+		if err != nil {
+			continue
+		}
+		idx.add(fp, sig)
+	}
+	run[assignmentID] = idx
+	return idx, nil
+}
+
+func (idx *answerIndex) add(fp domain.AnswerFingerprint, sig similarity.Signature) {
+	questionIndex := idx.byQuestion[fp.QuestionID]
+	if questionIndex == nil {
+		questionIndex = similarity.NewIndex()
+		idx.byQuestion[fp.QuestionID] = questionIndex
+	}
+	questionIndex.Add(fp.SubmissionID, sig)
+	idx.students[fp.SubmissionID] = fp.StudentID
+}
+
+func (idx *answerIndex) remove(submissionID string) {
+	for _, questionIndex := range idx.byQuestion {
+		questionIndex.Remove(submissionID)
+	}
+	delete(idx.students, submissionID)
+}
+
+// analyzeSubmission refreshes one submission's fingerprints and its pairs.
+func (s *SimilarityService) analyzeSubmission(ctx context.Context, run map[string]*answerIndex, submissionID string) error {
+	submission, items, err := s.submissions.GetByID(ctx, submissionID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	idx, err := s.loadIndex(ctx, run, submission.AssignmentID)
+	if err != nil || idx == nil {
+		return err
+	}
+
+	now := time.Now()
+	fingerprints := make([]domain.AnswerFingerprint, 0, len(items))
+	signatures := make([]similarity.Signature, 0, len(items))
+	for _, item := range items {
+		question, ok := idx.questions[item.QuestionID]
+		if !ok {
+			continue
+		}
+		set := similarity.Set(answerShingles(question, item.Answer))
+		if len(set) == 0 || (question.Type == domain.QuestionEssay && len(set) < similarityMinShingles) {
+			continue
+		}
+		sig := similarity.MinHash(set)
+		fingerprints = append(fingerprints, domain.AnswerFingerprint{
+			ID:           uuid.NewString(),
+			AssignmentID: submission.AssignmentID,
+			QuestionID:   item.QuestionID,
+			SubmissionID: submission.ID,
+			StudentID:    submission.StudentID,
+			Signature:    sig.Encode(),
+			ShingleCount: len(set),
+			CreatedAt:    now,
+		})
+		signatures = append(signatures, sig)
+	}
+	if err := s.similarity.ReplaceFingerprints(ctx, submission.ID, fingerprints); err != nil {
+		return err
+	}
+	idx.remove(submission.ID)
+	for i, fp := range fingerprints {
+		idx.add(fp, signatures[i])
+	}
+
+	matches, err := s.compareSubmission(ctx, idx, submission, items, fingerprints, signatures)
+	if err != nil {
+		return err
+	}
+	if err := s.similarity.ReplaceMatches(ctx, submission.ID, matches); err != nil {
+		return err
+	}
+	return s.similarity.MarkChecked(ctx, &domain.SimilarityCheck{
+		SubmissionID:        submission.ID,
+		AssignmentID:        submission.AssignmentID,
+		SubmissionUpdatedAt: submission.UpdatedAt,
+		CheckedAt:           now,
+	})
+}
+
+// compareSubmission finds LSH candidates for the submission's fingerprints
+// among the assignment's other answers and scores them exactly.
+func (s *SimilarityService) compareSubmission(ctx context.Context, idx *answerIndex, submission *domain.AssignmentSubmission, items []domain.SubmissionItem, own []domain.AnswerFingerprint, signatures []similarity.Signature) ([]domain.SimilarityMatch, error) {
+	type candidate struct{ questionID, other string }
+	var candidates []candidate
+	otherIDs := make([]string, 0)
+	for i, fp := range own {
+		for _, other := range idx.byQuestion[fp.QuestionID].Candidates(signatures[i], submission.ID) {
+			candidates = append(candidates, candidate{questionID: fp.QuestionID, other: other})
+			otherIDs = append(otherIDs, other)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+
+	otherItems, err := s.submissions.ListItemsBySubmissionIDs(ctx, uniqueStrings(otherIDs))
+	if err != nil {
+		return nil, err
+	}
+	answers := make(map[[2]string]string, len(otherItems)+len(items))
+	for _, item := range append(otherItems, items...) {
+		answers[[2]string{item.SubmissionID, item.QuestionID}] = item.Answer
+	}
+
+	now := time.Now()
+	matches := make([]domain.SimilarityMatch, 0, len(candidates))
+	for _, cand := range candidates {
+		question := idx.questions[cand.questionID]
+		a, b := submission.ID, cand.other
+		if a > b {
+			a, b = b, a
+		}
+		shA := answerShingles(question, answers[[2]string{a, cand.questionID}])
+		shB := answerShingles(question, answers[[2]string{b, cand.questionID}])
+		score := similarity.Jaccard(similarity.Set(shA), similarity.Set(shB))
+		if score < similarityStoreThreshold {
+			continue
+		}
+		spansA, spansB := similarity.Overlaps(shA, shB)
+		matches = append(matches, domain.SimilarityMatch{
+			ID:            uuid.NewString(),
+			AssignmentID:  submission.AssignmentID,
+			QuestionID:    cand.questionID,
+			SubmissionAID: a,
+			SubmissionBID: b,
+			StudentAID:    idx.students[a],
+			StudentBID:    idx.students[b],
+			Score:         score,
+			SpansA:        encodeSpans(spansA),
+			SpansB:        encodeSpans(spansB),
+			CreatedAt:     now,
+		})
+	}
+	return matches, nil
+}
+
+// GetSimilarityReport lists pairs at or above minScore (DefaultSimilarityThreshold when zero).
+func (s *SimilarityService) GetSimilarityReport(ctx context.Context, accountID string, role domain.Role, assignmentID string, minScore float64) (*SimilarityReport, error) {
+	if _, _, err := s.loadOwnedAssignment(ctx, accountID, role, assignmentID); err != nil {
+		return nil, err
+	}
+	if minScore <= 0 {
+		minScore = DefaultSimilarityThreshold
+	}
+	matches, err := s.similarity.ListMatches(ctx, assignmentID, minScore)
+	if err != nil {
+		return nil, err
+	}
+	pending, err := s.similarity.CountStale(ctx, assignmentID)
+	if err != nil {
+		return nil, err
+	}
+	return &SimilarityReport{AssignmentID: assignmentID, Threshold: minScore, Matches: matches, Pending: pending}, nil
+}
+
+// GetSimilarityMatch returns a pair with both answers and the overlapping spans.
+func (s *SimilarityService) GetSimilarityMatch(ctx context.Context, accountID string, role domain.Role, assignmentID, matchID string) (*SimilarityMatchDetail, error) {
+	_, questions, err := s.loadOwnedAssignment(ctx, accountID, role, assignmentID)
+	if err != nil {
+		return nil, err
+	}
+	match, err := s.similarity.GetMatch(ctx, matchID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSimilarityMatchNotFound
+		}
+		return nil, err
+	}
+	if match.AssignmentID != assignmentID {
+		return nil, ErrSimilarityMatchNotFound
+	}
+
+	items, err := s.submissions.ListItemsBySubmissionIDs(ctx, []string{match.SubmissionAID, match.SubmissionBID})
+	if err != nil {
+		return nil, err
+	}
+	detail := &SimilarityMatchDetail{Match: *match}
+	for i := range questions {
+		if questions[i].ID == match.QuestionID {
+			detail.Question = &questions[i]
+		}
+	}
+	for _, item := range items {
+		if item.QuestionID != match.QuestionID {
+			continue
+		}
+		switch item.SubmissionID {
+		case match.SubmissionAID:
+			detail.AnswerA = item.Answer
+		case match.SubmissionBID:
+			detail.AnswerB = item.Answer
+		}
+	}
+	_ = json.Unmarshal([]byte(match.SpansA), &detail.SpansA)
+	_ = json.Unmarshal([]byte(match.SpansB), &detail.SpansB)
+	return detail, nil
+}
+
+// RescanAssignment discards the analysis state of an assignment so the next
+// run compares every submission again, e.g. after tuning answers or questions.
+func (s *SimilarityService) RescanAssignment(ctx context.Context, accountID string, role domain.Role, assignmentID string) (int64, error) {
+	if _, _, err := s.loadOwnedAssignment(ctx, accountID, role, assignmentID); err != nil {
+		return 0, err
+	}
+	if err := s.similarity.ResetChecks(ctx, assignmentID); err != nil {
+		return 0, err
+	}
+	return s.similarity.CountStale(ctx, assignmentID)
+}
+
+func (s *SimilarityService) loadOwnedAssignment(ctx context.Context, accountID string, role domain.Role, assignmentID string) (*domain.Assignment, []domain.AssignmentQuestion, error) {
+	if accountID == "" || assignmentID == "" {
+		return nil, nil, errors.New("account and assignment required")
+	}
+	assignment, questions, err := s.assignments.Get(ctx, assignmentID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrAssignmentNotFound
+		}
+		return nil, nil, err
+	}
+	if role != domain.RoleAdmin && assignment.TeacherID != accountID {
+		return nil, nil, ErrAssignmentForbidden
+	}
+	return assignment, questions, nil
+}
+
+// comparableQuestions keeps the free-text questions worth comparing.
+func comparableQuestions(questions []domain.AssignmentQuestion) map[string]domain.AssignmentQuestion {
+	result := make(map[string]domain.AssignmentQuestion)
+	for _, q := range questions {
+		if q.Type == domain.QuestionEssay || q.Type == domain.QuestionFill {
+			result[q.ID] = q
+		}
+	}
+	return result
+}
+
+// answerShingles shingles an answer for comparison. Essay answers drop text
+// shared with the prompt or reference answer so quoting the question does not
+// count as copying. A fill answer is one shingle over the normalized text, so
+// two fill answers match only when identical; the reference answer yields
+// none, since students who are right are expected to agree.
+func answerShingles(question domain.AssignmentQuestion, answer string) []similarity.Shingle {
+	if question.Type == domain.QuestionFill {
+		whole := similarity.Whole(similarity.Tokenize(answer))
+		reference := similarity.Whole(similarity.Tokenize(question.Answer))
+		if len(whole) > 0 && len(reference) > 0 && whole[0].Hash == reference[0].Hash {
+			return nil
+		}
+		return whole
+	}
+	common := similarity.Set(similarity.Shingles(similarity.Tokenize(question.Prompt)))
+	for h := range similarity.Set(similarity.Shingles(similarity.Tokenize(question.Answer))) {
+		common[h] = struct{}{}
+	}
+	return similarity.Exclude(similarity.Shingles(similarity.Tokenize(answer)), common)
+}
+
+func encodeSpans(spans []similarity.Span) string {
+	if len(spans) == 0 {
+		return "[]"
+	}
+	data, err := json.Marshal(spans)
+	if err != nil {
+		return "[]"
+	}
+	return string(data)
+}
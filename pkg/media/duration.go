@@ -0,0 +1,147 @@
+package media
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+)
+
+// ErrUnsupportedContainer indicates Duration does not understand the format.
+var ErrUnsupportedContainer = errors.New("media: unsupported container")
+
+// maxBoxDepth bounds recursion into nested MP4 boxes.
+const maxBoxDepth = 4
+
+// Duration extracts the playback duration of an audio or video file. MP4
+// family containers (mp4, m4a, mov, 3gp) and RIFF WAVE are supported.
+func Duration(r io.ReadSeeker, contentType string) (time.Duration, error) {
+	switch contentType {
+	case "video/mp4", "audio/mp4", "video/quicktime", "video/3gpp":
+		return mp4Duration(r)
+	case "audio/wave", "audio/wav", "audio/x-wav":
+		return wavDuration(r)
+	default:
+		return 0, ErrUnsupportedContainer
+	}
+}
+
+// mp4Duration walks the box tree to moov/mvhd and divides duration by timescale.
+func mp4Duration(r io.ReadSeeker) (time.Duration, error) {
+	end, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+	return findMvhd(r, 0, end, 0)
+}
+
+func findMvhd(r io.ReadSeeker, start, end int64, depth int) (time.Duration, error) {
+	for offset := start; offset+8 <= end; {
+		if _, err := r.Seek(offset, io.SeekStart); err != nil {
+			return 0, err
+		}
+		var header [16]byte
+		if _, err := io.ReadFull(r, header[:8]); err != nil {
+			return 0, err
+		}
+		size := int64(binary.BigEndian.Uint32(header[:4]))
+		boxType := string(header[4:8])
+		headerLen := int64(8)
+		switch size {
+		case 0:
+			size = end - offset
+		case 1:
+			if _, err := io.ReadFull(r, header[8:16]); err != nil {
+				return 0, err
+			}
+			size = int64(binary.BigEndian.Uint64(header[8:16]))
+			headerLen = 16
+		}
+		if size < headerLen || offset+size > end {
+			return 0, ErrUnsupportedContainer
+		}
+
+		switch boxType {
+		case "moov":
+			if depth < maxBoxDepth {
+				return findMvhd(r, offset+headerLen, offset+size, depth+1)
+			}
+		case "mvhd":
+			return readMvhd(r)
+		}
+		offset += size
+	}
+	return 0, ErrUnsupportedContainer
+}
+
+func readMvhd(r io.Reader) (time.Duration, error) {
+	var version [4]byte
+	if _, err := io.ReadFull(r, version[:]); err != nil {
+		return 0, err
+	}
+	var timescale uint32
+	var duration uint64
+	if version[0] == 1 {
+		var body [28]byte // created(8) modified(8) timescale(4) duration(8)
+		if _, err := io.ReadFull(r, body[:]); err != nil {
+			return 0, err
+		}
+		timescale = binary.BigEndian.Uint32(body[16:20])
+		duration = binary.BigEndian.Uint64(body[20:28])
+	} else {
+		var body [16]byte // created(4) modified(4) timescale(4) duration(4)
+		if _, err := io.ReadFull(r, body[:]); err != nil {
+			return 0, err
+		}
+		timescale = binary.BigEndian.Uint32(body[8:12])
+		duration = uint64(binary.BigEndian.Uint32(body[12:16]))
+	}
+	if timescale == 0 {
+		return 0, ErrUnsupportedContainer
+	}
+	return time.Duration(duration) * time.Second / time.Duration(timescale), nil
+}
+
+// wavDuration reads the fmt chunk's byte rate and the data chunk's size.
+func wavDuration(r io.ReadSeeker) (time.Duration, error) {
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	var riff [12]byte
+	if _, err := io.ReadFull(r, riff[:]); err != nil {
+		return 0, err
+	}
+	if string(riff[0:4]) != "RIFF" || string(riff[8:12]) != "WAVE" {
+		return 0, ErrUnsupportedContainer
+	}
+
+	var byteRate uint32
+	for {
+		var chunk [8]byte
+		if _, err := io.ReadFull(r, chunk[:]); err != nil {
+			return 0, ErrUnsupportedContainer
+		}
+		size := int64(binary.LittleEndian.Uint32(chunk[4:8]))
+		switch string(chunk[0:4]) {
+		case "fmt ":
+			var fmtChunk [12]byte // format(2) channels(2) sampleRate(4) byteRate(4)
+			if size < int64(len(fmtChunk)) {
+				return 0, ErrUnsupportedContainer
+			}
+			if _, err := io.ReadFull(r, fmtChunk[:]); err != nil {
+				return 0, err
+			}
+			byteRate = binary.LittleEndian.Uint32(fmtChunk[8:12])
+			size -= int64(len(fmtChunk))
+		case "data":
+			if byteRate == 0 {
+				return 0, ErrUnsupportedContainer
+			}
+			return time.Duration(size) * time.Second / time.Duration(byteRate), nil
+		}
+		// Chunks are padded to an even length.
+		if _, err := r.Seek(size+size%2, io.SeekCurrent); err != nil {
+			return 0, err
+		}
+	}
+}
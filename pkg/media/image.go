@@ -0,0 +1,97 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+
+	// Register the decoders the standard library ships with.
+	_ "image/gif"
+	_ "image/png"
+)
+
+// MaxImagePixels guards against decompression bombs: images with more pixels
+// than this still report dimensions but are not decoded for thumbnails.
+const MaxImagePixels = 40_000_000
+
+// ErrImageTooLarge indicates an image whose pixel count exceeds MaxImagePixels.
+var ErrImageTooLarge = errors.New("media: image too large to decode")
+
+// ImageConfig returns the dimensions and format of an encoded image without
+// decoding the pixels.
+func ImageConfig(r io.Reader) (width, height int, format string, err error) {
+	cfg, format, err := image.DecodeConfig(r)
+	if err != nil {
+		return 0, 0, "", err
+	}
+	return cfg.Width, cfg.Height, format, nil
+}
+
+// Thumbnail decodes an image and returns a JPEG no larger than maxSide on its
+// longest edge, together with the thumbnail's dimensions. Images already small
+// enough are re-encoded at their original size.
+func Thumbnail(data []byte, maxSide int) ([]byte, int, int, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	if cfg.Width*cfg.Height > MaxImagePixels {
+		return nil, 0, 0, ErrImageTooLarge
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	width, height := fitWithin(src.Bounds().Dx(), src.Bounds().Dy(), maxSide)
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	scaleBox(dst, src)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 80}); err != nil {
+		return nil, 0, 0, err
+	}
+	return buf.Bytes(), width, height, nil
+}
+
+func fitWithin(width, height, maxSide int) (int, int) {
+	if maxSide <= 0 || (width <= maxSide && height <= maxSide) {
+		return width, height
+	}
+	if width >= height {
+		return maxSide, max(1, height*maxSide/width)
+	}
+	return max(1, width*maxSide/height), maxSide
+}
+
+// scaleBox downsamples src into dst by averaging the source pixels that fall
+// into each destination pixel. JPEG has no alpha, so transparent areas are
+// flattened onto white.
+func scaleBox(dst *image.RGBA, src image.Image) {
+	sb := src.Bounds()
+	db := dst.Bounds()
+	for y := 0; y < db.Dy(); y++ {
+		y0 := sb.Min.Y + y*sb.Dy()/db.Dy()
+		y1 := max(y0+1, sb.Min.Y+(y+1)*sb.Dy()/db.Dy())
+		for x := 0; x < db.Dx(); x++ {
+			x0 := sb.Min.X + x*sb.Dx()/db.Dx()
+			x1 := max(x0+1, sb.Min.X+(x+1)*sb.Dx()/db.Dx())
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(cr), g+uint64(cg), b+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			// Colors are alpha-premultiplied, so adding the uncovered
+			// fraction of white composites the average over white.
+			bg := 0xffff - a/n
+			dst.SetRGBA64(x, y, color.RGBA64{R: uint16(r/n + bg), G: uint16(g/n + bg), B: uint16(b/n + bg), A: 0xffff})
+		}
+	}
+}
@@ -0,0 +1,95 @@
+// Package media inspects uploaded chat media: content sniffing, image
+// dimensions and thumbnails, and audio/video durations.
+package media
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+)
+
+// SniffLen is the number of leading bytes Sniff looks at.
+const SniffLen = 512
+
+// Kind groups content types into the message kinds the chat understands.
+type Kind string
+
+const (
+	KindImage Kind = "image"
+	KindVideo Kind = "video"
+	KindAudio Kind = "audio"
+	KindFile  Kind = "file"
+)
+
+// Sniff detects the content type from the first bytes of a file. It extends
+// http.DetectContentType with the ISO-BMFF brands phones commonly produce
+// (HEIC photos, M4A voice notes, QuickTime video) and AMR voice messages.
+func Sniff(head []byte) string {
+	if len(head) > SniffLen {
+		head = head[:SniffLen]
+	}
+	if bytes.HasPrefix(head, []byte("#!AMR")) {
+		return "audio/amr"
+	}
+	if brand, ok := ftypBrand(head); ok {
+		switch brand {
+		case "heic", "heix", "heim", "heis", "mif1", "msf1":
+			return "image/heic"
+		case "avif", "avis":
+			return "image/avif"
+		case "M4A ", "M4B ":
+			return "audio/mp4"
+		case "qt  ":
+			return "video/quicktime"
+		case "3gp4", "3gp5", "3gp6", "3g2a":
+			return "video/3gpp"
+		default:
+			return "video/mp4"
+		}
+	}
+	contentType := http.DetectContentType(head)
+	if i := strings.IndexByte(contentType, ';'); i >= 0 {
+		contentType = contentType[:i]
+	}
+	if contentType == "application/ogg" {
+		// Ogg is overwhelmingly Opus/Vorbis voice notes in chat.
+		return "audio/ogg"
+	}
+	return contentType
+}
+
+// KindOf maps a content type to a message kind.
+func KindOf(contentType string) Kind {
+	switch {
+	case strings.HasPrefix(contentType, "image/"):
+		return KindImage
+	case strings.HasPrefix(contentType, "video/"):
+		return KindVideo
+	case strings.HasPrefix(contentType, "audio/"):
+		return KindAudio
+	default:
+		return KindFile
+	}
+}
+
+// Compatible reports whether sniffed content can be sent as the given kind.
+// Files accept anything; an MP4 container also passes as audio because many
+// recorders write voice notes with a generic brand.
+func Compatible(kind Kind, sniffed string) bool {
+	actual := KindOf(sniffed)
+	switch kind {
+	case KindFile:
+		return true
+	case KindAudio:
+		return actual == KindAudio || sniffed == "video/mp4" || sniffed == "video/3gpp"
+	default:
+		return actual == kind
+	}
+}
+
+func ftypBrand(head []byte) (string, bool) {
+	if len(head) < 12 || string(head[4:8]) != "ftyp" {
+		return "", false
+	}
+	return string(head[8:12]), true
+}
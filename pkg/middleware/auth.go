@@ -28,14 +28,12 @@ func JWTAuth(cfg AuthConfig) gin.HandlerFunc {
 	}
 
 	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" || !strings.HasPrefix(strings.ToLower(authHeader), "bearer ") {
+		tokenString := bearerToken(c)
+		if tokenString == "" {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"success": false, "error": gin.H{"message": "missing authorization"}})
 			return
 		}
 
-		tokenString := strings.TrimSpace(authHeader[7:])
-
 		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 				return nil, jwt.ErrTokenSignatureInvalid
@@ -73,3 +71,17 @@ func JWTAuth(cfg AuthConfig) gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// bearerToken reads the token from the Authorization header. Browsers cannot
+// set headers on websocket handshakes, so upgrade requests may pass it in the
+// access_token query parameter instead.
+func bearerToken(c *gin.Context) string {
+	authHeader := c.GetHeader("Authorization")
+	if strings.HasPrefix(strings.ToLower(authHeader), "bearer ") {
+		return strings.TrimSpace(authHeader[7:])
+	}
+	if authHeader == "" && strings.EqualFold(c.GetHeader("Upgrade"), "websocket") {
+		return c.Query("access_token")
+	}
+	return ""
+}
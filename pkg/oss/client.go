@@ -1,32 +1,44 @@
 package oss
 
-import "context"
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
 
-// Client defines minimal interface for interacting with Aliyun OSS.
-type Client interface {
-	GenerateUploadCredentials(ctx context.Context, objectKey string) (*UploadCredentials, error)
-}
+// ErrObjectNotFound indicates the requested object does not exist.
+var ErrObjectNotFound = errors.New("object not found")
 
-// UploadCredentials represent temporary upload data for clients.
-type UploadCredentials struct {
-	Endpoint        string `json:"endpoint"`
-	Bucket          string `json:"bucket"`
-	AccessKeyID     string `json:"access_key_id"`
-	AccessKeySecret string `json:"access_key_secret"`
-	SecurityToken   string `json:"security_token"`
-	ExpireAt        int64  `json:"expire_at"`
+// Client is a pluggable object store. LocalStorage keeps objects on disk and
+// serves signed URLs through the application; S3Storage talks to any
+// S3-compatible service such as Aliyun OSS or MinIO.
+type Client interface {
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, *ObjectInfo, error)
+	Head(ctx context.Context, key string) (*ObjectInfo, error)
+	Delete(ctx context.Context, key string) error
+	// PresignUpload returns a URL the client can PUT the object to directly.
+	// A positive size is signed into the URL, so the upload must be exactly
+	// that many bytes.
+	PresignUpload(ctx context.Context, key, contentType string, size int64, ttl time.Duration) (*UploadCredentials, error)
+	// PresignDownload returns a time-limited GET URL for the object.
+	PresignDownload(ctx context.Context, key string, ttl time.Duration) (string, error)
 }
 
-// StaticClient returns preconfigured credentials (placeholder for integration).
-type StaticClient struct {
-	Endpoint string
-	Bucket   string
+// ObjectInfo describes a stored object.
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	ContentType  string
+	ETag         string
+	LastModified time.Time
 }
 
-// GenerateUploadCredentials returns static credentials. Replace with real STS integration.
-func (c *StaticClient) GenerateUploadCredentials(ctx context.Context, objectKey string) (*UploadCredentials, error) {
-	return &UploadCredentials{
-		Endpoint: c.Endpoint,
-		Bucket:   c.Bucket,
-	}, nil
+// UploadCredentials tell a client how to upload an object directly to storage.
+type UploadCredentials struct {
+	Method   string            `json:"method"`
+	URL      string            `json:"url"`
+	Headers  map[string]string `json:"headers"`
+	ExpireAt int64             `json:"expire_at"`
 }
@@ -0,0 +1,333 @@
+package oss
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// localMetaDir holds per-object metadata next to the objects themselves.
+const localMetaDir = ".meta"
+
+var (
+	errInvalidSignature = errors.New("invalid or expired signature")
+	errObjectExists     = errors.New("object already exists")
+)
+
+// LocalStorage stores objects on the local filesystem. Presigned URLs point at
+// the application's own HTTP server, which verifies them via ServeHTTP.
+type LocalStorage struct {
+	root    string
+	baseURL string
+	secret  []byte
+}
+
+type localMeta struct {
+	ContentType string `json:"content_type"`
+}
+
+// NewLocalStorage creates a filesystem store rooted at root. baseURL is the
+// public URL under which ServeHTTP is mounted, e.g. http://localhost:8080/files.
+func NewLocalStorage(root, baseURL string, secret []byte) (*LocalStorage, error) {
+	if len(secret) == 0 {
+		return nil, errors.New("oss: local storage requires a signing secret")
+	}
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(abs, 0o755); err != nil {
+		return nil, err
+	}
+	return &LocalStorage{root: abs, baseURL: strings.TrimRight(baseURL, "/"), secret: secret}, nil
+}
+
+// Put writes the object atomically, replacing any existing version.
+func (s *LocalStorage) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	return s.put(key, body, size, contentType, true)
+}
+
+// put writes the object through a temporary file. Without overwrite the file
+// is hard-linked into place, so an existing object is never replaced even by
+// concurrent uploads.
+func (s *LocalStorage) put(key string, body io.Reader, size int64, contentType string, overwrite bool) error {
+	path, err := s.objectPath(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	written, err := io.Copy(tmp, body)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if size >= 0 && written != size {
+		return fmt.Errorf("oss: expected %d bytes, got %d", size, written)
+	}
+	if !overwrite {
+		if err := os.Link(tmp.Name(), path); err != nil {
+			if errors.Is(err, os.ErrExist) {
+				return errObjectExists
+			}
+			return err
+		}
+		return s.writeMeta(key, localMeta{ContentType: contentType})
+	}
+	if err := s.writeMeta(key, localMeta{ContentType: contentType}); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Get opens the object for reading. The caller must close the reader.
+func (s *LocalStorage) Get(ctx context.Context, key string) (io.ReadCloser, *ObjectInfo, error) {
+	path, err := s.objectPath(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil, ErrObjectNotFound
+		}
+		return nil, nil, err
+	}
+	info, err := s.info(key, file)
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	return file, info, nil
+}
+
+// Head returns object metadata without opening the content.
+func (s *LocalStorage) Head(ctx context.Context, key string) (*ObjectInfo, error) {
+	path, err := s.objectPath(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrObjectNotFound
+		}
+		return nil, err
+	}
+	defer file.Close()
+	return s.info(key, file)
+}
+
+// Delete removes the object. Deleting a missing object is not an error.
+func (s *LocalStorage) Delete(ctx context.Context, key string) error {
+	path, err := s.objectPath(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err := os.Remove(s.metaPath(path)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// PresignUpload returns a signed PUT URL served by ServeHTTP. The URL only
+// creates the object; it cannot replace one that already exists.
+func (s *LocalStorage) PresignUpload(ctx context.Context, key, contentType string, size int64, ttl time.Duration) (*UploadCredentials, error) {
+	if _, err := s.objectPath(key); err != nil {
+		return nil, err
+	}
+	expires := time.Now().Add(ttl).Unix()
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expires, 10))
+	if contentType != "" {
+		query.Set("content_type", contentType)
+	}
+	if size > 0 {
+		query.Set("size", strconv.FormatInt(size, 10))
+	}
+	query.Set("signature", s.sign(http.MethodPut, key, expires, contentType, query.Get("size")))
+
+	headers := map[string]string{}
+	if contentType != "" {
+		headers["Content-Type"] = contentType
+	}
+	return &UploadCredentials{
+		Method:   http.MethodPut,
+		URL:      s.objectURL(key, query),
+		Headers:  headers,
+		ExpireAt: expires,
+	}, nil
+}
+
+// PresignDownload returns a signed GET URL served by ServeHTTP.
+func (s *LocalStorage) PresignDownload(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	if _, err := s.objectPath(key); err != nil {
+		return "", err
+	}
+	expires := time.Now().Add(ttl).Unix()
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expires, 10))
+	query.Set("signature", s.sign(http.MethodGet, key, expires, "", ""))
+	return s.objectURL(key, query), nil
+}
+
+// ServeHTTP serves presigned GET, HEAD and PUT requests. Mount it with the
+// URL prefix stripped so the request path is the object key.
+func (s *LocalStorage) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, "/")
+	query := r.URL.Query()
+
+	method := r.Method
+	if method == http.MethodHead {
+		method = http.MethodGet
+	}
+	if method != http.MethodGet && method != http.MethodPut {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := s.verify(method, key, query); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	if method == http.MethodPut {
+		contentType := query.Get("content_type")
+		if contentType != "" && r.Header.Get("Content-Type") != contentType {
+			http.Error(w, "content type does not match signature", http.StatusBadRequest)
+			return
+		}
+		if r.ContentLength < 0 {
+			http.Error(w, "content length required", http.StatusLengthRequired)
+			return
+		}
+		if size := query.Get("size"); size != "" && strconv.FormatInt(r.ContentLength, 10) != size {
+			http.Error(w, "content length does not match signature", http.StatusBadRequest)
+			return
+		}
+		body := http.MaxBytesReader(w, r.Body, r.ContentLength)
+		if err := s.put(key, body, r.ContentLength, r.Header.Get("Content-Type"), false); err != nil {
+			if errors.Is(err, errObjectExists) {
+				http.Error(w, err.Error(), http.StatusConflict)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	body, info, err := s.Get(r.Context(), key)
+	if err != nil {
+		if errors.Is(err, ErrObjectNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer body.Close()
+
+	if info.ContentType != "" {
+		w.Header().Set("Content-Type", info.ContentType)
+	}
+	w.Header().Set("ETag", info.ETag)
+	http.ServeContent(w, r, filepath.Base(key), info.LastModified, body.(io.ReadSeeker))
+}
+
+func (s *LocalStorage) verify(method, key string, query url.Values) error {
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return errInvalidSignature
+	}
+	expected := s.sign(method, key, expires, query.Get("content_type"), query.Get("size"))
+	if !hmac.Equal([]byte(expected), []byte(query.Get("signature"))) {
+		return errInvalidSignature
+	}
+	return nil
+}
+
+func (s *LocalStorage) sign(method, key string, expires int64, contentType, size string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	fmt.Fprintf(mac, "%s\n%s\n%d\n%s\n%s", method, key, expires, contentType, size)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *LocalStorage) objectURL(key string, query url.Values) string {
+	return s.baseURL + (&url.URL{Path: "/" + key}).EscapedPath() + "?" + query.Encode()
+}
+
+// objectPath maps a key to a path under root, rejecting traversal and the metadata directory.
+func (s *LocalStorage) objectPath(key string) (string, error) {
+	clean := filepath.ToSlash(filepath.Clean("/" + key))[1:]
+	if key == "" || clean != key || clean == localMetaDir || strings.HasPrefix(clean, localMetaDir+"/") {
+		return "", fmt.Errorf("oss: invalid object key %q", key)
+	}
+	return filepath.Join(s.root, filepath.FromSlash(clean)), nil
+}
+
+func (s *LocalStorage) metaPath(objectPath string) string {
+	rel, _ := filepath.Rel(s.root, objectPath)
+	return filepath.Join(s.root, localMetaDir, rel+".json")
+}
+
+func (s *LocalStorage) writeMeta(key string, meta localMeta) error {
+	path, err := s.objectPath(key)
+	if err != nil {
+		return err
+	}
+	metaPath := s.metaPath(path)
+	if err := os.MkdirAll(filepath.Dir(metaPath), 0o755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(metaPath, data, 0o644)
+}
+
+func (s *LocalStorage) info(key string, file *os.File) (*ObjectInfo, error) {
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	var meta localMeta
+	if data, err := os.ReadFile(s.metaPath(file.Name())); err == nil {
+		_ = json.Unmarshal(data, &meta)
+	}
+	return &ObjectInfo{
+		Key:          key,
+		Size:         stat.Size(),
+		ContentType:  meta.ContentType,
+		ETag:         fmt.Sprintf("\"%x-%x\"", stat.ModTime().UnixNano(), stat.Size()),
+		LastModified: stat.ModTime(),
+	}, nil
+}
+
+var _ Client = (*LocalStorage)(nil)
@@ -0,0 +1,167 @@
+package oss
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestLocalStorage(t *testing.T) (*LocalStorage, *httptest.Server) {
+	t.Helper()
+	storage, err := NewLocalStorage(t.TempDir(), "", []byte("secret"))
+	if err != nil {
+		t.Fatalf("NewLocalStorage: %v", err)
+	}
+	server := httptest.NewServer(http.StripPrefix("/files", storage))
+	t.Cleanup(server.Close)
+	storage.baseURL = server.URL + "/files"
+	return storage, server
+}
+
+func uploadRequest(t *testing.T, credentials *UploadCredentials, body string, contentLength int64) *http.Request {
+	t.Helper()
+	req, err := http.NewRequest(credentials.Method, credentials.URL, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	for name, value := range credentials.Headers {
+		req.Header.Set(name, value)
+	}
+	req.ContentLength = contentLength
+	return req
+}
+
+func doRequest(t *testing.T, req *http.Request) int {
+	t.Helper()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", req.Method, req.URL, err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestLocalPresignedUploadAndDownload(t *testing.T) {
+	storage, _ := newTestLocalStorage(t)
+	ctx := context.Background()
+
+	credentials, err := storage.PresignUpload(ctx, "docs/a.txt", "text/plain", 5, time.Minute)
+	if err != nil {
+		t.Fatalf("PresignUpload: %v", err)
+	}
+	if status := doRequest(t, uploadRequest(t, credentials, "hello", 5)); status != http.StatusOK {
+		t.Fatalf("upload status = %d", status)
+	}
+
+	download, err := storage.PresignDownload(ctx, "docs/a.txt", time.Minute)
+	if err != nil {
+		t.Fatalf("PresignDownload: %v", err)
+	}
+	resp, err := http.Get(download)
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(data) != "hello" || resp.Header.Get("Content-Type") != "text/plain" {
+		t.Fatalf("download = %d %q %q", resp.StatusCode, data, resp.Header.Get("Content-Type"))
+	}
+}
+
+func TestLocalUploadRejectsSizeMismatch(t *testing.T) {
+	storage, _ := newTestLocalStorage(t)
+
+	credentials, err := storage.PresignUpload(context.Background(), "a.bin", "", 5, time.Minute)
+	if err != nil {
+		t.Fatalf("PresignUpload: %v", err)
+	}
+	if status := doRequest(t, uploadRequest(t, credentials, "hello world", 11)); status != http.StatusBadRequest {
+		t.Errorf("oversized upload status = %d, want 400", status)
+	}
+	if _, err := storage.Head(context.Background(), "a.bin"); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("Head after rejected upload: %v", err)
+	}
+}
+
+func TestLocalUploadRejectsTamperedSize(t *testing.T) {
+	storage, _ := newTestLocalStorage(t)
+
+	credentials, err := storage.PresignUpload(context.Background(), "a.bin", "", 5, time.Minute)
+	if err != nil {
+		t.Fatalf("PresignUpload: %v", err)
+	}
+	u, _ := url.Parse(credentials.URL)
+	query := u.Query()
+	query.Set("size", "11")
+	u.RawQuery = query.Encode()
+	credentials.URL = u.String()
+	if status := doRequest(t, uploadRequest(t, credentials, "hello world", 11)); status != http.StatusForbidden {
+		t.Errorf("tampered upload status = %d, want 403", status)
+	}
+}
+
+func TestLocalUploadRequiresContentLength(t *testing.T) {
+	storage, _ := newTestLocalStorage(t)
+
+	credentials, err := storage.PresignUpload(context.Background(), "a.bin", "", 0, time.Minute)
+	if err != nil {
+		t.Fatalf("PresignUpload: %v", err)
+	}
+	// An unknown length makes the client send a chunked body.
+	if status := doRequest(t, uploadRequest(t, credentials, "hello", -1)); status != http.StatusLengthRequired {
+		t.Errorf("chunked upload status = %d, want 411", status)
+	}
+}
+
+func TestLocalUploadDoesNotOverwrite(t *testing.T) {
+	storage, _ := newTestLocalStorage(t)
+	ctx := context.Background()
+
+	credentials, err := storage.PresignUpload(ctx, "a.txt", "", 5, time.Minute)
+	if err != nil {
+		t.Fatalf("PresignUpload: %v", err)
+	}
+	if status := doRequest(t, uploadRequest(t, credentials, "hello", 5)); status != http.StatusOK {
+		t.Fatalf("first upload status = %d", status)
+	}
+	if status := doRequest(t, uploadRequest(t, credentials, "HELLO", 5)); status != http.StatusConflict {
+		t.Errorf("second upload status = %d, want 409", status)
+	}
+
+	body, _, err := storage.Get(ctx, "a.txt")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	defer body.Close()
+	data, _ := io.ReadAll(body)
+	if string(data) != "hello" {
+		t.Errorf("object = %q, want original content", data)
+	}
+}
+
+func TestLocalUploadRejectsExpiredSignature(t *testing.T) {
+	storage, _ := newTestLocalStorage(t)
+
+	credentials, err := storage.PresignUpload(context.Background(), "a.txt", "", 5, -time.Minute)
+	if err != nil {
+		t.Fatalf("PresignUpload: %v", err)
+	}
+	if status := doRequest(t, uploadRequest(t, credentials, "hello", 5)); status != http.StatusForbidden {
+		t.Errorf("expired upload status = %d, want 403", status)
+	}
+}
+
+func TestLocalObjectPathRejectsTraversal(t *testing.T) {
+	storage, _ := newTestLocalStorage(t)
+	for _, key := range []string{"", "../x", "a/../../x", "/abs", ".meta/a.json", "a//b"} {
+		if _, err := storage.objectPath(key); err == nil {
+			t.Errorf("objectPath(%q) accepted", key)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package similarity
+
+import "sort"
+
+// Index buckets signatures by LSH band so each lookup only touches the items
+// sharing a band with the query. Build it once per batch and keep it current
+// with Add and Remove instead of regrouping every signature per query.
+type Index struct {
+	buckets map[string]map[string]struct{}
+	keys    map[string][]string
+}
+
+// NewIndex creates an empty index.
+func NewIndex() *Index {
+	return &Index{
+		buckets: make(map[string]map[string]struct{}),
+		keys:    make(map[string][]string),
+	}
+}
+
+// Add indexes sig under id, replacing any signature id had before.
+func (x *Index) Add(id string, sig Signature) {
+	x.Remove(id)
+	keys := sig.BandKeys()
+	for _, key := range keys {
+		bucket := x.buckets[key]
+		if bucket == nil {
+			bucket = make(map[string]struct{})
+			x.buckets[key] = bucket
+		}
+		bucket[id] = struct{}{}
+	}
+	x.keys[id] = keys
+}
+
+// Remove drops id from the index; removing an unknown id is a no-op.
+func (x *Index) Remove(id string) {
+	for _, key := range x.keys[id] {
+		bucket := x.buckets[key]
+		delete(bucket, id)
+		if len(bucket) == 0 {
+			delete(x.buckets, key)
+		}
+	}
+	delete(x.keys, id)
+}
+
+// Len reports how many items are indexed.
+func (x *Index) Len() int {
+	return len(x.keys)
+}
+
+// Candidates returns the indexed items sharing at least one band with sig,
+// except exclude, sorted by ID.
+func (x *Index) Candidates(sig Signature, exclude string) []string {
+	seen := make(map[string]struct{})
+	for _, key := range sig.BandKeys() {
+		for id := range x.buckets[key] {
+			if id != exclude {
+				seen[id] = struct{}{}
+			}
+		}
+	}
+	ids := make([]string, 0, len(seen))
+	for id := range seen {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
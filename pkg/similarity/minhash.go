@@ -0,0 +1,185 @@
+package similarity
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+)
+
+const (
+	// ShingleSize is the number of consecutive tokens per shingle. Five CJK
+	// characters is roughly a short phrase, long enough to be distinctive.
+	ShingleSize = 5
+	// NumHashes is the MinHash signature length.
+	NumHashes = 128
+	// Bands and rows split the signature for locality-sensitive hashing;
+	// with 32x4 a pair at Jaccard 0.3 becomes a candidate ~23% of the time,
+	// at 0.5 ~87%, and at 0.7 all but certainly.
+	Bands = 32
+	rows  = NumHashes / Bands
+)
+
+// ErrInvalidSignature indicates an encoded signature of the wrong shape.
+var ErrInvalidSignature = errors.New("similarity: invalid signature")
+
+// Shingle is a hashed run of ShingleSize tokens and the rune span it covers.
+type Shingle struct {
+	Hash  uint64
+	Start int
+	End   int
+}
+
+// Shingles hashes every window of ShingleSize tokens. Texts shorter than one
+// window produce a single shingle over all tokens, or none if empty.
+func Shingles(tokens []Token) []Shingle {
+	if len(tokens) == 0 {
+		return nil
+	}
+	size := ShingleSize
+	if len(tokens) < size {
+		size = len(tokens)
+	}
+	shingles := make([]Shingle, 0, len(tokens)-size+1)
+	for i := 0; i+size <= len(tokens); i++ {
+		h := fnv.New64a()
+		for _, tok := range tokens[i : i+size] {
+			h.Write([]byte(tok.Text))
+			h.Write([]byte{0})
+		}
+		shingles = append(shingles, Shingle{Hash: h.Sum64(), Start: tokens[i].Start, End: tokens[i+size-1].End})
+	}
+	return shingles
+}
+
+// Whole hashes all tokens as one shingle spanning the text, for short answers
+// such as fill-in-the-blank responses that only count as copied when they
+// match exactly after normalization. It returns nil for no tokens.
+func Whole(tokens []Token) []Shingle {
+	if len(tokens) == 0 {
+		return nil
+	}
+	h := fnv.New64a()
+	for _, tok := range tokens {
+		h.Write([]byte(tok.Text))
+		h.Write([]byte{0})
+	}
+	return []Shingle{{Hash: h.Sum64(), Start: tokens[0].Start, End: tokens[len(tokens)-1].End}}
+}
+
+// Set returns the distinct shingle hashes.
+func Set(shingles []Shingle) map[uint64]struct{} {
+	set := make(map[uint64]struct{}, len(shingles))
+	for _, sh := range shingles {
+		set[sh.Hash] = struct{}{}
+	}
+	return set
+}
+
+// Jaccard computes |a∩b| / |a∪b| exactly.
+func Jaccard(a, b map[uint64]struct{}) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 0
+	}
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	inter := 0
+	for h := range a {
+		if _, ok := b[h]; ok {
+			inter++
+		}
+	}
+	return float64(inter) / float64(len(a)+len(b)-inter)
+}
+
+// Signature is a MinHash sketch of a shingle set.
+type Signature [NumHashes]uint64
+
+// MinHash computes the signature of a shingle set using NumHashes independent
+// mixes of each shingle hash.
+func MinHash(set map[uint64]struct{}) Signature {
+	var sig Signature
+	for i := range sig {
+		sig[i] = ^uint64(0)
+	}
+	for h := range set {
+		for i := range sig {
+			if v := mix(h ^ seeds[i]); v < sig[i] {
+				sig[i] = v
+			}
+		}
+	}
+	return sig
+}
+
+// Estimate approximates the Jaccard similarity of the underlying sets.
+func (s Signature) Estimate(other Signature) float64 {
+	same := 0
+	for i := range s {
+		if s[i] == other[i] {
+			same++
+		}
+	}
+	return float64(same) / NumHashes
+}
+
+// BandKeys returns one LSH bucket key per band; two signatures sharing any
+// key are candidates for a closer look.
+func (s Signature) BandKeys() []string {
+	keys := make([]string, Bands)
+	for b := 0; b < Bands; b++ {
+		h := fnv.New64a()
+		var buf [8]byte
+		for _, v := range s[b*rows : (b+1)*rows] {
+			binary.LittleEndian.PutUint64(buf[:], v)
+			h.Write(buf[:])
+		}
+		keys[b] = fmt.Sprintf("%02d:%016x", b, h.Sum64())
+	}
+	return keys
+}
+
+// Encode serializes the signature for storage.
+func (s Signature) Encode() string {
+	buf := make([]byte, 8*NumHashes)
+	for i, v := range s {
+		binary.LittleEndian.PutUint64(buf[i*8:], v)
+	}
+	return base64.RawStdEncoding.EncodeToString(buf)
+}
+
+// DecodeSignature parses a signature produced by Encode.
+func DecodeSignature(encoded string) (Signature, error) {
+	var sig Signature
+	buf, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil || len(buf) != 8*NumHashes {
+		return sig, ErrInvalidSignature
+	}
+	for i := range sig {
+		sig[i] = binary.LittleEndian.Uint64(buf[i*8:])
+	}
+	return sig, nil
+}
+
+// mix is the splitmix64 finalizer, a cheap well-distributed permutation.
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// seeds are fixed so stored signatures stay comparable across restarts.
+var seeds = func() [NumHashes]uint64 {
+	var s [NumHashes]uint64
+	state := uint64(0x9e3779b97f4a7c15)
+	for i := range s {
+		state += 0x9e3779b97f4a7c15
+		s[i] = mix(state)
+	}
+	return s
+}()
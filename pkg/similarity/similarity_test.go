@@ -0,0 +1,198 @@
+package similarity
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func tokenTexts(tokens []Token) []string {
+	texts := make([]string, len(tokens))
+	for i, tok := range tokens {
+		texts[i] = tok.Text
+	}
+	return texts
+}
+
+func TestTokenize(t *testing.T) {
+	tokens := Tokenize("光合作用，ＡＢＣ  Hello-world 42")
+	want := []string{"光", "合", "作", "用", "abc", "hello", "world", "42"}
+	if got := tokenTexts(tokens); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Tokenize = %v, want %v", got, want)
+	}
+	if tokens[4].Start != 5 || tokens[4].End != 8 {
+		t.Errorf("full-width word span = [%d,%d), want [5,8)", tokens[4].Start, tokens[4].End)
+	}
+}
+
+func TestShingles(t *testing.T) {
+	if Shingles(nil) != nil {
+		t.Error("Shingles(nil) should be nil")
+	}
+	short := Shingles(Tokenize("一二三"))
+	if len(short) != 1 || short[0].Start != 0 || short[0].End != 3 {
+		t.Errorf("short text shingles = %+v", short)
+	}
+	long := Shingles(Tokenize("一二三四五六七"))
+	if len(long) != 3 {
+		t.Fatalf("len = %d, want 3", len(long))
+	}
+	if long[2].Start != 2 || long[2].End != 7 {
+		t.Errorf("last shingle span = [%d,%d), want [2,7)", long[2].Start, long[2].End)
+	}
+}
+
+func TestWhole(t *testing.T) {
+	if Whole(Tokenize(" ，")) != nil {
+		t.Error("Whole of punctuation should be nil")
+	}
+	a := Whole(Tokenize("Mitochondria!"))
+	b := Whole(Tokenize(" mitochondria "))
+	c := Whole(Tokenize("chloroplast"))
+	if a[0].Hash != b[0].Hash {
+		t.Error("answers differing only in case and punctuation should match")
+	}
+	if a[0].Hash == c[0].Hash {
+		t.Error("different answers should not match")
+	}
+	if b[0].Start != 1 || b[0].End != 13 {
+		t.Errorf("span = [%d,%d), want [1,13)", b[0].Start, b[0].End)
+	}
+}
+
+func TestJaccard(t *testing.T) {
+	a := map[uint64]struct{}{1: {}, 2: {}, 3: {}}
+	b := map[uint64]struct{}{2: {}, 3: {}, 4: {}}
+	if got := Jaccard(a, b); got != 0.5 {
+		t.Errorf("Jaccard = %v, want 0.5", got)
+	}
+	if got := Jaccard(nil, nil); got != 0 {
+		t.Errorf("Jaccard of empty sets = %v, want 0", got)
+	}
+}
+
+func numberSet(from, to int) map[uint64]struct{} {
+	set := make(map[uint64]struct{}, to-from)
+	for i := from; i < to; i++ {
+		set[uint64(i)*0x9e3779b97f4a7c15] = struct{}{}
+	}
+	return set
+}
+
+func TestMinHashEstimatesJaccard(t *testing.T) {
+	// 0..300 and 100..400 share 200 of 400 elements.
+	a, b := numberSet(0, 300), numberSet(100, 400)
+	exact := Jaccard(a, b)
+	estimate := MinHash(a).Estimate(MinHash(b))
+	if math.Abs(estimate-exact) > 0.15 {
+		t.Errorf("estimate %v too far from exact %v", estimate, exact)
+	}
+	if got := MinHash(a).Estimate(MinHash(a)); got != 1 {
+		t.Errorf("self estimate = %v, want 1", got)
+	}
+}
+
+func TestSignatureEncodeRoundTrip(t *testing.T) {
+	sig := MinHash(numberSet(0, 50))
+	decoded, err := DecodeSignature(sig.Encode())
+	if err != nil {
+		t.Fatalf("DecodeSignature: %v", err)
+	}
+	if decoded != sig {
+		t.Error("decoded signature differs")
+	}
+	if _, err := DecodeSignature("not-base64!"); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("bad input error = %v", err)
+	}
+	if _, err := DecodeSignature("AAAA"); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("short input error = %v", err)
+	}
+}
+
+func TestIndexCandidates(t *testing.T) {
+	index := NewIndex()
+	base := numberSet(0, 200)
+	near := numberSet(10, 210) // Jaccard ~0.9 with base
+	far := numberSet(1000, 1200)
+	index.Add("base", MinHash(base))
+	index.Add("near", MinHash(near))
+	index.Add("far", MinHash(far))
+
+	if got := index.Candidates(MinHash(base), "base"); !reflect.DeepEqual(got, []string{"near"}) {
+		t.Errorf("Candidates = %v, want [near]", got)
+	}
+
+	// Re-adding replaces the old signature.
+	index.Add("near", MinHash(far))
+	if got := index.Candidates(MinHash(base), "base"); len(got) != 0 {
+		t.Errorf("after replace Candidates = %v, want none", got)
+	}
+	if got := index.Candidates(MinHash(far), ""); !reflect.DeepEqual(got, []string{"far", "near"}) {
+		t.Errorf("Candidates = %v, want [far near]", got)
+	}
+
+	index.Remove("far")
+	index.Remove("near")
+	index.Remove("missing")
+	if index.Len() != 1 {
+		t.Errorf("Len = %d, want 1", index.Len())
+	}
+	if len(index.buckets) != Bands {
+		t.Errorf("buckets = %d, want %d after removals", len(index.buckets), Bands)
+	}
+}
+
+func TestIndexAmongManyItems(t *testing.T) {
+	index := NewIndex()
+	for i := 0; i < 500; i++ {
+		index.Add(strconv.Itoa(i), MinHash(numberSet(i*1000, i*1000+100)))
+	}
+	if got := index.Candidates(MinHash(numberSet(0, 100)), ""); !reflect.DeepEqual(got, []string{"0"}) {
+		t.Errorf("Candidates = %v, want [0]", got)
+	}
+}
+
+func TestOverlaps(t *testing.T) {
+	a := "今天天气很好我们去公园散步吧"
+	b := "我们去公园散步吧明天再说"
+	spansA, spansB := Overlaps(Shingles(Tokenize(a)), Shingles(Tokenize(b)))
+	if !reflect.DeepEqual(spansA, []Span{{Start: 6, End: 14}}) {
+		t.Errorf("spansA = %v", spansA)
+	}
+	if !reflect.DeepEqual(spansB, []Span{{Start: 0, End: 8}}) {
+		t.Errorf("spansB = %v", spansB)
+	}
+	if got := Excerpt(a, spansA[0]); got != "我们去公园散步吧" {
+		t.Errorf("Excerpt = %q", got)
+	}
+	if got := Excerpt(a, Span{Start: 3, End: 99}); got != "" {
+		t.Errorf("out of range Excerpt = %q", got)
+	}
+}
+
+func TestMergeSpans(t *testing.T) {
+	got := mergeSpans([]Span{{5, 9}, {0, 3}, {2, 4}, {9, 10}, {12, 13}})
+	want := []Span{{0, 4}, {5, 10}, {12, 13}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("mergeSpans = %v, want %v", got, want)
+	}
+}
+
+func TestExclude(t *testing.T) {
+	shingles := Shingles(Tokenize("请简述光合作用的过程光合作用需要光"))
+	prompt := Set(Shingles(Tokenize("请简述光合作用的过程")))
+	kept := Exclude(shingles, prompt)
+	if len(kept) == 0 || len(kept) >= len(shingles) {
+		t.Fatalf("kept %d of %d shingles", len(kept), len(shingles))
+	}
+	for _, sh := range kept {
+		if _, ok := prompt[sh.Hash]; ok {
+			t.Errorf("prompt shingle at %d kept", sh.Start)
+		}
+	}
+	if got := Exclude(shingles, nil); len(got) != len(shingles) {
+		t.Error("Exclude with no common set should keep everything")
+	}
+}
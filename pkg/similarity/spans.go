@@ -0,0 +1,69 @@
+package similarity
+
+import "sort"
+
+// Span is a rune range [Start, End) of the original text.
+type Span struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// Exclude drops shingles whose hash appears in the given set, e.g. text copied
+// from the question prompt that every student is expected to repeat.
+func Exclude(shingles []Shingle, common map[uint64]struct{}) []Shingle {
+	if len(common) == 0 {
+		return shingles
+	}
+	kept := shingles[:0:0]
+	for _, sh := range shingles {
+		if _, ok := common[sh.Hash]; !ok {
+			kept = append(kept, sh)
+		}
+	}
+	return kept
+}
+
+// Overlaps returns the merged spans of a and b covered by shingles the two
+// texts share, suitable for highlighting copied passages side by side.
+func Overlaps(a, b []Shingle) (spansA, spansB []Span) {
+	setA, setB := Set(a), Set(b)
+	return matchedSpans(a, setB), matchedSpans(b, setA)
+}
+
+func matchedSpans(shingles []Shingle, other map[uint64]struct{}) []Span {
+	var spans []Span
+	for _, sh := range shingles {
+		if _, ok := other[sh.Hash]; ok {
+			spans = append(spans, Span{Start: sh.Start, End: sh.End})
+		}
+	}
+	return mergeSpans(spans)
+}
+
+func mergeSpans(spans []Span) []Span {
+	if len(spans) == 0 {
+		return nil
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].Start < spans[j].Start })
+	merged := []Span{spans[0]}
+	for _, sp := range spans[1:] {
+		last := &merged[len(merged)-1]
+		if sp.Start <= last.End {
+			if sp.End > last.End {
+				last.End = sp.End
+			}
+			continue
+		}
+		merged = append(merged, sp)
+	}
+	return merged
+}
+
+// Excerpt returns the text covered by a span.
+func Excerpt(text string, span Span) string {
+	runes := []rune(text)
+	if span.Start < 0 || span.End > len(runes) || span.Start >= span.End {
+		return ""
+	}
+	return string(runes[span.Start:span.End])
+}
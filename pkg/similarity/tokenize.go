@@ -0,0 +1,63 @@
+// Package similarity finds near-duplicate texts with shingling and MinHash.
+// Tokenization is CJK-aware: each Han, kana or Hangul character is its own
+// token, while runs of Latin letters and digits form words.
+package similarity
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// Token is a normalized unit of text with its rune offsets in the original.
+type Token struct {
+	Text  string
+	Start int // rune offset, inclusive
+	End   int // rune offset, exclusive
+}
+
+// Tokenize splits text into tokens, dropping whitespace and punctuation and
+// folding case and full-width forms so trivial edits do not hide copying.
+func Tokenize(text string) []Token {
+	tokens := make([]Token, 0, utf8.RuneCountInString(text))
+	var word []rune
+	wordStart := 0
+
+	flush := func(end int) {
+		if len(word) > 0 {
+			tokens = append(tokens, Token{Text: string(word), Start: wordStart, End: end})
+			word = word[:0]
+		}
+	}
+
+	pos := 0
+	for _, r := range text {
+		r = fold(r)
+		switch {
+		case isCJK(r):
+			flush(pos)
+			tokens = append(tokens, Token{Text: string(r), Start: pos, End: pos + 1})
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if len(word) == 0 {
+				wordStart = pos
+			}
+			word = append(word, r)
+		default:
+			flush(pos)
+		}
+		pos++
+	}
+	flush(pos)
+	return tokens
+}
+
+// fold maps full-width ASCII variants to ASCII and lowercases.
+func fold(r rune) rune {
+	if r >= 0xFF01 && r <= 0xFF5E {
+		r -= 0xFEE0
+	}
+	return unicode.ToLower(r)
+}
+
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}